@@ -30,6 +30,9 @@
 #   SYGALDRY_IMAGE=myimage:tag             # Custom Docker image
 #   SYGALDRY_GPU=false                     # Disable GPU support
 #   SYGALDRY_ENTRYPOINT=dev                # Use container/entrypoints/dev.sh
+#   SYGALDRY_NETWORK=none                  # Network mode: host (default), none, proxy-only
+#   SYGALDRY_PROXY_NETWORK=egress-proxy    # Internal Docker network for proxy-only
+#   SYGALDRY_PROXY_URL=http://proxy:3128   # Proxy exported as HTTP(S)_PROXY for proxy-only
 #   BAZEL_VERSION=6.4.0                    # Bazel version
 #   PYTHON_VERSION=3.12                    # Python version
 #   RUST_VERSION=1.79.0                    # Rust version
@@ -236,6 +239,27 @@ check_requirements() {
     log "System requirements check passed"
 }
 
+# Validates SYGALDRY_NETWORK before any container is started so an isolated
+# step never silently falls back to host networking.
+check_network_mode() {
+    case "${SYGALDRY_NETWORK:-host}" in
+        host|none)
+            ;;
+        proxy-only)
+            if [[ -z "${SYGALDRY_PROXY_NETWORK:-}" || -z "${SYGALDRY_PROXY_URL:-}" ]]; then
+                error "SYGALDRY_NETWORK=proxy-only requires SYGALDRY_PROXY_NETWORK and SYGALDRY_PROXY_URL"
+            fi
+            if [[ "$(docker network inspect --format '{{.Internal}}' "${SYGALDRY_PROXY_NETWORK}" 2>/dev/null)" != "true" ]]; then
+                error "Proxy network ${SYGALDRY_PROXY_NETWORK} must exist and be internal (docker network create --internal)"
+            fi
+            ;;
+        *)
+            error "Unsupported SYGALDRY_NETWORK: ${SYGALDRY_NETWORK} (expected host, none or proxy-only)"
+            ;;
+    esac
+    log "Network mode: ${SYGALDRY_NETWORK:-host}"
+}
+
 # ============================================================================
 # Host Environment Setup
 # ============================================================================
@@ -355,9 +379,27 @@ build_docker_args() {
     
     # Network and IPC configuration
     # --net=host: Use host network (for development convenience)
+    # --net=none: No network at all (SYGALDRY_NETWORK=none)
+    # --net=<internal network>: Only the egress proxy is reachable (SYGALDRY_NETWORK=proxy-only)
     # --ipc=host: Use host IPC namespace
+    case "${SYGALDRY_NETWORK:-host}" in
+        none)
+            docker_args+=("--net=none")
+            ;;
+        proxy-only)
+            docker_args+=(
+                "--net=${SYGALDRY_PROXY_NETWORK}"
+                "--env=HTTP_PROXY=${SYGALDRY_PROXY_URL}"
+                "--env=HTTPS_PROXY=${SYGALDRY_PROXY_URL}"
+                "--env=http_proxy=${SYGALDRY_PROXY_URL}"
+                "--env=https_proxy=${SYGALDRY_PROXY_URL}"
+            )
+            ;;
+        *)
+            docker_args+=("--net=host")
+            ;;
+    esac
     docker_args+=(
-        "--net=host"
         "--ipc=host"
     )
     
@@ -482,6 +524,7 @@ main() {
     
     # Validate environment and system requirements
     check_requirements
+    check_network_mode
     
     # Setup host environment (directories)
     setup_host_directories
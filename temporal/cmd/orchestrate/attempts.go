@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// attemptOutcome summarises how an attempt ended: "ok", "exit 2", the error,
+// or "running".
+func attemptOutcome(attempt activities.StepAttempt) string {
+	switch {
+	case attempt.EndedAt == "":
+		return "running"
+	case attempt.Error != "":
+		return attempt.Error
+	case attempt.ExitCode != 0:
+		return fmt.Sprintf("exit %d", attempt.ExitCode)
+	}
+	return "ok"
+}
+
+// attemptDuration is how long an attempt ran, or "-" when unknown.
+func attemptDuration(attempt activities.StepAttempt) string {
+	started, err := time.Parse(time.RFC3339Nano, attempt.StartedAt)
+	if err != nil {
+		return "-"
+	}
+	ended, err := time.Parse(time.RFC3339Nano, attempt.EndedAt)
+	if err != nil {
+		return "-"
+	}
+	return ended.Sub(started).Round(time.Second).String()
+}
+
+// writeAttemptTimeline writes a markdown section with the attempts of every
+// step that needed more than one, so flaky steps stand out in the report.
+func writeAttemptTimeline(b io.Writer, steps []workflows.StepOutcome) {
+	header := false
+	for _, step := range steps {
+		attempts := step.Result.Attempts
+		if len(attempts) < 2 {
+			continue
+		}
+		if !header {
+			io.WriteString(b, "\n## Attempts\n")
+			header = true
+		}
+		fmt.Fprintf(b, "\n### %s (%d attempts)\n\n| Attempt | Worker | Started | Duration | Outcome |\n|---|---|---|---|---|\n", step.ID, len(attempts))
+		for _, attempt := range attempts {
+			fmt.Fprintf(b, "| %d | %s | %s | %s | %s |\n", attempt.Attempt, orDash(attempt.Worker), attempt.StartedAt,
+				attemptDuration(attempt), strings.ReplaceAll(attemptOutcome(attempt), "|", "\\|"))
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"gopkg.in/yaml.v3"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+// BatchManifest lists the plans of a batch submission. Plan paths are
+// relative to the manifest.
+type BatchManifest struct {
+	Plans []BatchEntry `yaml:"plans"`
+}
+
+type BatchEntry struct {
+	Plan       string            `yaml:"plan"`
+	WorkflowID string            `yaml:"workflow_id"`
+	Params     map[string]string `yaml:"params"`
+}
+
+// batchOutcome is one row of the batch summary.
+type batchOutcome struct {
+	Plan       string        `json:"plan"`
+	WorkflowID string        `json:"workflowId"`
+	RunID      string        `json:"runId,omitempty"`
+	Status     string        `json:"status"`
+	Duration   time.Duration `json:"-"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// workflowStarter is the part of client.Client that submit-batch uses.
+type workflowStarter interface {
+	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error)
+}
+
+// runSubmitBatch implements `orchestrate submit-batch <dir|manifest>`.
+func runSubmitBatch(args []string) error {
+	fs := flag.NewFlagSet("submit-batch", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", 4, "Maximum number of plans running at once")
+	idPrefix := fs.String("id-prefix", "batch-"+time.Now().Format("20060102-150405"), "Workflow ID prefix")
+	idsFile := fs.String("ids-file", "", "Append {plan, workflowId, runId} JSON lines here as plans start")
+	taskQueue := fs.String("task-queue", envOr("TEMPORAL_TASK_QUEUE", "orchestration"), "Task queue")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	logDir := fs.String("log-dir", "", "Log directory for step outputs (overrides plans and TEMPORAL_LOG_DIR)")
+	timeout := fs.Duration("timeout", 24*time.Hour, "Give up waiting for the batch after this long")
+	yes := fs.Bool("yes", false, "Run destructive steps without asking")
+	noCheck := fs.Bool("skip-worker-check", false, "Submit without checking the task queue's workers support the plans")
+	paramArgs := paramFlags{}
+	fs.Var(paramArgs, "param", "Parameter applied to every plan as name=value (repeatable)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate submit-batch [flags] <plan-dir|manifest.yaml>")
+	}
+	if *concurrency < 1 {
+		return errors.New("-concurrency must be at least 1")
+	}
+
+	entries, err := collectBatch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	workflowIDs := batchWorkflowIDs(*idPrefix, entries)
+	inputs := make([]workflows.PipelineInput, len(entries))
+	for i, entry := range entries {
+		params := map[string]string{}
+		for name, value := range entry.Params {
+			params[name] = value
+		}
+		for name, value := range paramArgs {
+			params[name] = value
+		}
+		input, err := loadPlan(entry.Plan, params, *logDir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Plan, err)
+		}
+		if input.Schedule != nil {
+			return fmt.Errorf("%s: scheduled plans never complete and cannot be batch submitted", entry.Plan)
+		}
+		if err := resolveRoute(&input, fs, *namespace, *taskQueue); err != nil {
+			return fmt.Errorf("%s: %w", entry.Plan, err)
+		}
+		if i > 0 && input.Namespace != inputs[0].Namespace {
+			return fmt.Errorf("%s: plan targets namespace %s, but %s targets %s; submit them separately", entry.Plan, input.Namespace, entries[0].Plan, inputs[0].Namespace)
+		}
+		inputs[i] = input
+	}
+	var destructive []string
+	for i, entry := range entries {
+		ids := workflows.DestructiveSteps(inputs[i].Steps)
+		for _, id := range ids {
+			destructive = append(destructive, entry.Plan+": "+id)
+		}
+		inputs[i].ConfirmDestructive = len(ids) > 0
+	}
+	if err := confirmDestructive(destructive, *yes, isTerminal(os.Stdin), os.Stdin, os.Stderr); err != nil {
+		return err
+	}
+
+	var idsOut io.Writer
+	if *idsFile != "" {
+		file, err := os.OpenFile(*idsFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		idsOut = file
+	}
+
+	c, err := dialClient(*address, inputs[0].Namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if !*noCheck {
+		byQueue := map[string][]workflows.PipelineInput{}
+		var queues []string
+		for _, input := range inputs {
+			if byQueue[input.TaskQueue] == nil {
+				queues = append(queues, input.TaskQueue)
+			}
+			byQueue[input.TaskQueue] = append(byQueue[input.TaskQueue], input)
+		}
+		for _, queue := range queues {
+			if err := preflightWorkers(ctx, c, queue, byQueue[queue], os.Stderr); err != nil {
+				return err
+			}
+		}
+	}
+
+	outcomes := submitBatch(ctx, c, *taskQueue, entries, workflowIDs, inputs, *concurrency, idsOut)
+	failed := printBatchSummary(os.Stdout, outcomes)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d plans did not succeed", failed, len(outcomes))
+	}
+	return nil
+}
+
+// collectBatch lists the plans of a directory (*.yaml and *.yml, sorted) or a
+// batch manifest.
+func collectBatch(source string) ([]BatchEntry, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		var plans []string
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(source, pattern))
+			if err != nil {
+				return nil, err
+			}
+			plans = append(plans, matches...)
+		}
+		sort.Strings(plans)
+		if len(plans) == 0 {
+			return nil, fmt.Errorf("no plans found in %s", source)
+		}
+		entries := make([]BatchEntry, len(plans))
+		for i, plan := range plans {
+			entries[i] = BatchEntry{Plan: plan}
+		}
+		return entries, nil
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return nil, err
+	}
+	var manifest BatchManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse batch manifest: %w", err)
+	}
+	if len(manifest.Plans) == 0 {
+		return nil, fmt.Errorf("batch manifest %s lists no plans", source)
+	}
+	for i := range manifest.Plans {
+		entry := &manifest.Plans[i]
+		if entry.Plan == "" {
+			return nil, fmt.Errorf("batch manifest entry %d is missing plan", i)
+		}
+		if !filepath.IsAbs(entry.Plan) {
+			entry.Plan = filepath.Join(filepath.Dir(source), entry.Plan)
+		}
+	}
+	return manifest.Plans, nil
+}
+
+// batchWorkflowIDs returns the workflow ID of every entry: its own
+// workflow_id, or <prefix>-<plan file stem>, suffixed when stems repeat.
+func batchWorkflowIDs(prefix string, entries []BatchEntry) []string {
+	ids := make([]string, len(entries))
+	seen := map[string]int{}
+	for i, entry := range entries {
+		id := entry.WorkflowID
+		if id == "" {
+			stem := strings.TrimSuffix(filepath.Base(entry.Plan), filepath.Ext(entry.Plan))
+			id = prefix + "-" + stem
+		}
+		seen[id]++
+		if seen[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// submitBatch runs the plans with at most concurrency workflows in flight and
+// waits for all of them. Plans go to their own task queue, or to taskQueue
+// if they name none. Started workflows are recorded to ids as JSON lines.
+func submitBatch(ctx context.Context, c workflowStarter, taskQueue string, entries []BatchEntry, workflowIDs []string, inputs []workflows.PipelineInput, concurrency int, ids io.Writer) []batchOutcome {
+	outcomes := make([]batchOutcome, len(entries))
+	slots := make(chan struct{}, concurrency)
+	var idsMu sync.Mutex
+	var wg sync.WaitGroup
+	for i := range entries {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			outcome := batchOutcome{Plan: entries[i].Plan, WorkflowID: workflowIDs[i]}
+			start := time.Now()
+			queue := taskQueue
+			if inputs[i].TaskQueue != "" {
+				queue = inputs[i].TaskQueue
+			}
+			run, err := c.ExecuteWorkflow(ctx, startOptions(workflowIDs[i], queue, inputs[i]), workflows.Pipeline, inputs[i])
+			if err != nil {
+				outcome.Status = "start_failed"
+				outcome.Error = err.Error()
+				outcomes[i] = outcome
+				return
+			}
+			outcome.RunID = run.GetRunID()
+			if ids != nil {
+				line, _ := json.Marshal(batchOutcome{Plan: outcome.Plan, WorkflowID: outcome.WorkflowID, RunID: outcome.RunID, Status: "started"})
+				idsMu.Lock()
+				ids.Write(append(line, '\n'))
+				idsMu.Unlock()
+			}
+
+			var result workflows.PipelineResult
+			err = run.Get(ctx, &result)
+			outcome.Duration = time.Since(start)
+			outcome.Status = result.Status
+			if err != nil {
+				outcome.Error = err.Error()
+				if outcome.Status == "" {
+					outcome.Status = workflows.StatusFailed
+				}
+			}
+			outcomes[i] = outcome
+		}(i)
+	}
+	wg.Wait()
+	return outcomes
+}
+
+// printBatchSummary writes one row per plan and a status tally. It returns
+// the number of plans that did not succeed.
+func printBatchSummary(w io.Writer, outcomes []batchOutcome) int {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PLAN\tWORKFLOW ID\tSTATUS\tDURATION\tERROR")
+	tally := map[string]int{}
+	failed := 0
+	for _, outcome := range outcomes {
+		tally[outcome.Status]++
+		if outcome.Status != workflows.StatusSucceeded {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", outcome.Plan, outcome.WorkflowID, outcome.Status, outcome.Duration.Round(time.Second), orDash(outcome.Error))
+	}
+	tw.Flush()
+
+	statuses := make([]string, 0, len(tally))
+	for status := range tally {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%d %s", tally[status], status)
+	}
+	fmt.Fprintf(w, "\n%d plans: %s\n", len(outcomes), strings.Join(parts, ", "))
+	return failed
+}
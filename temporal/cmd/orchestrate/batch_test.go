@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+type fakeRun struct {
+	id      string
+	status  string
+	err     error
+	starter *fakeStarter
+}
+
+func (r *fakeRun) GetID() string    { return r.id }
+func (r *fakeRun) GetRunID() string { return r.id + "-run" }
+func (r *fakeRun) Get(ctx context.Context, valuePtr interface{}) error {
+	time.Sleep(20 * time.Millisecond)
+	r.starter.mu.Lock()
+	r.starter.running--
+	r.starter.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	*valuePtr.(*workflows.PipelineResult) = workflows.PipelineResult{Status: r.status, Succeeded: r.status == workflows.StatusSucceeded}
+	return nil
+}
+func (r *fakeRun) GetWithOptions(ctx context.Context, valuePtr interface{}, _ client.WorkflowRunGetOptions) error {
+	return r.Get(ctx, valuePtr)
+}
+
+type fakeStarter struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	fail       map[string]error
+}
+
+func (s *fakeStarter) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasSuffix(options.ID, "-dup") {
+		return nil, errors.New("workflow already started")
+	}
+	s.running++
+	if s.running > s.maxRunning {
+		s.maxRunning = s.running
+	}
+	return &fakeRun{id: options.ID, status: workflows.StatusSucceeded, err: s.fail[options.ID], starter: s}, nil
+}
+
+func TestCollectBatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"shard-02.yaml", "shard-01.yml", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("steps: []"), 0o644)
+	}
+	entries, err := collectBatch(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || filepath.Base(entries[0].Plan) != "shard-01.yml" || filepath.Base(entries[1].Plan) != "shard-02.yaml" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestCollectBatchManifest(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "nightly.yaml")
+	os.WriteFile(manifest, []byte(`plans:
+  - plan: plans/ingest.yaml
+    params: {customer: acme}
+  - plan: /abs/ingest.yaml
+    workflow_id: ingest-globex
+`), 0o644)
+	entries, err := collectBatch(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries[0].Plan != filepath.Join(dir, "plans/ingest.yaml") || entries[0].Params["customer"] != "acme" {
+		t.Errorf("entry 0 = %+v", entries[0])
+	}
+	if entries[1].Plan != "/abs/ingest.yaml" || entries[1].WorkflowID != "ingest-globex" {
+		t.Errorf("entry 1 = %+v", entries[1])
+	}
+}
+
+func TestBatchWorkflowIDs(t *testing.T) {
+	ids := batchWorkflowIDs("nightly", []BatchEntry{
+		{Plan: "a/ingest.yaml"},
+		{Plan: "b/ingest.yaml"},
+		{Plan: "c/train.yaml", WorkflowID: "custom"},
+	})
+	if strings.Join(ids, " ") != "nightly-ingest nightly-ingest-2 custom" {
+		t.Errorf("ids = %v", ids)
+	}
+}
+
+func TestSubmitBatch(t *testing.T) {
+	starter := &fakeStarter{fail: map[string]error{"b-2": errors.New("step returned non-zero exit code")}}
+	entries := make([]BatchEntry, 6)
+	ids := []string{"b-1", "b-2", "b-3", "b-4", "b-5", "b-dup"}
+	for i := range entries {
+		entries[i] = BatchEntry{Plan: ids[i] + ".yaml"}
+	}
+	var started bytes.Buffer
+	outcomes := submitBatch(context.Background(), starter, "orchestration", entries, ids, make([]workflows.PipelineInput, len(entries)), 2, &started)
+
+	if starter.maxRunning > 2 {
+		t.Errorf("ran %d plans at once, want at most 2", starter.maxRunning)
+	}
+	if strings.Count(started.String(), "\n") != 5 {
+		t.Errorf("ids file should list the 5 started workflows:\n%s", started.String())
+	}
+	statuses := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		statuses[i] = outcome.Status
+	}
+	if strings.Join(statuses, " ") != "succeeded failed succeeded succeeded succeeded start_failed" {
+		t.Errorf("statuses = %v", statuses)
+	}
+
+	var summary bytes.Buffer
+	if failed := printBatchSummary(&summary, outcomes); failed != 2 {
+		t.Errorf("failed = %d, want 2", failed)
+	}
+	if !strings.Contains(summary.String(), "6 plans: 1 failed, 1 start_failed, 4 succeeded") {
+		t.Errorf("summary:\n%s", summary.String())
+	}
+}
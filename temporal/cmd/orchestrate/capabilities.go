@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+// capabilityTimeout bounds the wait for a worker to answer the capability
+// check. Workers built before the check existed never answer it.
+const capabilityTimeout = 15 * time.Second
+
+// workerProber is the part of client.Client the worker preflight uses.
+type workerProber interface {
+	DescribeTaskQueue(ctx context.Context, taskQueue string, taskQueueType enumspb.TaskQueueType) (*workflowservice.DescribeTaskQueueResponse, error)
+	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow any, args ...any) (client.WorkflowRun, error)
+}
+
+// preflightWorkers checks that taskQueue has workers and that they register
+// every activity the plans need. A worker that does not answer the
+// capability check is reported on warn and the submission goes ahead.
+func preflightWorkers(ctx context.Context, c workerProber, taskQueue string, inputs []workflows.PipelineInput, warn io.Writer) error {
+	for _, queueType := range []enumspb.TaskQueueType{enumspb.TASK_QUEUE_TYPE_WORKFLOW, enumspb.TASK_QUEUE_TYPE_ACTIVITY} {
+		described, err := c.DescribeTaskQueue(ctx, taskQueue, queueType)
+		if err != nil {
+			return fmt.Errorf("describe task queue %s: %w", taskQueue, err)
+		}
+		if len(described.GetPollers()) == 0 {
+			return fmt.Errorf("no worker is polling task queue %s", taskQueue)
+		}
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, capabilityTimeout)
+	defer cancel()
+	run, err := c.ExecuteWorkflow(checkCtx, client.StartWorkflowOptions{
+		ID:                       fmt.Sprintf("worker-capabilities-%s-%d", taskQueue, time.Now().UnixNano()),
+		TaskQueue:                taskQueue,
+		WorkflowExecutionTimeout: capabilityTimeout,
+	}, workflows.WorkerCapabilities)
+	var registered []string
+	if err == nil {
+		err = run.Get(checkCtx, &registered)
+	}
+	if err != nil {
+		fmt.Fprintf(warn, "unable to confirm worker capabilities on task queue %s (older worker?): %v\n", taskQueue, err)
+		return nil
+	}
+
+	var steps []workflows.PipelineStep
+	for _, input := range inputs {
+		steps = append(steps, input.Steps...)
+	}
+	return checkCapabilities(taskQueue, workflows.RequiredActivities(steps), registered)
+}
+
+// checkCapabilities reports the step types whose activity is missing from
+// registered, e.g. "no worker supports hf_download_model on queue gpu".
+func checkCapabilities(taskQueue string, required map[string]string, registered []string) error {
+	var missing []string
+	for stepType, activity := range required {
+		if !slices.Contains(registered, activity) {
+			missing = append(missing, stepType)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return errors.New("no worker supports " + strings.Join(missing, ", ") + " on queue " + taskQueue)
+}
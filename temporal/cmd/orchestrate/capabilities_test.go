@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	taskqueuepb "go.temporal.io/api/taskqueue/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+type capabilityRun struct {
+	activities []string
+	err        error
+}
+
+func (r capabilityRun) GetID() string    { return "worker-capabilities" }
+func (r capabilityRun) GetRunID() string { return "run" }
+func (r capabilityRun) Get(ctx context.Context, valuePtr interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	*valuePtr.(*[]string) = r.activities
+	return nil
+}
+func (r capabilityRun) GetWithOptions(ctx context.Context, valuePtr interface{}, _ client.WorkflowRunGetOptions) error {
+	return r.Get(ctx, valuePtr)
+}
+
+type fakeProber struct {
+	pollers bool
+	run     capabilityRun
+	started int
+}
+
+func (p *fakeProber) DescribeTaskQueue(ctx context.Context, taskQueue string, _ enumspb.TaskQueueType) (*workflowservice.DescribeTaskQueueResponse, error) {
+	response := &workflowservice.DescribeTaskQueueResponse{}
+	if p.pollers {
+		response.Pollers = []*taskqueuepb.PollerInfo{{Identity: "worker@host"}}
+	}
+	return response, nil
+}
+
+func (p *fakeProber) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	p.started++
+	return p.run, nil
+}
+
+func TestPreflightWorkers(t *testing.T) {
+	plan := []workflows.PipelineInput{{Steps: []workflows.PipelineStep{
+		{ID: "weights", Type: "hf_download_model"},
+		{ID: "train", Type: "command"},
+		{ID: "total", Type: "join"},
+	}}}
+	var warn bytes.Buffer
+
+	err := preflightWorkers(context.Background(), &fakeProber{}, "gpu", plan, &warn)
+	if err == nil || err.Error() != "no worker is polling task queue gpu" {
+		t.Errorf("no pollers: err = %v", err)
+	}
+
+	prober := &fakeProber{pollers: true, run: capabilityRun{activities: []string{"RecordEvent", "RunCommand"}}}
+	err = preflightWorkers(context.Background(), prober, "gpu", plan, &warn)
+	if err == nil || err.Error() != "no worker supports hf_download_model on queue gpu" {
+		t.Errorf("missing activity: err = %v", err)
+	}
+
+	prober.run.activities = append(prober.run.activities, "HFDownloadModel")
+	if err := preflightWorkers(context.Background(), prober, "gpu", plan, &warn); err != nil {
+		t.Errorf("supported plan: err = %v", err)
+	}
+
+	prober.run = capabilityRun{err: errors.New("workflow timeout")}
+	if err := preflightWorkers(context.Background(), prober, "gpu", plan, &warn); err != nil {
+		t.Errorf("unanswered check should only warn: err = %v", err)
+	}
+	if !strings.Contains(warn.String(), "unable to confirm worker capabilities on task queue gpu") {
+		t.Errorf("warning = %q", warn.String())
+	}
+}
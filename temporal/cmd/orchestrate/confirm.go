@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmDestructive guards starting plans with the given destructive steps.
+// With yes it proceeds; on a terminal it asks; otherwise it refuses, so a
+// copied command cannot deploy by accident.
+func confirmDestructive(steps []string, yes, interactive bool, in io.Reader, out io.Writer) error {
+	if len(steps) == 0 || yes {
+		return nil
+	}
+	if !interactive {
+		return fmt.Errorf("plan has destructive steps (%s); pass -yes to run it without a terminal", strings.Join(steps, ", "))
+	}
+	fmt.Fprintln(out, "This run includes destructive steps:")
+	for _, step := range steps {
+		fmt.Fprintf(out, "  - %s\n", step)
+	}
+	fmt.Fprint(out, "Type yes to continue: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "yes" && answer != "y" {
+		return errors.New("run not confirmed")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConfirmDestructive(t *testing.T) {
+	steps := []string{"deploy-prod", "drop-tables"}
+	tests := []struct {
+		name        string
+		steps       []string
+		yes         bool
+		interactive bool
+		answer      string
+		want        string
+	}{
+		{"no destructive steps", nil, false, false, "", ""},
+		{"yes flag", steps, true, false, "", ""},
+		{"no terminal", steps, false, false, "", "pass -yes"},
+		{"confirmed", steps, false, true, "yes\n", ""},
+		{"declined", steps, false, true, "n\n", "not confirmed"},
+		{"closed input", steps, false, true, "", "not confirmed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := confirmDestructive(tt.steps, tt.yes, tt.interactive, strings.NewReader(tt.answer), &out)
+			if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+			if tt.interactive && !strings.Contains(out.String(), "  - drop-tables") {
+				t.Errorf("prompt does not list the steps:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+// runDeployment implements `orchestrate deployment show|promote`, which
+// inspect and move the build ID that new runs of a worker deployment use.
+func runDeployment(args []string) error {
+	usage := errors.New("usage: orchestrate deployment show [flags] <deployment> | promote [flags] <deployment> <build-id>")
+	if len(args) == 0 || (args[0] != "show" && args[0] != "promote") {
+		return usage
+	}
+	fs := flag.NewFlagSet("deployment "+args[0], flag.ExitOnError)
+	ramp := fs.Float64("ramp", 0, "Send this percentage of new runs to the build instead of making it current (promote only)")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args[1:])
+	wantArgs := 1
+	if args[0] == "promote" {
+		wantArgs = 2
+	}
+	if fs.NArg() != wantArgs {
+		return usage
+	}
+	if *ramp < 0 || *ramp > 100 {
+		return errors.New("-ramp must be between 0 and 100")
+	}
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	handle := c.WorkerDeploymentClient().GetHandle(fs.Arg(0))
+
+	if args[0] == "show" {
+		described, err := handle.Describe(ctx, client.WorkerDeploymentDescribeOptions{})
+		if err != nil {
+			return err
+		}
+		printDeployment(os.Stdout, described.Info)
+		return nil
+	}
+	buildID := fs.Arg(1)
+	if *ramp > 0 {
+		response, err := handle.SetRampingVersion(ctx, client.WorkerDeploymentSetRampingVersionOptions{BuildID: buildID, Percentage: float32(*ramp)})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("deployment %s: ramping %g%% of new runs to build %s (was %s)\n", fs.Arg(0), *ramp, buildID, versionName(response.PreviousVersion))
+		return nil
+	}
+	response, err := handle.SetCurrentVersion(ctx, client.WorkerDeploymentSetCurrentVersionOptions{BuildID: buildID})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("deployment %s: new runs use build %s (was %s); running workflows stay on their build\n", fs.Arg(0), buildID, versionName(response.PreviousVersion))
+	return nil
+}
+
+func versionName(version *worker.WorkerDeploymentVersion) string {
+	if version == nil || version.BuildID == "" {
+		return "unversioned"
+	}
+	return version.BuildID
+}
+
+// printDeployment writes the routing of a worker deployment and its builds.
+func printDeployment(w io.Writer, info client.WorkerDeploymentInfo) {
+	routing := info.RoutingConfig
+	fmt.Fprintf(w, "deployment %s\n", info.Name)
+	fmt.Fprintf(w, "current  %s\n", versionName(routing.CurrentVersion))
+	if routing.RampingVersion != nil {
+		fmt.Fprintf(w, "ramping  %s at %g%%\n", versionName(routing.RampingVersion), routing.RampingVersionPercentage)
+	}
+	if len(info.VersionSummaries) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "BUILD\tCREATED\tDRAINAGE")
+	for _, summary := range info.VersionSummaries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", summary.Version.BuildID, summary.CreateTime.UTC().Format(time.RFC3339), drainageName(summary.DrainageStatus))
+	}
+	tw.Flush()
+}
+
+// drainageName describes whether a build still has workflows pinned to it.
+func drainageName(status client.WorkerDeploymentVersionDrainageStatus) string {
+	switch status {
+	case client.WorkerDeploymentVersionDrainageStatusDraining:
+		return "draining"
+	case client.WorkerDeploymentVersionDrainageStatusDrained:
+		return "drained"
+	}
+	return "-"
+}
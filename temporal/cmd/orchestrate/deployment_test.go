@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/worker"
+)
+
+func TestPrintDeployment(t *testing.T) {
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	info := client.WorkerDeploymentInfo{
+		Name: "orchestration",
+		RoutingConfig: client.WorkerDeploymentRoutingConfig{
+			CurrentVersion:           &worker.WorkerDeploymentVersion{DeploymentName: "orchestration", BuildID: "v42"},
+			RampingVersion:           &worker.WorkerDeploymentVersion{DeploymentName: "orchestration", BuildID: "v43"},
+			RampingVersionPercentage: 10,
+		},
+		VersionSummaries: []client.WorkerDeploymentVersionSummary{
+			{Version: worker.WorkerDeploymentVersion{BuildID: "v41"}, CreateTime: created, DrainageStatus: client.WorkerDeploymentVersionDrainageStatusDraining},
+			{Version: worker.WorkerDeploymentVersion{BuildID: "v42"}, CreateTime: created},
+		},
+	}
+	var out bytes.Buffer
+	printDeployment(&out, info)
+	got := out.String()
+	for _, want := range []string{"deployment orchestration", "current  v42", "ramping  v43 at 10%", "v41    2025-03-01T12:00:00Z  draining"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+
+	out.Reset()
+	printDeployment(&out, client.WorkerDeploymentInfo{Name: "fresh"})
+	if out.String() != "deployment fresh\ncurrent  unversioned\n" {
+		t.Errorf("empty deployment:\n%s", out.String())
+	}
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// BundleManifest is written as manifest.json at the root of an export bundle.
+// It records where the run came from and a checksum of every other file.
+type BundleManifest struct {
+	WorkflowID string       `json:"workflowId"`
+	RunID      string       `json:"runId"`
+	Status     string       `json:"status"`
+	Namespace  string       `json:"namespace"`
+	StartTime  time.Time    `json:"startTime"`
+	CloseTime  time.Time    `json:"closeTime,omitempty"`
+	ExportedAt time.Time    `json:"exportedAt"`
+	ExportedBy string       `json:"exportedBy"`
+	Files      []BundleFile `json:"files"`
+}
+
+type BundleFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// runRecord is what Temporal knows about one pipeline run.
+type runRecord struct {
+	WorkflowID string
+	RunID      string
+	Status     string
+	Namespace  string
+	StartTime  time.Time
+	CloseTime  time.Time
+	Plan       *workflows.PipelineInput
+	Result     *workflows.PipelineResult
+	Failure    string
+}
+
+// runExport implements `orchestrate export <workflow-id>`.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	output := fs.String("o", "", "Bundle path (default: <workflow-id>_<run-id>.tar.zst; .tar.gz selects gzip)")
+	logDir := fs.String("log-dir", "", "Log directory holding the run's files (default: plan log_dir, TEMPORAL_LOG_DIR or ./logs)")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate export [flags] <workflow-id>")
+	}
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	record, err := fetchRunRecord(ctx, c, fs.Arg(0), *runID)
+	if err != nil {
+		return err
+	}
+	record.Namespace = *namespace
+
+	dir := *logDir
+	if dir == "" && record.Plan != nil {
+		dir = record.Plan.LogDir
+	}
+	if dir == "" {
+		dir = envOr("TEMPORAL_LOG_DIR", "./logs")
+	}
+	target := *output
+	if target == "" {
+		target = bundleName(record) + ".tar.zst"
+	}
+
+	manifest, err := exportBundle(target, record, dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %s (%d files) to %s\n", record.WorkflowID, len(manifest.Files), target)
+	return nil
+}
+
+// exportBundle writes the bundle of record to target. A bundle that could not
+// be written completely is removed.
+func exportBundle(target string, record *runRecord, logDir string) (manifest BundleManifest, err error) {
+	file, err := os.Create(target)
+	if err != nil {
+		return manifest, err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(target)
+		}
+	}()
+	w, finish, err := compressWriter(file, target)
+	if err != nil {
+		return manifest, err
+	}
+	manifest, err = writeBundle(w, record, logDir)
+	// The compressor is finished even after a failure, so zstd exits.
+	if finishErr := finish(); err == nil {
+		err = finishErr
+	}
+	return manifest, err
+}
+
+// runImport implements `orchestrate import <bundle>`.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dest := fs.String("dest", ".", "Directory to extract the bundle into")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate import [-dest dir] <bundle>")
+	}
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	r, finish, err := decompressReader(file, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root, manifest, err := importBundle(r, *dest)
+	if err != nil {
+		return err
+	}
+	if err := finish(); err != nil {
+		return err
+	}
+	fmt.Printf("imported %s run %s (%s, %d files verified) into %s\n", manifest.WorkflowID, manifest.RunID, manifest.Status, len(manifest.Files), root)
+	return nil
+}
+
+// runPrintReport implements `orchestrate report <workflow-id>`, which prints
+// the report of an export bundle without exporting. A run Temporal no longer
+// has is reported from its copy in the results store, and -bundle reports a
+// run from an exported bundle.
+func runPrintReport(args []string) error {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	bundle := fs.String("bundle", "", "Report the run of this exported bundle, an archive or an imported directory, instead of asking Temporal")
+	logDir := fs.String("log-dir", envOr("TEMPORAL_LOG_DIR", "./logs"), "Log directory of the runs (locates the results store)")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args)
+	if *bundle != "" {
+		if fs.NArg() != 0 {
+			return errors.New("usage: orchestrate report -bundle <bundle>")
+		}
+		record, err := bundleRunRecord(*bundle)
+		if err != nil {
+			return err
+		}
+		fmt.Print(runReport(record))
+		return nil
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate report [flags] <workflow-id>")
+	}
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	record, source, err := loadRunRecord(ctx, c, fs.Arg(0), *runID, *logDir)
+	if err != nil {
+		return err
+	}
+	if source != "" {
+		fmt.Fprintf(os.Stderr, "Temporal no longer has this run; reporting its copy in %s\n", source)
+	}
+	fmt.Print(runReport(record))
+	return nil
+}
+
+func fetchRunRecord(ctx context.Context, c client.Client, workflowID, runID string) (*runRecord, error) {
+	described, err := c.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return nil, fmt.Errorf("describe workflow: %w", err)
+	}
+	info := described.GetWorkflowExecutionInfo()
+	record := &runRecord{
+		WorkflowID: workflowID,
+		RunID:      info.GetExecution().GetRunId(),
+		Status:     strings.ToLower(strings.TrimPrefix(info.GetStatus().String(), "WORKFLOW_EXECUTION_STATUS_")),
+		StartTime:  info.GetStartTime().AsTime(),
+	}
+	if info.GetCloseTime() != nil {
+		record.CloseTime = info.GetCloseTime().AsTime()
+	}
+
+	dc := converter.GetDefaultDataConverter()
+	iter := c.GetWorkflowHistory(ctx, workflowID, record.RunID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("read history: %w", err)
+		}
+		switch event.GetEventType() {
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED:
+			var plan workflows.PipelineInput
+			if err := dc.FromPayloads(event.GetWorkflowExecutionStartedEventAttributes().GetInput(), &plan); err == nil {
+				record.Plan = &plan
+			}
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED:
+			var result workflows.PipelineResult
+			if err := dc.FromPayloads(event.GetWorkflowExecutionCompletedEventAttributes().GetResult(), &result); err == nil {
+				record.Result = &result
+			}
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+			// Cron runs close by continuing as new with their result attached.
+			attrs := event.GetWorkflowExecutionContinuedAsNewEventAttributes()
+			var result workflows.PipelineResult
+			if err := dc.FromPayloads(attrs.GetLastCompletionResult(), &result); err == nil && attrs.GetLastCompletionResult() != nil {
+				record.Result = &result
+			}
+			if attrs.GetFailure() != nil {
+				record.Failure = attrs.GetFailure().GetMessage()
+			}
+		case enumspb.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED:
+			record.Failure = event.GetWorkflowExecutionFailedEventAttributes().GetFailure().GetMessage()
+		}
+	}
+	return record, nil
+}
+
+func bundleName(record *runRecord) string {
+	return strings.TrimSuffix(activities.LogFilePrefix(record.WorkflowID, record.RunID), "_")
+}
+
+// writeBundle writes the run's plan, effective plan, result, events, step
+// logs and a report as a tar stream, followed by a manifest with their checksums.
+// Events and logs are read from a local logDir only; a remote one is reported
+// and left out.
+func writeBundle(w io.Writer, record *runRecord, logDir string) (BundleManifest, error) {
+	if activities.IsRemoteLogDir(logDir) {
+		fmt.Fprintf(os.Stderr, "warning: the bundle has no events or step logs: %s is remote; sync it locally, e.g. with aws s3 sync, and pass its copy as -log-dir\n", logDir)
+		logDir = ""
+	}
+	tw := tar.NewWriter(w)
+	root := bundleName(record)
+	manifest := BundleManifest{
+		WorkflowID: record.WorkflowID,
+		RunID:      record.RunID,
+		Status:     record.Status,
+		Namespace:  record.Namespace,
+		StartTime:  record.StartTime,
+		CloseTime:  record.CloseTime,
+		ExportedAt: time.Now().UTC(),
+	}
+	manifest.ExportedBy, _ = os.Hostname()
+
+	add := func(name string, data []byte) error {
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, BundleFile{Path: name, Size: int64(len(data)), Sha256: hex.EncodeToString(sum[:])})
+		return writeTarFile(tw, path.Join(root, name), data)
+	}
+
+	plan, err := json.MarshalIndent(record.Plan, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := add("plan.json", plan); err != nil {
+		return manifest, err
+	}
+	result, err := json.MarshalIndent(map[string]any{"status": record.Status, "result": record.Result, "failure": record.Failure}, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := add("result.json", result); err != nil {
+		return manifest, err
+	}
+	if record.Result != nil && record.Result.EffectivePlan != nil {
+		effective, err := json.MarshalIndent(record.Result.EffectivePlan.Plan, "", "  ")
+		if err != nil {
+			return manifest, err
+		}
+		if err := add("effective-plan.json", effective); err != nil {
+			return manifest, err
+		}
+	}
+	var events []byte
+	var logFiles []string
+	if logDir != "" {
+		if events, err = runEvents(logDir, record.WorkflowID, record.RunID); err != nil {
+			return manifest, err
+		}
+		if logFiles, err = filepath.Glob(filepath.Join(logDir, activities.LogFilePrefix(record.WorkflowID, record.RunID)+"*")); err != nil {
+			return manifest, err
+		}
+	}
+	if err := add("events.jsonl", events); err != nil {
+		return manifest, err
+	}
+
+	sort.Strings(logFiles)
+	for _, file := range logFiles {
+		if activities.IsLogPart(file) {
+			continue
+		}
+		data, err := activities.ReadLogParts(file)
+		if err != nil {
+			return manifest, err
+		}
+		if err := add(path.Join("logs", filepath.Base(file)), data); err != nil {
+			return manifest, err
+		}
+	}
+	if err := add("report.md", []byte(runReport(record))); err != nil {
+		return manifest, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	if err := writeTarFile(tw, path.Join(root, "manifest.json"), data); err != nil {
+		return manifest, err
+	}
+	return manifest, tw.Close()
+}
+
+// stepSeconds is a step's duration in seconds, to the millisecond for
+// results that have DurationMs.
+func stepSeconds(result workflows.PipelineStepResult) string {
+	if result.DurationMs > 0 {
+		return strconv.FormatFloat(float64(result.DurationMs)/1000, 'f', 3, 64)
+	}
+	return strconv.FormatInt(result.DurationSec, 10)
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// runEvents returns the events.jsonl lines that belong to one run, leaving
+// out damaged lines.
+func runEvents(logDir, workflowID, runID string) ([]byte, error) {
+	file, err := os.Open(filepath.Join(logDir, "events.jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var out bytes.Buffer
+	damaged, err := activities.ScanEvents(file, func(event activities.StepEvent, line []byte) {
+		if event.WorkflowID == workflowID && event.RunID == runID {
+			out.Write(line)
+			out.WriteByte('\n')
+		}
+	})
+	if damaged > 0 {
+		fmt.Fprintf(os.Stderr, "warning: skipped %d damaged lines of %s\n", damaged, file.Name())
+	}
+	return out.Bytes(), err
+}
+
+// runReport renders a short human-readable summary of the run.
+func runReport(record *runRecord) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", record.WorkflowID)
+	fmt.Fprintf(&b, "- Run: `%s`\n- Status: %s\n- Started: %s\n", record.RunID, record.Status, record.StartTime.Format(time.RFC3339))
+	if !record.CloseTime.IsZero() {
+		fmt.Fprintf(&b, "- Closed: %s\n", record.CloseTime.Format(time.RFC3339))
+	}
+	if record.Failure != "" {
+		fmt.Fprintf(&b, "- Failure: %s\n", record.Failure)
+	}
+	if record.Result == nil {
+		return b.String()
+	}
+	if len(record.Result.Params) > 0 {
+		names := make([]string, 0, len(record.Result.Params))
+		for name := range record.Result.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString("\n## Params\n\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "- %s = %s\n", name, record.Result.Params[name])
+		}
+	}
+	b.WriteString("\n## Steps\n\n| Step | State | Exit code | Duration (s) | Note |\n|---|---|---|---|---|\n")
+	for _, step := range record.Result.Steps {
+		note := step.SkipReason
+		if step.Result.Error != "" {
+			note = step.Result.Error
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %s | %s |\n", step.ID, step.State, step.Result.ExitCode, stepSeconds(step.Result), strings.ReplaceAll(note, "|", "\\|"))
+	}
+	writeAttemptTimeline(&b, record.Result.Steps)
+	writeHotspots(&b, record.Result.Steps)
+	writeEffectivePlan(&b, record.Result.EffectivePlan)
+	if baseline := record.Result.Baseline; baseline != nil {
+		fmt.Fprintf(&b, "\n## Golden baseline\n\nCompared with golden run `%s` of plan %s.\n\n", baseline.GoldenRun, baseline.Plan)
+		if len(baseline.Deviations) == 0 {
+			b.WriteString("No deviations.\n")
+			return b.String()
+		}
+		b.WriteString("| Step | Deviation | Key | Golden | This run |\n|---|---|---|---|---|\n")
+		for _, d := range baseline.Deviations {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", d.StepID, d.Kind, d.Key, d.Golden, d.Current)
+		}
+	}
+	return b.String()
+}
+
+// writeHotspots adds the top frames of the profiled steps to a report.
+func writeHotspots(b *strings.Builder, steps []workflows.StepOutcome) {
+	header := false
+	for _, step := range steps {
+		profile := step.Result.Profile
+		if profile == nil {
+			continue
+		}
+		if !header {
+			b.WriteString("\n## Hotspots\n")
+			header = true
+		}
+		fmt.Fprintf(b, "\n### %s (%s, %d samples)\n\n", step.ID, profile.Tool, profile.Samples)
+		if profile.Path != "" {
+			fmt.Fprintf(b, "Folded stacks: `%s`\n\n", profile.Path)
+		}
+		b.WriteString("| Frame | Samples | % |\n|---|---|---|\n")
+		for _, spot := range profile.Hotspots {
+			fmt.Fprintf(b, "| %s | %d | %.1f |\n", strings.ReplaceAll(spot.Frame, "|", "\\|"), spot.Samples, spot.Percent)
+		}
+	}
+}
+
+// writeEffectivePlan adds the plan the run executed to a report.
+func writeEffectivePlan(b *strings.Builder, effective *workflows.EffectivePlan) {
+	if effective == nil {
+		return
+	}
+	data, err := json.MarshalIndent(effective.Plan, "", "  ")
+	if err != nil {
+		return
+	}
+	b.WriteString("\n## Effective plan\n\n")
+	if effective.Path != "" {
+		fmt.Fprintf(b, "Written to `%s` (sha256 %s). ", effective.Path, effective.Sha256)
+	}
+	b.WriteString("Secrets are redacted.\n\n```json\n")
+	b.Write(data)
+	b.WriteString("\n```\n")
+}
+
+// importBundle extracts a bundle under dest and verifies every file against
+// the manifest. It returns the directory the run was extracted to.
+func importBundle(r io.Reader, dest string) (string, BundleManifest, error) {
+	var manifest BundleManifest
+	sums := map[string]string{}
+	root := ""
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", manifest, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		top, rel, ok := strings.Cut(name, "/")
+		if !ok || strings.HasPrefix(name, "/") || strings.HasPrefix(name, "../") || (root != "" && top != root) {
+			return "", manifest, fmt.Errorf("unexpected bundle entry %q", header.Name)
+		}
+		root = top
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return "", manifest, err
+		}
+		if rel == "manifest.json" {
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return "", manifest, fmt.Errorf("read manifest: %w", err)
+			}
+		} else {
+			sum := sha256.Sum256(data)
+			sums[rel] = hex.EncodeToString(sum[:])
+		}
+		target := filepath.Join(dest, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", manifest, err
+		}
+		if err := os.WriteFile(target, data, 0o644); err != nil {
+			return "", manifest, err
+		}
+	}
+	if manifest.WorkflowID == "" {
+		return "", manifest, errors.New("bundle has no manifest.json")
+	}
+	for _, file := range manifest.Files {
+		if sums[file.Path] != file.Sha256 {
+			return "", manifest, fmt.Errorf("checksum mismatch for %s", file.Path)
+		}
+	}
+	return filepath.Join(dest, root), manifest, nil
+}
+
+// compressWriter wraps w in gzip for .tar.gz/.tgz targets and in zstd (via the
+// zstd binary) otherwise. finish flushes and waits for the compressor.
+func compressWriter(w io.Writer, target string) (io.Writer, func() error, error) {
+	if strings.HasSuffix(target, ".tar.gz") || strings.HasSuffix(target, ".tgz") {
+		gz := gzip.NewWriter(w)
+		return gz, gz.Close, nil
+	}
+	cmd := exec.Command("zstd", "-q", "-c")
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("zstd is required for .tar.zst bundles (or use -o <file>.tar.gz): %w", err)
+	}
+	return stdin, func() error {
+		err := stdin.Close()
+		if waitErr := cmd.Wait(); err == nil {
+			err = waitErr
+		}
+		return err
+	}, nil
+}
+
+func decompressReader(r io.Reader, source string) (io.Reader, func() error, error) {
+	if strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz") {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		return gz, gz.Close, nil
+	}
+	cmd := exec.Command("zstd", "-q", "-d", "-c")
+	cmd.Stdin = r
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("zstd is required to read .tar.zst bundles: %w", err)
+	}
+	return stdout, cmd.Wait, nil
+}
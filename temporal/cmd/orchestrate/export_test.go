@@ -0,0 +1,263 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+func exportFixture(t *testing.T) (*runRecord, string) {
+	t.Helper()
+	logDir := t.TempDir()
+	files := map[string]string{
+		"nightly_run-1_train_stdout.log":       "epoch 1\n",
+		"nightly_run-1_train_structured.jsonl": "{\"line\":\"epoch 1\"}\n",
+		"nightly_run-0_train_stdout.log":       "previous run\n",
+		"events.jsonl": `{"workflowId":"nightly","runId":"run-1","stepId":"train","status":"step_started"}
+{"workflowId":"nightly","runId":"run-0","stepId":"train","status":"step_started"}
+{"workflowId":"other","runId":"run-1","stepId":"x","status":"step_started"}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(logDir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	record := &runRecord{
+		WorkflowID: "nightly",
+		RunID:      "run-1",
+		Status:     "completed",
+		StartTime:  time.Date(2024, 6, 1, 2, 0, 0, 0, time.UTC),
+		Plan:       &workflows.PipelineInput{LogDir: logDir, Steps: []workflows.PipelineStep{{ID: "train", Type: "command", Command: "python"}}},
+		Result: &workflows.PipelineResult{
+			Succeeded: true,
+			Status:    workflows.StatusSucceeded,
+			Steps:     []workflows.StepOutcome{{ID: "train", State: "success", Result: workflows.PipelineStepResult{DurationSec: 42}}},
+		},
+	}
+	return record, logDir
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	record, logDir := exportFixture(t)
+	var bundle bytes.Buffer
+	manifest, err := writeBundle(&bundle, record, logDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	want := "plan.json result.json events.jsonl logs/nightly_run-1_train_stdout.log logs/nightly_run-1_train_structured.jsonl report.md"
+	if strings.Join(paths, " ") != want {
+		t.Errorf("bundle files = %v", paths)
+	}
+
+	dest := t.TempDir()
+	root, imported, err := importBundle(&bundle, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != filepath.Join(dest, "nightly_run-1") || imported.RunID != "run-1" {
+		t.Errorf("root = %s, manifest = %+v", root, imported)
+	}
+	events, _ := os.ReadFile(filepath.Join(root, "events.jsonl"))
+	if strings.Count(string(events), "\n") != 1 {
+		t.Errorf("events not filtered to the run:\n%s", events)
+	}
+	report, _ := os.ReadFile(filepath.Join(root, "report.md"))
+	if !strings.Contains(string(report), "| train | success | 0 | 42 |") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestImportDetectsTampering(t *testing.T) {
+	record, logDir := exportFixture(t)
+	var bundle bytes.Buffer
+	if _, err := writeBundle(&bundle, record, logDir); err != nil {
+		t.Fatal(err)
+	}
+
+	var tampered bytes.Buffer
+	tr := tar.NewReader(&bundle)
+	tw := tar.NewWriter(&tampered)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		data, _ := io.ReadAll(tr)
+		if strings.HasSuffix(header.Name, "result.json") {
+			data = bytes.Replace(data, []byte("completed"), []byte("failed!!!"), 1)
+		}
+		tw.WriteHeader(header)
+		tw.Write(data)
+	}
+	tw.Close()
+
+	if _, _, err := importBundle(&tampered, t.TempDir()); err == nil || !strings.Contains(err.Error(), "checksum mismatch for result.json") {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+}
+
+func TestImportRejectsPathTraversal(t *testing.T) {
+	var bundle bytes.Buffer
+	tw := tar.NewWriter(&bundle)
+	writeTarFile(tw, "../escape.txt", []byte("x"))
+	tw.Close()
+	if _, _, err := importBundle(&bundle, t.TempDir()); err == nil {
+		t.Error("expected traversal entry to be rejected")
+	}
+}
+
+func TestExportBundleRemovesPartialBundle(t *testing.T) {
+	record, logDir := exportFixture(t)
+	// A directory named like a log file cannot be read into the bundle.
+	if err := os.Mkdir(filepath.Join(logDir, "nightly_run-1_train_profile.folded"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if _, err := exportBundle(target, record, logDir); err == nil {
+		t.Fatal("expected the unreadable log to fail the export")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Errorf("partial bundle left at %s (%v)", target, err)
+	}
+
+	os.Remove(filepath.Join(logDir, "nightly_run-1_train_profile.folded"))
+	if _, err := exportBundle(target, record, logDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bundleRunRecord(target); err != nil {
+		t.Errorf("exported bundle is unreadable: %v", err)
+	}
+}
+
+func TestWriteBundleRemoteLogDir(t *testing.T) {
+	record, _ := exportFixture(t)
+	var bundle bytes.Buffer
+	manifest, err := writeBundle(&bundle, record, "s3://ci-logs/sygaldry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range manifest.Files {
+		if strings.HasPrefix(file.Path, "logs/") || (file.Path == "events.jsonl" && file.Size > 0) {
+			t.Errorf("remote log dir should leave out logs and events, got %+v", file)
+		}
+	}
+}
+
+func TestBundleCompression(t *testing.T) {
+	targets := []string{"bundle.tar.gz"}
+	if _, err := exec.LookPath("zstd"); err == nil {
+		targets = append(targets, "bundle.tar.zst")
+	}
+	for _, target := range targets {
+		var compressed bytes.Buffer
+		w, finish, err := compressWriter(&compressed, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, "payload")
+		if err := finish(); err != nil {
+			t.Fatal(err)
+		}
+		r, done, err := decompressReader(&compressed, target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		if err := done(); err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "payload" {
+			t.Errorf("%s round trip = %q", target, data)
+		}
+	}
+}
+
+func TestRunReportAttemptTimeline(t *testing.T) {
+	const lostAttempt = "attempt ended without a result (worker lost or timed out)"
+	record := &runRecord{WorkflowID: "nightly", RunID: "run-1", Status: "completed", Result: &workflows.PipelineResult{
+		Steps: []workflows.StepOutcome{
+			{ID: "fetch", State: "success", Result: workflows.PipelineStepResult{Attempts: []activities.StepAttempt{
+				{Attempt: 1, StartedAt: "2025-01-01T00:00:00Z", EndedAt: "2025-01-01T00:00:05Z"},
+			}}},
+			{ID: "train", State: "success", Result: workflows.PipelineStepResult{Attempts: []activities.StepAttempt{
+				{Attempt: 1, Worker: "gpu-2", StartedAt: "2025-01-01T00:00:00Z", EndedAt: "2025-01-01T00:01:30Z", ExitCode: -1, Error: lostAttempt},
+				{Attempt: 2, Worker: "gpu-1", StartedAt: "2025-01-01T00:02:00Z", EndedAt: "2025-01-01T00:12:00Z"},
+			}}},
+		},
+	}}
+	report := runReport(record)
+	for _, want := range []string{"## Attempts", "### train (2 attempts)", "| 1 | gpu-2 | 2025-01-01T00:00:00Z | 1m30s | " + lostAttempt + " |", "| 2 | gpu-1 | 2025-01-01T00:02:00Z | 10m0s | ok |"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "### fetch") {
+		t.Errorf("single-attempt step listed:\n%s", report)
+	}
+}
+
+func TestRunReportEffectivePlan(t *testing.T) {
+	record := &runRecord{WorkflowID: "nightly", RunID: "run-1", Status: "completed", Result: &workflows.PipelineResult{
+		EffectivePlan: &workflows.EffectivePlan{
+			Path:   "/logs/nightly_run-1_effective-plan.json",
+			Sha256: "abc",
+			Plan:   workflows.PipelineInput{Name: "nightly", Steps: []workflows.PipelineStep{{ID: "build", Name: "build", Type: "command"}}},
+		},
+	}}
+	report := runReport(record)
+	for _, want := range []string{"## Effective plan", "`/logs/nightly_run-1_effective-plan.json` (sha256 abc)", "```json", `"id": "build"`} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+
+	var bundle bytes.Buffer
+	manifest, err := writeBundle(&bundle, record, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(manifest.Files, func(f BundleFile) bool { return f.Path == "effective-plan.json" }) {
+		t.Errorf("bundle files = %+v, want effective-plan.json", manifest.Files)
+	}
+}
+
+func TestRunReportHotspots(t *testing.T) {
+	record := &runRecord{WorkflowID: "nightly", RunID: "run-1", Status: "completed", Result: &workflows.PipelineResult{
+		Steps: []workflows.StepOutcome{
+			{ID: "fetch", State: "success"},
+			{ID: "prep", State: "success", Result: workflows.PipelineStepResult{Profile: &activities.StepProfile{
+				Tool:    "py-spy",
+				Path:    "/logs/nightly_run-1_prep_profile.folded",
+				Samples: 200,
+				Hotspots: []activities.Hotspot{
+					{Frame: "tokenize (prep.py:40)", Samples: 150, Percent: 75},
+					{Frame: "write (prep.py:88)", Samples: 50, Percent: 25},
+				},
+			}}},
+		},
+	}}
+	report := runReport(record)
+	for _, want := range []string{"## Hotspots", "### prep (py-spy, 200 samples)", "`/logs/nightly_run-1_prep_profile.folded`", "| tokenize (prep.py:40) | 150 | 75.0 |"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "### fetch") {
+		t.Errorf("report lists a step without a profile:\n%s", report)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+// runGet implements `orchestrate get <workflow-id> [key]`, which reads a
+// run's context values (step outputs as "<step-id>.<key>") through a
+// workflow query. A key prints just its value, for use in scripts.
+func runGet(args []string) error {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args)
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		return errors.New("usage: orchestrate get [flags] <workflow-id> [key]")
+	}
+	key := fs.Arg(1)
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	value, err := c.QueryWorkflow(ctx, fs.Arg(0), *runID, workflows.ContextQuery, key)
+	if err != nil {
+		return err
+	}
+	var values map[string]string
+	if err := value.Get(&values); err != nil {
+		return err
+	}
+	printContext(os.Stdout, values, key)
+	return nil
+}
+
+// printContext writes the value of key, or every value as key=value lines
+// sorted by key.
+func printContext(w io.Writer, values map[string]string, key string) {
+	if key != "" {
+		fmt.Fprintln(w, values[key])
+		return
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s=%s\n", k, values[k])
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrintContext(t *testing.T) {
+	values := map[string]string{"train.loss": "0.31", "train.checkpoint": "s3://ckpt/42"}
+	var out bytes.Buffer
+	printContext(&out, values, "")
+	if out.String() != "train.checkpoint=s3://ckpt/42\ntrain.loss=0.31\n" {
+		t.Errorf("all values:\n%s", out.String())
+	}
+	out.Reset()
+	printContext(&out, values, "train.checkpoint")
+	if out.String() != "s3://ckpt/42\n" {
+		t.Errorf("one value: %q", out.String())
+	}
+}
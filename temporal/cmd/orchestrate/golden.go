@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// runGolden implements `orchestrate golden pin|show|unpin`.
+func runGolden(args []string) error {
+	usage := errors.New("usage: orchestrate golden pin [flags] <workflow-id> | show [flags] <plan> | unpin [flags] <plan>")
+	if len(args) == 0 {
+		return usage
+	}
+	switch args[0] {
+	case "pin":
+		return runGoldenPin(args[1:])
+	case "show", "unpin":
+		fs := flag.NewFlagSet("golden "+args[0], flag.ExitOnError)
+		logDir := fs.String("log-dir", envOr("TEMPORAL_LOG_DIR", "./logs"), "Log directory of the plan's runs (locates the results store)")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return usage
+		}
+		path := activities.GoldenBaselinePath(*logDir, fs.Arg(0))
+		if args[0] == "unpin" {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			fmt.Printf("removed golden baseline of %s\n", fs.Arg(0))
+			return nil
+		}
+		baseline, err := activities.ReadGoldenBaseline(path)
+		if err != nil {
+			return err
+		}
+		printGolden(os.Stdout, baseline)
+		return nil
+	}
+	return usage
+}
+
+func runGoldenPin(args []string) error {
+	fs := flag.NewFlagSet("golden pin", flag.ExitOnError)
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	logDir := fs.String("log-dir", "", "Log directory of the plan's runs (default: plan log_dir, TEMPORAL_LOG_DIR or ./logs)")
+	force := fs.Bool("force", false, "Pin a run that did not succeed")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate golden pin [flags] <workflow-id>")
+	}
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	record, err := fetchRunRecord(ctx, c, fs.Arg(0), *runID)
+	if err != nil {
+		return err
+	}
+
+	baseline, err := goldenFromRecord(record, *force)
+	if err != nil {
+		return err
+	}
+	if current, err := user.Current(); err == nil {
+		baseline.PinnedBy = current.Username
+	}
+	dir := *logDir
+	if dir == "" && record.Plan.LogDir != "" {
+		dir = record.Plan.LogDir
+	}
+	if dir == "" {
+		dir = envOr("TEMPORAL_LOG_DIR", "./logs")
+	}
+	path := activities.GoldenBaselinePath(dir, baseline.Plan)
+	if err := activities.WriteGoldenBaseline(path, baseline); err != nil {
+		return err
+	}
+	fmt.Printf("pinned %s run %s as the golden run of %s (%s)\n", record.WorkflowID, record.RunID, baseline.Plan, path)
+	return nil
+}
+
+// goldenFromRecord checks that a run can be pinned and builds its baseline.
+func goldenFromRecord(record *runRecord, force bool) (activities.GoldenBaseline, error) {
+	if record.Plan == nil || record.Result == nil {
+		return activities.GoldenBaseline{}, fmt.Errorf("run %s has no recorded plan and result", record.RunID)
+	}
+	if record.Plan.Name == "" {
+		return activities.GoldenBaseline{}, errors.New("the run's plan has no name; set name: in the plan to compare runs")
+	}
+	if record.Result.Status != workflows.StatusSucceeded && !force {
+		return activities.GoldenBaseline{}, fmt.Errorf("run %s is %s; use -force to pin it anyway", record.RunID, record.Result.Status)
+	}
+	baseline := workflows.GoldenFromResult(record.Plan.Name, record.WorkflowID, record.RunID, *record.Result)
+	baseline.PinnedAt = time.Now().UTC().Format(time.RFC3339)
+	return baseline, nil
+}
+
+func printGolden(w io.Writer, baseline activities.GoldenBaseline) {
+	fmt.Fprintf(w, "plan %s: golden run %s (%s), pinned %s", baseline.Plan, baseline.RunID, baseline.WorkflowID, baseline.PinnedAt)
+	if baseline.PinnedBy != "" {
+		fmt.Fprintf(w, " by %s", baseline.PinnedBy)
+	}
+	fmt.Fprintln(w)
+	ids := make([]string, 0, len(baseline.Steps))
+	for id := range baseline.Steps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATE\tDURATION\tOUTPUTS\tDIGESTS")
+	for _, id := range ids {
+		step := baseline.Steps[id]
+		fmt.Fprintf(tw, "%s\t%s\t%ds\t%d\t%d\n", id, step.State, step.DurationSec, len(step.Outputs), len(step.Digests))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+func TestGoldenFromRecord(t *testing.T) {
+	record, _ := exportFixture(t)
+	if _, err := goldenFromRecord(record, false); err == nil || !strings.Contains(err.Error(), "no name") {
+		t.Errorf("unnamed plan: err = %v", err)
+	}
+
+	record.Plan.Name = "nightly-train"
+	baseline, err := goldenFromRecord(record, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline.Plan != "nightly-train" || baseline.RunID != "run-1" || baseline.Steps["train"].DurationSec != 42 || baseline.PinnedAt == "" {
+		t.Errorf("baseline = %+v", baseline)
+	}
+
+	record.Result.Status = workflows.StatusFailed
+	if _, err := goldenFromRecord(record, false); err == nil {
+		t.Error("a failed run was pinned without -force")
+	}
+	if _, err := goldenFromRecord(record, true); err != nil {
+		t.Errorf("-force: %v", err)
+	}
+}
+
+func TestReportListsGoldenDeviations(t *testing.T) {
+	record, _ := exportFixture(t)
+	record.Result.Baseline = &workflows.BaselineComparison{
+		Plan:      "nightly-train",
+		GoldenRun: "run-0",
+		Deviations: []workflows.Deviation{
+			{StepID: "train", Kind: "duration", Golden: "20s", Current: "42s"},
+		},
+	}
+	report := runReport(record)
+	for _, want := range []string{"## Golden baseline", "`run-0`", "| train | duration |  | 20s | 42s |"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestPrintGolden(t *testing.T) {
+	var out bytes.Buffer
+	printGolden(&out, activities.GoldenBaseline{
+		Plan: "release", RunID: "run-1", WorkflowID: "wf", PinnedAt: "2024-06-01T00:00:00Z", PinnedBy: "ci",
+		Steps: map[string]activities.GoldenStep{"build": {State: "success", DurationSec: 3, Outputs: map[string]string{"a": "b"}}},
+	})
+	if !strings.Contains(out.String(), "golden run run-1 (wf)") || !strings.Contains(out.String(), "by ci") || !strings.Contains(out.String(), "build") {
+		t.Errorf("output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// runLogs implements `orchestrate logs cat|tail`, transparently decrypting
+// log files written with TEMPORAL_LOG_ENCRYPTION_KEY*, also from inside an
+// exported bundle, and `orchestrate logs locate`, which finds runs in the run
+// index.
+func runLogs(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: orchestrate logs cat|tail [flags] <file>... | locate [flags]")
+	}
+	switch args[0] {
+	case "cat":
+		fs := flag.NewFlagSet("logs cat", flag.ExitOnError)
+		bundle := fs.String("bundle", "", "Read the files from this exported bundle; names are relative to the bundle or its logs/ directory")
+		fs.Parse(args[1:])
+		for _, path := range fs.Args() {
+			data, err := readLog(*bundle, path)
+			if err != nil {
+				return err
+			}
+			os.Stdout.Write(data)
+		}
+		return nil
+	case "tail":
+		fs := flag.NewFlagSet("logs tail", flag.ExitOnError)
+		lines := fs.Int("n", 20, "Number of trailing lines to print")
+		bundle := fs.String("bundle", "", "Read the files from this exported bundle; names are relative to the bundle or its logs/ directory")
+		fs.Parse(args[1:])
+		for _, path := range fs.Args() {
+			data, err := readLog(*bundle, path)
+			if err != nil {
+				return err
+			}
+			fmt.Print(tailLines(string(data), *lines))
+		}
+		return nil
+	case "locate":
+		fs := flag.NewFlagSet("logs locate", flag.ExitOnError)
+		logDir := fs.String("log-dir", envOr("TEMPORAL_LOG_DIR", "./logs"), "Log directory of the runs (locates the results store)")
+		plan := fs.String("plan", "", "Only runs of this plan")
+		status := fs.String("status", "", "Only runs with this status, e.g. failed or running")
+		since := fs.Duration("since", 0, "Only runs started within this long, e.g. 48h")
+		limit := fs.Int("limit", 20, "Show at most this many runs, newest first (0 for all)")
+		asJSON := fs.Bool("json", false, "Print the runs as JSON lines")
+		labels := paramFlags{}
+		fs.Var(labels, "label", "Only runs with this label as name=value (repeatable)")
+		fs.Parse(args[1:])
+		runs, err := activities.ReadRunIndex(activities.RunIndexPath(*logDir))
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("no run index at %s", activities.RunIndexPath(*logDir))
+		}
+		if err != nil {
+			return err
+		}
+		filter := runFilter{Plan: *plan, Status: *status, Labels: labels}
+		if *since > 0 {
+			filter.After = time.Now().Add(-*since)
+		}
+		runs = locateRuns(runs, filter, *limit)
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			for _, run := range runs {
+				encoder.Encode(run)
+			}
+			return nil
+		}
+		printRuns(os.Stdout, runs)
+		return nil
+	default:
+		return fmt.Errorf("unknown logs command %q", args[0])
+	}
+}
+
+// readLog reads a log file, from bundle if set, with readLogFile.
+func readLog(bundle, name string) ([]byte, error) {
+	if bundle == "" {
+		return readLogFile(name)
+	}
+	files, err := readBundleFiles(bundle, name, "logs/"+name)
+	if err != nil {
+		return nil, err
+	}
+	data, ok := files[name]
+	if !ok {
+		if data, ok = files["logs/"+name]; !ok {
+			return nil, fmt.Errorf("%s has no file %s", bundle, name)
+		}
+	}
+	return decryptLog(name, data)
+}
+
+// readLogFile returns the plaintext of a step log file, joined from its parts
+// when it was synced from S3, decrypting it with the configured log key when
+// it carries the encrypted header.
+func readLogFile(path string) ([]byte, error) {
+	data, err := activities.ReadLogParts(path)
+	if err != nil {
+		return nil, err
+	}
+	return decryptLog(path, data)
+}
+
+func decryptLog(path string, data []byte) ([]byte, error) {
+	if !activities.IsEncryptedLog(data) {
+		return data, nil
+	}
+	key, err := activities.LogEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%s is encrypted; set TEMPORAL_LOG_ENCRYPTION_KEY, _KEY_FILE or _KEY_COMMAND", path)
+	}
+	var plain bytes.Buffer
+	err = activities.DecryptLog(&plain, bytes.NewReader(data), key)
+	if errors.Is(err, activities.ErrTruncatedLog) {
+		// A step still running has not finished its last frame yet.
+		fmt.Fprintf(os.Stderr, "%s: %v; showing what was complete\n", path, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plain.Bytes(), nil
+}
+
+func tailLines(text string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		io.WriteString(&b, line)
+	}
+	return b.String()
+}
+
+// runFilter selects runs of the run index; zero fields match any run.
+type runFilter struct {
+	Plan   string
+	Status string
+	Labels map[string]string
+	After  time.Time
+}
+
+// locateRuns returns the runs matching filter, newest first, at most limit
+// of them unless limit is 0.
+func locateRuns(runs []activities.RunIndexEntry, filter runFilter, limit int) []activities.RunIndexEntry {
+	var found []activities.RunIndexEntry
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if filter.Plan != "" && run.Plan != filter.Plan || filter.Status != "" && run.Status != filter.Status {
+			continue
+		}
+		if !filter.After.IsZero() {
+			started, err := time.Parse(time.RFC3339, run.StartedAt)
+			if err != nil || started.Before(filter.After) {
+				continue
+			}
+		}
+		matches := true
+		for name, value := range filter.Labels {
+			matches = matches && run.Labels[name] == value
+		}
+		if !matches {
+			continue
+		}
+		found = append(found, run)
+		if limit > 0 && len(found) == limit {
+			break
+		}
+	}
+	return found
+}
+
+// printRuns writes runs as a table with the log directory of each.
+func printRuns(w io.Writer, runs []activities.RunIndexEntry) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tSTATUS\tPLAN\tWORKFLOW\tRUN\tLABELS\tLOG DIR")
+	for _, run := range runs {
+		names := make([]string, 0, len(run.Labels))
+		for name := range run.Labels {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		labels := make([]string, len(names))
+		for i, name := range names {
+			labels[i] = name + "=" + run.Labels[name]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", run.StartedAt, run.Status, orDash(run.Plan), run.WorkflowID, run.RunID, orDash(strings.Join(labels, ",")), run.LogDir)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestTailLines(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"a\nb\nc\n", 2, "b\nc\n"},
+		{"a\nb\nc", 2, "b\nc"},
+		{"a\n", 5, "a\n"},
+		{"a\nb\n", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := tailLines(tt.text, tt.n); got != tt.want {
+			t.Errorf("tailLines(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestReadLogFilePlain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x_stdout.log")
+	os.WriteFile(path, []byte("hello\n"), 0o644)
+	data, err := readLogFile(path)
+	if err != nil || string(data) != "hello\n" {
+		t.Errorf("readLogFile() = %q, %v", data, err)
+	}
+}
+
+func TestReadLogFileEncryptedWithoutKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "x_stdout.log.enc")
+	os.WriteFile(path, []byte(activities.EncryptedLogMagic), 0o644)
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY", "")
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND", "")
+	if _, err := readLogFile(path); err == nil || !strings.Contains(err.Error(), "is encrypted") {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+}
+
+func TestLocateRuns(t *testing.T) {
+	runs := []activities.RunIndexEntry{
+		{WorkflowID: "a", Plan: "fineweb", Status: "failed", StartedAt: "2025-03-01T10:00:00Z", Labels: map[string]string{"dataset": "fineweb"}},
+		{WorkflowID: "b", Plan: "fineweb", Status: "succeeded", StartedAt: "2025-03-02T10:00:00Z", Labels: map[string]string{"dataset": "fineweb"}},
+		{WorkflowID: "c", Plan: "qwen", Status: "failed", StartedAt: "2025-03-02T11:00:00Z"},
+		{WorkflowID: "d", Plan: "fineweb", Status: "failed", StartedAt: "2025-03-02T12:00:00Z", Labels: map[string]string{"dataset": "fineweb", "team": "ml"}},
+	}
+	ids := func(runs []activities.RunIndexEntry) string {
+		var ids []string
+		for _, run := range runs {
+			ids = append(ids, run.WorkflowID)
+		}
+		return strings.Join(ids, ",")
+	}
+	after, _ := time.Parse(time.RFC3339, "2025-03-02T00:00:00Z")
+	tests := []struct {
+		name   string
+		filter runFilter
+		limit  int
+		want   string
+	}{
+		{"all newest first", runFilter{}, 0, "d,c,b,a"},
+		{"limit", runFilter{}, 2, "d,c"},
+		{"failed fineweb", runFilter{Status: "failed", Labels: map[string]string{"dataset": "fineweb"}}, 0, "d,a"},
+		{"since", runFilter{Plan: "fineweb", After: after}, 0, "d,b"},
+		{"two labels", runFilter{Labels: map[string]string{"dataset": "fineweb", "team": "ml"}}, 0, "d"},
+	}
+	for _, tt := range tests {
+		if got := ids(locateRuns(runs, tt.filter, tt.limit)); got != tt.want {
+			t.Errorf("%s: runs = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	var out bytes.Buffer
+	printRuns(&out, runs[3:])
+	if !strings.Contains(out.String(), "dataset=fineweb,team=ml") {
+		t.Errorf("table:\n%s", out.String())
+	}
+}
@@ -2,30 +2,73 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
 	"gopkg.in/yaml.v3"
 
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/metrics"
+	"temporal-orchestration/internal/stepwatch"
 	"temporal-orchestration/internal/workflows"
 )
 
-var allowedTypes = map[string]bool{
-	"command":             true,
-	"download":            true,
-	"docker_build":        true,
-	"docker_push":         true,
-	"package_build":       true,
-	"container_job":       true,
-	"hf_download_dataset": true,
-	"hf_download_model":   true,
+// maxHFWorkers caps parallel downloads of one hf_download_dataset step.
+const maxHFWorkers = 32
+
+// subcommands are dispatched on the first argument; anything else runs a plan.
+var subcommands = map[string]func(args []string) error{
+	"deployment":   runDeployment,
+	"export":       runExport,
+	"get":          runGet,
+	"golden":       runGolden,
+	"import":       runImport,
+	"logs":         runLogs,
+	"params":       runParams,
+	"report":       runPrintReport,
+	"seal":         runSeal,
+	"status":       runStatus,
+	"submit-batch": runSubmitBatch,
+}
+
+// networkModes lists the network isolation modes each step type can enforce.
+// Types not listed only run with host networking.
+var networkModes = map[string]map[string]bool{
+	"command":       {"host": true, "none": true},
+	"package_build": {"host": true, "none": true},
+	"docker_build":  {"host": true, "none": true},
+	"container_job": {"host": true, "none": true, "proxy-only": true},
 }
 
 func main() {
+	exporter, err := metrics.FromEnv("orchestrate")
+	if err != nil {
+		log.Fatal(err)
+	}
+	metricsExporter = exporter
+	defer metricsExporter.Close()
+
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
 	var (
 		workflowID = flag.String("workflow-id", "pipeline-"+time.Now().Format("20060102-150405"), "Workflow ID")
 		planPath   = flag.String("plan", "", "Path to YAML plan")
@@ -33,65 +76,184 @@ func main() {
 		address    = flag.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
 		namespace  = flag.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
 		logDir     = flag.String("log-dir", "", "Log directory for step outputs (overrides plan and TEMPORAL_LOG_DIR)")
+		yes        = flag.Bool("yes", false, "Run destructive steps without asking")
+		noCheck    = flag.Bool("skip-worker-check", false, "Submit without checking the task queue's workers support the plan")
+		paramArgs  = paramFlags{}
+		labelArgs  = paramFlags{}
+		eventsOut  = flag.Bool("events-stdout", false, "Write step lifecycle events to stdout as JSON lines while waiting; the result goes to stderr")
 	)
+	flag.Var(paramArgs, "param", "Plan parameter as name=value (repeatable; overrides plan params)")
+	flag.Var(labelArgs, "label", "Run label as name=value for the run index (repeatable; overrides plan labels)")
 	flag.Parse()
 
 	if *planPath == "" {
-		log.Fatal("-plan is required")
+		fatalf("-plan is required")
 	}
 
-	inputBytes, err := os.ReadFile(*planPath)
+	input, err := loadPlan(*planPath, paramArgs, *logDir)
 	if err != nil {
-		log.Fatalf("unable to read plan file: %v", err)
+		fatalf("%v", err)
 	}
-
-	var input workflows.PipelineInput
-	if err := yaml.Unmarshal(inputBytes, &input); err != nil {
-		log.Fatalf("unable to parse plan: %v", err)
+	if err := resolveRoute(&input, flag.CommandLine, *namespace, *taskQueue); err != nil {
+		fatalf("%v", err)
 	}
-
-	if *logDir != "" {
-		input.LogDir = *logDir
-	} else if input.LogDir == "" {
-		if env := os.Getenv("TEMPORAL_LOG_DIR"); env != "" {
-			input.LogDir = env
+	for name, value := range labelArgs {
+		if input.Labels == nil {
+			input.Labels = map[string]string{}
 		}
+		input.Labels[name] = value
 	}
-
-	if err := validatePlan(&input); err != nil {
-		log.Fatalf("plan validation failed: %v", err)
+	destructive := workflows.DestructiveSteps(input.Steps)
+	if err := confirmDestructive(destructive, *yes, isTerminal(os.Stdin), os.Stdin, os.Stderr); err != nil {
+		fatalf("%v", err)
 	}
+	input.ConfirmDestructive = len(destructive) > 0
 
-	c, err := client.Dial(client.Options{HostPort: *address, Namespace: *namespace})
+	c, err := dialClient(*address, input.Namespace)
 	if err != nil {
-		log.Fatalf("unable to create Temporal client: %v", err)
+		fatalf("unable to create Temporal client: %v", err)
 	}
 	defer c.Close()
 
-	options := client.StartWorkflowOptions{
-		ID:        *workflowID,
-		TaskQueue: *taskQueue,
-	}
+	options := startOptions(*workflowID, input.TaskQueue, input)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 4*time.Hour)
 	defer cancel()
 
+	if !*noCheck {
+		if err := preflightWorkers(ctx, c, input.TaskQueue, []workflows.PipelineInput{input}, os.Stderr); err != nil {
+			fatalf("%v", err)
+		}
+	}
+
 	we, err := c.ExecuteWorkflow(ctx, options, workflows.Pipeline, input)
 	if err != nil {
-		log.Fatalf("unable to start workflow: %v", err)
+		fatalf("unable to start workflow: %v", err)
+	}
+	if options.CronSchedule != "" {
+		// Cron workflows never complete; each run is visible in Temporal.
+		fmt.Printf("scheduled workflow %s (%s)\n", we.GetID(), options.CronSchedule)
+		return
+	}
+
+	resultOut := os.Stdout
+	finishEvents := func(string) {}
+	if *eventsOut {
+		resultOut = os.Stderr
+		finishEvents = stepwatch.Stream(c, we.GetID(), we.GetRunID(), os.Stdout)
 	}
 
 	var result workflows.PipelineResult
 	if err := we.Get(ctx, &result); err != nil {
-		log.Fatalf("workflow failed: %v", err)
+		if temporal.IsCanceledError(err) {
+			finishEvents(workflows.StatusCancelled)
+		} else {
+			finishEvents(workflows.StatusFailed)
+		}
+		fatalf("workflow failed: %v", err)
 	}
+	finishEvents(result.Status)
 
 	output, err := yaml.Marshal(result)
 	if err != nil {
-		log.Fatalf("unable to serialize result: %v", err)
+		fatalf("unable to serialize result: %v", err)
+	}
+
+	fmt.Fprintln(resultOut, string(output))
+	if result.Status == workflows.StatusPrerequisitesNotMet {
+		// Distinct from a failed run so callers can retry later.
+		metricsExporter.Close()
+		os.Exit(3)
+	}
+}
+
+// metricsExporter collects the SDK metrics of every client this process
+// dials (see TEMPORAL_METRICS_ADDR and TEMPORAL_METRICS_FILE).
+var metricsExporter *metrics.Exporter
+
+func dialClient(address, namespace string) (client.Client, error) {
+	return client.Dial(client.Options{HostPort: address, Namespace: namespace, MetricsHandler: metricsExporter.Handler()})
+}
+
+// fatalf writes the metrics file before exiting, which log.Fatalf would skip.
+func fatalf(format string, args ...any) {
+	metricsExporter.Close()
+	log.Fatalf(format, args...)
+}
+
+// loadPlan reads and validates a plan, applying parameter overrides and the
+// log directory (flag, then plan, then TEMPORAL_LOG_DIR). Uses of deprecated
+// step types and fields are warned about on stderr.
+func loadPlan(path string, params map[string]string, logDir string) (workflows.PipelineInput, error) {
+	var input workflows.PipelineInput
+	inputBytes, err := os.ReadFile(path)
+	if err != nil {
+		return input, fmt.Errorf("unable to read plan file: %w", err)
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(inputBytes, &document); err != nil {
+		return input, fmt.Errorf("unable to parse plan: %w", err)
+	}
+	if err := markSealedValues(&document); err != nil {
+		return input, fmt.Errorf("unable to parse plan: %w", err)
+	}
+	if err := document.Decode(&input); err != nil {
+		return input, fmt.Errorf("unable to parse plan: %w", err)
+	}
+
+	if len(params) > 0 && input.Params == nil {
+		input.Params = map[string]string{}
+	}
+	for name, value := range params {
+		input.Params[name] = value
+	}
+
+	if schedule := input.Schedule; schedule != nil && schedule.HolidayCalendar != "" {
+		calendar := schedule.HolidayCalendar
+		if !filepath.IsAbs(calendar) {
+			calendar = filepath.Join(filepath.Dir(path), calendar)
+		}
+		file, err := os.Open(calendar)
+		if err != nil {
+			return input, fmt.Errorf("unable to read holiday calendar: %w", err)
+		}
+		holidays, err := workflows.ReadHolidayCalendar(file)
+		file.Close()
+		if err != nil {
+			return input, fmt.Errorf("holiday calendar %s: %w", calendar, err)
+		}
+		schedule.Holidays = append(schedule.Holidays, holidays...)
+	}
+
+	if logDir != "" {
+		input.LogDir = logDir
+	} else if input.LogDir == "" {
+		if env := os.Getenv("TEMPORAL_LOG_DIR"); env != "" {
+			input.LogDir = env
+		}
 	}
 
-	fmt.Println(string(output))
+	if err := validatePlan(&input); err != nil {
+		return input, fmt.Errorf("plan validation failed: %w", err)
+	}
+	for _, warning := range workflows.DeprecationWarnings(input.Steps) {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", path, warning)
+	}
+	return input, nil
+}
+
+func startOptions(workflowID, taskQueue string, input workflows.PipelineInput) client.StartWorkflowOptions {
+	options := client.StartWorkflowOptions{
+		ID:        workflowID,
+		TaskQueue: taskQueue,
+	}
+	if input.Schedule != nil {
+		options.CronSchedule = input.Schedule.CronSchedule()
+	}
+	if input.ExecutionMode == workflows.ExecutionAtMostOnce {
+		options.RetryPolicy = &temporal.RetryPolicy{MaximumAttempts: 1}
+	}
+	return options
 }
 
 func validatePlan(input *workflows.PipelineInput) error {
@@ -112,16 +274,77 @@ func validatePlan(input *workflows.PipelineInput) error {
 		if step.Type == "" {
 			return fmt.Errorf("step %s is missing type", step.ID)
 		}
-		if !allowedTypes[step.Type] {
+		if !workflows.IsStepType(step.Type) {
 			return fmt.Errorf("step %s has unsupported type %s", step.ID, step.Type)
 		}
 		if step.Name == "" {
 			step.Name = step.ID
 		}
+		if step.Network != "" && step.Network != "host" && !networkModes[step.Type][step.Network] {
+			return fmt.Errorf("step %s: network %s is not supported for %s steps", step.ID, step.Network, step.Type)
+		}
+		for _, path := range step.LocalArtifacts {
+			if path == "" {
+				return fmt.Errorf("step %s has an empty local_artifacts entry", step.ID)
+			}
+		}
+		for _, name := range step.RateLimits {
+			if !activities.RateLimiterNamePattern.MatchString(name) {
+				return fmt.Errorf("step %s: invalid rate limiter name %q", step.ID, name)
+			}
+		}
+		if len(step.RateLimits) > 0 && (step.Type == "approval" || step.Type == "watch_path" || step.Type == "join") {
+			return fmt.Errorf("step %s: %s steps cannot use rate_limits", step.ID, step.Type)
+		}
+		switch step.OutputTruncation {
+		case "", "head", "tail", "head_tail":
+		default:
+			return fmt.Errorf("step %s: output_truncation must be head, tail or head_tail", step.ID)
+		}
+		if step.OutputTruncation != "" && (step.Type == "approval" || step.Type == "watch_path" || step.Type == "join" || step.Type == "download") {
+			return fmt.Errorf("step %s: %s steps cannot use output_truncation", step.ID, step.Type)
+		}
+		if step.CircuitKey != "" && (step.Type == "approval" || step.Type == "join") {
+			return fmt.Errorf("step %s: %s steps cannot use circuit_key", step.ID, step.Type)
+		}
+		if err := step.Locale.Validate(); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		if err := step.Profile.Validate(); err != nil {
+			return fmt.Errorf("step %s: %w", step.ID, err)
+		}
+		if step.Profile != nil && step.Type != "command" {
+			return fmt.Errorf("step %s: %s steps cannot use profile", step.ID, step.Type)
+		}
+		if step.KeepTmpOnFailure && step.Type != "command" && step.Type != "package_build" && step.Type != "container_job" {
+			return fmt.Errorf("step %s: %s steps cannot use keep_tmp_on_failure", step.ID, step.Type)
+		}
+		if len(step.CaptureOnFailure) > 0 && (step.Type == "approval" || step.Type == "join") {
+			return fmt.Errorf("step %s: %s steps have no workspace to capture_on_failure", step.ID, step.Type)
+		}
+		for _, entry := range step.CaptureOnFailure {
+			if err := validateCaptureEntry(entry); err != nil {
+				return fmt.Errorf("step %s capture_on_failure: %w", step.ID, err)
+			}
+		}
 		switch step.Type {
 		case "command":
+			if len(step.Run) > 0 {
+				if step.Command != "" || len(step.Args) > 0 {
+					return fmt.Errorf("step %s: run and command/args are mutually exclusive", step.ID)
+				}
+				for i, line := range step.Run {
+					if strings.TrimSpace(line) == "" {
+						return fmt.Errorf("step %s run entry %d is empty", step.ID, i)
+					}
+				}
+				break
+			}
+			if step.StopOnFailure != nil {
+				return fmt.Errorf("step %s: stop_on_failure requires run", step.ID)
+			}
 			if step.Command == "" {
-				return fmt.Errorf("step %s command is required", step.ID)
+				return fmt.Errorf("step %s command is required unless run is set", step.ID)
 			}
 		case "download":
 			if step.Download == nil || step.Download.URL == "" || step.Download.Output == "" {
@@ -131,10 +354,19 @@ func validatePlan(input *workflows.PipelineInput) error {
 			if step.DockerBuild == nil || step.DockerBuild.Image == "" {
 				return fmt.Errorf("step %s docker_build requires image", step.ID)
 			}
+			if err := validateDockerHost(step.DockerBuild.DockerHost); err != nil {
+				return fmt.Errorf("step %s: %w", step.ID, err)
+			}
+			if step.DockerBuild.BuilderPool && step.DockerBuild.DockerHost != nil {
+				return fmt.Errorf("step %s: builder_pool and docker_host are mutually exclusive", step.ID)
+			}
 		case "docker_push":
 			if step.DockerPush == nil || step.DockerPush.Image == "" {
 				return fmt.Errorf("step %s docker_push requires image", step.ID)
 			}
+			if err := validateDockerHost(step.DockerPush.DockerHost); err != nil {
+				return fmt.Errorf("step %s: %w", step.ID, err)
+			}
 		case "package_build":
 			if step.PackageBuild == nil || step.PackageBuild.Command == "" {
 				return fmt.Errorf("step %s package_build requires command", step.ID)
@@ -147,23 +379,180 @@ func validatePlan(input *workflows.PipelineInput) error {
 			if step.HFDownloadDataset == nil || step.HFDownloadDataset.DatasetID == "" {
 				return fmt.Errorf("step %s hf_download_dataset requires dataset_id", step.ID)
 			}
+			if workers := step.HFDownloadDataset.MaxWorkers; workers < 0 || workers > maxHFWorkers {
+				return fmt.Errorf("step %s hf_download_dataset max_workers must be between 1 and %d", step.ID, maxHFWorkers)
+			}
+			for _, pattern := range step.HFDownloadDataset.AllowPatterns {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("step %s hf_download_dataset has invalid allow_patterns entry %q", step.ID, pattern)
+				}
+			}
 		case "hf_download_model":
 			if step.HFDownloadModel == nil || step.HFDownloadModel.ModelID == "" {
 				return fmt.Errorf("step %s hf_download_model requires model_id", step.ID)
 			}
+		case "watch_path":
+			spec := step.WatchPath
+			if spec == nil || spec.Path == "" {
+				return fmt.Errorf("step %s watch_path requires path", step.ID)
+			}
+			if _, err := filepath.Match(spec.Path, ""); err != nil {
+				return fmt.Errorf("step %s watch_path has invalid path pattern: %v", step.ID, err)
+			}
+			if spec.Condition != "" && spec.Condition != "exists" && spec.Condition != "changed" {
+				return fmt.Errorf("step %s watch_path condition must be exists or changed", step.ID)
+			}
+			if spec.PollSeconds < 0 {
+				return fmt.Errorf("step %s watch_path poll_seconds must not be negative", step.ID)
+			}
+		case "join":
+			if err := validateJoin(*step); err != nil {
+				return err
+			}
 		}
 	}
 
+	switch input.ExecutionMode {
+	case "", workflows.ExecutionAtLeastOnce, workflows.ExecutionAtMostOnce:
+	default:
+		return fmt.Errorf("execution_mode must be at_least_once or at_most_once")
+	}
+
+	for name := range input.Params {
+		if name == "" {
+			return fmt.Errorf("params must not contain an empty name")
+		}
+	}
+	if err := validateParamSchema(input.Parameters); err != nil {
+		return err
+	}
+	if _, err := workflows.ApplyParamSchema(input.Parameters, input.Params); err != nil {
+		return err
+	}
+	if schedule := input.Schedule; schedule != nil {
+		if schedule.Cron == "" {
+			return fmt.Errorf("schedule.cron is required")
+		}
+		for name, ref := range schedule.CarryOutputs {
+			stepID, key, ok := strings.Cut(ref, ".")
+			if !ok || key == "" || !ids[stepID] {
+				return fmt.Errorf("schedule.carry_outputs.%s must reference <step-id>.<output> of a known step, got %q", name, ref)
+			}
+			if len(input.Parameters) > 0 && !slices.ContainsFunc(input.Parameters, func(p workflows.ParamSpec) bool { return p.Name == name }) {
+				return fmt.Errorf("schedule.carry_outputs.%s is not a declared parameter", name)
+			}
+		}
+		if _, err := schedule.Location(); err != nil {
+			return err
+		}
+		for i, window := range schedule.Blackouts {
+			if err := window.Validate(); err != nil {
+				return fmt.Errorf("schedule.blackouts[%d]: %w", i, err)
+			}
+		}
+		for _, holiday := range schedule.Holidays {
+			if _, _, err := workflows.ParseHoliday(holiday); err != nil {
+				return fmt.Errorf("schedule.holidays: %w", err)
+			}
+		}
+		switch schedule.OnBlackout {
+		case "", workflows.BlackoutSkip, workflows.BlackoutDefer:
+		default:
+			return fmt.Errorf("schedule.on_blackout must be %s or %s, got %q", workflows.BlackoutSkip, workflows.BlackoutDefer, schedule.OnBlackout)
+		}
+	}
+
+	for i, requirement := range input.Require {
+		if err := validateRequirement(requirement); err != nil {
+			return fmt.Errorf("require[%d]: %w", i, err)
+		}
+	}
+
+	for i, hook := range input.Webhooks {
+		if err := validateWebhook(hook); err != nil {
+			return fmt.Errorf("webhooks[%d]: %w", i, err)
+		}
+	}
+
+	if policy := input.Golden; policy != nil {
+		if input.Name == "" {
+			return fmt.Errorf("golden requires the plan to have a name")
+		}
+		if policy.DurationTolerance < 0 {
+			return fmt.Errorf("golden.duration_tolerance must not be negative")
+		}
+	}
+
+	if policy := input.IdlePolicy; policy != nil {
+		if policy.TimeoutHours <= 0 {
+			return fmt.Errorf("idle_policy.timeout_hours must be positive")
+		}
+		switch policy.Action {
+		case "", "cancel":
+		case "notify_then_cancel":
+			if policy.GraceHours <= 0 {
+				return fmt.Errorf("idle_policy.grace_hours must be positive for notify_then_cancel")
+			}
+		default:
+			return fmt.Errorf("idle_policy.action must be cancel or notify_then_cancel")
+		}
+	}
+
+	if err := input.Scheduling.Validate(input.Steps); err != nil {
+		return err
+	}
+	if err := workflows.ValidateCanaries(input.Steps); err != nil {
+		return err
+	}
+	if err := input.Locale.Validate(); err != nil {
+		return err
+	}
+
+	if breaker := input.CircuitBreaker; breaker != nil {
+		if breaker.Failures < 0 {
+			return fmt.Errorf("circuit_breaker.failures must not be negative")
+		}
+		if breaker.WindowHours < 0 {
+			return fmt.Errorf("circuit_breaker.window_hours must not be negative")
+		}
+	}
+
+	downloads := map[string]bool{}
+	for _, step := range input.Steps {
+		downloads[step.ID] = step.Type == "download"
+	}
 	for _, step := range input.Steps {
 		for _, dep := range step.DependsOn {
 			if !ids[dep] {
 				return fmt.Errorf("step %s depends on unknown step %s", step.ID, dep)
 			}
 		}
+		if step.VerifyBeforeUse {
+			switch step.Type {
+			case "command", "docker_build", "package_build", "container_job":
+			default:
+				return fmt.Errorf("step %s: %s steps cannot use verify_before_use", step.ID, step.Type)
+			}
+			if !slices.ContainsFunc(step.DependsOn, func(dep string) bool { return downloads[dep] }) {
+				return fmt.Errorf("step %s: verify_before_use needs a download step in depends_on", step.ID)
+			}
+		}
+		if step.DockerBuild != nil {
+			if err := validateDockerBuildTemplates(step, input); err != nil {
+				return err
+			}
+		}
 		if step.When != nil {
-			if step.When.Step == "" || (step.When.Status != "success" && step.When.Status != "failure") {
+			validStatus := step.When.Status == "success" || step.When.Status == "failure" ||
+				(step.When.Status == "" && step.When.Metric != "")
+			if step.When.Step == "" || !validStatus {
 				return fmt.Errorf("step %s has invalid when condition", step.ID)
 			}
+			if step.When.Metric != "" {
+				if _, err := workflows.ParseMetricCondition(step.When.Metric); err != nil {
+					return fmt.Errorf("step %s has invalid when condition: %w", step.ID, err)
+				}
+			}
 			if !ids[step.When.Step] {
 				return fmt.Errorf("step %s when references unknown step %s", step.ID, step.When.Step)
 			}
@@ -173,9 +562,191 @@ func validatePlan(input *workflows.PipelineInput) error {
 	return nil
 }
 
+// validateDockerBuildTemplates checks that ${params.*} references name a known
+// parameter and ${steps.<id>.outputs.*} references a direct dependency, so the
+// value exists by the time the build starts.
+func validateDockerBuildTemplates(step workflows.PipelineStep, input *workflows.PipelineInput) error {
+	spec := step.DockerBuild
+	fields := []string{spec.Image, spec.Target, spec.Platform, spec.Dockerfile}
+	for _, value := range spec.BuildArgs {
+		fields = append(fields, value)
+	}
+	for _, value := range spec.Labels {
+		fields = append(fields, value)
+	}
+	for _, field := range fields {
+		for _, ref := range workflows.TemplateRefs(field) {
+			kind, stepID, name, ok := workflows.ParseTemplateRef(ref)
+			if !ok {
+				return fmt.Errorf("step %s: malformed reference ${%s}", step.ID, ref)
+			}
+			if kind == "params" {
+				_, inParams := input.Params[name]
+				declared := slices.ContainsFunc(input.Parameters, func(p workflows.ParamSpec) bool { return p.Name == name })
+				if !inParams && !declared {
+					return fmt.Errorf("step %s: ${%s} references an unknown parameter", step.ID, ref)
+				}
+				if activities.IsSealed(input.Params[name]) {
+					return fmt.Errorf("step %s: ${%s} is sealed; sealed params reach steps only as SYGALDRY_PARAM_* env", step.ID, ref)
+				}
+				continue
+			}
+			if !slices.Contains(step.DependsOn, stepID) {
+				return fmt.Errorf("step %s: ${%s} must reference a step in depends_on", step.ID, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// validateDockerHost checks a docker_host: ssh:// hosts use the worker's SSH
+// setup, and tcp:// hosts must name all three TLS secret envs.
+func validateDockerHost(host *workflows.DockerHostSpec) error {
+	if host == nil {
+		return nil
+	}
+	parsed, err := url.Parse(host.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "ssh" && parsed.Scheme != "tcp") {
+		return fmt.Errorf("docker_host.url %q must be an ssh:// or tcp:// URL", host.URL)
+	}
+	envs := []string{host.CAEnv, host.CertEnv, host.KeyEnv}
+	if parsed.Scheme == "ssh" {
+		if slices.ContainsFunc(envs, func(env string) bool { return env != "" }) {
+			return fmt.Errorf("docker_host %s: TLS envs only apply to tcp:// hosts", host.URL)
+		}
+		return nil
+	}
+	if slices.Contains(envs, "") {
+		return fmt.Errorf("docker_host %s: tcp:// hosts require ca_env, cert_env and key_env", host.URL)
+	}
+	return nil
+}
+
+// validateJoin checks a join step's outputs. Only steps in depends_on can be
+// joined, so their outputs exist by the time the join runs.
+func validateJoin(step workflows.PipelineStep) error {
+	if step.Join == nil || len(step.Join.Outputs) == 0 {
+		return fmt.Errorf("step %s join requires outputs", step.ID)
+	}
+	if len(step.DependsOn) == 0 {
+		return fmt.Errorf("step %s: join steps require depends_on", step.ID)
+	}
+	for name, output := range step.Join.Outputs {
+		if name == "" || strings.ContainsAny(name, "=\n") {
+			return fmt.Errorf("step %s join has invalid output name %q", step.ID, name)
+		}
+		if output.From == "" {
+			return fmt.Errorf("step %s join output %s requires from", step.ID, name)
+		}
+		if err := workflows.ValidateAggregate(output.Aggregate); err != nil {
+			return fmt.Errorf("step %s join output %s: %w", step.ID, name, err)
+		}
+		for _, pattern := range output.Steps {
+			matched := slices.ContainsFunc(step.DependsOn, func(dep string) bool {
+				ok, _ := path.Match(pattern, dep)
+				return ok
+			})
+			if !matched {
+				return fmt.Errorf("step %s join output %s: %q matches no step in depends_on", step.ID, name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func validateParamSchema(schema []workflows.ParamSpec) error {
+	names := map[string]bool{}
+	for i, spec := range schema {
+		if spec.Name == "" {
+			return fmt.Errorf("parameters[%d] is missing name", i)
+		}
+		if names[spec.Name] {
+			return fmt.Errorf("duplicate parameter: %s", spec.Name)
+		}
+		names[spec.Name] = true
+		if !workflows.ParamTypes[spec.Type] {
+			return fmt.Errorf("parameter %s has unsupported type %s", spec.Name, spec.Type)
+		}
+		for _, value := range spec.Enum {
+			if err := workflows.CheckParamValue(workflows.ParamSpec{Name: spec.Name, Type: spec.Type}, value); err != nil {
+				return fmt.Errorf("enum: %w", err)
+			}
+		}
+		if spec.Default != "" {
+			if spec.Required {
+				return fmt.Errorf("parameter %s cannot be both required and have a default", spec.Name)
+			}
+			if err := workflows.CheckParamValue(spec, spec.Default); err != nil {
+				return fmt.Errorf("default: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateRequirement(r workflows.Requirement) error {
+	kinds := 0
+	if r.HTTP != nil {
+		kinds++
+		if r.HTTP.URL == "" {
+			return fmt.Errorf("http requires url")
+		}
+	}
+	if r.Image != nil {
+		kinds++
+		if r.Image.Ref == "" {
+			return fmt.Errorf("image requires ref")
+		}
+	}
+	if r.HFRevision != nil {
+		kinds++
+		if r.HFRevision.RepoID == "" || r.HFRevision.Revision == "" {
+			return fmt.Errorf("hf_revision requires repo_id and revision")
+		}
+		if t := r.HFRevision.RepoType; t != "" && t != "dataset" && t != "model" {
+			return fmt.Errorf("hf_revision repo_type must be dataset or model")
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of http, image or hf_revision is required")
+	}
+	return nil
+}
+
+func validateWebhook(hook workflows.WebhookSpec) error {
+	target, err := url.Parse(hook.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	for _, field := range hook.Fields {
+		if !workflows.ValidWebhookField(field) {
+			return fmt.Errorf("unknown result field %q", field)
+		}
+	}
+	return nil
+}
+
 func envOr(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return fallback
 }
+
+// validateCaptureEntry checks one capture_on_failure path, glob or
+// docker-logs:<container> entry.
+func validateCaptureEntry(entry string) error {
+	if container, ok := strings.CutPrefix(entry, activities.DockerLogsPrefix); ok {
+		if strings.TrimSpace(container) == "" {
+			return fmt.Errorf("%q names no container", entry)
+		}
+		return nil
+	}
+	if strings.TrimSpace(entry) == "" {
+		return errors.New("empty entry")
+	}
+	if _, err := filepath.Match(entry, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", entry, err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -54,7 +56,7 @@ func TestValidatePlanUnsupportedType(t *testing.T) {
 }
 
 func TestValidatePlanAllTypes(t *testing.T) {
-	for typ := range allowedTypes {
+	for _, typ := range workflows.StepTypes() {
 		t.Run(typ, func(t *testing.T) {
 			step := workflows.PipelineStep{ID: typ + "-step", Type: typ}
 			// Provide required fields per type
@@ -75,8 +77,16 @@ func TestValidatePlanAllTypes(t *testing.T) {
 				step.HFDownloadDataset = &workflows.HFDownloadDatasetSpec{DatasetID: "ns/ds"}
 			case "hf_download_model":
 				step.HFDownloadModel = &workflows.HFDownloadModelSpec{ModelID: "ns/model"}
+			case "watch_path":
+				step.WatchPath = &workflows.WatchPathSpec{Path: "/tmp/ready"}
+			case "join":
+				step.DependsOn = []string{"shard"}
+				step.Join = &workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows"}}}
 			}
 			input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{step}}
+			if typ == "join" {
+				input.Steps = append([]workflows.PipelineStep{{ID: "shard", Type: "command", Command: "echo"}}, step)
+			}
 			if err := validatePlan(input); err != nil {
 				t.Errorf("valid %s step failed: %v", typ, err)
 			}
@@ -100,6 +110,12 @@ func TestValidatePlanMissingRequiredFields(t *testing.T) {
 		{"container_job nil", workflows.PipelineStep{ID: "a", Type: "container_job"}, "container_job requires command"},
 		{"hf_download_dataset nil", workflows.PipelineStep{ID: "a", Type: "hf_download_dataset"}, "hf_download_dataset requires dataset_id"},
 		{"hf_download_model nil", workflows.PipelineStep{ID: "a", Type: "hf_download_model"}, "hf_download_model requires model_id"},
+		{"output_truncation unknown", workflows.PipelineStep{ID: "a", Type: "command", Command: "make", OutputTruncation: "middle"}, "output_truncation must be head, tail or head_tail"},
+		{"output_truncation download", workflows.PipelineStep{ID: "a", Type: "download", Download: &workflows.DownloadSpec{URL: "http://x", Output: "/tmp/x"}, OutputTruncation: "tail"}, "download steps cannot use output_truncation"},
+		{"profile tool", workflows.PipelineStep{ID: "a", Type: "command", Command: "make", Profile: &workflows.ProfileSpec{Tool: "valgrind"}}, "profile.tool must be py-spy or perf"},
+		{"profile download", workflows.PipelineStep{ID: "a", Type: "download", Download: &workflows.DownloadSpec{URL: "http://x", Output: "/tmp/x"}, Profile: &workflows.ProfileSpec{Tool: "perf"}}, "download steps cannot use profile"},
+		{"hf_download_dataset max_workers", workflows.PipelineStep{ID: "a", Type: "hf_download_dataset", HFDownloadDataset: &workflows.HFDownloadDatasetSpec{DatasetID: "ns/ds", MaxWorkers: 64}}, "max_workers must be between 1 and 32"},
+		{"hf_download_dataset allow_patterns", workflows.PipelineStep{ID: "a", Type: "hf_download_dataset", HFDownloadDataset: &workflows.HFDownloadDatasetSpec{DatasetID: "ns/ds", AllowPatterns: []string{"["}}}, "invalid allow_patterns entry"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -188,6 +204,22 @@ func TestValidatePlanWhenClause(t *testing.T) {
 		}
 	})
 
+	t.Run("when on metric", func(t *testing.T) {
+		input := &workflows.PipelineInput{
+			Steps: []workflows.PipelineStep{
+				{ID: "train", Type: "command", Command: "echo"},
+				{ID: "publish", Type: "command", Command: "echo", DependsOn: []string{"train"}, When: &workflows.When{Step: "train", Metric: "loss < 0.5"}},
+			},
+		}
+		if err := validatePlan(input); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		input.Steps[1].When.Metric = "loss ~ 0.5"
+		if err := validatePlan(input); err == nil || !strings.Contains(err.Error(), "invalid when") {
+			t.Errorf("expected invalid when error, got: %v", err)
+		}
+	})
+
 	t.Run("when missing step field", func(t *testing.T) {
 		input := &workflows.PipelineInput{
 			Steps: []workflows.PipelineStep{
@@ -214,6 +246,407 @@ func TestValidatePlanNameDefaulting(t *testing.T) {
 	}
 }
 
+func TestValidatePlanLocalArtifacts(t *testing.T) {
+	input := &workflows.PipelineInput{
+		Steps: []workflows.PipelineStep{
+			{ID: "a", Type: "command", Command: "echo", LocalArtifacts: []string{"/data/shards", ""}},
+		},
+	}
+	if err := validatePlan(input); err == nil || !strings.Contains(err.Error(), "empty local_artifacts") {
+		t.Errorf("expected empty local_artifacts error, got: %v", err)
+	}
+
+	input.Steps[0].LocalArtifacts = []string{"/data/shards"}
+	if err := validatePlan(input); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidatePlanCaptureOnFailure(t *testing.T) {
+	tests := []struct {
+		step    workflows.PipelineStep
+		wantErr bool
+	}{
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "x", CaptureOnFailure: []string{"/tmp/core.*", "/work/tmp", "docker-logs:trainer"}}, false},
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "x", CaptureOnFailure: []string{""}}, true},
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "x", CaptureOnFailure: []string{"/tmp/[bad"}}, true},
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "x", CaptureOnFailure: []string{"docker-logs:"}}, true},
+		{workflows.PipelineStep{ID: "a", Type: "approval", CaptureOnFailure: []string{"/tmp"}}, true},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{tt.step}}
+		if err := validatePlan(input); (err != nil) != tt.wantErr {
+			t.Errorf("capture_on_failure %v on %s: err = %v, wantErr %v", tt.step.CaptureOnFailure, tt.step.Type, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePlanRunList(t *testing.T) {
+	stop := false
+	tests := []struct {
+		step    workflows.PipelineStep
+		wantErr bool
+	}{
+		{workflows.PipelineStep{ID: "a", Type: "command", Run: []string{"make deps", "make test"}}, false},
+		{workflows.PipelineStep{ID: "a", Type: "command", Run: []string{"make test"}, StopOnFailure: &stop}, false},
+		{workflows.PipelineStep{ID: "a", Type: "command", Run: []string{"make test"}, Command: "make"}, true},
+		{workflows.PipelineStep{ID: "a", Type: "command", Run: []string{"make test", ""}}, true},
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "make", StopOnFailure: &stop}, true},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{tt.step}}
+		if err := validatePlan(input); (err != nil) != tt.wantErr {
+			t.Errorf("step %+v: err = %v, wantErr %v", tt.step, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePlanRateLimits(t *testing.T) {
+	tests := []struct {
+		step    workflows.PipelineStep
+		wantErr bool
+	}{
+		{workflows.PipelineStep{ID: "a", Type: "hf_download_model", HFDownloadModel: &workflows.HFDownloadModelSpec{ModelID: "m"}, RateLimits: []string{"hf-api"}}, false},
+		{workflows.PipelineStep{ID: "a", Type: "command", Command: "x", RateLimits: []string{"HF API"}}, true},
+		{workflows.PipelineStep{ID: "a", Type: "approval", RateLimits: []string{"hf-api"}}, true},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{tt.step}}
+		if err := validatePlan(input); (err != nil) != tt.wantErr {
+			t.Errorf("rate_limits %v on %s: err = %v, wantErr %v", tt.step.RateLimits, tt.step.Type, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePlanGolden(t *testing.T) {
+	steps := []workflows.PipelineStep{{ID: "a", Type: "command", Command: "x"}}
+	if err := validatePlan(&workflows.PipelineInput{Golden: &workflows.GoldenPolicy{}, Steps: steps}); err == nil {
+		t.Error("golden without a plan name should fail")
+	}
+	if err := validatePlan(&workflows.PipelineInput{Name: "release", Golden: &workflows.GoldenPolicy{DurationTolerance: -1}, Steps: steps}); err == nil {
+		t.Error("negative duration_tolerance should fail")
+	}
+	if err := validatePlan(&workflows.PipelineInput{Name: "release", Golden: &workflows.GoldenPolicy{DurationTolerance: 0.2}, Steps: steps}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidatePlanNetwork(t *testing.T) {
+	tests := []struct {
+		name    string
+		step    workflows.PipelineStep
+		wantErr bool
+	}{
+		{"command none", workflows.PipelineStep{ID: "a", Type: "command", Command: "echo", Network: "none"}, false},
+		{"command proxy-only", workflows.PipelineStep{ID: "a", Type: "command", Command: "echo", Network: "proxy-only"}, true},
+		{"container_job proxy-only", workflows.PipelineStep{ID: "a", Type: "container_job", ContainerJob: &workflows.ContainerJobSpec{Command: "x"}, Network: "proxy-only"}, false},
+		{"download none", workflows.PipelineStep{ID: "a", Type: "download", Download: &workflows.DownloadSpec{URL: "http://x", Output: "/tmp/x"}, Network: "none"}, true},
+		{"download host", workflows.PipelineStep{ID: "a", Type: "download", Download: &workflows.DownloadSpec{URL: "http://x", Output: "/tmp/x"}, Network: "host"}, false},
+		{"unknown mode", workflows.PipelineStep{ID: "a", Type: "command", Command: "echo", Network: "bridge"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{Steps: []workflows.PipelineStep{tt.step}})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validatePlan() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanIdlePolicy(t *testing.T) {
+	steps := []workflows.PipelineStep{{ID: "gate", Type: "approval"}}
+	tests := []struct {
+		name    string
+		policy  *workflows.IdlePolicy
+		wantErr string
+	}{
+		{"no policy", nil, ""},
+		{"cancel", &workflows.IdlePolicy{TimeoutHours: 24}, ""},
+		{"notify then cancel", &workflows.IdlePolicy{TimeoutHours: 24, Action: "notify_then_cancel", GraceHours: 4}, ""},
+		{"missing timeout", &workflows.IdlePolicy{Action: "cancel"}, "timeout_hours"},
+		{"missing grace", &workflows.IdlePolicy{TimeoutHours: 24, Action: "notify_then_cancel"}, "grace_hours"},
+		{"unknown action", &workflows.IdlePolicy{TimeoutHours: 24, Action: "page"}, "idle_policy.action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{IdlePolicy: tt.policy, Steps: steps})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanSchedule(t *testing.T) {
+	steps := []workflows.PipelineStep{{ID: "ingest", Type: "command", Command: "echo"}}
+	tests := []struct {
+		name     string
+		schedule *workflows.ScheduleSpec
+		wantErr  string
+	}{
+		{"valid", &workflows.ScheduleSpec{Cron: "0 2 * * *", CarryOutputs: map[string]string{"offset": "ingest.offset"}}, ""},
+		{"missing cron", &workflows.ScheduleSpec{}, "schedule.cron"},
+		{"unknown step", &workflows.ScheduleSpec{Cron: "@daily", CarryOutputs: map[string]string{"offset": "ghost.offset"}}, "carry_outputs.offset"},
+		{"missing output", &workflows.ScheduleSpec{Cron: "@daily", CarryOutputs: map[string]string{"offset": "ingest"}}, "carry_outputs.offset"},
+		{"blackouts", &workflows.ScheduleSpec{Cron: "@daily", Timezone: "Europe/Berlin", Blackouts: []workflows.BlackoutWindow{{Start: "Fri 18:00", End: "Mon 06:00"}}, Holidays: []string{"2025-12-25 Christmas"}, OnBlackout: "defer"}, ""},
+		{"unknown timezone", &workflows.ScheduleSpec{Cron: "@daily", Timezone: "Mars/Olympus"}, "schedule.timezone"},
+		{"bad window", &workflows.ScheduleSpec{Cron: "@daily", Blackouts: []workflows.BlackoutWindow{{Start: "Fri 18:00"}}}, "schedule.blackouts[0]"},
+		{"bad holiday", &workflows.ScheduleSpec{Cron: "@daily", Holidays: []string{"25/12/2025"}}, "schedule.holidays"},
+		{"bad action", &workflows.ScheduleSpec{Cron: "@daily", OnBlackout: "queue"}, "on_blackout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{Schedule: tt.schedule, Steps: steps})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRequirement(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     workflows.Requirement
+		wantErr bool
+	}{
+		{"http", workflows.Requirement{HTTP: &workflows.HTTPRequirement{URL: "http://x/healthz"}}, false},
+		{"image", workflows.Requirement{Image: &workflows.ImageRequirement{Ref: "org/img:v1"}}, false},
+		{"hf", workflows.Requirement{HFRevision: &workflows.HFRevisionRequirement{RepoID: "org/ds", Revision: "main"}}, false},
+		{"empty", workflows.Requirement{Name: "nothing"}, true},
+		{"two kinds", workflows.Requirement{HTTP: &workflows.HTTPRequirement{URL: "http://x"}, Image: &workflows.ImageRequirement{Ref: "x"}}, true},
+		{"http without url", workflows.Requirement{HTTP: &workflows.HTTPRequirement{}}, true},
+		{"hf bad repo type", workflows.Requirement{HFRevision: &workflows.HFRevisionRequirement{RepoID: "x", Revision: "y", RepoType: "space"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateRequirement(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("validateRequirement() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    workflows.WebhookSpec
+		wantErr bool
+	}{
+		{"valid", workflows.WebhookSpec{URL: "https://registry.internal/hooks/pipeline", Fields: []string{"status", "steps"}}, false},
+		{"relative url", workflows.WebhookSpec{URL: "/hooks"}, true},
+		{"bad scheme", workflows.WebhookSpec{URL: "ftp://host/x"}, true},
+		{"unknown field", workflows.WebhookSpec{URL: "http://host/x", Fields: []string{"stdout"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateWebhook(tt.hook); (err != nil) != tt.wantErr {
+				t.Errorf("validateWebhook() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanDockerBuildTemplates(t *testing.T) {
+	build := func(image string, deps ...string) *workflows.PipelineInput {
+		return &workflows.PipelineInput{
+			Params: map[string]string{"env": "dev"},
+			Steps: []workflows.PipelineStep{
+				{ID: "version", Type: "command", Command: "echo"},
+				{ID: "image", Type: "docker_build", DependsOn: deps, DockerBuild: &workflows.DockerBuildSpec{Image: image}},
+			},
+		}
+	}
+	if err := validatePlan(build("org/app:${steps.version.outputs.tag}-${params.env}", "version")); err != nil {
+		t.Errorf("valid templates rejected: %v", err)
+	}
+	for image, want := range map[string]string{
+		"org/app:${params.tier}":               "unknown parameter",
+		"org/app:${steps.version.outputs.tag}": "depends_on",
+		"org/app:${version.tag}":               "malformed",
+	} {
+		if err := validatePlan(build(image)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("image %q: error = %v, want %q", image, err, want)
+		}
+	}
+}
+
+func TestValidatePlanWatchPath(t *testing.T) {
+	tests := []struct {
+		spec    *workflows.WatchPathSpec
+		wantErr bool
+	}{
+		{&workflows.WatchPathSpec{Path: "/shared/drop/*.parquet"}, false},
+		{&workflows.WatchPathSpec{Path: "/shared/ready", Condition: "changed", PollSeconds: 30}, false},
+		{nil, true},
+		{&workflows.WatchPathSpec{Path: "/shared/[bad"}, true},
+		{&workflows.WatchPathSpec{Path: "/shared/ready", Condition: "deleted"}, true},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{{ID: "wait", Type: "watch_path", WatchPath: tt.spec}}}
+		if err := validatePlan(input); (err != nil) != tt.wantErr {
+			t.Errorf("spec %+v: error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePlanDockerHost(t *testing.T) {
+	tests := []struct {
+		host    *workflows.DockerHostSpec
+		wantErr bool
+	}{
+		{&workflows.DockerHostSpec{URL: "ssh://builder@build-01"}, false},
+		{&workflows.DockerHostSpec{URL: "tcp://build-01:2376", CAEnv: "CA", CertEnv: "CERT", KeyEnv: "KEY"}, false},
+		{&workflows.DockerHostSpec{URL: "tcp://build-01:2376", CAEnv: "CA"}, true},
+		{&workflows.DockerHostSpec{URL: "ssh://builder@build-01", KeyEnv: "KEY"}, true},
+		{&workflows.DockerHostSpec{URL: "build-01:2376"}, true},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{
+			{ID: "build", Type: "docker_build", DockerBuild: &workflows.DockerBuildSpec{Image: "app:dev", DockerHost: tt.host}},
+			{ID: "push", Type: "docker_push", DependsOn: []string{"build"}, DockerPush: &workflows.DockerPushSpec{Image: "app:dev", DockerHost: tt.host}},
+		}}
+		if err := validatePlan(input); (err != nil) != tt.wantErr {
+			t.Errorf("docker_host %+v: error = %v, wantErr %v", tt.host, err, tt.wantErr)
+		}
+	}
+
+	pooled := &workflows.PipelineInput{Steps: []workflows.PipelineStep{{ID: "build", Type: "docker_build", DockerBuild: &workflows.DockerBuildSpec{
+		Image: "app:dev", BuilderPool: true, DockerHost: &workflows.DockerHostSpec{URL: "ssh://builder@build-01"},
+	}}}}
+	if err := validatePlan(pooled); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("builder_pool with docker_host: error = %v", err)
+	}
+}
+
+func TestValidatePlanJoin(t *testing.T) {
+	tests := []struct {
+		join      *workflows.JoinSpec
+		dependsOn []string
+		want      string
+	}{
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows", Aggregate: "sum"}, "ids": {From: "id", Steps: []string{"shard-*"}, Aggregate: "unique | join(;)"}}}, []string{"shard-0", "shard-1"}, ""},
+		{nil, []string{"shard-0"}, "join requires outputs"},
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows"}}}, nil, "require depends_on"},
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {}}}, []string{"shard-0"}, "requires from"},
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows", Aggregate: "median"}}}, []string{"shard-0"}, "unknown stage"},
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows", Aggregate: "sum | sort"}}}, []string{"shard-0"}, "single value"},
+		{&workflows.JoinSpec{Outputs: map[string]workflows.JoinOutput{"rows": {From: "rows", Steps: []string{"eval-*"}}}}, []string{"shard-0"}, "matches no step"},
+	}
+	for _, tt := range tests {
+		input := &workflows.PipelineInput{Steps: []workflows.PipelineStep{
+			{ID: "shard-0", Type: "command", Command: "echo"},
+			{ID: "shard-1", Type: "command", Command: "echo"},
+			{ID: "total", Type: "join", DependsOn: tt.dependsOn, Join: tt.join},
+		}}
+		err := validatePlan(input)
+		if tt.want == "" && err != nil || tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+			t.Errorf("join %+v: error = %v, want %q", tt.join, err, tt.want)
+		}
+	}
+}
+
+func TestValidatePlanExecutionMode(t *testing.T) {
+	for mode, wantErr := range map[string]bool{"": false, "at_least_once": false, "at_most_once": false, "exactly_once": true} {
+		input := &workflows.PipelineInput{ExecutionMode: mode, Steps: []workflows.PipelineStep{{ID: "a", Type: "command", Command: "echo"}}}
+		if err := validatePlan(input); (err != nil) != wantErr {
+			t.Errorf("execution_mode %q: error = %v, wantErr %v", mode, err, wantErr)
+		}
+	}
+}
+
+func TestValidatePlanScheduling(t *testing.T) {
+	gpu := func(n int) workflows.PipelineStep {
+		return workflows.PipelineStep{ID: "train", Type: "command", Command: "train", Resources: map[string]int{"gpu": n}}
+	}
+	tests := []struct {
+		name       string
+		scheduling *workflows.SchedulingSpec
+		step       workflows.PipelineStep
+		wantErr    string
+	}{
+		{"none", nil, workflows.PipelineStep{ID: "a", Type: "command", Command: "make"}, ""},
+		{"critical path", &workflows.SchedulingSpec{Strategy: "critical_path", MaxParallel: 2}, workflows.PipelineStep{ID: "a", Type: "command", Command: "make"}, ""},
+		{"packing", &workflows.SchedulingSpec{Strategy: "resource_packing", Resources: map[string]int{"gpu": 4}}, gpu(4), ""},
+		{"unknown strategy", &workflows.SchedulingSpec{Strategy: "random"}, gpu(0), "scheduling.strategy"},
+		{"negative max_parallel", &workflows.SchedulingSpec{MaxParallel: -1}, workflows.PipelineStep{ID: "a", Type: "command", Command: "make"}, "max_parallel"},
+		{"packing without resources", &workflows.SchedulingSpec{Strategy: "resource_packing"}, workflows.PipelineStep{ID: "a", Type: "command", Command: "make"}, "requires scheduling.resources"},
+		{"zero capacity", &workflows.SchedulingSpec{Resources: map[string]int{"gpu": 0}}, workflows.PipelineStep{ID: "a", Type: "command", Command: "make"}, "scheduling.resources.gpu must be positive"},
+		{"undeclared resource", nil, gpu(1), "does not declare"},
+		{"over capacity", &workflows.SchedulingSpec{Resources: map[string]int{"gpu": 4}}, gpu(8), "claims 8 gpu"},
+		{"approval", &workflows.SchedulingSpec{Resources: map[string]int{"gpu": 4}}, workflows.PipelineStep{ID: "gate", Type: "approval", Resources: map[string]int{"gpu": 1}}, "approval steps cannot claim resources"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{Scheduling: tt.scheduling, Steps: []workflows.PipelineStep{tt.step}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanVerifyBeforeUse(t *testing.T) {
+	fetch := workflows.PipelineStep{ID: "fetch", Type: "download", Download: &workflows.DownloadSpec{URL: "https://example.com/x", Output: "x"}}
+	prep := workflows.PipelineStep{ID: "prep", Type: "command", Command: "prep"}
+	tests := []struct {
+		name    string
+		step    workflows.PipelineStep
+		wantErr string
+	}{
+		{"command", workflows.PipelineStep{ID: "train", Type: "command", Command: "train", DependsOn: []string{"prep", "fetch"}, VerifyBeforeUse: true}, ""},
+		{"no download dependency", workflows.PipelineStep{ID: "train", Type: "command", Command: "train", DependsOn: []string{"prep"}, VerifyBeforeUse: true}, "needs a download step in depends_on"},
+		{"docker_push", workflows.PipelineStep{ID: "push", Type: "docker_push", DockerPush: &workflows.DockerPushSpec{Image: "x"}, DependsOn: []string{"fetch"}, VerifyBeforeUse: true}, "docker_push steps cannot use verify_before_use"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{Steps: []workflows.PipelineStep{fetch, prep, tt.step}})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanCircuitBreaker(t *testing.T) {
+	push := workflows.PipelineStep{ID: "push", Type: "docker_push", DockerPush: &workflows.DockerPushSpec{Image: "ghcr.io/org/app"}, CircuitKey: "ghcr"}
+	if err := validatePlan(&workflows.PipelineInput{CircuitBreaker: &workflows.CircuitBreaker{Failures: 3, WindowHours: 12}, Steps: []workflows.PipelineStep{push}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validatePlan(&workflows.PipelineInput{CircuitBreaker: &workflows.CircuitBreaker{Failures: -1}, Steps: []workflows.PipelineStep{push}}); err == nil || !strings.Contains(err.Error(), "circuit_breaker.failures") {
+		t.Errorf("error = %v, want negative failures rejected", err)
+	}
+	gate := workflows.PipelineStep{ID: "gate", Type: "approval", CircuitKey: "ghcr"}
+	if err := validatePlan(&workflows.PipelineInput{Steps: []workflows.PipelineStep{gate}}); err == nil || !strings.Contains(err.Error(), "approval steps cannot use circuit_key") {
+		t.Errorf("error = %v, want circuit_key rejected on approval", err)
+	}
+}
+
 func TestEnvOr(t *testing.T) {
 	t.Setenv("TEST_ENV_OR_KEY", "from_env")
 	if got := envOr("TEST_ENV_OR_KEY", "fallback"); got != "from_env" {
@@ -223,3 +656,69 @@ func TestEnvOr(t *testing.T) {
 		t.Errorf("envOr with missing var = %q, want 'fallback'", got)
 	}
 }
+
+func TestLoadPlanReadsHolidayCalendar(t *testing.T) {
+	dir := t.TempDir()
+	plan := "schedule:\n  cron: \"@daily\"\n  holidays: [\"2025-01-01\"]\n  holiday_calendar: holidays.txt\nsteps:\n  - id: ingest\n    type: command\n    command: echo\n"
+	if err := os.WriteFile(filepath.Join(dir, "plan.yaml"), []byte(plan), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "holidays.txt"), []byte("# company holidays\n2025-12-25 Christmas\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input, err := loadPlan(filepath.Join(dir, "plan.yaml"), nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := input.Schedule.Holidays; len(got) != 2 || got[1] != "2025-12-25 Christmas" {
+		t.Errorf("holidays = %q", got)
+	}
+}
+
+func TestValidatePlanCanary(t *testing.T) {
+	check := workflows.PipelineStep{ID: "check", Type: "command", Command: "check"}
+	deploy := func(canary *workflows.CanarySpec) workflows.PipelineStep {
+		return workflows.PipelineStep{ID: "deploy", Type: "command", Command: "deploy", Canary: canary}
+	}
+	tests := []struct {
+		name    string
+		steps   []workflows.PipelineStep
+		wantErr string
+	}{
+		{"valid", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 10, Evaluate: "check", Promote: "error_rate < 0.01"}), check}, ""},
+		{"percent", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 100, Evaluate: "check"}), check}, "canary.percent"},
+		{"unknown evaluate", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 10, Evaluate: "verify"})}, "canary.evaluate"},
+		{"evaluate after step", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 10, Evaluate: "check"}), {ID: "check", Type: "command", Command: "check", DependsOn: []string{"deploy"}}}, "cannot depend on it"},
+		{"bad promote", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 10, Evaluate: "check", Promote: "healthy"}), check}, "canary.promote"},
+		{"id taken", []workflows.PipelineStep{deploy(&workflows.CanarySpec{Percent: 10, Evaluate: "check"}), check, {ID: "deploy-canary", Type: "command", Command: "x"}}, "already a step id"},
+		{"download", []workflows.PipelineStep{{ID: "fetch", Type: "download", Download: &workflows.DownloadSpec{URL: "https://example.com/x", Output: "x"}, Canary: &workflows.CanarySpec{Percent: 10, Evaluate: "check"}}, check}, "download steps cannot use canary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePlan(&workflows.PipelineInput{Steps: tt.steps})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePlanLocale(t *testing.T) {
+	step := workflows.PipelineStep{ID: "build", Type: "command", Command: "make"}
+	if err := validatePlan(&workflows.PipelineInput{Locale: &workflows.LocaleSpec{Lang: "C.UTF-8", OutputEncoding: "auto"}, Steps: []workflows.PipelineStep{step}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validatePlan(&workflows.PipelineInput{Locale: &workflows.LocaleSpec{OutputEncoding: "cp1252"}, Steps: []workflows.PipelineStep{step}}); err == nil || !strings.Contains(err.Error(), "output_encoding") {
+		t.Errorf("error = %v, want an output_encoding error", err)
+	}
+	step.Locale = &workflows.LocaleSpec{LCAll: "en_US UTF-8"}
+	if err := validatePlan(&workflows.PipelineInput{Steps: []workflows.PipelineStep{step}}); err == nil || !strings.Contains(err.Error(), "step build: locale.lc_all") {
+		t.Errorf("error = %v, want a step locale error", err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// A closed run stays readable once Temporal's retention has removed its
+// history: from an exported bundle, as an archive or as imported by
+// `orchestrate import`, or from the copy the pipeline keeps in the results
+// store. Both are read-only mirrors of the run.
+
+// loadRunRecord returns what Temporal knows about a run or, when Temporal
+// no longer has it, its copy in the results store of logDir. source is the
+// copy's path, empty when the record came from Temporal.
+func loadRunRecord(ctx context.Context, c client.Client, workflowID, runID, logDir string) (record *runRecord, source string, err error) {
+	record, err = fetchRunRecord(ctx, c, workflowID, runID)
+	var notFound *serviceerror.NotFound
+	if err == nil || !errors.As(err, &notFound) {
+		return record, "", err
+	}
+	record, source, archiveErr := archivedRunRecord(logDir, workflowID, runID)
+	if archiveErr != nil {
+		return nil, "", fmt.Errorf("%w (and no copy in the results store: %v)", err, archiveErr)
+	}
+	return record, source, nil
+}
+
+// archivedRunRecord reads a run from the results store of logDir. Without
+// runID it takes the latest run of the workflow in the run index.
+func archivedRunRecord(logDir, workflowID, runID string) (*runRecord, string, error) {
+	if runID == "" {
+		runs, err := activities.ReadRunIndex(activities.RunIndexPath(logDir))
+		if err != nil {
+			return nil, "", err
+		}
+		for _, run := range runs {
+			if run.WorkflowID == workflowID {
+				runID = run.RunID
+			}
+		}
+		if runID == "" {
+			return nil, "", fmt.Errorf("%s is not in the run index", workflowID)
+		}
+	}
+	source := activities.ArchivedRunPath(logDir, workflowID, runID)
+	archived, err := activities.ReadArchivedRun(source)
+	if err != nil {
+		return nil, "", err
+	}
+	record := &runRecord{WorkflowID: archived.WorkflowID, RunID: archived.RunID, Status: archived.Status}
+	record.StartTime, _ = time.Parse(time.RFC3339, archived.StartedAt)
+	record.CloseTime, _ = time.Parse(time.RFC3339, archived.ClosedAt)
+	var result workflows.PipelineResult
+	if err := json.Unmarshal(archived.Result, &result); err != nil {
+		return nil, "", fmt.Errorf("archived run %s: %w", source, err)
+	}
+	record.Result = &result
+	if result.EffectivePlan != nil {
+		record.Plan = &result.EffectivePlan.Plan
+	}
+	return record, source, nil
+}
+
+// bundleRunRecord reads a run from an exported bundle.
+func bundleRunRecord(bundle string) (*runRecord, error) {
+	files, err := readBundleFiles(bundle, "manifest.json", "plan.json", "result.json")
+	if err != nil {
+		return nil, err
+	}
+	var manifest BundleManifest
+	if data, ok := files["manifest.json"]; !ok {
+		return nil, fmt.Errorf("%s has no manifest.json; is it an exported bundle?", bundle)
+	} else if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	record := &runRecord{
+		WorkflowID: manifest.WorkflowID,
+		RunID:      manifest.RunID,
+		Status:     manifest.Status,
+		Namespace:  manifest.Namespace,
+		StartTime:  manifest.StartTime,
+		CloseTime:  manifest.CloseTime,
+	}
+	if data, ok := files["plan.json"]; ok {
+		var plan workflows.PipelineInput
+		if err := json.Unmarshal(data, &plan); err != nil {
+			return nil, fmt.Errorf("read plan.json: %w", err)
+		}
+		record.Plan = &plan
+	}
+	if data, ok := files["result.json"]; ok {
+		var result struct {
+			Result  *workflows.PipelineResult `json:"result"`
+			Failure string                    `json:"failure"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("read result.json: %w", err)
+		}
+		record.Result, record.Failure = result.Result, result.Failure
+	}
+	return record, nil
+}
+
+// readBundleFiles returns the files of a bundle with the given names,
+// relative to its root, leaving out those it does not have. bundle is a
+// .tar.zst or .tar.gz archive, or a directory it was imported into.
+func readBundleFiles(bundle string, names ...string) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	info, err := os.Stat(bundle)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		for _, name := range names {
+			data, err := os.ReadFile(filepath.Join(bundle, filepath.FromSlash(name)))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			files[name] = data
+		}
+		return files, nil
+	}
+
+	file, err := os.Open(bundle)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, finish, err := decompressReader(file, bundle)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, rel, ok := strings.Cut(path.Clean(header.Name), "/")
+		if !ok || header.Typeflag != tar.TypeReg || !wanted[rel] {
+			continue
+		}
+		if files[rel], err = io.ReadAll(tr); err != nil {
+			return nil, err
+		}
+	}
+	// Drain the stream so the decompressor exits cleanly.
+	io.Copy(io.Discard, r)
+	return files, finish()
+}
+
+// printRecordStatus writes the state of a closed run read from a mirror, with
+// one line per step.
+func printRecordStatus(w io.Writer, record *runRecord, source string) {
+	fmt.Fprintf(w, "workflow %s (run %s): %s\n", record.WorkflowID, record.RunID, record.Status)
+	fmt.Fprintf(w, "read-only copy from %s\n", source)
+	if !record.StartTime.IsZero() {
+		fmt.Fprintf(w, "started %s\n", record.StartTime.UTC().Format(time.RFC3339))
+	}
+	if !record.CloseTime.IsZero() {
+		fmt.Fprintf(w, "closed %s\n", record.CloseTime.UTC().Format(time.RFC3339))
+	}
+	if record.Failure != "" {
+		fmt.Fprintf(w, "failure %s\n", record.Failure)
+	}
+	if record.Result == nil || len(record.Result.Steps) == 0 {
+		fmt.Fprintln(w, "\nno steps ran")
+		return
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tSTATE\tEXIT\tDURATION (S)\tNOTE")
+	for _, step := range record.Result.Steps {
+		note := step.SkipReason
+		if step.Result.Error != "" {
+			note = step.Result.Error
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", step.ID, step.State, step.Result.ExitCode, stepSeconds(step.Result), orDash(note))
+	}
+	tw.Flush()
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+func TestArchivedRunRecord(t *testing.T) {
+	logDir := t.TempDir()
+	result, err := json.Marshal(workflows.PipelineResult{
+		Status: workflows.StatusFailed,
+		Steps: []workflows.StepOutcome{
+			{ID: "prep", State: "success", Result: workflows.PipelineStepResult{DurationSec: 12}},
+			{ID: "train", State: "failed", Result: workflows.PipelineStepResult{ExitCode: 2, Error: "exit status 2"}},
+		},
+		EffectivePlan: &workflows.EffectivePlan{Plan: workflows.PipelineInput{Name: "nightly"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, runID := range []string{"run-1", "run-2"} {
+		entry := activities.RunIndexEntry{WorkflowID: "nightly", RunID: runID, StartedAt: "2024-06-01T02:00:00Z", Status: workflows.StatusFailed}
+		if err := activities.RecordRunIndex(context.Background(), activities.RecordRunIndexInput{LogDir: logDir, Entry: entry}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := activities.ArchiveRun(context.Background(), activities.ArchiveRunInput{LogDir: logDir, Run: activities.ArchivedRun{
+			WorkflowID: "nightly",
+			RunID:      runID,
+			Status:     workflows.StatusFailed,
+			StartedAt:  "2024-06-01T02:00:00Z",
+			ClosedAt:   "2024-06-01T02:30:00Z",
+			Result:     result,
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	record, source, err := archivedRunRecord(logDir, "nightly", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.RunID != "run-2" || source != activities.ArchivedRunPath(logDir, "nightly", "run-2") || record.Plan == nil || record.Plan.Name != "nightly" {
+		t.Errorf("record = %+v from %s, want the latest run", record, source)
+	}
+	var out bytes.Buffer
+	printRecordStatus(&out, record, source)
+	for _, want := range []string{"workflow nightly (run run-2): failed", "read-only copy from " + source, "closed 2024-06-01T02:30:00Z", "train  failed   2     0             exit status 2"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("status missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if _, _, err := archivedRunRecord(logDir, "weekly", ""); err == nil || !strings.Contains(err.Error(), "not in the run index") {
+		t.Errorf("error = %v, want weekly not in the run index", err)
+	}
+}
+
+func TestBundleMirror(t *testing.T) {
+	record, logDir := exportFixture(t)
+	archive := filepath.Join(t.TempDir(), "nightly.tar.gz")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, finish, err := compressWriter(file, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writeBundle(w, record, logDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	imported, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer imported.Close()
+	r, done, err := decompressReader(imported, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, _, err := importBundle(r, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+
+	for _, bundle := range []string{archive, root} {
+		mirrored, err := bundleRunRecord(bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mirrored.RunID != "run-1" || mirrored.Status != "completed" || mirrored.Result == nil || len(mirrored.Result.Steps) != 1 || mirrored.Plan == nil {
+			t.Errorf("%s: record = %+v", bundle, mirrored)
+		}
+		if report := runReport(mirrored); !strings.Contains(report, "| train | success | 0 | 42 |") {
+			t.Errorf("%s: report:\n%s", bundle, report)
+		}
+		data, err := readLog(bundle, "nightly_run-1_train_stdout.log")
+		if err != nil || string(data) != "epoch 1\n" {
+			t.Errorf("%s: log = %q, %v", bundle, data, err)
+		}
+		if _, err := readLog(bundle, "nightly_run-0_train_stdout.log"); err == nil {
+			t.Errorf("%s: read a log of another run", bundle)
+		}
+	}
+	if _, err := bundleRunRecord(logDir); err == nil || !strings.Contains(err.Error(), "no manifest.json") {
+		t.Errorf("error = %v, want no manifest.json", err)
+	}
+}
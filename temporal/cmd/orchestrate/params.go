@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+// paramFlags collects repeated -param name=value flags.
+type paramFlags map[string]string
+
+func (p paramFlags) String() string {
+	return fmt.Sprint(map[string]string(p))
+}
+
+func (p paramFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected name=value, got %q", value)
+	}
+	p[name] = val
+	return nil
+}
+
+// runParams implements `orchestrate params <plan>`, listing the parameters a
+// plan declares.
+func runParams(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: orchestrate params <plan>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var input workflows.PipelineInput
+	if err := yaml.Unmarshal(data, &input); err != nil {
+		return fmt.Errorf("parse plan: %w", err)
+	}
+	if len(input.Parameters) == 0 {
+		fmt.Println("plan declares no parameters")
+		return nil
+	}
+	return printParams(os.Stdout, input.Parameters)
+}
+
+func printParams(w io.Writer, schema []workflows.ParamSpec) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTYPE\tREQUIRED\tDEFAULT\tALLOWED\tDESCRIPTION")
+	for _, spec := range schema {
+		typ := spec.Type
+		if typ == "" {
+			typ = "string"
+		}
+		required := "no"
+		if spec.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", spec.Name, typ, required, orDash(spec.Default), orDash(strings.Join(spec.Enum, "|")), spec.Description)
+	}
+	return tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+func TestValidatePlanParameters(t *testing.T) {
+	steps := []workflows.PipelineStep{{ID: "a", Type: "command", Command: "echo"}}
+	tests := []struct {
+		name    string
+		schema  []workflows.ParamSpec
+		params  map[string]string
+		wantErr string
+	}{
+		{"valid", []workflows.ParamSpec{{Name: "shard", Type: "int", Default: "0"}}, nil, ""},
+		{"unknown type", []workflows.ParamSpec{{Name: "shard", Type: "number"}}, nil, "unsupported type"},
+		{"bad default", []workflows.ParamSpec{{Name: "shard", Type: "int", Default: "one"}}, nil, "default"},
+		{"bad enum", []workflows.ParamSpec{{Name: "flag", Type: "bool", Enum: []string{"maybe"}}}, nil, "enum"},
+		{"duplicate", []workflows.ParamSpec{{Name: "a"}, {Name: "a"}}, nil, "duplicate parameter"},
+		{"required and default", []workflows.ParamSpec{{Name: "a", Required: true, Default: "x"}}, nil, "both required"},
+		{"missing required value", []workflows.ParamSpec{{Name: "a", Required: true}}, nil, "required"},
+		{"undeclared value", []workflows.ParamSpec{{Name: "a"}}, map[string]string{"b": "1"}, "undeclared"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &workflows.PipelineInput{Parameters: tt.schema, Params: tt.params, Steps: steps}
+			err := validatePlan(input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParamFlags(t *testing.T) {
+	flags := paramFlags{}
+	if err := flags.Set("shard=3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flags.Set("query=a=b"); err != nil {
+		t.Fatal(err)
+	}
+	if flags["shard"] != "3" || flags["query"] != "a=b" {
+		t.Errorf("flags = %v", flags)
+	}
+	if err := flags.Set("novalue"); err == nil {
+		t.Error("expected error without '='")
+	}
+}
+
+func TestPrintParams(t *testing.T) {
+	var out bytes.Buffer
+	err := printParams(&out, []workflows.ParamSpec{
+		{Name: "shard", Type: "int", Required: true, Description: "Shard index"},
+		{Name: "split", Enum: []string{"train", "test"}, Default: "train"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "shard int yes - - Shard index" {
+		t.Errorf("shard row = %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "split string no train train|test" {
+		t.Errorf("split row = %q", lines[2])
+	}
+}
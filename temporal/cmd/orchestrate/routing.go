@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+// resolveRoute sets input's namespace and task queue to the ones it is
+// submitted to. A -namespace or -task-queue flag given on the command line
+// wins over the plan, which wins over TEMPORAL_NAMESPACE, TEMPORAL_TASK_QUEUE
+// and the defaults, the flags' default values. The result must match
+// SYGALDRY_ALLOWED_NAMESPACES and SYGALDRY_ALLOWED_TASK_QUEUES when they are
+// set.
+func resolveRoute(input *workflows.PipelineInput, fs *flag.FlagSet, namespace, taskQueue string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if set["namespace"] || input.Namespace == "" {
+		input.Namespace = namespace
+	}
+	if set["task-queue"] || input.TaskQueue == "" {
+		input.TaskQueue = taskQueue
+	}
+	if err := checkAllowed("SYGALDRY_ALLOWED_NAMESPACES", "namespace", input.Namespace); err != nil {
+		return err
+	}
+	return checkAllowed("SYGALDRY_ALLOWED_TASK_QUEUES", "task queue", input.TaskQueue)
+}
+
+// checkAllowed reports whether value matches the comma-separated globs in
+// the environment variable key, e.g. "ml-*,orchestration". An unset list
+// allows any value.
+func checkAllowed(key, kind, value string) error {
+	list := strings.TrimSpace(os.Getenv(key))
+	if list == "" {
+		return nil
+	}
+	for _, pattern := range strings.Split(list, ",") {
+		if ok, _ := path.Match(strings.TrimSpace(pattern), value); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s %s is not allowed (%s=%s)", kind, value, key, list)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"temporal-orchestration/internal/workflows"
+)
+
+func TestResolveRoute(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      []string
+		plan      workflows.PipelineInput
+		wantNS    string
+		wantQueue string
+	}{
+		{"defaults", nil, workflows.PipelineInput{}, "default", "orchestration"},
+		{"plan", nil, workflows.PipelineInput{Namespace: "ml", TaskQueue: "gpu"}, "ml", "gpu"},
+		{"flag wins", []string{"-task-queue", "gpu-canary"}, workflows.PipelineInput{Namespace: "ml", TaskQueue: "gpu"}, "ml", "gpu-canary"},
+		{"flag equal to default still wins", []string{"-namespace", "default"}, workflows.PipelineInput{Namespace: "ml"}, "default", "orchestration"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			namespace := fs.String("namespace", "default", "")
+			taskQueue := fs.String("task-queue", "orchestration", "")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			input := tt.plan
+			if err := resolveRoute(&input, fs, *namespace, *taskQueue); err != nil {
+				t.Fatal(err)
+			}
+			if input.Namespace != tt.wantNS || input.TaskQueue != tt.wantQueue {
+				t.Errorf("route = %s/%s, want %s/%s", input.Namespace, input.TaskQueue, tt.wantNS, tt.wantQueue)
+			}
+		})
+	}
+}
+
+func TestResolveRouteAllowList(t *testing.T) {
+	t.Setenv("SYGALDRY_ALLOWED_NAMESPACES", "default, ml-*")
+	t.Setenv("SYGALDRY_ALLOWED_TASK_QUEUES", "orchestration,gpu-*")
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	input := workflows.PipelineInput{Namespace: "ml-research", TaskQueue: "gpu-a100"}
+	if err := resolveRoute(&input, fs, "default", "orchestration"); err != nil {
+		t.Errorf("allowed route rejected: %v", err)
+	}
+	input = workflows.PipelineInput{TaskQueue: "cpu"}
+	err := resolveRoute(&input, fs, "default", "orchestration")
+	if err == nil || !strings.Contains(err.Error(), "task queue cpu is not allowed") {
+		t.Errorf("err = %v, want task queue rejected", err)
+	}
+	input = workflows.PipelineInput{Namespace: "prod"}
+	err = resolveRoute(&input, fs, "default", "orchestration")
+	if err == nil || !strings.Contains(err.Error(), "namespace prod is not allowed") {
+		t.Errorf("err = %v, want namespace rejected", err)
+	}
+}
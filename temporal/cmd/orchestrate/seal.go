@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// encryptedTag marks a plan value sealed with `orchestrate seal`.
+const encryptedTag = "!encrypted"
+
+// runSeal implements `orchestrate seal`, which encrypts a value for the
+// workers' public key, and `orchestrate seal -keygen`, which makes a key pair.
+func runSeal(args []string) error {
+	fs := flag.NewFlagSet("seal", flag.ExitOnError)
+	publicKey := fs.String("public-key", os.Getenv("SYGALDRY_SEAL_PUBLIC_KEY"), "Workers' public key, base64 or a file containing it")
+	keygen := fs.Bool("keygen", false, "Print a new key pair instead of sealing a value")
+	fs.Parse(args)
+	if *keygen {
+		private, public, err := activities.GenerateSealKey()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("private key (TEMPORAL_SEAL_PRIVATE_KEY on the workers): %s\n", private)
+		fmt.Printf("public key (SYGALDRY_SEAL_PUBLIC_KEY): %s\n", public)
+		return nil
+	}
+	if fs.NArg() > 1 {
+		return errors.New("usage: orchestrate seal [-public-key key] [value]  (reads the value from stdin without an argument)")
+	}
+	value := fs.Arg(0)
+	if fs.NArg() == 0 {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimSuffix(string(data), "\n")
+	}
+	sealed, err := sealForPlan(*publicKey, value)
+	if err != nil {
+		return err
+	}
+	fmt.Println(sealed)
+	return nil
+}
+
+// sealForPlan seals value and formats it for a plan, e.g.
+// `!encrypted AbC...`. key is the base64 public key or a file holding it.
+func sealForPlan(key, value string) (string, error) {
+	if key == "" {
+		return "", errors.New("no public key: pass -public-key or set SYGALDRY_SEAL_PUBLIC_KEY")
+	}
+	if data, err := os.ReadFile(key); err == nil {
+		key = string(data)
+	}
+	sealed, err := activities.SealValue(key, value)
+	if err != nil {
+		return "", err
+	}
+	return encryptedTag + " " + strings.TrimPrefix(sealed, activities.SealedPrefix), nil
+}
+
+// markSealedValues rewrites !encrypted scalars in a parsed plan to plain
+// strings carrying activities.SealedPrefix, which workers open when the step
+// runs. Sealed values are only allowed in env and params maps, the places
+// that reach a step as environment variables.
+func markSealedValues(node *yaml.Node) error {
+	return markSealed(node, "")
+}
+
+// markSealed walks node, the value of the mapping key field ("" outside a
+// mapping).
+func markSealed(node *yaml.Node, field string) error {
+	switch {
+	case node.Tag == encryptedTag:
+		return fmt.Errorf("line %d: %s values are only supported in env and params", node.Line, encryptedTag)
+	case node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			value := node.Content[i+1]
+			if value.Tag == encryptedTag && (field == "env" || field == "params") {
+				if err := markSealedScalar(value); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := markSealed(value, node.Content[i].Value); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, child := range node.Content {
+			if err := markSealed(child, ""); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func markSealedScalar(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: %s needs a scalar value", node.Line, encryptedTag)
+	}
+	value := strings.TrimSpace(node.Value)
+	if !activities.IsSealed(value) {
+		value = activities.SealedPrefix + value
+	}
+	node.Tag, node.Value, node.Style = "!!str", value, 0
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestLoadPlanMarksSealedValues(t *testing.T) {
+	private, public, _ := activities.GenerateSealKey()
+	sealed, err := sealForPlan(public, "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, "!encrypted ") {
+		t.Fatalf("sealForPlan = %q", sealed)
+	}
+	dir := t.TempDir()
+	plan := "params:\n  token: " + sealed + "\nsteps:\n  - id: push\n    type: command\n    command: push.sh\n    env:\n      API_TOKEN: " + sealed + "\n      REGION: eu\n"
+	path := filepath.Join(dir, "plan.yaml")
+	if err := os.WriteFile(path, []byte(plan), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input, err := loadPlan(path, nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"param": input.Params["token"], "env": input.Steps[0].Env["API_TOKEN"]} {
+		if opened, err := activities.OpenValue(private, value); err != nil || opened != "s3cret" {
+			t.Errorf("%s = %q: %q, %v", name, value, opened, err)
+		}
+	}
+	if input.Steps[0].Env["REGION"] != "eu" {
+		t.Errorf("REGION = %q", input.Steps[0].Env["REGION"])
+	}
+
+	misplaced := "steps:\n  - id: push\n    type: command\n    command: " + sealed + "\n"
+	if err := os.WriteFile(path, []byte(misplaced), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPlan(path, nil, dir); err == nil || !strings.Contains(err.Error(), "only supported in env and params") {
+		t.Errorf("sealed command: err = %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// runStatus implements `orchestrate status <workflow-id>`. Running steps are
+// listed with the log tail their worker last sent as heartbeat details, so no
+// access to the worker's log directory is needed. A run Temporal no longer
+// has is shown from its copy in the results store, and -bundle shows a run
+// from an exported bundle without Temporal.
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	runID := fs.String("run-id", "", "Run ID (default: latest run)")
+	tailLines := fs.Int("n", 10, "Tail lines to show per running step (0 hides the tail)")
+	bundle := fs.String("bundle", "", "Show the run of this exported bundle, an archive or an imported directory, instead of asking Temporal")
+	logDir := fs.String("log-dir", envOr("TEMPORAL_LOG_DIR", "./logs"), "Log directory of the runs (locates the results store)")
+	address := fs.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
+	namespace := fs.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
+	fs.Parse(args)
+	if *bundle != "" {
+		if fs.NArg() != 0 {
+			return errors.New("usage: orchestrate status -bundle <bundle>")
+		}
+		record, err := bundleRunRecord(*bundle)
+		if err != nil {
+			return err
+		}
+		printRecordStatus(os.Stdout, record, *bundle)
+		return nil
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: orchestrate status [flags] <workflow-id>")
+	}
+
+	c, err := dialClient(*address, *namespace)
+	if err != nil {
+		return fmt.Errorf("unable to create Temporal client: %w", err)
+	}
+	defer c.Close()
+
+	described, err := c.DescribeWorkflowExecution(context.Background(), fs.Arg(0), *runID)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		record, source, archiveErr := archivedRunRecord(*logDir, fs.Arg(0), *runID)
+		if archiveErr != nil {
+			return fmt.Errorf("describe workflow: %w (and no copy in the results store: %v)", err, archiveErr)
+		}
+		printRecordStatus(os.Stdout, record, source)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("describe workflow: %w", err)
+	}
+	var progress *activities.PipelineProgress
+	if described.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+		// Runs started before progress tracking have no handler; skip them.
+		if value, err := c.QueryWorkflow(context.Background(), fs.Arg(0), *runID, workflows.ProgressQuery); err == nil {
+			progress = &activities.PipelineProgress{}
+			if value.Get(progress) != nil {
+				progress = nil
+			}
+		}
+	}
+	printStatus(os.Stdout, described, progress, *tailLines, time.Now())
+	return nil
+}
+
+// formatProgress renders progress as "3 of 7 steps done (42.9%), 2 running,
+// ETA 12m0s".
+func formatProgress(progress activities.PipelineProgress) string {
+	text := fmt.Sprintf("%d of %d steps done (%g%%)", progress.Done, progress.Total, progress.Percent)
+	if progress.Running > 0 {
+		text += fmt.Sprintf(", %d running", progress.Running)
+	}
+	if progress.EtaSec != nil {
+		text += fmt.Sprintf(", ETA %s", time.Duration(*progress.EtaSec)*time.Second)
+	}
+	if len(progress.Slow) > 0 {
+		text += fmt.Sprintf(", slow: %s", strings.Join(progress.Slow, ", "))
+	}
+	return text
+}
+
+// printStatus writes the workflow state and one block per pending step.
+func printStatus(w io.Writer, described *workflowservice.DescribeWorkflowExecutionResponse, progress *activities.PipelineProgress, tailLines int, now time.Time) {
+	info := described.GetWorkflowExecutionInfo()
+	fmt.Fprintf(w, "workflow %s (run %s): %s\n", info.GetExecution().GetWorkflowId(), info.GetExecution().GetRunId(),
+		strings.ToLower(strings.TrimPrefix(info.GetStatus().String(), "WORKFLOW_EXECUTION_STATUS_")))
+	if info.GetStartTime() != nil {
+		fmt.Fprintf(w, "started %s\n", info.GetStartTime().AsTime().UTC().Format(time.RFC3339))
+	}
+	if progress != nil {
+		fmt.Fprintf(w, "progress %s\n", formatProgress(*progress))
+	}
+
+	pending := described.GetPendingActivities()
+	if len(pending) == 0 {
+		fmt.Fprintln(w, "\nno running steps")
+		return
+	}
+	dc := converter.GetDefaultDataConverter()
+	for _, activity := range pending {
+		fmt.Fprintln(w)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "step\t%s (%s)\n", activity.GetActivityId(), activity.GetActivityType().GetName())
+		fmt.Fprintf(tw, "state\t%s\n", strings.ToLower(strings.TrimPrefix(activity.GetState().String(), "PENDING_ACTIVITY_STATE_")))
+		fmt.Fprintf(tw, "attempt\t%d\n", activity.GetAttempt())
+		if worker := activity.GetLastWorkerIdentity(); worker != "" {
+			fmt.Fprintf(tw, "worker\t%s\n", worker)
+		}
+		if activity.GetLastHeartbeatTime() != nil {
+			fmt.Fprintf(tw, "heartbeat\t%s ago\n", now.Sub(activity.GetLastHeartbeatTime().AsTime()).Round(time.Second))
+		}
+		if failure := activity.GetLastFailure(); failure != nil {
+			fmt.Fprintf(tw, "last failure\t%s\n", failure.GetMessage())
+		}
+		var heartbeat activities.StepHeartbeat
+		if activity.GetHeartbeatDetails() != nil && dc.FromPayloads(activity.GetHeartbeatDetails(), &heartbeat) != nil {
+			heartbeat = activities.StepHeartbeat{}
+		}
+		// Earlier attempts of a retried step, the running one excluded.
+		for _, attempt := range heartbeat.Attempts {
+			if attempt.EndedAt != "" {
+				fmt.Fprintf(tw, "attempt %d\t%s on %s after %s\n", attempt.Attempt, attemptOutcome(attempt), orDash(attempt.Worker), attemptDuration(attempt))
+			}
+		}
+		tw.Flush()
+
+		if tailLines <= 0 || len(heartbeat.Tail) == 0 {
+			continue
+		}
+		tail := heartbeat.Tail
+		if len(tail) > tailLines {
+			tail = tail[len(tail)-tailLines:]
+		}
+		for _, line := range tail {
+			prefix := "  | "
+			if line.Stream == "stderr" {
+				prefix = "  ! "
+			}
+			fmt.Fprintln(w, prefix+line.Message)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestPrintStatusShowsHeartbeatTail(t *testing.T) {
+	details, err := converter.GetDefaultDataConverter().ToPayloads(activities.StepHeartbeat{
+		StepID: "train",
+		Tail: []activities.LogTailLine{
+			{Stream: "stdout", Message: "epoch 1"},
+			{Stream: "stdout", Message: "epoch 2"},
+			{Stream: "stderr", Message: "warning: lr high"},
+		},
+		Attempts: []activities.StepAttempt{
+			{Attempt: 1, Worker: "gpu-2", StartedAt: "2025-01-01T00:00:00Z", EndedAt: "2025-01-01T00:01:30Z", ExitCode: -1, Error: "CUDA error: out of memory"},
+			{Attempt: 2, Worker: "gpu-1", StartedAt: "2025-01-01T00:02:00Z"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	described := &workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "nightly", RunId: "run-1"},
+			Status:    enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING,
+		},
+		PendingActivities: []*workflowpb.PendingActivityInfo{{
+			ActivityId:         "train",
+			ActivityType:       &commonpb.ActivityType{Name: "RunCommand"},
+			State:              enumspb.PENDING_ACTIVITY_STATE_STARTED,
+			Attempt:            2,
+			LastWorkerIdentity: "worker@gpu-1",
+			HeartbeatDetails:   details,
+		}},
+	}
+
+	eta := int64(720)
+	progress := activities.PipelineProgress{Done: 3, Total: 7, Running: 1, Percent: 42.9, EtaSec: &eta, Slow: []string{"train"}}
+
+	var out bytes.Buffer
+	printStatus(&out, described, &progress, 2, time.Now())
+	got := out.String()
+	for _, want := range []string{"workflow nightly (run run-1): running", "progress 3 of 7 steps done (42.9%), 1 running, ETA 12m0s, slow: train", "train (RunCommand)", "started", "worker@gpu-1", "CUDA error: out of memory on gpu-2 after 1m30s", "  | epoch 2", "  ! warning: lr high"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "epoch 1") {
+		t.Errorf("tail not limited to 2 lines:\n%s", got)
+	}
+}
+
+func TestPrintStatusNoRunningSteps(t *testing.T) {
+	described := &workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Execution: &commonpb.WorkflowExecution{WorkflowId: "nightly", RunId: "run-1"},
+			Status:    enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+		},
+	}
+	var out bytes.Buffer
+	printStatus(&out, described, nil, 10, time.Now())
+	if !strings.Contains(out.String(), "completed") || !strings.Contains(out.String(), "no running steps") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
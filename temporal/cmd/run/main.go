@@ -11,6 +11,8 @@ import (
 
 	"go.temporal.io/sdk/client"
 
+	"temporal-orchestration/internal/metrics"
+	"temporal-orchestration/internal/stepwatch"
 	"temporal-orchestration/internal/workflows"
 )
 
@@ -22,21 +24,29 @@ func main() {
 		address    = flag.String("address", envOr("TEMPORAL_ADDRESS", "localhost:7233"), "Temporal host:port")
 		namespace  = flag.String("namespace", envOr("TEMPORAL_NAMESPACE", "default"), "Temporal namespace")
 		logDir     = flag.String("log-dir", "", "Log directory for step outputs (overrides input and TEMPORAL_LOG_DIR)")
+		eventsOut  = flag.Bool("events-stdout", false, "Write step lifecycle events to stdout as JSON lines while waiting; the result goes to stderr")
 	)
 	flag.Parse()
 
+	exporter, err := metrics.FromEnv("run")
+	if err != nil {
+		log.Fatal(err)
+	}
+	metricsExporter = exporter
+	defer metricsExporter.Close()
+
 	if *inputPath == "" {
-		log.Fatal("-input is required")
+		fatalf("-input is required")
 	}
 
 	inputBytes, err := os.ReadFile(*inputPath)
 	if err != nil {
-		log.Fatalf("unable to read input file: %v", err)
+		fatalf("unable to read input file: %v", err)
 	}
 
 	var input workflows.OrchestrationInput
 	if err := json.Unmarshal(inputBytes, &input); err != nil {
-		log.Fatalf("unable to parse input: %v", err)
+		fatalf("unable to parse input: %v", err)
 	}
 
 	if *logDir != "" {
@@ -47,9 +57,9 @@ func main() {
 		}
 	}
 
-	c, err := client.Dial(client.Options{HostPort: *address, Namespace: *namespace})
+	c, err := dialClient(*address, *namespace)
 	if err != nil {
-		log.Fatalf("unable to create Temporal client: %v", err)
+		fatalf("unable to create Temporal client: %v", err)
 	}
 	defer c.Close()
 
@@ -63,20 +73,47 @@ func main() {
 
 	we, err := c.ExecuteWorkflow(ctx, options, workflows.Orchestrate, input)
 	if err != nil {
-		log.Fatalf("unable to start workflow: %v", err)
+		fatalf("unable to start workflow: %v", err)
+	}
+
+	resultOut := os.Stdout
+	finishEvents := func(string) {}
+	if *eventsOut {
+		resultOut = os.Stderr
+		finishEvents = stepwatch.Stream(c, we.GetID(), we.GetRunID(), os.Stdout)
 	}
 
 	var result workflows.OrchestrationResult
 	if err := we.Get(ctx, &result); err != nil {
-		log.Fatalf("workflow failed: %v", err)
+		finishEvents(workflows.StatusFailed)
+		fatalf("workflow failed: %v", err)
+	}
+	if result.Succeeded {
+		finishEvents(workflows.StatusSucceeded)
+	} else {
+		finishEvents(workflows.StatusFailed)
 	}
 
 	output, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
-		log.Fatalf("unable to serialize result: %v", err)
+		fatalf("unable to serialize result: %v", err)
 	}
 
-	fmt.Println(string(output))
+	fmt.Fprintln(resultOut, string(output))
+}
+
+// metricsExporter collects the client's SDK metrics (see
+// TEMPORAL_METRICS_ADDR and TEMPORAL_METRICS_FILE).
+var metricsExporter *metrics.Exporter
+
+func dialClient(address, namespace string) (client.Client, error) {
+	return client.Dial(client.Options{HostPort: address, Namespace: namespace, MetricsHandler: metricsExporter.Handler()})
+}
+
+// fatalf writes the metrics file before exiting, which log.Fatalf would skip.
+func fatalf(format string, args ...any) {
+	metricsExporter.Close()
+	log.Fatalf(format, args...)
 }
 
 func envOr(key, fallback string) string {
@@ -1,45 +1,212 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"os"
+	// Schedule timezones must resolve even on hosts without zoneinfo.
+	_ "time/tzdata"
 
 	"go.temporal.io/sdk/client"
+	sdklog "go.temporal.io/sdk/log"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 
 	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/chaos"
+	"temporal-orchestration/internal/metrics"
+	"temporal-orchestration/internal/systemd"
 	"temporal-orchestration/internal/workflows"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate-unit" {
+		if err := runGenerateUnit(os.Args[2:]); err != nil {
+			log.Fatalf("generate-unit: %v", err)
+		}
+		return
+	}
+
+	// Under systemd, TEMPORAL_LOG_JOURNAL=1 logs to the journal with
+	// priorities and fields instead of stdout.
+	var logger sdklog.Logger
+	if os.Getenv("TEMPORAL_LOG_JOURNAL") == "1" {
+		journal, err := systemd.OpenJournal("sygaldry-worker")
+		if err != nil {
+			log.Printf("logging to stderr: %v", err)
+		} else {
+			defer journal.Close()
+			log.SetOutput(journal)
+			log.SetFlags(0)
+			logger = sdklog.NewStructuredLogger(slog.New(journal.Handler(slog.LevelInfo)))
+		}
+	}
+
 	address := envOr("TEMPORAL_ADDRESS", "localhost:7233")
 	namespace := envOr("TEMPORAL_NAMESPACE", "default")
 	taskQueue := envOr("TEMPORAL_TASK_QUEUE", "orchestration")
+	hostname, _ := os.Hostname()
+	workerQueue := envOr("TEMPORAL_WORKER_QUEUE", taskQueue+"-"+hostname)
+	// Activities record this queue as the owner of worker-local artifacts.
+	activities.SetWorkerQueue(workerQueue)
+	limits, err := activities.ConfigureRateLimiters(os.Getenv("TEMPORAL_RATE_LIMITS"))
+	if err != nil {
+		log.Fatalf("invalid TEMPORAL_RATE_LIMITS: %v", err)
+	}
+	for name, limit := range limits {
+		log.Printf("rate limiter %s: %d per %s", name, limit.Count, limit.Per)
+	}
+	pool, err := activities.ConfigureBuilderPool(os.Getenv("TEMPORAL_BUILDKIT_POOL"))
+	if err != nil {
+		log.Fatalf("invalid TEMPORAL_BUILDKIT_POOL: %v", err)
+	}
+	for _, builder := range pool {
+		log.Printf("builder %s: up to %d builds", builder.Endpoint, builder.MaxBuilds)
+	}
 
-	c, err := client.Dial(client.Options{HostPort: address, Namespace: namespace})
+	// SDK metrics (task latencies, activity failures) and step metrics share
+	// one endpoint; see TEMPORAL_METRICS_ADDR.
+	exporter, err := metrics.FromEnv("worker")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer exporter.Close()
+	activities.SetEventMetricsHandler(exporter.Handler())
+	// Deliver events still queued for an unreachable log directory, or move
+	// them to the fallback file, before exiting.
+	defer func() {
+		if err := activities.FlushEvents(); err != nil {
+			log.Printf("events lost: %v", err)
+		}
+		if stats := activities.CurrentEventStats(); stats.WriteErrors > 0 || stats.Fallback > 0 {
+			log.Printf("event delivery: %d write errors, %d events sent to fallback", stats.WriteErrors, stats.Fallback)
+		}
+	}()
+
+	// The systemd watchdog is only pinged while the worker's polls are
+	// answered.
+	polls := systemd.NewPollTracker()
+	c, err := client.Dial(client.Options{HostPort: address, Namespace: namespace, MetricsHandler: polls.Handler(exporter.Handler()), Logger: logger})
 	if err != nil {
 		log.Fatalf("unable to create Temporal client: %v", err)
 	}
 	defer c.Close()
 
-	w := worker.New(c, taskQueue, worker.Options{})
+	names := make([]string, len(workerActivities))
+	for i, activity := range workerActivities {
+		names[i] = workflows.ActivityName(activity)
+	}
+	workflows.SetWorkerCapabilities(names)
+
+	options, err := workerOptions(os.Getenv("TEMPORAL_WORKER_DEPLOYMENT"), os.Getenv("TEMPORAL_WORKER_BUILD_ID"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if version := options.DeploymentOptions.Version; options.DeploymentOptions.UseVersioning {
+		log.Printf("worker deployment %s, build %s", version.DeploymentName, version.BuildID)
+	}
+	injector, err := chaos.FromEnv()
+	if err != nil {
+		log.Fatalf("invalid TEMPORAL_CHAOS: %v", err)
+	}
+	if injector != nil {
+		log.Printf("WARNING: injecting faults for testing (TEMPORAL_CHAOS=%s); do not use in production", injector)
+		options.Interceptors = append(options.Interceptors, injector)
+	}
+
+	w := worker.New(c, taskQueue, options)
 	w.RegisterWorkflow(workflows.Orchestrate)
 	w.RegisterWorkflow(workflows.Pipeline)
-	w.RegisterActivity(activities.RunCommand)
-	w.RegisterActivity(activities.DownloadFile)
-	w.RegisterActivity(activities.DockerBuild)
-	w.RegisterActivity(activities.DockerPush)
-	w.RegisterActivity(activities.PackageBuild)
-	w.RegisterActivity(activities.ContainerJob)
-	w.RegisterActivity(activities.HFDownloadDataset)
-	w.RegisterActivity(activities.HFDownloadModel)
-
-	log.Printf("worker started on task queue %s", taskQueue)
-	if err := w.Run(worker.InterruptCh()); err != nil {
+	w.RegisterWorkflow(workflows.WorkerCapabilities)
+	registerActivities(w)
+
+	// Steps consuming this worker's local artifacts are routed to its own queue.
+	pinned := worker.New(c, workerQueue, options)
+	registerActivities(pinned)
+	if err := pinned.Start(); err != nil {
+		log.Fatalf("unable to start worker queue %s: %v", workerQueue, err)
+	}
+	defer pinned.Stop()
+
+	log.Printf("worker started on task queue %s (worker queue %s)", taskQueue, workerQueue)
+	// Run starts w before it waits, so systemd is told the worker is ready
+	// as it begins polling, and that it is stopping on the first signal.
+	notify("READY=1\nSTATUS=polling " + taskQueue + " and " + workerQueue)
+	if timeout, ok := systemd.WatchdogInterval(); ok {
+		log.Printf("pinging the systemd watchdog every %s while Temporal polls are answered", timeout/2)
+		ctx, stop := context.WithCancel(context.Background())
+		defer stop()
+		go systemd.Watchdog(ctx, timeout, func() error { return polls.Healthy(systemd.PollGrace) })
+	}
+	interrupt := make(chan interface{})
+	go func() {
+		<-worker.InterruptCh()
+		notify("STOPPING=1")
+		close(interrupt)
+	}()
+	if err := w.Run(interrupt); err != nil {
 		log.Fatalf("worker failed: %v", err)
 	}
 }
 
+// notify reports the worker's state to systemd when it runs as a
+// Type=notify service.
+func notify(state string) {
+	if err := systemd.Notify(state); err != nil {
+		log.Printf("unable to notify systemd: %v", err)
+	}
+}
+
+// workerActivities are registered on both of the worker's task queues.
+var workerActivities = []any{
+	activities.RunCommand,
+	activities.DownloadFile,
+	activities.DockerBuild,
+	activities.DockerPush,
+	activities.PackageBuild,
+	activities.ContainerJob,
+	activities.HFDownloadDataset,
+	activities.HFDownloadModel,
+	activities.WatchPath,
+	activities.CaptureFailureArtifacts,
+	activities.LoadGoldenBaseline,
+	activities.LoadDurationHistory,
+	activities.RecordDurationHistory,
+	activities.AcquireArtifactLeases,
+	activities.ReleaseArtifactLeases,
+	activities.RecordEvent,
+	activities.CheckRequirement,
+	activities.PostWebhook,
+	activities.CollectRunLogs,
+	activities.WriteRunLogManifest,
+}
+
+func registerActivities(w worker.Worker) {
+	for _, activity := range workerActivities {
+		w.RegisterActivity(activity)
+	}
+}
+
+// workerOptions opts the worker into Worker Deployment Versioning when a
+// deployment name and build ID are given. Workflows are pinned to the build
+// they started on, so a new build only receives new runs and in-flight runs
+// finish on the code that started them.
+func workerOptions(deployment, buildID string) (worker.Options, error) {
+	if deployment == "" && buildID == "" {
+		return worker.Options{}, nil
+	}
+	if deployment == "" || buildID == "" {
+		return worker.Options{}, errors.New("TEMPORAL_WORKER_DEPLOYMENT and TEMPORAL_WORKER_BUILD_ID must be set together")
+	}
+	return worker.Options{DeploymentOptions: worker.DeploymentOptions{
+		UseVersioning:             true,
+		Version:                   worker.WorkerDeploymentVersion{DeploymentName: deployment, BuildID: buildID},
+		DefaultVersioningBehavior: workflow.VersioningBehaviorPinned,
+	}}, nil
+}
+
 func envOr(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
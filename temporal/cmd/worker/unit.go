@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"temporal-orchestration/internal/systemd"
+)
+
+// envFlags collects repeated NAME=value flags.
+type envFlags map[string]string
+
+func (f envFlags) String() string { return "" }
+
+func (f envFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want NAME=value, got %q", value)
+	}
+	f[name] = val
+	return nil
+}
+
+// runGenerateUnit implements `worker generate-unit`, which prints a systemd
+// unit for running this worker as a supervised service.
+func runGenerateUnit(args []string) error {
+	fs := flag.NewFlagSet("generate-unit", flag.ExitOnError)
+	binary := fs.String("binary", "", "Worker binary (default: this executable)")
+	description := fs.String("description", "", "Unit description")
+	user := fs.String("user", "sygaldry", "User to run the worker as (empty: root)")
+	group := fs.String("group", "", "Group to run the worker as")
+	workingDir := fs.String("working-dir", "/var/lib/sygaldry", "Working directory, where relative log and step paths resolve")
+	envFile := fs.String("env-file", "/etc/sygaldry/worker.env", "Environment file with TEMPORAL_* settings and secrets")
+	watchdog := fs.Duration("watchdog", 3*time.Minute, "Watchdog timeout; pings stop after 2m without answered Temporal polls, and systemd restarts the worker this long after the last one (0 disables)")
+	journal := fs.Bool("journal", true, "Log to the systemd journal with priorities and fields")
+	stopTimeout := fs.Duration("stop-timeout", 5*time.Minute, "Time running steps get to stop on shutdown")
+	env := envFlags{}
+	fs.Var(env, "env", "Environment variable as NAME=value (repeatable)")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		return errors.New("usage: worker generate-unit [flags] > /etc/systemd/system/sygaldry-worker.service")
+	}
+
+	path := *binary
+	if path == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		path = executable
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	unit, err := systemd.Unit{
+		Description:      *description,
+		ExecStart:        path,
+		User:             *user,
+		Group:            *group,
+		WorkingDirectory: *workingDir,
+		EnvironmentFile:  *envFile,
+		Environment:      env,
+		WatchdogSec:      *watchdog,
+		Journal:          *journal,
+		StopTimeout:      *stopTimeout,
+	}.Render()
+	if err != nil {
+		return err
+	}
+	fmt.Print(unit)
+	return nil
+}
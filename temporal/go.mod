@@ -5,6 +5,8 @@ go 1.23.0
 toolchain go1.24.12
 
 require (
+	github.com/stretchr/testify v1.10.0
+	go.temporal.io/api v1.59.0
 	go.temporal.io/sdk v1.39.0
 	gopkg.in/yaml.v3 v3.0.1
 )
@@ -21,8 +23,6 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/robfig/cron v1.2.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
-	go.temporal.io/api v1.59.0 // indirect
 	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.32.0 // indirect
@@ -0,0 +1,73 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ArchivedRun is the copy of a finished run kept in the results store, so
+// the CLI can still show the run once Temporal's retention has removed its
+// history.
+type ArchivedRun struct {
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	Status     string `json:"status"`
+	StartedAt  string `json:"startedAt"`
+	ClosedAt   string `json:"closedAt"`
+	// Result is the run's pipeline result, with secret params redacted.
+	Result json.RawMessage `json:"result"`
+}
+
+type ArchiveRunInput struct {
+	LogDir string      `json:"logDir"`
+	Run    ArchivedRun `json:"run"`
+}
+
+// ArchivedRunPath is where the copy of a run is kept: runs/<workflow>_<run>.json
+// in the results store.
+func ArchivedRunPath(logDir, workflowID, runID string) string {
+	return filepath.Join(resultsDir(logDir), "runs", RunLogsDir(workflowID, runID)+".json")
+}
+
+// ArchiveRun writes the copy of a finished run to the results store,
+// replacing the one of an earlier attempt, and returns its path.
+func ArchiveRun(ctx context.Context, input ArchiveRunInput) (string, error) {
+	path := ArchivedRunPath(input.LogDir, input.Run.WorkflowID, input.Run.RunID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(input.Run, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = temp.Write(append(data, '\n'))
+	if err := errors.Join(err, temp.Close()); err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+func ReadArchivedRun(path string) (ArchivedRun, error) {
+	var run ArchivedRun
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return run, err
+	}
+	if err := json.Unmarshal(data, &run); err != nil {
+		return run, fmt.Errorf("archived run %s: %w", path, err)
+	}
+	return run, nil
+}
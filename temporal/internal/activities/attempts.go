@@ -0,0 +1,138 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// StepAttempt is one attempt of a step's activity. Attempts that failed are
+// carried to the next attempt in the heartbeat details, so the last one
+// reports the whole history.
+type StepAttempt struct {
+	Attempt   int32  `json:"attempt"`
+	Worker    string `json:"worker,omitempty"`
+	StartedAt string `json:"startedAt"`
+	EndedAt   string `json:"endedAt,omitempty"`
+	ExitCode  int    `json:"exitCode"`
+	Error     string `json:"error,omitempty"`
+}
+
+// lostAttemptError describes an attempt whose worker never reported an end,
+// e.g. because it crashed or the attempt timed out.
+const lostAttemptError = "attempt ended without a result (worker lost or timed out)"
+
+// attemptLog tracks the attempts of the running step activity.
+type attemptLog struct {
+	previous []StepAttempt
+	current  StepAttempt
+}
+
+// startAttempt begins the current attempt, picking up earlier ones from the
+// previous attempt's last heartbeat.
+func startAttempt(ctx context.Context) *attemptLog {
+	log := &attemptLog{current: StepAttempt{
+		Attempt:   1,
+		Worker:    attemptWorker(),
+		StartedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}}
+	if !activity.IsActivity(ctx) {
+		return log
+	}
+	log.current.Attempt = activity.GetInfo(ctx).Attempt
+	var heartbeat StepHeartbeat
+	if activity.HasHeartbeatDetails(ctx) && activity.GetHeartbeatDetails(ctx, &heartbeat) == nil {
+		log.previous = closeLostAttempts(heartbeat.Attempts, heartbeat.Timestamp)
+	}
+	return log
+}
+
+// attempts returns the attempts so far, the current one last.
+func (l *attemptLog) attempts() []StepAttempt {
+	if l == nil {
+		return nil
+	}
+	return append(append([]StepAttempt(nil), l.previous...), l.current)
+}
+
+// finish ends the current attempt and returns all attempts.
+func (l *attemptLog) finish(exitCode int, err error) []StepAttempt {
+	l.current.EndedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	l.current.ExitCode = exitCode
+	if err != nil {
+		l.current.Error = err.Error()
+	}
+	return l.attempts()
+}
+
+// fail ends the current attempt with err and returns the error for the
+// activity. The attempts are recorded as heartbeat details for the next
+// attempt and attached to the error for the workflow; cancellations and
+// timeouts are returned unchanged so Temporal still recognises them.
+func (l *attemptLog) fail(ctx context.Context, stepID string, err error) error {
+	attempts := l.finish(-1, err)
+	if !activity.IsActivity(ctx) {
+		return err
+	}
+	activity.RecordHeartbeat(ctx, StepHeartbeat{
+		Timestamp: l.current.EndedAt,
+		StepID:    stepID,
+		Attempts:  attempts,
+	})
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	options := temporal.ApplicationErrorOptions{Details: []any{attempts}}
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) {
+		options.NonRetryable = appErr.NonRetryable()
+		options.Cause = errors.Unwrap(appErr)
+		return temporal.NewApplicationErrorWithOptions(appErr.Message(), appErr.Type(), options)
+	}
+	return temporal.NewApplicationErrorWithOptions(err.Error(), "StepAttemptError", options)
+}
+
+// closeLostAttempts marks attempts that never ended as lost at the time of
+// their last heartbeat.
+func closeLostAttempts(attempts []StepAttempt, lastSeen string) []StepAttempt {
+	closed := append([]StepAttempt(nil), attempts...)
+	for i := range closed {
+		if closed[i].EndedAt == "" {
+			closed[i].EndedAt = lastSeen
+			closed[i].ExitCode = -1
+			closed[i].Error = lostAttemptError
+		}
+	}
+	return closed
+}
+
+// AttemptsFromError recovers the attempts of a step whose activity failed:
+// from the details of an error raised by the activity, or from the last
+// heartbeat of an attempt that timed out.
+func AttemptsFromError(err error) []StepAttempt {
+	var attempts []StepAttempt
+	var appErr *temporal.ApplicationError
+	if errors.As(err, &appErr) && appErr.HasDetails() && appErr.Details(&attempts) == nil {
+		return attempts
+	}
+	var timeoutErr *temporal.TimeoutError
+	var heartbeat StepHeartbeat
+	if errors.As(err, &timeoutErr) && timeoutErr.HasLastHeartbeatDetails() && timeoutErr.LastHeartbeatDetails(&heartbeat) == nil {
+		return closeLostAttempts(heartbeat.Attempts, heartbeat.Timestamp)
+	}
+	return nil
+}
+
+// attemptWorker names the worker running an attempt: its worker queue, or
+// the host name.
+func attemptWorker() string {
+	if queue := workerQueue(); queue != "" {
+		return queue
+	}
+	host, _ := os.Hostname()
+	return host
+}
@@ -0,0 +1,73 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestRunCommandRecordsAttempts(t *testing.T) {
+	setWorkerQueue(t, "worker-a")
+	result, err := RunCommand(context.Background(), RunCommandInput{Command: "true", StepID: "ok", LogDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Attempts) != 1 {
+		t.Fatalf("attempts = %+v", result.Attempts)
+	}
+	attempt := result.Attempts[0]
+	if attempt.Attempt != 1 || attempt.Worker != "worker-a" || attempt.StartedAt == "" || attempt.EndedAt == "" || attempt.Error != "" {
+		t.Errorf("attempt = %+v", attempt)
+	}
+}
+
+func TestRunCommandCarriesAttemptsAcrossRetries(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(RunCommand)
+	input := RunCommandInput{
+		Command:    "true",
+		StepID:     "flaky",
+		LogDir:     t.TempDir(),
+		WorkingDir: filepath.Join(t.TempDir(), "missing"),
+	}
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute,
+			RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3, InitialInterval: time.Millisecond},
+		})
+		return workflow.ExecuteActivity(ctx, RunCommand, input).Get(ctx, nil)
+	})
+	err := env.GetWorkflowError()
+	if err == nil {
+		t.Fatal("expected the step to fail")
+	}
+	attempts := AttemptsFromError(err)
+	if len(attempts) != 3 {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+	for i, attempt := range attempts {
+		if attempt.Attempt != int32(i+1) || attempt.Error == "" || attempt.EndedAt == "" {
+			t.Errorf("attempt %d = %+v", i+1, attempt)
+		}
+	}
+}
+
+func TestCloseLostAttempts(t *testing.T) {
+	if got := AttemptsFromError(errors.New("boom")); got != nil {
+		t.Errorf("plain error: %+v", got)
+	}
+	lost := closeLostAttempts([]StepAttempt{
+		{Attempt: 1, StartedAt: "2025-01-01T00:00:00Z", EndedAt: "2025-01-01T00:01:00Z", Error: "boom"},
+		{Attempt: 2, StartedAt: "2025-01-01T00:02:00Z"},
+	}, "2025-01-01T00:05:00Z")
+	if lost[0].Error != "boom" || lost[1].EndedAt != "2025-01-01T00:05:00Z" || lost[1].Error != lostAttemptError {
+		t.Errorf("closed = %+v", lost)
+	}
+}
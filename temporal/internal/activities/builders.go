@@ -0,0 +1,226 @@
+package activities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Builder is one remote buildkit endpoint of the worker's builder pool.
+type Builder struct {
+	Endpoint  string
+	MaxBuilds int
+}
+
+// builderHealthTTL is how long a health check result is trusted.
+const builderHealthTTL = 30 * time.Second
+
+// builderPollInterval is how often a build waiting for a free builder looks
+// again.
+var builderPollInterval = 2 * time.Second
+
+// builderHealthCheck reports whether a buildkit endpoint answers. tcp://
+// endpoints are dialled; unix:// sockets are left to buildx to report.
+var builderHealthCheck = func(ctx context.Context, endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "tcp" {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: 3 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", parsed.Host)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// ensureBuildxBuilder registers endpoint as a buildx remote builder called
+// name unless it already exists.
+var ensureBuildxBuilder = func(ctx context.Context, name, endpoint string) error {
+	if exec.CommandContext(ctx, "docker", "buildx", "inspect", name).Run() == nil {
+		return nil
+	}
+	output, err := exec.CommandContext(ctx, "docker", "buildx", "create", "--name", name, "--driver", "remote", endpoint).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("docker buildx create %s: %v: %s", endpoint, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+type poolBuilder struct {
+	Builder
+	name       string
+	active     int
+	healthy    bool
+	checked    time.Time
+	registered bool
+}
+
+type builderPool struct {
+	mu       sync.Mutex
+	builders []*poolBuilder
+}
+
+var builders = &builderPool{}
+
+// ParseBuilderPool parses a worker's builder pool, a comma separated list of
+// buildkit endpoints with an optional concurrent build cap, e.g.
+// "tcp://bk-1:1234*4,tcp://bk-2:1234*2". The cap defaults to 1.
+func ParseBuilderPool(spec string) ([]Builder, error) {
+	var pool []Builder
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, capText, hasCap := strings.Cut(entry, "*")
+		endpoint = strings.TrimSpace(endpoint)
+		builder := Builder{Endpoint: endpoint, MaxBuilds: 1}
+		if hasCap {
+			limit, err := strconv.Atoi(strings.TrimSpace(capText))
+			if err != nil || limit <= 0 {
+				return nil, fmt.Errorf("builder %q: build cap must be a positive integer", entry)
+			}
+			builder.MaxBuilds = limit
+		}
+		if parsed, err := url.Parse(endpoint); err != nil || (parsed.Scheme != "tcp" && parsed.Scheme != "unix") {
+			return nil, fmt.Errorf("builder %q: want a tcp:// or unix:// buildkit endpoint", entry)
+		}
+		if seen[endpoint] {
+			return nil, fmt.Errorf("builder %s is listed twice", endpoint)
+		}
+		seen[endpoint] = true
+		pool = append(pool, builder)
+	}
+	return pool, nil
+}
+
+// ConfigureBuilderPool replaces the worker's builder pool with spec (see
+// ParseBuilderPool). Build caps apply to this worker process.
+func ConfigureBuilderPool(spec string) ([]Builder, error) {
+	pool, err := ParseBuilderPool(spec)
+	if err != nil {
+		return nil, err
+	}
+	configured := make([]*poolBuilder, len(pool))
+	for i, builder := range pool {
+		sum := sha256.Sum256([]byte(builder.Endpoint))
+		configured[i] = &poolBuilder{Builder: builder, name: "sygaldry-" + hex.EncodeToString(sum[:4])}
+	}
+	builders.mu.Lock()
+	builders.builders = configured
+	builders.mu.Unlock()
+	return pool, nil
+}
+
+// acquire waits for the least loaded healthy builder with a free slot,
+// noting any wait on log, and returns the buildx builder to use. release
+// frees the slot.
+func (p *builderPool) acquire(ctx context.Context, log io.Writer) (*poolBuilder, func(), error) {
+	waiting := false
+	for {
+		p.refreshHealth(ctx)
+		p.mu.Lock()
+		if len(p.builders) == 0 {
+			p.mu.Unlock()
+			return nil, nil, temporal.NewNonRetryableApplicationError(
+				"docker_build uses the builder pool, but this worker has none (TEMPORAL_BUILDKIT_POOL)", "BuilderPoolNotConfigured", nil)
+		}
+		var best *poolBuilder
+		healthy := 0
+		for _, builder := range p.builders {
+			if !builder.healthy {
+				continue
+			}
+			healthy++
+			if builder.active >= builder.MaxBuilds {
+				continue
+			}
+			// Compare active/MaxBuilds without floating point.
+			if best == nil || builder.active*best.MaxBuilds < best.active*builder.MaxBuilds {
+				best = builder
+			}
+		}
+		if best != nil {
+			best.active++
+			p.mu.Unlock()
+			release := func() {
+				p.mu.Lock()
+				best.active--
+				p.mu.Unlock()
+			}
+			return best, release, nil
+		}
+		p.mu.Unlock()
+		if healthy == 0 {
+			// Retryable: the builders may come back before the step's
+			// retries run out.
+			return nil, nil, fmt.Errorf("no healthy builder in the pool")
+		}
+		if !waiting {
+			fmt.Fprintln(log, "waiting for a free builder")
+			waiting = true
+		}
+		timer := time.NewTimer(builderPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// refreshHealth re-checks builders whose last check has expired.
+func (p *builderPool) refreshHealth(ctx context.Context) {
+	p.mu.Lock()
+	var stale []*poolBuilder
+	now := time.Now()
+	for _, builder := range p.builders {
+		if now.Sub(builder.checked) >= builderHealthTTL {
+			stale = append(stale, builder)
+		}
+	}
+	p.mu.Unlock()
+	for _, builder := range stale {
+		err := builderHealthCheck(ctx, builder.Endpoint)
+		p.mu.Lock()
+		builder.healthy, builder.checked = err == nil, time.Now()
+		p.mu.Unlock()
+	}
+}
+
+// acquirePoolBuilder waits for a pool builder for a docker buildx build and
+// returns the environment that selects it (BUILDX_BUILDER).
+func acquirePoolBuilder(ctx context.Context, log io.Writer) (map[string]string, func(), error) {
+	builder, release, err := builders.acquire(ctx, log)
+	if err != nil {
+		return nil, nil, err
+	}
+	builders.mu.Lock()
+	registered := builder.registered
+	builders.mu.Unlock()
+	if !registered {
+		if err := ensureBuildxBuilder(ctx, builder.name, builder.Endpoint); err != nil {
+			release()
+			return nil, nil, err
+		}
+		builders.mu.Lock()
+		builder.registered = true
+		builders.mu.Unlock()
+	}
+	fmt.Fprintf(log, "building on %s\n", builder.Endpoint)
+	return map[string]string{"BUILDX_BUILDER": builder.name}, release, nil
+}
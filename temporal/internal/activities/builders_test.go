@@ -0,0 +1,91 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBuilderPool(t *testing.T) {
+	pool, err := ParseBuilderPool(" tcp://bk-1:1234*4, tcp://bk-2:1234 ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pool) != 2 || pool[0] != (Builder{Endpoint: "tcp://bk-1:1234", MaxBuilds: 4}) || pool[1].MaxBuilds != 1 {
+		t.Errorf("pool = %+v", pool)
+	}
+	for _, spec := range []string{"bk-1:1234", "tcp://bk-1:1234*0", "tcp://bk-1:1234*x", "tcp://a:1,tcp://a:1"} {
+		if _, err := ParseBuilderPool(spec); err == nil {
+			t.Errorf("ParseBuilderPool(%q) accepted an invalid pool", spec)
+		}
+	}
+}
+
+func TestBuilderPoolPicksLeastLoadedHealthyBuilder(t *testing.T) {
+	down := map[string]bool{"tcp://bk-3:1234": true}
+	oldCheck, oldEnsure, oldPoll := builderHealthCheck, ensureBuildxBuilder, builderPollInterval
+	builderHealthCheck = func(ctx context.Context, endpoint string) error {
+		if down[endpoint] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+	registered := 0
+	ensureBuildxBuilder = func(ctx context.Context, name, endpoint string) error {
+		registered++
+		return nil
+	}
+	builderPollInterval = 10 * time.Millisecond
+	defer func() {
+		builderHealthCheck, ensureBuildxBuilder, builderPollInterval = oldCheck, oldEnsure, oldPoll
+		ConfigureBuilderPool("")
+	}()
+	if _, err := ConfigureBuilderPool("tcp://bk-1:1234*2,tcp://bk-2:1234,tcp://bk-3:1234*8"); err != nil {
+		t.Fatal(err)
+	}
+
+	var log bytes.Buffer
+	ctx := context.Background()
+	// bk-3 is down, so the three slots are two on bk-1 and one on bk-2.
+	var used []string
+	var releases []func()
+	for i := 0; i < 3; i++ {
+		env, release, err := acquirePoolBuilder(ctx, &log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		used = append(used, env["BUILDX_BUILDER"])
+		releases = append(releases, release)
+	}
+	if used[0] == used[1] || used[0] != used[2] {
+		t.Errorf("builders used = %v, want bk-1, bk-2, bk-1", used)
+	}
+	if registered != 2 {
+		t.Errorf("registered %d builders, want 2", registered)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := acquirePoolBuilder(waitCtx, &log); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("full pool: err = %v", err)
+	}
+	if !strings.Contains(log.String(), "waiting for a free builder") {
+		t.Errorf("log = %q", log.String())
+	}
+	releases[1]()
+	if env, release, err := acquirePoolBuilder(ctx, &log); err != nil || env["BUILDX_BUILDER"] != used[1] {
+		t.Errorf("after release: env = %v, err = %v", env, err)
+	} else {
+		release()
+	}
+}
+
+func TestBuilderPoolNotConfigured(t *testing.T) {
+	ConfigureBuilderPool("")
+	if _, _, err := acquirePoolBuilder(context.Background(), &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "TEMPORAL_BUILDKIT_POOL") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package activities
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DockerLogsPrefix marks a capture entry as a container whose `docker logs`
+// output is captured, e.g. "docker-logs:trainer".
+const DockerLogsPrefix = "docker-logs:"
+
+const defaultFailureCaptureMaxBytes = 256 << 20
+
+// FailureArtifact describes the debug bundle captured after a step failed.
+type FailureArtifact struct {
+	Path        string   `json:"path"`
+	SizeBytes   int64    `json:"sizeBytes"`
+	Files       int      `json:"files"`
+	Missing     []string `json:"missing,omitempty"`
+	Truncated   bool     `json:"truncated,omitempty"`
+	WorkerQueue string   `json:"workerQueue,omitempty"`
+}
+
+type CaptureFailureInput struct {
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	StepID     string `json:"stepId"`
+	LogDir     string `json:"logDir"`
+	// Paths are files, directories or globs, or DockerLogsPrefix entries.
+	Paths []string `json:"paths"`
+}
+
+// CaptureFailureArtifacts packs a failed step's declared debug paths into
+// <prefix>_failure.tar.gz in the log directory (and so in S3 when the log
+// directory is remote). It must run on the worker that ran the step. Entries
+// that match nothing are reported in Missing rather than failing the capture,
+// and files beyond TEMPORAL_FAILURE_CAPTURE_MAX_BYTES (default 256 MiB) are
+// left out with Truncated set.
+func CaptureFailureArtifacts(ctx context.Context, input CaptureFailureInput) (FailureArtifact, error) {
+	if len(input.Paths) == 0 {
+		return FailureArtifact{}, errors.New("no capture paths declared")
+	}
+	logFS, err := openLogFS(resolveLogDir(input.LogDir))
+	if err != nil {
+		return FailureArtifact{}, fmt.Errorf("log directory unavailable: %w", err)
+	}
+	key, err := LogEncryptionKey()
+	if err != nil {
+		return FailureArtifact{}, fmt.Errorf("log encryption unavailable: %w", err)
+	}
+	name := LogFilePrefix(input.WorkflowID, input.RunID) + safeName(input.StepID) + "_failure.tar.gz"
+	if key != nil {
+		name += ".enc"
+	}
+	writer, closer, err := createLogFile(logFS, name, key)
+	if err != nil {
+		return FailureArtifact{}, err
+	}
+
+	counter := &countingWriter{w: writer}
+	gz := gzip.NewWriter(counter)
+	tw := tar.NewWriter(gz)
+	capture := &failureCapture{tw: tw, budget: failureCaptureMaxBytes()}
+	artifact := FailureArtifact{Path: logFS.Path(name), WorkerQueue: workerQueue()}
+	for _, entry := range input.Paths {
+		if err := ctx.Err(); err != nil {
+			closer.Close()
+			return artifact, err
+		}
+		found, err := capture.add(ctx, entry)
+		if err != nil {
+			closer.Close()
+			return artifact, err
+		}
+		if !found {
+			artifact.Missing = append(artifact.Missing, entry)
+		}
+	}
+	err = errors.Join(tw.Close(), gz.Close())
+	if closeErr := closer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return artifact, err
+	}
+	artifact.Files = capture.files
+	artifact.Truncated = capture.truncated
+	artifact.SizeBytes = counter.n
+	return artifact, nil
+}
+
+func failureCaptureMaxBytes() int64 {
+	if value := os.Getenv("TEMPORAL_FAILURE_CAPTURE_MAX_BYTES"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultFailureCaptureMaxBytes
+}
+
+type failureCapture struct {
+	tw        *tar.Writer
+	budget    int64
+	files     int
+	truncated bool
+}
+
+// add captures one entry and reports whether it matched anything.
+func (c *failureCapture) add(ctx context.Context, entry string) (bool, error) {
+	if container, ok := strings.CutPrefix(entry, DockerLogsPrefix); ok {
+		cmd := exec.CommandContext(ctx, "docker", "logs", "--timestamps", container)
+		out, err := cmd.CombinedOutput()
+		if err != nil && len(out) == 0 {
+			return false, nil
+		}
+		return true, c.addBytes("docker-logs/"+safeName(container)+".log", out)
+	}
+
+	matches, err := filepath.Glob(entry)
+	if err != nil {
+		return false, fmt.Errorf("invalid capture pattern %q: %w", entry, err)
+	}
+	found := false
+	for _, match := range matches {
+		err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return nil
+			}
+			found = true
+			return c.addFile(path)
+		})
+		if err != nil {
+			return found, err
+		}
+	}
+	return found, nil
+}
+
+func (c *failureCapture) addFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil
+	}
+	if info.Size() > c.budget {
+		c.truncated = true
+		return nil
+	}
+	header := &tar.Header{
+		Name:    archiveName(path),
+		Mode:    int64(info.Mode().Perm()),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if err := c.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	// The file may still be growing; copy exactly the size in the header.
+	if _, err := io.CopyN(c.tw, file, info.Size()); err != nil {
+		return fmt.Errorf("capture %s: %w", path, err)
+	}
+	c.budget -= info.Size()
+	c.files++
+	return nil
+}
+
+func (c *failureCapture) addBytes(name string, data []byte) error {
+	if int64(len(data)) > c.budget {
+		c.truncated = true
+		return nil
+	}
+	header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: time.Now()}
+	if err := c.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := c.tw.Write(data); err != nil {
+		return err
+	}
+	c.budget -= int64(len(data))
+	c.files++
+	return nil
+}
+
+// archiveName keeps the captured file's path, made relative to the root.
+func archiveName(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
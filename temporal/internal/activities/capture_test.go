@@ -0,0 +1,97 @@
+package activities
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func readCapture(t *testing.T, path string) map[string]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestCaptureFailureArtifacts(t *testing.T) {
+	work := t.TempDir()
+	os.WriteFile(filepath.Join(work, "core.123"), []byte("core"), 0o644)
+	os.MkdirAll(filepath.Join(work, "tmp", "nested"), 0o755)
+	os.WriteFile(filepath.Join(work, "tmp", "nested", "state.json"), []byte("{}"), 0o644)
+	os.WriteFile(filepath.Join(work, "huge.bin"), make([]byte, 64), 0o644)
+	t.Setenv("TEMPORAL_FAILURE_CAPTURE_MAX_BYTES", "32")
+	setWorkerQueue(t, "gpu-1")
+
+	logDir := t.TempDir()
+	artifact, err := CaptureFailureArtifacts(context.Background(), CaptureFailureInput{
+		WorkflowID: "wf",
+		RunID:      "run",
+		StepID:     "train",
+		LogDir:     logDir,
+		Paths: []string{
+			filepath.Join(work, "core.*"),
+			filepath.Join(work, "tmp"),
+			filepath.Join(work, "huge.bin"),
+			filepath.Join(work, "missing.log"),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if artifact.Path != filepath.Join(logDir, "wf_run_train_failure.tar.gz") || artifact.WorkerQueue != "gpu-1" {
+		t.Errorf("artifact = %+v", artifact)
+	}
+	if artifact.Files != 2 || !artifact.Truncated || len(artifact.Missing) != 1 || artifact.Missing[0] != filepath.Join(work, "missing.log") {
+		t.Errorf("artifact = %+v, want 2 files, truncated, one missing entry", artifact)
+	}
+	if info, err := os.Stat(artifact.Path); err != nil || info.Size() != artifact.SizeBytes {
+		t.Errorf("size %d does not match file: %v %v", artifact.SizeBytes, info, err)
+	}
+
+	files := readCapture(t, artifact.Path)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{archiveName(filepath.Join(work, "core.123")), archiveName(filepath.Join(work, "tmp", "nested", "state.json"))}
+	if len(names) != 2 || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("archive entries = %v, want %v", names, want)
+	}
+	if files[want[0]] != "core" {
+		t.Errorf("core contents = %q", files[want[0]])
+	}
+}
+
+func TestCaptureFailureArtifactsInvalidPattern(t *testing.T) {
+	_, err := CaptureFailureArtifacts(context.Background(), CaptureFailureInput{
+		WorkflowID: "wf", StepID: "s", LogDir: t.TempDir(), Paths: []string{"[bad"},
+	})
+	if err == nil {
+		t.Error("expected an invalid pattern error")
+	}
+}
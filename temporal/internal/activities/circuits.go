@@ -0,0 +1,147 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CircuitRecord is how a step that depends on a shared target, such as a
+// registry or the HF hub, ended in one run. Runs append them to the circuit
+// log when they finish.
+type CircuitRecord struct {
+	Timestamp  string `json:"timestamp"`
+	Key        string `json:"key"`
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	StepID     string `json:"stepId"`
+	Failed     bool   `json:"failed"`
+	Error      string `json:"error,omitempty"`
+}
+
+type RecordCircuitsInput struct {
+	LogDir  string          `json:"logDir"`
+	Records []CircuitRecord `json:"records"`
+}
+
+type CheckCircuitInput struct {
+	LogDir string `json:"logDir"`
+	Key    string `json:"key"`
+	// Failures is how many recent runs must have failed on Key, with no
+	// success since, for the circuit to be open. Only records newer than
+	// WindowSec count.
+	Failures  int   `json:"failures"`
+	WindowSec int64 `json:"windowSec"`
+}
+
+// CircuitState is the state of the circuit of a key. An open circuit closes
+// at RetryAt, when its oldest counted failure leaves the window, unless
+// more runs fail before then.
+type CircuitState struct {
+	Open      bool   `json:"open"`
+	Failures  int    `json:"failures"`
+	LastError string `json:"lastError,omitempty"`
+	RetryAt   string `json:"retryAt,omitempty"`
+}
+
+// CircuitPath is the circuit log of a log directory: circuits.jsonl in its
+// results store.
+func CircuitPath(logDir string) string {
+	return filepath.Join(resultsDir(logDir), "circuits.jsonl")
+}
+
+// RecordCircuits appends a run's records to the circuit log.
+func RecordCircuits(ctx context.Context, input RecordCircuitsInput) error {
+	if len(input.Records) == 0 {
+		return nil
+	}
+	path := CircuitPath(input.LogDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	var data []byte
+	for _, record := range input.Records {
+		record.Timestamp = now
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return errors.Join(err, file.Close())
+}
+
+// CheckCircuit reports whether the last input.Failures runs that used the
+// key within the window all failed on it. A missing log means no failures.
+func CheckCircuit(ctx context.Context, input CheckCircuitInput) (CircuitState, error) {
+	records, err := readCircuitRecords(CircuitPath(input.LogDir), input.Key)
+	if errors.Is(err, os.ErrNotExist) {
+		return CircuitState{}, nil
+	}
+	if err != nil {
+		return CircuitState{}, err
+	}
+	return circuitState(records, input, time.Now()), nil
+}
+
+func circuitState(records []CircuitRecord, input CheckCircuitInput, now time.Time) CircuitState {
+	var state CircuitState
+	window := time.Duration(input.WindowSec) * time.Second
+	failed := map[string]bool{}
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		at, err := time.Parse(time.RFC3339, record.Timestamp)
+		if err != nil || now.Sub(at) > window {
+			break
+		}
+		if !record.Failed {
+			break
+		}
+		if state.LastError == "" {
+			state.LastError = record.Error
+		}
+		run := record.WorkflowID + "/" + record.RunID
+		if failed[run] {
+			continue
+		}
+		failed[run] = true
+		state.Failures++
+		if state.Failures == input.Failures {
+			state.Open = true
+			state.RetryAt = at.Add(window).UTC().Format(time.RFC3339)
+			break
+		}
+	}
+	return state
+}
+
+// readCircuitRecords returns the records of key, oldest first. Malformed
+// lines are skipped.
+func readCircuitRecords(path, key string) ([]CircuitRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []CircuitRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var record CircuitRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil || record.Key != key {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,68 @@
+package activities
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCircuitState(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hoursAgo int) string { return now.Add(-time.Duration(hoursAgo) * time.Hour).Format(time.RFC3339) }
+	fail := func(run string, hoursAgo int) CircuitRecord {
+		return CircuitRecord{Timestamp: at(hoursAgo), Key: "registry:ghcr.io", WorkflowID: "wf", RunID: run, Failed: true, Error: "503 from " + run}
+	}
+	ok := func(run string, hoursAgo int) CircuitRecord {
+		return CircuitRecord{Timestamp: at(hoursAgo), Key: "registry:ghcr.io", WorkflowID: "wf", RunID: run}
+	}
+	input := CheckCircuitInput{Failures: 3, WindowSec: 24 * 3600}
+	tests := []struct {
+		name     string
+		records  []CircuitRecord
+		open     bool
+		failures int
+	}{
+		{"no records", nil, false, 0},
+		{"three failed runs", []CircuitRecord{fail("a", 5), fail("b", 3), fail("c", 1)}, true, 3},
+		{"success since", []CircuitRecord{fail("a", 5), fail("b", 3), ok("c", 2), fail("d", 1)}, false, 1},
+		{"one run failing twice", []CircuitRecord{fail("a", 5), fail("b", 3), fail("b", 3)}, false, 2},
+		{"old failures", []CircuitRecord{fail("a", 30), fail("b", 3), fail("c", 1)}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := circuitState(tt.records, input, now)
+			if state.Open != tt.open || state.Failures != tt.failures {
+				t.Errorf("state = %+v, want open %v with %d failures", state, tt.open, tt.failures)
+			}
+		})
+	}
+
+	state := circuitState([]CircuitRecord{fail("a", 5), fail("b", 3), fail("c", 1)}, input, now)
+	if state.LastError != "503 from c" || state.RetryAt != now.Add(19*time.Hour).Format(time.RFC3339) {
+		t.Errorf("state = %+v, want the last error and a retry when run a leaves the window", state)
+	}
+}
+
+func TestRecordAndCheckCircuit(t *testing.T) {
+	logDir := t.TempDir()
+	for _, run := range []string{"r1", "r2"} {
+		err := RecordCircuits(context.Background(), RecordCircuitsInput{LogDir: logDir, Records: []CircuitRecord{
+			{Key: "hf-hub", WorkflowID: "wf", RunID: run, StepID: "model", Failed: true, Error: "timeout"},
+			{Key: "host:example.com", WorkflowID: "wf", RunID: run, StepID: "data"},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	state, err := CheckCircuit(context.Background(), CheckCircuitInput{LogDir: logDir, Key: "hf-hub", Failures: 2, WindowSec: 3600})
+	if err != nil || !state.Open {
+		t.Errorf("hf-hub = %+v, %v; want open", state, err)
+	}
+	state, err = CheckCircuit(context.Background(), CheckCircuitInput{LogDir: logDir, Key: "host:example.com", Failures: 2, WindowSec: 3600})
+	if err != nil || state.Open {
+		t.Errorf("host:example.com = %+v, %v; want closed", state, err)
+	}
+	if state, err := CheckCircuit(context.Background(), CheckCircuitInput{LogDir: t.TempDir(), Key: "hf-hub", Failures: 1, WindowSec: 3600}); err != nil || state.Open {
+		t.Errorf("without a log = %+v, %v", state, err)
+	}
+}
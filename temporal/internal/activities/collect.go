@@ -0,0 +1,194 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunLogsDir is the directory of a run under a log collection destination.
+func RunLogsDir(workflowID, runID string) string {
+	return safeName(workflowID) + "_" + safeName(runID)
+}
+
+// CollectedFile is a log file copied to a run's collection.
+type CollectedFile struct {
+	// Name is relative to the run's collection directory:
+	// <worker>/<file name>.
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Source string `json:"source"`
+	Worker string `json:"worker"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type CollectRunLogsInput struct {
+	LogDir      string `json:"logDir"`
+	Destination string `json:"destination"`
+	WorkflowID  string `json:"workflowId"`
+	RunID       string `json:"runId"`
+}
+
+type CollectRunLogsResult struct {
+	Worker string          `json:"worker"`
+	Files  []CollectedFile `json:"files,omitempty"`
+	// Remote is set when the worker's log directory is remote, e.g. S3:
+	// its files are already in one place and are not copied.
+	Remote string `json:"remote,omitempty"`
+}
+
+// CollectRunLogs copies this worker's files of a run, and its lines of
+// events.jsonl, from its log directory to <destination>/<run>/<worker>/. It
+// runs on each worker that took part in the run. Encrypted files are copied
+// as they are.
+func CollectRunLogs(ctx context.Context, input CollectRunLogsInput) (CollectRunLogsResult, error) {
+	worker := attemptWorker()
+	result := CollectRunLogsResult{Worker: worker}
+	logDir := resolveLogDir(input.LogDir)
+	if IsRemoteLogDir(logDir) {
+		result.Remote = logDir
+		return result, nil
+	}
+	dest, err := openLogFS(logLocation(input.Destination))
+	if err != nil {
+		return result, fmt.Errorf("log collection destination unavailable: %w", err)
+	}
+	runDir, workerDir := RunLogsDir(input.WorkflowID, input.RunID), safeName(worker)
+	prefix := LogFilePrefix(input.WorkflowID, input.RunID)
+
+	entries, err := os.ReadDir(logDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		source := filepath.Join(logDir, entry.Name())
+		file, err := os.Open(source)
+		if err != nil {
+			return result, err
+		}
+		collected, err := copyToLogFS(dest, runDir, path.Join(workerDir, entry.Name()), file)
+		file.Close()
+		if err != nil {
+			return result, fmt.Errorf("collect %s: %w", source, err)
+		}
+		collected.Source, collected.Worker = source, worker
+		result.Files = append(result.Files, collected)
+	}
+
+	events, err := runEvents(filepath.Join(logDir, "events.jsonl"), input.WorkflowID, input.RunID)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return result, err
+	}
+	if len(events) > 0 {
+		collected, err := copyToLogFS(dest, runDir, path.Join(workerDir, "events.jsonl"), bytes.NewReader(events))
+		if err != nil {
+			return result, fmt.Errorf("collect events: %w", err)
+		}
+		collected.Source, collected.Worker = filepath.Join(logDir, "events.jsonl"), worker
+		result.Files = append(result.Files, collected)
+	}
+	return result, nil
+}
+
+// copyToLogFS writes r to dir/name in fs and describes the copy.
+func copyToLogFS(fs LogFS, dir, name string, r io.Reader) (CollectedFile, error) {
+	writer, err := fs.Create(path.Join(dir, name))
+	if err != nil {
+		return CollectedFile{}, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(writer, hash), r)
+	if err := errors.Join(err, writer.Close()); err != nil {
+		return CollectedFile{}, err
+	}
+	return CollectedFile{
+		Name:   name,
+		Path:   fs.Path(path.Join(dir, name)),
+		Size:   size,
+		Sha256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// runEvents returns the lines of an events.jsonl file that belong to a run.
+// Damaged lines are left out and logged.
+func runEvents(path, workflowID, runID string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var lines []byte
+	damaged, err := ScanEvents(file, func(event StepEvent, line []byte) {
+		if event.WorkflowID == workflowID && event.RunID == runID {
+			lines = append(append(lines, line...), '\n')
+		}
+	})
+	if damaged > 0 {
+		log.Printf("skipped %d damaged lines of %s", damaged, path)
+	}
+	return lines, err
+}
+
+// RunLogManifest lists the files collected for a run. It is written as
+// manifest.json in the run's collection directory.
+type RunLogManifest struct {
+	WorkflowID  string          `json:"workflowId"`
+	RunID       string          `json:"runId"`
+	CollectedAt string          `json:"collectedAt"`
+	Workers     []string        `json:"workers"`
+	Files       []CollectedFile `json:"files"`
+	// Remote lists log directories that were already shared and not copied.
+	Remote []string `json:"remote,omitempty"`
+	// Missing lists workers whose files could not be collected, with why.
+	Missing []string `json:"missing,omitempty"`
+}
+
+type WriteRunLogManifestInput struct {
+	Destination string         `json:"destination"`
+	Manifest    RunLogManifest `json:"manifest"`
+}
+
+// WriteRunLogManifest writes the manifest of a run's collection and returns
+// its location.
+func WriteRunLogManifest(ctx context.Context, input WriteRunLogManifestInput) (string, error) {
+	dest, err := openLogFS(logLocation(input.Destination))
+	if err != nil {
+		return "", fmt.Errorf("log collection destination unavailable: %w", err)
+	}
+	manifest := input.Manifest
+	manifest.CollectedAt = time.Now().UTC().Format(time.RFC3339)
+	sort.Slice(manifest.Files, func(i, j int) bool { return manifest.Files[i].Name < manifest.Files[j].Name })
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := path.Join(RunLogsDir(manifest.WorkflowID, manifest.RunID), "manifest.json")
+	writer, err := dest.Create(name)
+	if err != nil {
+		return "", err
+	}
+	_, err = writer.Write(append(data, '\n'))
+	if err := errors.Join(err, writer.Close()); err != nil {
+		return "", err
+	}
+	return dest.Path(name), nil
+}
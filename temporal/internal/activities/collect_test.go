@@ -0,0 +1,73 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCollectRunLogs(t *testing.T) {
+	setWorkerQueue(t, "gpu-1")
+	logDir, dest := t.TempDir(), t.TempDir()
+	for name, data := range map[string]string{
+		"wf_run_train.log":     "training\n",
+		"wf_run_train.err.log": "warning\n",
+		"wf_other_train.log":   "another run\n",
+		"events.jsonl":         `{"workflowId":"wf","runId":"run","stepId":"train","status":"started"}` + "\n" + `{"workflowId":"wf","runId":"other","stepId":"train","status":"started"}` + "\n",
+	} {
+		if err := os.WriteFile(filepath.Join(logDir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := CollectRunLogsInput{LogDir: logDir, Destination: dest, WorkflowID: "wf", RunID: "run"}
+	result, err := CollectRunLogs(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Worker != "gpu-1" || len(result.Files) != 3 {
+		t.Fatalf("result = %+v, want 3 files from gpu-1", result)
+	}
+	workerDir := filepath.Join(dest, "wf_run", "gpu-1")
+	events, err := os.ReadFile(filepath.Join(workerDir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(events), "other") || !strings.Contains(string(events), `"runId":"run"`) {
+		t.Errorf("collected events = %s, want only this run's", events)
+	}
+	if _, err := os.Stat(filepath.Join(workerDir, "wf_other_train.log")); !os.IsNotExist(err) {
+		t.Errorf("another run's log was collected: %v", err)
+	}
+
+	path, err := WriteRunLogManifest(context.Background(), WriteRunLogManifestInput{
+		Destination: dest,
+		Manifest:    RunLogManifest{WorkflowID: "wf", RunID: "run", Workers: []string{result.Worker}, Files: result.Files, Missing: []string{"gpu-2: timeout"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dest, "wf_run", "manifest.json") {
+		t.Errorf("manifest path = %s", path)
+	}
+	var manifest RunLogManifest
+	data, _ := os.ReadFile(path)
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 3 || manifest.Files[0].Name != "gpu-1/events.jsonl" || manifest.Files[0].Sha256 == "" || manifest.CollectedAt == "" {
+		t.Errorf("manifest = %+v", manifest)
+	}
+}
+
+func TestCollectRunLogsSkipsRemoteLogDir(t *testing.T) {
+	result, err := CollectRunLogs(context.Background(), CollectRunLogsInput{LogDir: "s3://logs/sygaldry", Destination: t.TempDir(), WorkflowID: "wf", RunID: "run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Remote != "s3://logs/sygaldry" || len(result.Files) != 0 {
+		t.Errorf("result = %+v, want the remote log dir recorded and nothing copied", result)
+	}
+}
@@ -0,0 +1,60 @@
+package activities
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// DockerHost points a docker_build or docker_push step at a remote daemon.
+// For tcp:// hosts the TLS client material is read from the worker
+// environment variables named by CAEnv, CertEnv and KeyEnv, so it never
+// appears in workflow history.
+type DockerHost struct {
+	URL     string `json:"url"`
+	CAEnv   string `json:"caEnv,omitempty"`
+	CertEnv string `json:"certEnv,omitempty"`
+	KeyEnv  string `json:"keyEnv,omitempty"`
+}
+
+// dockerHostEnv returns the environment that makes the docker CLI talk to
+// host. TLS material is written to a private directory that cleanup removes.
+func dockerHostEnv(host *DockerHost) (map[string]string, func(), error) {
+	if host == nil || host.URL == "" {
+		return nil, func() {}, nil
+	}
+	parsed, err := url.Parse(host.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "ssh" && parsed.Scheme != "tcp") {
+		return nil, nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("docker_host %q must be an ssh:// or tcp:// URL", host.URL), "InvalidDockerHost", nil)
+	}
+	env := map[string]string{"DOCKER_HOST": host.URL}
+	if parsed.Scheme == "ssh" {
+		return env, func() {}, nil
+	}
+
+	material := map[string]string{"ca.pem": host.CAEnv, "cert.pem": host.CertEnv, "key.pem": host.KeyEnv}
+	dir, err := os.MkdirTemp("", "sygaldry-docker-tls-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	for name, envName := range material {
+		value := os.Getenv(envName)
+		if envName == "" || value == "" {
+			cleanup()
+			return nil, nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("docker_host %s: TLS secret env %q for %s is not set on the worker", host.URL, envName, name), "DockerHostSecretMissing", nil)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o600); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+	}
+	env["DOCKER_TLS_VERIFY"] = "1"
+	env["DOCKER_CERT_PATH"] = dir
+	return env, cleanup, nil
+}
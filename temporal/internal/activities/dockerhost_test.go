@@ -0,0 +1,51 @@
+package activities
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerHostEnv(t *testing.T) {
+	env, cleanup, err := dockerHostEnv(&DockerHost{URL: "ssh://builder@build-01"})
+	if err != nil || env["DOCKER_HOST"] != "ssh://builder@build-01" || env["DOCKER_CERT_PATH"] != "" {
+		t.Fatalf("ssh host: env = %v, err = %v", env, err)
+	}
+	cleanup()
+
+	t.Setenv("BUILD01_CA", "ca-pem")
+	t.Setenv("BUILD01_CERT", "cert-pem")
+	t.Setenv("BUILD01_KEY", "key-pem")
+	host := &DockerHost{URL: "tcp://build-01:2376", CAEnv: "BUILD01_CA", CertEnv: "BUILD01_CERT", KeyEnv: "BUILD01_KEY"}
+	env, cleanup, err = dockerHostEnv(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := env["DOCKER_CERT_PATH"]
+	if env["DOCKER_HOST"] != host.URL || env["DOCKER_TLS_VERIFY"] != "1" || dir == "" {
+		t.Fatalf("tcp host: env = %v", env)
+	}
+	info, err := os.Stat(filepath.Join(dir, "key.pem"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key.pem: %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "ca.pem")); string(data) != "ca-pem" {
+		t.Errorf("ca.pem = %q", data)
+	}
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("TLS directory not removed: %v", err)
+	}
+
+	host.KeyEnv = "BUILD01_MISSING"
+	if _, _, err := dockerHostEnv(host); err == nil || !strings.Contains(err.Error(), "BUILD01_MISSING") {
+		t.Errorf("missing secret: err = %v", err)
+	}
+	if _, _, err := dockerHostEnv(&DockerHost{URL: "unix:///var/run/docker.sock"}); err == nil {
+		t.Error("expected unix:// host to be rejected")
+	}
+	if env, _, err := dockerHostEnv(nil); err != nil || env != nil {
+		t.Errorf("no host: env = %v, err = %v", env, err)
+	}
+}
@@ -0,0 +1,46 @@
+package activities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// EffectivePlanFile is the name, after the run's LogFilePrefix, of the file
+// holding the plan a run executed.
+const EffectivePlanFile = "effective-plan.json"
+
+type WriteEffectivePlanInput struct {
+	LogDir     string `json:"logDir"`
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	// Plan is the JSON of the effective plan, already redacted.
+	Plan []byte `json:"plan"`
+}
+
+type WriteEffectivePlanResult struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+}
+
+// WriteEffectivePlan writes the plan a run executed next to its logs, so it
+// is exported and collected with them.
+func WriteEffectivePlan(ctx context.Context, input WriteEffectivePlanInput) (WriteEffectivePlanResult, error) {
+	fs, err := openLogFS(resolveLogDir(input.LogDir))
+	if err != nil {
+		return WriteEffectivePlanResult{}, fmt.Errorf("log directory unavailable: %w", err)
+	}
+	name := LogFilePrefix(input.WorkflowID, input.RunID) + EffectivePlanFile
+	writer, err := fs.Create(name)
+	if err != nil {
+		return WriteEffectivePlanResult{}, err
+	}
+	_, err = writer.Write(input.Plan)
+	if err := errors.Join(err, writer.Close()); err != nil {
+		return WriteEffectivePlanResult{}, err
+	}
+	sum := sha256.Sum256(input.Plan)
+	return WriteEffectivePlanResult{Path: fs.Path(name), Sha256: hex.EncodeToString(sum[:])}, nil
+}
@@ -0,0 +1,336 @@
+package activities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// Event delivery metrics.
+const (
+	EventWriteErrorsMetric = "sygaldry_events_write_errors"
+	EventsFallbackMetric   = "sygaldry_events_fallback"
+	EventsPendingMetric    = "sygaldry_events_pending"
+)
+
+var (
+	// maxPendingEvents bounds the events held for log directories that cannot
+	// be written; the oldest go to the fallback sink beyond it.
+	maxPendingEvents = 10000
+	// eventRetryInterval is how soon a log directory that could not be
+	// written is tried again.
+	eventRetryInterval = 5 * time.Second
+	// eventWaitTimeout bounds how long a finishing step waits for its events
+	// to be delivered.
+	eventWaitTimeout = 10 * time.Second
+)
+
+type pendingEvent struct {
+	location string
+	line     []byte
+}
+
+// eventQueue delivers events to events.jsonl in order. Each log directory
+// has its own flusher, which writes outside mu, so a slow or unreachable
+// directory only holds back its own events. Events for a directory that
+// cannot be opened or written stay queued until its retry timer fires.
+type eventQueue struct {
+	mu          sync.Mutex
+	pending     []pendingEvent
+	flushers    map[string]*eventFlusher
+	writeErrors int64
+	fallback    int64
+	metrics     client.MetricsHandler
+}
+
+// eventFlusher is the delivery state of one log directory.
+type eventFlusher struct {
+	// running is set while a goroutine delivers the directory's events;
+	// done is closed when it stops.
+	running bool
+	done    chan struct{}
+	// inflight counts the events taken off the queue by the running write.
+	inflight int
+	// retrying is set while the retry timer is armed after a failed write.
+	retrying bool
+	// failing is set from a failed write to the next successful one.
+	failing bool
+}
+
+var events = &eventQueue{flushers: map[string]*eventFlusher{}, metrics: client.MetricsNopHandler}
+
+// EventStats reports how event delivery is doing on this worker.
+type EventStats struct {
+	Pending     int   `json:"pending"`
+	WriteErrors int64 `json:"writeErrors"`
+	Fallback    int64 `json:"fallback"`
+}
+
+// SetEventMetricsHandler reports event delivery metrics through handler.
+func SetEventMetricsHandler(handler client.MetricsHandler) {
+	if handler == nil {
+		handler = client.MetricsNopHandler
+	}
+	events.mu.Lock()
+	events.metrics = handler
+	events.mu.Unlock()
+}
+
+func CurrentEventStats() EventStats {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	return EventStats{Pending: events.pendingLocked(), WriteErrors: events.writeErrors, Fallback: events.fallback}
+}
+
+// emitEvent queues event for the events.jsonl of logDir and returns; the
+// directory's flusher writes it.
+func emitEvent(logDir string, event StepEvent) {
+	if logDir == "" {
+		return
+	}
+	if event.Timestamp == "" {
+		event.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	location := resolveLogDir(logDir)
+	events.mu.Lock()
+	events.pending = append(events.pending, pendingEvent{location: location, line: append(data, '\n')})
+	var dropped []pendingEvent
+	if over := len(events.pending) - maxPendingEvents; over > 0 {
+		dropped = append(dropped, events.pending[:over]...)
+		events.pending = events.pending[over:]
+	}
+	events.startLocked(location)
+	events.metrics.Gauge(EventsPendingMetric).Update(float64(events.pendingLocked()))
+	events.mu.Unlock()
+	events.toFallback(dropped)
+}
+
+// waitEvents waits, at most eventWaitTimeout, until the events queued so far
+// for logDir were written or failed to be.
+func waitEvents(logDir string) {
+	if logDir == "" {
+		return
+	}
+	events.mu.Lock()
+	f := events.flushers[resolveLogDir(logDir)]
+	if f == nil || !f.running {
+		events.mu.Unlock()
+		return
+	}
+	done := f.done
+	events.mu.Unlock()
+	timer := time.NewTimer(eventWaitTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+}
+
+func (q *eventQueue) pendingLocked() int {
+	n := len(q.pending)
+	for _, f := range q.flushers {
+		n += f.inflight
+	}
+	return n
+}
+
+// startLocked starts the flusher of location unless it is already running or
+// waiting to retry. It must be called with mu held.
+func (q *eventQueue) startLocked(location string) {
+	f := q.flushers[location]
+	if f == nil {
+		f = &eventFlusher{}
+		q.flushers[location] = f
+	}
+	if f.running || f.retrying {
+		return
+	}
+	f.running, f.done = true, make(chan struct{})
+	go q.flush(location, f)
+}
+
+// takeLocked removes the queued events of location and returns them with
+// their lines joined. It must be called with mu held.
+func (q *eventQueue) takeLocked(location string) ([]pendingEvent, []byte) {
+	var taken []pendingEvent
+	var data []byte
+	kept := q.pending[:0]
+	for _, event := range q.pending {
+		if event.location != location {
+			kept = append(kept, event)
+			continue
+		}
+		taken = append(taken, event)
+		data = append(data, event.line...)
+	}
+	clear(q.pending[len(kept):])
+	q.pending = kept
+	return taken, data
+}
+
+// flush appends the queued events of location in one write until none are
+// left. When a write fails the events go back to the queue and the flusher
+// stops until its retry timer fires.
+func (q *eventQueue) flush(location string, f *eventFlusher) {
+	for {
+		q.mu.Lock()
+		batch, data := q.takeLocked(location)
+		if len(batch) == 0 {
+			f.running = false
+			close(f.done)
+			q.mu.Unlock()
+			return
+		}
+		f.inflight = len(batch)
+		q.mu.Unlock()
+
+		err := deliverEvents(location, data)
+
+		q.mu.Lock()
+		f.inflight = 0
+		if err != nil {
+			// They are older than anything queued since.
+			q.pending = append(batch, q.pending...)
+			q.writeErrors++
+			q.metrics.Counter(EventWriteErrorsMetric).Inc(1)
+			if !f.failing {
+				log.Printf("unable to write events to %s; will retry: %v", location, err)
+				f.failing = true
+			}
+			f.running, f.retrying = false, true
+			close(f.done)
+			time.AfterFunc(eventRetryInterval, func() { q.retry(location) })
+			q.metrics.Gauge(EventsPendingMetric).Update(float64(q.pendingLocked()))
+			q.mu.Unlock()
+			return
+		}
+		if f.failing {
+			log.Printf("events to %s are delivered again", location)
+			f.failing = false
+		}
+		q.metrics.Gauge(EventsPendingMetric).Update(float64(q.pendingLocked()))
+		q.mu.Unlock()
+	}
+}
+
+func (q *eventQueue) retry(location string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if f := q.flushers[location]; f != nil {
+		f.retrying = false
+	}
+	q.startLocked(location)
+}
+
+func deliverEvents(location string, data []byte) error {
+	fs, err := openLogFS(location)
+	if err != nil {
+		return err
+	}
+	return appendEvents(fs, data)
+}
+
+// fallbackLine is an event that could not be delivered to its log directory.
+type fallbackLine struct {
+	LogDir string          `json:"logDir"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// eventsFallbackPath is the local file that receives undeliverable events:
+// TEMPORAL_EVENTS_FALLBACK, or sygaldry-events-fallback.jsonl in the temp
+// directory.
+func eventsFallbackPath() string {
+	if path := os.Getenv("TEMPORAL_EVENTS_FALLBACK"); path != "" {
+		return path
+	}
+	return filepath.Join(os.TempDir(), "sygaldry-events-fallback.jsonl")
+}
+
+// toFallback writes events to the fallback sink, or as a last resort to the
+// worker log, so they are never silently lost.
+func (q *eventQueue) toFallback(pending []pendingEvent) error {
+	if len(pending) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	q.fallback += int64(len(pending))
+	q.metrics.Counter(EventsFallbackMetric).Inc(int64(len(pending)))
+	q.mu.Unlock()
+	var data []byte
+	for _, event := range pending {
+		line, _ := json.Marshal(fallbackLine{LogDir: event.location, Event: json.RawMessage(event.line[:len(event.line)-1])})
+		data = append(append(data, line...), '\n')
+	}
+	path := eventsFallbackPath()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err == nil {
+		_, err = file.Write(data)
+		err = errors.Join(err, file.Close())
+	}
+	if err != nil {
+		log.Printf("unable to write %d events to fallback %s: %v; events follow\n%s", len(pending), path, err, data)
+		return fmt.Errorf("event fallback %s: %w", path, err)
+	}
+	log.Printf("wrote %d undeliverable events to %s", len(pending), path)
+	return nil
+}
+
+// FlushEvents makes a final delivery attempt, e.g. when the worker stops.
+// Events that still cannot be written go to the fallback sink.
+func FlushEvents() error {
+	q := events
+	q.mu.Lock()
+	var running []chan struct{}
+	for _, f := range q.flushers {
+		if f.running {
+			running = append(running, f.done)
+		}
+	}
+	q.mu.Unlock()
+	for _, done := range running {
+		<-done
+	}
+
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	var order []string
+	batches := map[string][]pendingEvent{}
+	for _, event := range pending {
+		if _, ok := batches[event.location]; !ok {
+			order = append(order, event.location)
+		}
+		batches[event.location] = append(batches[event.location], event)
+	}
+	var failed []pendingEvent
+	for _, location := range order {
+		var data []byte
+		for _, event := range batches[location] {
+			data = append(data, event.line...)
+		}
+		if err := deliverEvents(location, data); err != nil {
+			q.mu.Lock()
+			q.writeErrors++
+			q.metrics.Counter(EventWriteErrorsMetric).Inc(1)
+			q.mu.Unlock()
+			failed = append(failed, batches[location]...)
+		}
+	}
+	q.mu.Lock()
+	q.metrics.Gauge(EventsPendingMetric).Update(float64(q.pendingLocked()))
+	q.mu.Unlock()
+	return q.toFallback(failed)
+}
@@ -0,0 +1,119 @@
+package activities
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventsAreQueuedUntilLogDirIsWritable(t *testing.T) {
+	defer func(interval time.Duration) { eventRetryInterval = interval }(eventRetryInterval)
+	eventRetryInterval = time.Hour
+	before := CurrentEventStats()
+
+	location := "mem://events-outage"
+	emitEvent(location, StepEvent{StepID: "a", Status: "step_started"})
+	waitEvents(location)
+	emitEvent(location, StepEvent{StepID: "a", Status: "step_completed"})
+	waitEvents(location)
+	stats := CurrentEventStats()
+	// The failed write is retried by the timer, not by the next event.
+	if stats.Pending != before.Pending+2 || stats.WriteErrors != before.WriteErrors+1 {
+		t.Fatalf("stats = %+v, before %+v", stats, before)
+	}
+
+	fs := NewMemLogFS("events-outage")
+	events.retry(location)
+	waitEvents(location)
+	data, ok := fs.ReadFile("events.jsonl")
+	if !ok {
+		t.Fatal("events.jsonl was not written after the log directory came back")
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "step_started") || !strings.Contains(lines[1], "step_completed") {
+		t.Errorf("events.jsonl = %q", data)
+	}
+	if got := CurrentEventStats().Pending; got != before.Pending {
+		t.Errorf("pending = %d, want %d", got, before.Pending)
+	}
+}
+
+func TestUndeliverableEventsGoToFallback(t *testing.T) {
+	defer func(limit int, interval time.Duration) {
+		maxPendingEvents, eventRetryInterval = limit, interval
+	}(maxPendingEvents, eventRetryInterval)
+	maxPendingEvents, eventRetryInterval = 1, time.Hour
+	fallback := filepath.Join(t.TempDir(), "fallback.jsonl")
+	t.Setenv("TEMPORAL_EVENTS_FALLBACK", fallback)
+
+	location := "mem://events-gone"
+	emitEvent(location, StepEvent{StepID: "a", Status: "step_started"})
+	emitEvent(location, StepEvent{StepID: "a", Status: "step_completed"})
+	if err := FlushEvents(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("fallback = %q", data)
+	}
+	var first fallbackLine
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	var event StepEvent
+	json.Unmarshal(first.Event, &event)
+	if first.LogDir != location || event.Status != "step_started" {
+		t.Errorf("first fallback line = %s", lines[0])
+	}
+	if got := CurrentEventStats().Pending; got != 0 {
+		t.Errorf("pending after flush = %d", got)
+	}
+}
+
+func TestSlowLogDirDoesNotHoldBackOtherEvents(t *testing.T) {
+	release := make(chan struct{})
+	var once sync.Once
+	defer once.Do(func() { close(release) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			<-release
+		}
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("TEMPORAL_LOG_S3_ENDPOINT", server.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	emitEvent("s3://slow-bucket/"+t.Name(), StepEvent{StepID: "a", Status: "step_started"})
+	dir := t.TempDir()
+	emitted := make(chan struct{})
+	go func() {
+		emitEvent(dir, StepEvent{StepID: "b", Status: "step_started"})
+		waitEvents(dir)
+		close(emitted)
+	}()
+	select {
+	case <-emitted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events for a healthy log directory waited on a slow one")
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "events.jsonl")); err != nil || !strings.Contains(string(data), `"b"`) {
+		t.Errorf("events.jsonl = %q, %v", data, err)
+	}
+	once.Do(func() { close(release) })
+	waitEvents("s3://slow-bucket/" + t.Name())
+}
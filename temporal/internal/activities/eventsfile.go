@@ -0,0 +1,119 @@
+package activities
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// EventsFile is the events file of a log directory.
+const EventsFile = "events.jsonl"
+
+// eventsWriteLimit bounds each write to an events file. Batches are split at
+// line boundaries; a single longer line is written on its own.
+var eventsWriteLimit = 64 << 10
+
+// appendEvents appends lines, whole JSON lines, to the events file of fs. In
+// a local directory it holds an exclusive lock on the file while it writes,
+// which other workers on the same host or shared storage honour, and starts
+// on a new line if an earlier writer died mid-line, so a torn line never
+// swallows the next event.
+func appendEvents(fs LogFS, lines []byte) error {
+	local, ok := fs.(localLogFS)
+	if !ok {
+		for _, chunk := range eventChunks(lines, eventsWriteLimit) {
+			if err := fs.Append(EventsFile, chunk); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	file, err := os.OpenFile(local.Path(EventsFile), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	// Closing the file releases the lock.
+	defer file.Close()
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	torn, err := endsMidLine(file)
+	if err != nil {
+		return err
+	}
+	if torn {
+		lines = append([]byte{'\n'}, lines...)
+	}
+	for _, chunk := range eventChunks(lines, eventsWriteLimit) {
+		if _, err := file.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endsMidLine reports whether file is not empty and does not end in a newline.
+func endsMidLine(file *os.File) (bool, error) {
+	info, err := file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false, err
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, info.Size()-1); err != nil {
+		return false, err
+	}
+	return last[0] != '\n', nil
+}
+
+// eventChunks splits lines into chunks of whole lines of at most limit bytes.
+func eventChunks(lines []byte, limit int) [][]byte {
+	var chunks [][]byte
+	for len(lines) > 0 {
+		end := len(lines)
+		if end > limit {
+			end = bytes.LastIndexByte(lines[:limit], '\n') + 1
+			if end == 0 {
+				end = bytes.IndexByte(lines, '\n') + 1
+				if end == 0 {
+					end = len(lines)
+				}
+			}
+		}
+		chunks = append(chunks, lines[:end])
+		lines = lines[end:]
+	}
+	return chunks
+}
+
+// ScanEvents calls fn with each intact event of an events file and returns
+// how many lines it skipped as damaged: torn or interleaved writes, lines
+// that are not a JSON event, and a last line without its newline, which may
+// still be being written.
+func ScanEvents(r io.Reader, fn func(event StepEvent, line []byte)) (damaged int, err error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				damaged++
+			}
+			return damaged, nil
+		}
+		if err != nil {
+			return damaged, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var event StepEvent
+		if json.Unmarshal(line, &event) != nil || event.WorkflowID == "" || event.Status == "" {
+			damaged++
+			continue
+		}
+		fn(event, line)
+	}
+}
@@ -0,0 +1,103 @@
+package activities
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppendEventsConcurrently(t *testing.T) {
+	saved := eventsWriteLimit
+	eventsWriteLimit = 4096
+	t.Cleanup(func() { eventsWriteLimit = saved })
+	dir := t.TempDir()
+	const writers, batches, perBatch = 8, 20, 10
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				var lines []byte
+				for i := 0; i < perBatch; i++ {
+					data, _ := json.Marshal(StepEvent{WorkflowID: "wf", RunID: fmt.Sprint(w), StepID: fmt.Sprint(b, "-", i), Status: "step_started", Message: strings.Repeat("x", 700)})
+					lines = append(append(lines, data...), '\n')
+				}
+				// Each call opens the file itself, as another process would.
+				if err := appendEvents(localLogFS(dir), lines); err != nil {
+					t.Error(err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	file, err := os.Open(filepath.Join(dir, EventsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	seen := map[string]bool{}
+	damaged, err := ScanEvents(file, func(event StepEvent, line []byte) {
+		seen[event.RunID+"/"+event.StepID] = true
+	})
+	if err != nil || damaged != 0 || len(seen) != writers*batches*perBatch {
+		t.Errorf("read %d distinct events with %d damaged lines (err %v), want %d intact", len(seen), damaged, err, writers*batches*perBatch)
+	}
+}
+
+func TestAppendEventsAfterTornLine(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, EventsFile)
+	torn := `{"workflowId":"wf","runId":"r","status":"step_sta`
+	if err := os.WriteFile(path, []byte(`{"workflowId":"wf","runId":"r","status":"step_started"}`+"\n"+torn), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := appendEvents(localLogFS(dir), []byte(`{"workflowId":"wf","runId":"r","status":"step_finished"}`+"\n")); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var statuses []string
+	damaged, err := ScanEvents(file, func(event StepEvent, line []byte) { statuses = append(statuses, event.Status) })
+	if err != nil || damaged != 1 || strings.Join(statuses, " ") != "step_started step_finished" {
+		t.Errorf("statuses = %v, damaged = %d, err = %v", statuses, damaged, err)
+	}
+}
+
+func TestScanEventsSkipsDamagedLines(t *testing.T) {
+	input := strings.Join([]string{
+		`{"workflowId":"wf","runId":"r","status":"step_started"}`,
+		`{"workflowId":"wf","runId":"r","sta{"workflowId":"wf","runId":"r","status":"step_finished"}`,
+		`not json`,
+		`{"message":"no run"}`,
+		``,
+		`{"workflowId":"wf","runId":"r","status":"pipeline_progress"}`,
+		`{"workflowId":"wf","runId":"r","status":"step_fin`,
+	}, "\n")
+	var statuses []string
+	damaged, err := ScanEvents(strings.NewReader(input), func(event StepEvent, line []byte) { statuses = append(statuses, event.Status) })
+	if err != nil || damaged != 4 || strings.Join(statuses, " ") != "step_started pipeline_progress" {
+		t.Errorf("statuses = %v, damaged = %d, err = %v", statuses, damaged, err)
+	}
+}
+
+func TestEventChunks(t *testing.T) {
+	lines := []byte("aaaa\nbbbb\ncccccccccccc\ndd\n")
+	var got []string
+	for _, chunk := range eventChunks(lines, 10) {
+		got = append(got, string(chunk))
+	}
+	want := []string{"aaaa\nbbbb\n", "cccccccccccc\n", "dd\n"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("chunks = %q, want %q", got, want)
+	}
+}
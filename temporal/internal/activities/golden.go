@@ -0,0 +1,90 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// GoldenBaseline is the pinned reference run of a named plan. Later runs of
+// the plan are compared against it.
+type GoldenBaseline struct {
+	Plan       string                `json:"plan"`
+	WorkflowID string                `json:"workflowId"`
+	RunID      string                `json:"runId"`
+	PinnedAt   string                `json:"pinnedAt"`
+	PinnedBy   string                `json:"pinnedBy,omitempty"`
+	Steps      map[string]GoldenStep `json:"steps"`
+}
+
+type GoldenStep struct {
+	State       string            `json:"state"`
+	DurationSec int64             `json:"durationSec"`
+	Outputs     map[string]string `json:"outputs,omitempty"`
+	Digests     map[string]string `json:"digests,omitempty"`
+}
+
+type LoadGoldenInput struct {
+	LogDir string `json:"logDir"`
+	Plan   string `json:"plan"`
+}
+
+// LoadGoldenResult carries the plan's baseline; Found is false when none is
+// pinned.
+type LoadGoldenResult struct {
+	Found    bool           `json:"found"`
+	Baseline GoldenBaseline `json:"baseline"`
+}
+
+// GoldenBaselinePath is where the baseline of plan is stored: golden/<plan>.json
+// in the results store.
+func GoldenBaselinePath(logDir, plan string) string {
+	return filepath.Join(resultsDir(logDir), "golden", safeName(plan)+".json")
+}
+
+// LoadGoldenBaseline reads the pinned baseline of a plan from the results
+// store.
+func LoadGoldenBaseline(ctx context.Context, input LoadGoldenInput) (LoadGoldenResult, error) {
+	if input.Plan == "" {
+		return LoadGoldenResult{}, errors.New("plan name is required")
+	}
+	baseline, err := ReadGoldenBaseline(GoldenBaselinePath(input.LogDir, input.Plan))
+	if errors.Is(err, os.ErrNotExist) {
+		return LoadGoldenResult{}, nil
+	}
+	if err != nil {
+		return LoadGoldenResult{}, err
+	}
+	return LoadGoldenResult{Found: true, Baseline: baseline}, nil
+}
+
+func ReadGoldenBaseline(path string) (GoldenBaseline, error) {
+	var baseline GoldenBaseline
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return baseline, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return baseline, fmt.Errorf("golden baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// WriteGoldenBaseline replaces the baseline at path.
+func WriteGoldenBaseline(path string, baseline GoldenBaseline) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(baseline, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
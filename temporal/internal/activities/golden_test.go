@@ -0,0 +1,28 @@
+package activities
+
+import (
+	"context"
+	"testing"
+)
+
+func TestGoldenBaselineRoundTrip(t *testing.T) {
+	t.Setenv("TEMPORAL_RESULTS_DIR", t.TempDir())
+	input := LoadGoldenInput{LogDir: t.TempDir(), Plan: "nightly release"}
+
+	result, err := LoadGoldenBaseline(context.Background(), input)
+	if err != nil || result.Found {
+		t.Fatalf("no baseline pinned: got %+v, %v", result, err)
+	}
+
+	baseline := GoldenBaseline{Plan: input.Plan, RunID: "run-1", Steps: map[string]GoldenStep{"build": {State: "success", DurationSec: 12}}}
+	if err := WriteGoldenBaseline(GoldenBaselinePath(input.LogDir, input.Plan), baseline); err != nil {
+		t.Fatal(err)
+	}
+	result, err = LoadGoldenBaseline(context.Background(), input)
+	if err != nil || !result.Found {
+		t.Fatalf("load after pin: %+v, %v", result, err)
+	}
+	if result.Baseline.RunID != "run-1" || result.Baseline.Steps["build"].DurationSec != 12 {
+		t.Errorf("baseline = %+v", result.Baseline)
+	}
+}
@@ -0,0 +1,116 @@
+package activities
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+const (
+	defaultHeartbeatTailLines = 20
+	// heartbeatTailMaxMessage caps each tail line so heartbeats stay small.
+	heartbeatTailMaxMessage = 512
+	heartbeatInterval       = 10 * time.Second
+)
+
+// StepHeartbeat is recorded as the heartbeat details of a running step so
+// `orchestrate status` can show a live tail without access to the worker's
+// log directory.
+type StepHeartbeat struct {
+	Timestamp string        `json:"timestamp"`
+	StepID    string        `json:"stepId"`
+	Tail      []LogTailLine `json:"tail"`
+	// Attempts are the step's attempts so far, the running one last.
+	Attempts []StepAttempt `json:"attempts,omitempty"`
+}
+
+type LogTailLine struct {
+	Timestamp string `json:"timestamp"`
+	Stream    string `json:"stream"`
+	Message   string `json:"message"`
+}
+
+// logTail keeps the last lines written to a structured log sink.
+type logTail struct {
+	mu    sync.Mutex
+	lines []LogTailLine
+	next  int
+	full  bool
+}
+
+// newLogTail sizes the tail from TEMPORAL_HEARTBEAT_TAIL_LINES (default 20).
+// It returns nil when the tail is disabled with 0.
+func newLogTail() *logTail {
+	size := defaultHeartbeatTailLines
+	if value := os.Getenv("TEMPORAL_HEARTBEAT_TAIL_LINES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			size = parsed
+		}
+	}
+	if size == 0 {
+		return nil
+	}
+	return &logTail{lines: make([]LogTailLine, size)}
+}
+
+func (t *logTail) add(line structuredLogLine) {
+	if t == nil {
+		return
+	}
+	message := line.Message
+	if len(message) > heartbeatTailMaxMessage {
+		message = message[:heartbeatTailMaxMessage] + "..."
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines[t.next] = LogTailLine{Timestamp: line.Timestamp, Stream: line.Stream, Message: message}
+	t.next = (t.next + 1) % len(t.lines)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// snapshot returns the buffered lines, oldest first.
+func (t *logTail) snapshot() []LogTailLine {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.full {
+		return append([]LogTailLine(nil), t.lines[:t.next]...)
+	}
+	out := make([]LogTailLine, 0, len(t.lines))
+	out = append(out, t.lines[t.next:]...)
+	return append(out, t.lines[:t.next]...)
+}
+
+// heartbeatTail records the step's log tail and attempts as heartbeat
+// details every heartbeatInterval until stop is closed. It is a no-op outside
+// an activity.
+func heartbeatTail(ctx context.Context, stepID string, tail *logTail, attempts *attemptLog, stop <-chan struct{}) {
+	if !activity.IsActivity(ctx) {
+		return
+	}
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		activity.RecordHeartbeat(ctx, StepHeartbeat{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			StepID:    stepID,
+			Tail:      tail.snapshot(),
+			Attempts:  attempts.attempts(),
+		})
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
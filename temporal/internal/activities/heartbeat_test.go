@@ -0,0 +1,66 @@
+package activities
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLogTailKeepsLastLines(t *testing.T) {
+	t.Setenv("TEMPORAL_HEARTBEAT_TAIL_LINES", "3")
+	tail := newLogTail()
+	if got := tail.snapshot(); len(got) != 0 {
+		t.Fatalf("empty tail = %v", got)
+	}
+	for i := 1; i <= 5; i++ {
+		tail.add(structuredLogLine{Stream: "stdout", Message: fmt.Sprintf("line %d", i)})
+	}
+	got := tail.snapshot()
+	if len(got) != 3 || got[0].Message != "line 3" || got[2].Message != "line 5" {
+		t.Errorf("snapshot = %+v, want lines 3..5", got)
+	}
+
+	tail.add(structuredLogLine{Stream: "stderr", Message: strings.Repeat("x", 2*heartbeatTailMaxMessage)})
+	got = tail.snapshot()
+	if last := got[len(got)-1]; len(last.Message) != heartbeatTailMaxMessage+3 || last.Stream != "stderr" {
+		t.Errorf("long line not capped: %d bytes", len(last.Message))
+	}
+}
+
+func TestLogTailDisabled(t *testing.T) {
+	t.Setenv("TEMPORAL_HEARTBEAT_TAIL_LINES", "0")
+	tail := newLogTail()
+	if tail != nil {
+		t.Fatal("tail of 0 lines should be disabled")
+	}
+	tail.add(structuredLogLine{Message: "ignored"})
+	if got := tail.snapshot(); got != nil {
+		t.Errorf("snapshot = %v", got)
+	}
+}
+
+func TestSetupLogWritersTailWithoutLogFiles(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	lw := setupLogWriters(&stdout, &stderr, "mem://unregistered", "wf", "run", "step", "")
+	defer lw.Close()
+	if lw.structuredPath != "" {
+		t.Fatalf("structured log unexpectedly created at %s", lw.structuredPath)
+	}
+	lw.stdoutWriter.Write([]byte("hello\npartial"))
+	lw.FlushPartial()
+	got := lw.tail.snapshot()
+	if len(got) != 2 || got[0].Message != "hello" || got[1].Message != "partial" {
+		t.Errorf("tail = %+v", got)
+	}
+}
+
+func TestSetupLogWritersNoTailWhenEncrypted(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY", strings.Repeat("ab", 32))
+	var stdout, stderr bytes.Buffer
+	lw := setupLogWriters(&stdout, &stderr, t.TempDir(), "wf", "run", "step", "")
+	defer lw.Close()
+	if lw.tail != nil {
+		t.Error("encrypted logs must not be copied into heartbeats")
+	}
+}
@@ -0,0 +1,73 @@
+package activities
+
+// defaultHFMaxWorkers is how many dataset files are downloaded at once.
+const defaultHFMaxWorkers = 8
+
+// hfDatasetScript downloads the selected files of a dataset repo into the HF
+// cache, max_workers at a time. It checks the files not yet cached fit on
+// the cache's disk before downloading (exiting 28, ENOSPC, if not), prints a progress line per finished
+// shard (visible in the heartbeat tail) and reports files, bytes, revision
+// and cache_path as step outputs.
+//
+// Files are selected by allow_patterns, or else by config (a directory in
+// the file's path) and split (a path component starting with the split
+// name; slices such as "train[:100]" are ignored).
+const hfDatasetScript = `
+import fnmatch, json, os, shutil, sys
+from concurrent.futures import ThreadPoolExecutor, as_completed
+cache_dir = os.environ['_HF_CACHE_DIR']
+os.environ['HF_HOME'] = cache_dir
+from huggingface_hub import HfApi, hf_hub_download, try_to_load_from_cache
+
+dataset_id = os.environ['_HF_DATASET_ID']
+revision = os.environ.get('_HF_REVISION') or None
+patterns = json.loads(os.environ.get('_HF_ALLOW_PATTERNS') or '[]')
+config = os.environ.get('_HF_CONFIG', '')
+split = os.environ.get('_HF_SPLIT', '').split('[')[0]
+max_workers = int(os.environ['_HF_MAX_WORKERS'])
+
+info = HfApi().dataset_info(dataset_id, revision=revision, files_metadata=True)
+
+def wanted(name):
+    if patterns:
+        return any(fnmatch.fnmatch(name, pattern) for pattern in patterns)
+    parts = name.split('/')
+    if config and config != 'default' and config not in parts[:-1]:
+        return False
+    if split and not any(part.startswith(split) for part in parts):
+        return False
+    return True
+
+files = [(s.rfilename, s.size or 0) for s in info.siblings if wanted(s.rfilename)]
+if not files:
+    print(f'no files of {dataset_id} match the selection', file=sys.stderr)
+    sys.exit(1)
+total = sum(size for _, size in files)
+missing = sum(size for name, size in files
+              if not isinstance(try_to_load_from_cache(dataset_id, name, cache_dir=cache_dir, revision=info.sha, repo_type='dataset'), str))
+os.makedirs(cache_dir, exist_ok=True)
+free = shutil.disk_usage(cache_dir).free
+if missing > free:
+    print(f'not enough disk space in {cache_dir}: {dataset_id} needs {missing} more bytes, {free} free', file=sys.stderr)
+    sys.exit(28)
+print(f'downloading {len(files)} files ({total} bytes, {missing} not cached) of {dataset_id}@{info.sha} with {max_workers} workers', flush=True)
+
+def fetch(name):
+    return hf_hub_download(dataset_id, name, repo_type='dataset', revision=info.sha, cache_dir=cache_dir)
+
+done, done_bytes, snapshot = 0, 0, ''
+with ThreadPoolExecutor(max_workers=max_workers) as pool:
+    futures = {pool.submit(fetch, name): (name, size) for name, size in files}
+    for future in as_completed(futures):
+        name, size = futures[future]
+        path = future.result()
+        snapshot = snapshot or path[:-len(name)].rstrip('/')
+        done, done_bytes = done + 1, done_bytes + size
+        print(f'shard {done}/{len(files)} {name} ({done_bytes}/{total} bytes)', flush=True)
+
+outputs = os.environ.get('SYGALDRY_OUTPUTS')
+if outputs:
+    with open(outputs, 'a') as f:
+        f.write(f'files={len(files)}\nbytes={total}\nrevision={info.sha}\ncache_path={snapshot}\n')
+print(f'Downloaded {len(files)} files ({total} bytes) of {dataset_id} to {snapshot}')
+`
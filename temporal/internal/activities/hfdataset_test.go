@@ -0,0 +1,92 @@
+package activities
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHubModule stands in for huggingface_hub: a dataset with two train
+// shards, one test shard, a README and a file too big for any disk, "downloaded" by writing stub files.
+const fakeHubModule = `
+import os
+class Sibling:
+    def __init__(self, name, size):
+        self.rfilename, self.size = name, size
+class Info:
+    sha = 'abc123'
+    siblings = [Sibling('README.md', 10), Sibling('data/train-00000.parquet', 100),
+                Sibling('data/train-00001.parquet', 200), Sibling('data/test-00000.parquet', 50),
+                Sibling('huge/all.bin', 10**18)]
+class HfApi:
+    def dataset_info(self, repo_id, revision=None, files_metadata=False):
+        return Info()
+def try_to_load_from_cache(repo_id, filename, cache_dir=None, revision=None, repo_type=None):
+    return None
+def hf_hub_download(repo_id, filename, repo_type=None, revision=None, cache_dir=None):
+    path = os.path.join(cache_dir, 'snapshots', revision, filename)
+    os.makedirs(os.path.dirname(path), exist_ok=True)
+    open(path, 'w').close()
+    return path
+`
+
+func TestHFDownloadDatasetParallelShards(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 not available")
+	}
+	modules := t.TempDir()
+	if err := os.WriteFile(filepath.Join(modules, "huggingface_hub.py"), []byte(fakeHubModule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PYTHONPATH", modules)
+	cache := t.TempDir()
+
+	result, err := HFDownloadDataset(context.Background(), HFDownloadDatasetInput{
+		StepID:     "data",
+		LogDir:     t.TempDir(),
+		DatasetID:  "org/corpus",
+		Split:      "train[:100]",
+		CacheDir:   cache,
+		MaxWorkers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 {
+		t.Fatalf("exit code %d: %s", result.ExitCode, result.Stderr)
+	}
+	want := map[string]string{"files": "2", "bytes": "300", "revision": "abc123", "cache_path": filepath.Join(cache, "snapshots", "abc123")}
+	for key, value := range want {
+		if result.Outputs[key] != value {
+			t.Errorf("outputs[%s] = %q, want %q", key, result.Outputs[key], value)
+		}
+	}
+	if !strings.Contains(result.Stdout, "shard 2/2") {
+		t.Errorf("no shard progress in stdout:\n%s", result.Stdout)
+	}
+
+	result, err = HFDownloadDataset(context.Background(), HFDownloadDatasetInput{
+		StepID:        "docs",
+		LogDir:        t.TempDir(),
+		DatasetID:     "org/corpus",
+		AllowPatterns: []string{"*.md"},
+		CacheDir:      cache,
+	})
+	if err != nil || result.Outputs["files"] != "1" || result.Outputs["bytes"] != "10" {
+		t.Errorf("allow_patterns: outputs = %v, err = %v, stderr = %s", result.Outputs, err, result.Stderr)
+	}
+
+	result, err = HFDownloadDataset(context.Background(), HFDownloadDatasetInput{
+		StepID:        "huge",
+		LogDir:        t.TempDir(),
+		DatasetID:     "org/corpus",
+		AllowPatterns: []string{"huge/*"},
+		CacheDir:      cache,
+	})
+	if err != nil || result.ExitCode != 28 || !strings.Contains(result.Stderr, "not enough disk space") {
+		t.Errorf("disk check: exit %d, err = %v, stderr = %s", result.ExitCode, err, result.Stderr)
+	}
+}
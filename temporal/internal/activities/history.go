@@ -0,0 +1,187 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// durationHistorySamples is how many recent durations are kept per step.
+const durationHistorySamples = 20
+
+// durationHistoryCompactLines is the history file length at which it is
+// rewritten with only the kept samples.
+var durationHistoryCompactLines = 2000
+
+// DurationSample is one successful run of a step, appended to the plan's
+// history file in the results store.
+type DurationSample struct {
+	Timestamp   string `json:"timestamp"`
+	WorkflowID  string `json:"workflowId"`
+	RunID       string `json:"runId"`
+	StepID      string `json:"stepId"`
+	DurationSec int64  `json:"durationSec"`
+}
+
+// StepDurationStats summarises a step's recent successful durations.
+type StepDurationStats struct {
+	Samples   int   `json:"samples"`
+	MedianSec int64 `json:"medianSec"`
+	MaxSec    int64 `json:"maxSec"`
+}
+
+type DurationHistoryInput struct {
+	LogDir string `json:"logDir"`
+	Plan   string `json:"plan"`
+}
+
+type DurationHistoryResult struct {
+	Steps map[string]StepDurationStats `json:"steps"`
+}
+
+type RecordDurationsInput struct {
+	LogDir     string           `json:"logDir"`
+	Plan       string           `json:"plan"`
+	WorkflowID string           `json:"workflowId"`
+	RunID      string           `json:"runId"`
+	Durations  map[string]int64 `json:"durations"`
+}
+
+// DurationHistoryPath is where the step durations of plan are kept:
+// history/<plan>.jsonl in the results store.
+func DurationHistoryPath(logDir, plan string) string {
+	return filepath.Join(resultsDir(logDir), "history", safeName(plan)+".jsonl")
+}
+
+// LoadDurationHistory summarises the last durationHistorySamples successful
+// durations of each step of a plan. A plan without history has no steps.
+func LoadDurationHistory(ctx context.Context, input DurationHistoryInput) (DurationHistoryResult, error) {
+	if input.Plan == "" {
+		return DurationHistoryResult{}, errors.New("plan name is required")
+	}
+	samples, _, err := readDurationSamples(DurationHistoryPath(input.LogDir, input.Plan))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return DurationHistoryResult{}, err
+	}
+	result := DurationHistoryResult{Steps: map[string]StepDurationStats{}}
+	for id, durations := range samples {
+		result.Steps[id] = durationStats(durations)
+	}
+	return result, nil
+}
+
+// RecordDurationHistory appends a run's step durations to the plan's history.
+func RecordDurationHistory(ctx context.Context, input RecordDurationsInput) error {
+	if input.Plan == "" {
+		return errors.New("plan name is required")
+	}
+	if len(input.Durations) == 0 {
+		return nil
+	}
+	path := DurationHistoryPath(input.LogDir, input.Plan)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(input.Durations))
+	for id := range input.Durations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	now := time.Now().UTC().Format(time.RFC3339)
+	var data []byte
+	for _, id := range ids {
+		line, err := json.Marshal(DurationSample{
+			Timestamp:   now,
+			WorkflowID:  input.WorkflowID,
+			RunID:       input.RunID,
+			StepID:      id,
+			DurationSec: input.Durations[id],
+		})
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err := errors.Join(err, file.Close()); err != nil {
+		return err
+	}
+	return compactDurationHistory(path)
+}
+
+// readDurationSamples returns the kept samples per step, oldest first, and
+// the number of lines in the file. Malformed lines are skipped.
+func readDurationSamples(path string) (map[string][]DurationSample, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	samples := map[string][]DurationSample{}
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+		var sample DurationSample
+		if json.Unmarshal(scanner.Bytes(), &sample) != nil || sample.StepID == "" {
+			continue
+		}
+		kept := append(samples[sample.StepID], sample)
+		if len(kept) > durationHistorySamples {
+			kept = kept[1:]
+		}
+		samples[sample.StepID] = kept
+	}
+	return samples, lines, scanner.Err()
+}
+
+// compactDurationHistory rewrites a long history file with only the kept
+// samples. A sample appended by a concurrent run during the rewrite may be
+// lost, which only costs one data point.
+func compactDurationHistory(path string) error {
+	samples, lines, err := readDurationSamples(path)
+	if err != nil || lines < durationHistoryCompactLines {
+		return err
+	}
+	var kept []DurationSample
+	for _, stepSamples := range samples {
+		kept = append(kept, stepSamples...)
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Timestamp < kept[j].Timestamp })
+	var data []byte
+	for _, sample := range kept {
+		line, err := json.Marshal(sample)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func durationStats(samples []DurationSample) StepDurationStats {
+	durations := make([]int64, len(samples))
+	for i, sample := range samples {
+		durations[i] = sample.DurationSec
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	stats := StepDurationStats{Samples: len(durations)}
+	if len(durations) > 0 {
+		stats.MedianSec = durations[len(durations)/2]
+		stats.MaxSec = durations[len(durations)-1]
+	}
+	return stats
+}
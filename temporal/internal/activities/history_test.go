@@ -0,0 +1,65 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDurationHistoryKeepsRecentSamples(t *testing.T) {
+	t.Setenv("TEMPORAL_RESULTS_DIR", t.TempDir())
+	logDir := t.TempDir()
+	ctx := context.Background()
+
+	result, err := LoadDurationHistory(ctx, DurationHistoryInput{LogDir: logDir, Plan: "nightly"})
+	if err != nil || len(result.Steps) != 0 {
+		t.Fatalf("empty history: got %+v, %v", result, err)
+	}
+
+	for i := 1; i <= durationHistorySamples+5; i++ {
+		err := RecordDurationHistory(ctx, RecordDurationsInput{
+			LogDir:    logDir,
+			Plan:      "nightly",
+			RunID:     fmt.Sprintf("run-%d", i),
+			Durations: map[string]int64{"train": int64(i * 10), "lint": 5},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err = LoadDurationHistory(ctx, DurationHistoryInput{LogDir: logDir, Plan: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only runs 6..25 are kept, so train's durations are 60..250.
+	if got := result.Steps["train"]; got.Samples != durationHistorySamples || got.MedianSec != 160 || got.MaxSec != 250 {
+		t.Errorf("train = %+v", got)
+	}
+	if got := result.Steps["lint"]; got.MedianSec != 5 {
+		t.Errorf("lint = %+v", got)
+	}
+}
+
+func TestDurationHistoryCompacts(t *testing.T) {
+	t.Setenv("TEMPORAL_RESULTS_DIR", t.TempDir())
+	logDir := t.TempDir()
+	path := DurationHistoryPath(logDir, "nightly")
+
+	old := durationHistoryCompactLines
+	durationHistoryCompactLines = 30
+	defer func() { durationHistoryCompactLines = old }()
+	for i := 0; i < 40; i++ {
+		if err := RecordDurationHistory(context.Background(), RecordDurationsInput{LogDir: logDir, Plan: "nightly", Durations: map[string]int64{"a": 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines >= 30 {
+		t.Errorf("history has %d lines after compaction", lines)
+	}
+}
@@ -0,0 +1,155 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArtifactLease records that a worker holds a step's local artifacts on its own
+// filesystem. Leases are appended to leases.jsonl in the results store.
+type ArtifactLease struct {
+	Timestamp   string `json:"timestamp"`
+	WorkflowID  string `json:"workflowId"`
+	RunID       string `json:"runId"`
+	StepID      string `json:"stepId"`
+	Path        string `json:"path"`
+	SizeBytes   int64  `json:"sizeBytes"`
+	WorkerQueue string `json:"workerQueue"`
+	Hostname    string `json:"hostname"`
+	Status      string `json:"status"`
+}
+
+type ArtifactLeaseInput struct {
+	WorkflowID string   `json:"workflowId"`
+	RunID      string   `json:"runId"`
+	StepID     string   `json:"stepId"`
+	LogDir     string   `json:"logDir"`
+	Paths      []string `json:"paths"`
+}
+
+type ArtifactLeaseResult struct {
+	WorkerQueue string          `json:"workerQueue"`
+	Leases      []ArtifactLease `json:"leases"`
+}
+
+type ReleaseLeasesInput struct {
+	WorkflowID string          `json:"workflowId"`
+	RunID      string          `json:"runId"`
+	LogDir     string          `json:"logDir"`
+	Leases     []ArtifactLease `json:"leases"`
+}
+
+// AcquireArtifactLeases runs on the worker that produced a step's local
+// artifacts. It checks that every path exists locally and records a lease
+// owned by this worker's queue.
+func AcquireArtifactLeases(ctx context.Context, input ArtifactLeaseInput) (ArtifactLeaseResult, error) {
+	queue := workerQueue()
+	if queue == "" {
+		return ArtifactLeaseResult{}, errors.New("this worker has no dedicated queue")
+	}
+	hostname, _ := os.Hostname()
+
+	result := ArtifactLeaseResult{WorkerQueue: queue}
+	for _, path := range input.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return result, fmt.Errorf("local artifact %s: %w", path, err)
+		}
+		lease := ArtifactLease{
+			Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+			WorkflowID:  input.WorkflowID,
+			RunID:       input.RunID,
+			StepID:      input.StepID,
+			Path:        path,
+			SizeBytes:   info.Size(),
+			WorkerQueue: queue,
+			Hostname:    hostname,
+			Status:      "acquired",
+		}
+		if err := appendLease(input.LogDir, lease); err != nil {
+			return result, err
+		}
+		result.Leases = append(result.Leases, lease)
+	}
+	return result, nil
+}
+
+// ReleaseArtifactLeases records the end of the run's hold on local artifacts.
+// The files themselves are left in place for the owning worker to clean up.
+func ReleaseArtifactLeases(ctx context.Context, input ReleaseLeasesInput) error {
+	for _, lease := range input.Leases {
+		lease.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+		lease.Status = "released"
+		if err := appendLease(input.LogDir, lease); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	workerQueueMu   sync.RWMutex
+	workerQueueName string
+)
+
+// SetWorkerQueue sets the task queue dedicated to this worker. Activities
+// record it as the owner of worker-local artifacts and in step results.
+func SetWorkerQueue(queue string) {
+	workerQueueMu.Lock()
+	workerQueueName = strings.TrimSpace(queue)
+	workerQueueMu.Unlock()
+}
+
+func workerQueue() string {
+	workerQueueMu.RLock()
+	defer workerQueueMu.RUnlock()
+	return workerQueueName
+}
+
+// resultsDir returns the results store directory: TEMPORAL_RESULTS_DIR if
+// set, otherwise a results/ directory inside the log dir.
+func resultsDir(logDir string) string {
+	dir := strings.TrimSpace(os.Getenv("TEMPORAL_RESULTS_DIR"))
+	if dir == "" {
+		if logDir == "" {
+			logDir = "./logs"
+		}
+		dir = filepath.Join(logDir, "results")
+		if IsRemoteLogDir(logDir) {
+			// Leases need a local append-only file.
+			dir = "results"
+		}
+	}
+	if !filepath.IsAbs(dir) {
+		if cwd, err := os.Getwd(); err == nil {
+			dir = filepath.Join(cwd, dir)
+		}
+	}
+	return dir
+}
+
+func appendLease(logDir string, lease ArtifactLease) error {
+	dir := resultsDir(logDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "leases.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
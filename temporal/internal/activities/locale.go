@@ -0,0 +1,82 @@
+package activities
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Output encodings of Locale.OutputEncoding.
+const (
+	// EncodingUTF8 reads output as UTF-8; each run of invalid bytes becomes
+	// U+FFFD.
+	EncodingUTF8 = "utf-8"
+	// EncodingLatin1 reads output as ISO 8859-1.
+	EncodingLatin1 = "latin-1"
+	// EncodingAuto keeps lines that are valid UTF-8 and reads the others as
+	// Latin-1, for tools that mix both.
+	EncodingAuto = "auto"
+)
+
+// Locale forces the locale of a step's processes and says how their output
+// is decoded before it reaches structured logs and the result. Raw log files
+// keep the bytes as written.
+type Locale struct {
+	Lang           string `json:"lang,omitempty"`
+	LCAll          string `json:"lcAll,omitempty"`
+	OutputEncoding string `json:"outputEncoding,omitempty"`
+}
+
+// env returns the LANG and LC_ALL settings of the locale.
+func (l *Locale) env() []string {
+	if l == nil {
+		return nil
+	}
+	var env []string
+	if l.Lang != "" {
+		env = append(env, "LANG="+l.Lang)
+	}
+	if l.LCAll != "" {
+		env = append(env, "LC_ALL="+l.LCAll)
+	}
+	return env
+}
+
+func (l *Locale) encoding() string {
+	if l == nil {
+		return ""
+	}
+	return l.OutputEncoding
+}
+
+// normalizeOutput decodes s with encoding into valid UTF-8. An empty
+// encoding leaves s as it is.
+func normalizeOutput(s, encoding string) string {
+	switch encoding {
+	case EncodingUTF8:
+		return strings.ToValidUTF8(s, "\uFFFD")
+	case EncodingLatin1:
+		return decodeLatin1(s)
+	case EncodingAuto:
+		if utf8.ValidString(s) {
+			return s
+		}
+		lines := strings.SplitAfter(s, "\n")
+		for i, line := range lines {
+			if !utf8.ValidString(line) {
+				lines[i] = decodeLatin1(line)
+			}
+		}
+		return strings.Join(lines, "")
+	}
+	return s
+}
+
+// decodeLatin1 maps each byte of s to the code point of the same value.
+func decodeLatin1(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(rune(s[i]))
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeOutput(t *testing.T) {
+	latin1 := "caf\xe9"
+	tests := []struct {
+		encoding, in, want string
+	}{
+		{"", latin1, latin1},
+		{EncodingUTF8, latin1 + "\xff\xfe ok", "caf� ok"},
+		{EncodingLatin1, latin1, "café"},
+		{EncodingLatin1, "naïve", "naÃ¯ve"},
+		{EncodingAuto, "naïve\n" + latin1 + "\n", "naïve\ncafé\n"},
+	}
+	for _, tt := range tests {
+		if got := normalizeOutput(tt.in, tt.encoding); got != tt.want {
+			t.Errorf("normalizeOutput(%q, %q) = %q, want %q", tt.in, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+func TestRunCommandLocale(t *testing.T) {
+	dir := t.TempDir()
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Command:    "bash",
+		Args:       []string{"-c", `echo "$LANG $LC_ALL"; printf 'caf\xe9\n'`},
+		WorkflowID: "wf",
+		RunID:      "run",
+		StepID:     "locale",
+		LogDir:     dir,
+		Locale:     &Locale{Lang: "C.UTF-8", LCAll: "C.UTF-8", OutputEncoding: EncodingAuto},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != "C.UTF-8 C.UTF-8\ncafé\n" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+	data, err := os.ReadFile(result.StructuredPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry structuredLogLine
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, entry.Message)
+	}
+	if strings.Join(messages, "|") != "C.UTF-8 C.UTF-8|café" {
+		t.Errorf("structured messages = %q", messages)
+	}
+	// The raw log keeps the bytes as written.
+	if raw, _ := os.ReadFile(result.StdoutPath); !strings.Contains(string(raw), "caf\xe9\n") {
+		t.Errorf("raw stdout log = %q", raw)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package activities
+
+import "os"
+
+// lockFile does nothing where flock is unavailable; events files then rely on
+// bounded O_APPEND writes alone.
+func lockFile(file *os.File) error {
+	return nil
+}
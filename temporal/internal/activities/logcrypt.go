@@ -0,0 +1,269 @@
+package activities
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// EncryptedLogMagic starts every encrypted log file. It is followed by a
+// random 16-byte file ID and frames of a 4-byte big-endian length and a
+// 12-byte nonce + AES-GCM ciphertext. Each frame is authenticated with the
+// file ID, its index in the file and whether it is the last one, so frames
+// cannot be dropped, reordered or moved to another file unnoticed. The last
+// frame is empty and written on Close.
+const EncryptedLogMagic = "SYGALDRY-AESGCM-2\n"
+
+const (
+	maxEncryptedFrame = 64 * 1024
+	logFileIDSize     = 16
+)
+
+// ErrTruncatedLog is returned by DecryptLog for a log without its final
+// frame, as one still being written or cut short has.
+var ErrTruncatedLog = errors.New("encrypted log is truncated")
+
+// logKeyCache holds the key resolved for the current key configuration, so a
+// worker runs the key command once rather than for every step and retry.
+var logKeyCache struct {
+	mu     sync.Mutex
+	config string
+	key    []byte
+}
+
+// LogEncryptionKey loads the at-rest log key from TEMPORAL_LOG_ENCRYPTION_KEY,
+// TEMPORAL_LOG_ENCRYPTION_KEY_FILE or the stdout of
+// TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND (e.g. a KMS decrypt call). Keys are
+// base64 or hex encoded AES-128/192/256 keys. It returns nil when encryption
+// is not configured.
+//
+// The key is resolved once and reused while the configuration is unchanged.
+// Failures are not kept, so a key command that failed is run again.
+func LogEncryptionKey() ([]byte, error) {
+	config := strings.Join([]string{
+		os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY"),
+		os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_FILE"),
+		os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND"),
+	}, "\x00")
+	logKeyCache.mu.Lock()
+	defer logKeyCache.mu.Unlock()
+	if logKeyCache.key != nil && logKeyCache.config == config {
+		return logKeyCache.key, nil
+	}
+	key, err := loadLogEncryptionKey()
+	if err != nil || key == nil {
+		return key, err
+	}
+	logKeyCache.config, logKeyCache.key = config, key
+	return key, nil
+}
+
+func loadLogEncryptionKey() ([]byte, error) {
+	var encoded string
+	switch {
+	case os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY") != "":
+		encoded = os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY")
+	case os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_FILE") != "":
+		data, err := os.ReadFile(os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("read log key file: %w", err)
+		}
+		encoded = string(data)
+	case os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND") != "":
+		out, err := exec.Command("sh", "-c", os.Getenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND")).Output()
+		if err != nil {
+			return nil, fmt.Errorf("log key command: %w", err)
+		}
+		encoded = string(out)
+	default:
+		return nil, nil
+	}
+	return parseLogKey(encoded)
+}
+
+func parseLogKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		key, err = base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, errors.New("log key must be hex or base64 encoded")
+		}
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("log key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptingWriter seals each Write as one or more frames so a partially
+// written file stays readable up to the last complete frame.
+type encryptingWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	fileID []byte
+	mu     sync.Mutex
+	frames uint64
+	closed bool
+}
+
+func newEncryptingWriter(w io.Writer, key []byte) (*encryptingWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	fileID := make([]byte, logFileIDSize)
+	if _, err := rand.Read(fileID); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append([]byte(EncryptedLogMagic), fileID...)); err != nil {
+		return nil, err
+	}
+	return &encryptingWriter{w: w, aead: aead, fileID: fileID}, nil
+}
+
+// frameAAD is the additional data frame n of a file is sealed with.
+func frameAAD(fileID []byte, n uint64, final bool) []byte {
+	aad := binary.BigEndian.AppendUint64(bytes.Clone(fileID), n)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+func (e *encryptingWriter) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return 0, errors.New("write to closed encrypted log")
+	}
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxEncryptedFrame {
+			chunk = chunk[:maxEncryptedFrame]
+		}
+		if err := e.writeFrame(chunk, false); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (e *encryptingWriter) writeFrame(plain []byte, final bool) error {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	frame := e.aead.Seal(nonce, nonce, plain, frameAAD(e.fileID, e.frames, final))
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(frame)))
+	if _, err := e.w.Write(append(size[:], frame...)); err != nil {
+		return err
+	}
+	e.frames++
+	return nil
+}
+
+// Close writes the final frame, which marks the log complete, and closes the
+// underlying writer when it is a Closer.
+func (e *encryptingWriter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	err := e.writeFrame(nil, true)
+	if closer, ok := e.w.(io.Closer); ok {
+		err = errors.Join(err, closer.Close())
+	}
+	return err
+}
+
+// DecryptLog copies the plaintext of an encrypted log from src to dst. A log
+// that ends before its final frame has every complete frame copied and
+// returns ErrTruncatedLog. Frames that were dropped, reordered or taken from
+// another file fail to authenticate.
+func DecryptLog(dst io.Writer, src io.Reader, key []byte) error {
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(src)
+	header := make([]byte, len(EncryptedLogMagic)+logFileIDSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(EncryptedLogMagic)]) != EncryptedLogMagic {
+		return errors.New("not an encrypted log file")
+	}
+	fileID := header[len(EncryptedLogMagic):]
+	maxFrame := aead.NonceSize() + maxEncryptedFrame + aead.Overhead()
+	for n := uint64(0); ; n++ {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrTruncatedLog
+			}
+			return err
+		}
+		length := binary.BigEndian.Uint32(size[:])
+		if length > uint32(maxFrame) {
+			return fmt.Errorf("corrupt encrypted log frame: %d bytes, at most %d expected", length, maxFrame)
+		}
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(r, frame); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return ErrTruncatedLog
+			}
+			return err
+		}
+		if len(frame) < aead.NonceSize() {
+			return errors.New("corrupt encrypted log frame")
+		}
+		nonce, sealed := frame[:aead.NonceSize()], frame[aead.NonceSize():]
+		final := false
+		plain, err := aead.Open(nil, nonce, sealed, frameAAD(fileID, n, false))
+		if err != nil {
+			if plain, err = aead.Open(nil, nonce, sealed, frameAAD(fileID, n, true)); err != nil {
+				return fmt.Errorf("decrypt log frame %d: %w", n, err)
+			}
+			final = true
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if final {
+			if _, err := r.ReadByte(); err != io.EOF {
+				return errors.New("encrypted log has data after its final frame")
+			}
+			return nil
+		}
+	}
+}
+
+// IsEncryptedLog reports whether data starts with the encrypted log header.
+func IsEncryptedLog(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(EncryptedLogMagic))
+}
@@ -0,0 +1,230 @@
+package activities
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testLogKey = "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f"
+
+func TestParseLogKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		encoded string
+		wantLen int
+		wantErr bool
+	}{
+		{"hex 256-bit", testLogKey, 32, false},
+		{"base64 128-bit", "AAECAwQFBgcICQoLDA0ODw==", 16, false},
+		{"whitespace trimmed", "  " + testLogKey + "\n", 32, false},
+		{"wrong length", "00010203", 0, true},
+		{"not encoded", "not a key!", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := parseLogKey(tt.encoded)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLogKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(key) != tt.wantLen {
+				t.Errorf("len(key) = %d, want %d", len(key), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestLogEncryptionKeySources(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY", "")
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY_FILE", "")
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND", "")
+	if key, err := LogEncryptionKey(); key != nil || err != nil {
+		t.Errorf("expected no key when unconfigured, got %v, %v", key, err)
+	}
+
+	// The key command runs once for as long as the configuration holds.
+	calls := filepath.Join(t.TempDir(), "calls")
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY_COMMAND", "echo >>"+calls+"; echo "+testLogKey)
+	for i := 0; i < 3; i++ {
+		if key, err := LogEncryptionKey(); err != nil || len(key) != 32 {
+			t.Errorf("key command: got %d bytes, err %v", len(key), err)
+		}
+	}
+	if data, err := os.ReadFile(calls); err != nil || len(data) != 1 {
+		t.Errorf("key command ran %d times, want once (%v)", len(data), err)
+	}
+}
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	key, _ := hex.DecodeString(testLogKey)
+	var file bytes.Buffer
+	w, err := newEncryptingWriter(&file, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	big := strings.Repeat("x", maxEncryptedFrame+10)
+	for _, chunk := range []string{"line one\n", "line two\n", big} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(file.String(), "line one") {
+		t.Fatal("plaintext leaked into encrypted file")
+	}
+	if !IsEncryptedLog(file.Bytes()) {
+		t.Fatal("missing encrypted header")
+	}
+
+	var plain bytes.Buffer
+	if err := DecryptLog(&plain, bytes.NewReader(file.Bytes()), key); err != nil {
+		t.Fatal(err)
+	}
+	if plain.String() != "line one\nline two\n"+big {
+		t.Errorf("round trip mismatch (%d bytes)", plain.Len())
+	}
+
+	// A log cut at a frame boundary lacks its final frame and is reported
+	// as truncated, after the complete frames before the cut.
+	unfinished := file.Bytes()[:file.Len()-(4+12+16)]
+	plain.Reset()
+	if err := DecryptLog(&plain, bytes.NewReader(unfinished), key); !errors.Is(err, ErrTruncatedLog) {
+		t.Fatalf("log without final frame: err = %v, want ErrTruncatedLog", err)
+	}
+	if plain.String() != "line one\nline two\n"+big {
+		t.Errorf("unfinished read returned %d bytes", plain.Len())
+	}
+	plain.Reset()
+	if err := DecryptLog(&plain, bytes.NewReader(unfinished[:len(unfinished)-5]), key); !errors.Is(err, ErrTruncatedLog) {
+		t.Fatalf("truncated log: err = %v, want ErrTruncatedLog", err)
+	}
+	if plain.String() != "line one\nline two\n"+big[:maxEncryptedFrame] {
+		t.Errorf("truncated read returned %d bytes", plain.Len())
+	}
+}
+
+// encryptFrames writes lines to a new encrypted log and returns its header
+// and frames.
+func encryptFrames(t *testing.T, key []byte, lines ...string) (header []byte, frames [][]byte) {
+	t.Helper()
+	var file bytes.Buffer
+	w, err := newEncryptingWriter(&file, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		w.Write([]byte(line))
+	}
+	w.Close()
+	data := file.Bytes()
+	header, data = data[:len(EncryptedLogMagic)+logFileIDSize], data[len(EncryptedLogMagic)+logFileIDSize:]
+	for len(data) > 0 {
+		n := 4 + int(binary.BigEndian.Uint32(data))
+		frames, data = append(frames, data[:n]), data[n:]
+	}
+	return header, frames
+}
+
+func TestDecryptLogRejectsMovedFrames(t *testing.T) {
+	key, _ := hex.DecodeString(testLogKey)
+	header, frames := encryptFrames(t, key, "one\n", "two\n", "three\n")
+	_, other := encryptFrames(t, key, "one\n", "two\n", "three\n")
+	join := func(frames ...[]byte) []byte {
+		return bytes.Join(append([][]byte{header}, frames...), nil)
+	}
+	tests := map[string][]byte{
+		"dropped":    join(frames[0], frames[2], frames[3]),
+		"reordered":  join(frames[1], frames[0], frames[2], frames[3]),
+		"other file": join(frames[0], other[1], frames[2], frames[3]),
+		"after end":  join(frames[0], frames[1], frames[2], frames[3], frames[2]),
+	}
+	for name, data := range tests {
+		if err := DecryptLog(&bytes.Buffer{}, bytes.NewReader(data), key); err == nil || errors.Is(err, ErrTruncatedLog) {
+			t.Errorf("%s frame: err = %v, want an integrity error", name, err)
+		}
+	}
+	var plain bytes.Buffer
+	if err := DecryptLog(&plain, bytes.NewReader(join(frames...)), key); err != nil || plain.String() != "one\ntwo\nthree\n" {
+		t.Errorf("intact log = %q, %v", plain.String(), err)
+	}
+}
+
+func TestDecryptLogRejectsOversizedFrame(t *testing.T) {
+	key, _ := hex.DecodeString(testLogKey)
+	data := []byte(EncryptedLogMagic)
+	data = binary.BigEndian.AppendUint32(data, 0xffffffff)
+	if err := DecryptLog(&bytes.Buffer{}, bytes.NewReader(data), key); err == nil || errors.Is(err, ErrTruncatedLog) {
+		t.Errorf("oversized frame: err = %v, want a corrupt frame error", err)
+	}
+}
+
+func TestDecryptLogRejectsTampering(t *testing.T) {
+	key, _ := hex.DecodeString(testLogKey)
+	var file bytes.Buffer
+	w, _ := newEncryptingWriter(&file, key)
+	w.Write([]byte("secret\n"))
+	data := file.Bytes()
+	data[len(data)-1] ^= 0xff
+
+	if err := DecryptLog(&bytes.Buffer{}, bytes.NewReader(data), key); err == nil {
+		t.Error("expected authentication failure")
+	}
+	if err := DecryptLog(&bytes.Buffer{}, strings.NewReader("plain text"), key); err == nil {
+		t.Error("expected error for unencrypted input")
+	}
+}
+
+func TestSetupLogWritersEncrypted(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY", testLogKey)
+	dir := t.TempDir()
+	var stdout, stderr bytes.Buffer
+	lw := setupLogWriters(&stdout, &stderr, dir, "wf", "run", "step", "test")
+	_, _ = lw.stdoutWriter.Write([]byte("regulated row\n"))
+	lw.FlushPartial()
+	lw.Close()
+
+	if !strings.HasSuffix(lw.stdoutPath, "_stdout.log.enc") || !strings.HasSuffix(lw.structuredPath, ".jsonl.enc") {
+		t.Errorf("unexpected paths: %s, %s", lw.stdoutPath, lw.structuredPath)
+	}
+	for _, path := range []string{lw.stdoutPath, lw.structuredPath} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "regulated row") {
+			t.Errorf("%s contains plaintext", path)
+		}
+		key, _ := hex.DecodeString(testLogKey)
+		var plain bytes.Buffer
+		if err := DecryptLog(&plain, bytes.NewReader(data), key); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(plain.String(), "regulated row") {
+			t.Errorf("%s decrypted content = %q", path, plain.String())
+		}
+	}
+	if stdout.String() != "regulated row\n" {
+		t.Errorf("in-memory stdout = %q", stdout.String())
+	}
+}
+
+func TestSetupLogWritersBadKeyDisablesFiles(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_ENCRYPTION_KEY", "short")
+	var stdout, stderr bytes.Buffer
+	lw := setupLogWriters(&stdout, &stderr, t.TempDir(), "wf", "run", "step", "test")
+	defer lw.Close()
+
+	if lw.stdoutPath != "" || len(lw.closers) != 0 {
+		t.Errorf("expected no log files, got %s (%d closers)", lw.stdoutPath, len(lw.closers))
+	}
+	if !strings.Contains(stderr.String(), "log encryption unavailable") {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
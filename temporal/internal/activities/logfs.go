@@ -0,0 +1,210 @@
+package activities
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LogFS stores step log files and events.jsonl. Names are relative to the log
+// directory. Implementations are chosen by the log directory location:
+// s3://bucket/prefix, mem://name (tests) or a local path.
+type LogFS interface {
+	// Create truncates or creates name for writing.
+	Create(name string) (io.WriteCloser, error)
+	// Append adds data to the end of name, creating it if needed.
+	Append(name string, data []byte) error
+	// Path returns the location of name as reported in step results.
+	Path(name string) string
+}
+
+// openLogFS returns the filesystem for a log directory location. Local paths
+// are made absolute and created.
+func openLogFS(location string) (LogFS, error) {
+	switch {
+	case strings.HasPrefix(location, "s3://"):
+		return openS3LogFS(location)
+	case strings.HasPrefix(location, "mem://"):
+		memFSMu.Lock()
+		defer memFSMu.Unlock()
+		fs, ok := memFS[location]
+		if !ok {
+			return nil, fmt.Errorf("in-memory log filesystem %s is not registered", location)
+		}
+		return fs, nil
+	}
+	dir := location
+	if !filepath.IsAbs(dir) {
+		if cwd, err := os.Getwd(); err == nil {
+			dir = filepath.Join(cwd, dir)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return localLogFS(dir), nil
+}
+
+// fallbackLogDir takes the log files of a local log directory that cannot be
+// created, e.g. one on a read-only mount.
+var fallbackLogDir = "/tmp/temporal-logs"
+
+// resolveLogDir applies the TEMPORAL_LOG_DIR and ./logs defaults and makes
+// local directories absolute. A local directory that cannot be created is
+// replaced by fallbackLogDir; remote locations have no fallback.
+func resolveLogDir(hint string) string {
+	dir := logLocation(hint)
+	if IsRemoteLogDir(dir) {
+		return dir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil && os.MkdirAll(fallbackLogDir, 0o755) == nil {
+		return fallbackLogDir
+	}
+	return dir
+}
+
+// logLocation is resolveLogDir without the fallback, for locations that must
+// not move, such as collect_logs destinations.
+func logLocation(hint string) string {
+	dir := strings.TrimSpace(hint)
+	if dir == "" {
+		dir = os.Getenv("TEMPORAL_LOG_DIR")
+	}
+	if dir == "" {
+		dir = "./logs"
+	}
+	if IsRemoteLogDir(dir) || filepath.IsAbs(dir) {
+		return dir
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		dir = filepath.Join(cwd, dir)
+	}
+	return dir
+}
+
+// IsRemoteLogDir reports whether dir is a remote log location rather than a
+// local path.
+func IsRemoteLogDir(dir string) bool {
+	return strings.HasPrefix(dir, "s3://") || strings.HasPrefix(dir, "mem://")
+}
+
+type localLogFS string
+
+func (d localLogFS) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(d.Path(name)), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(d.Path(name))
+}
+
+func (d localLogFS) Append(name string, data []byte) error {
+	file, err := os.OpenFile(d.Path(name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+func (d localLogFS) Path(name string) string {
+	return filepath.Join(string(d), name)
+}
+
+var (
+	memFSMu sync.Mutex
+	memFS   = map[string]*MemLogFS{}
+)
+
+// MemLogFS keeps log files in memory. Register one with NewMemLogFS and use
+// its Location as the log directory in tests.
+type MemLogFS struct {
+	location string
+	mu       sync.Mutex
+	files    map[string]*bytes.Buffer
+}
+
+// NewMemLogFS registers an empty in-memory log filesystem at mem://name.
+func NewMemLogFS(name string) *MemLogFS {
+	fs := &MemLogFS{location: "mem://" + name, files: map[string]*bytes.Buffer{}}
+	memFSMu.Lock()
+	memFS[fs.location] = fs
+	memFSMu.Unlock()
+	return fs
+}
+
+// Location is the log directory value that selects this filesystem.
+func (m *MemLogFS) Location() string {
+	return m.location
+}
+
+func (m *MemLogFS) Create(name string) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[name] = &bytes.Buffer{}
+	return &memFile{fs: m, name: name}, nil
+}
+
+func (m *MemLogFS) Append(name string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.files[name] == nil {
+		m.files[name] = &bytes.Buffer{}
+	}
+	m.files[name].Write(data)
+	return nil
+}
+
+func (m *MemLogFS) Path(name string) string {
+	return m.location + "/" + name
+}
+
+// ReadFile returns a copy of the contents of name.
+func (m *MemLogFS) ReadFile(name string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	buf, ok := m.files[name]
+	if !ok {
+		return nil, false
+	}
+	return bytes.Clone(buf.Bytes()), true
+}
+
+// Names lists the stored files in sorted order.
+func (m *MemLogFS) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type memFile struct {
+	fs   *MemLogFS
+	name string
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	return len(p), f.fs.Append(f.name, p)
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// joinKey joins an object key prefix and name.
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return path.Join(prefix, name)
+}
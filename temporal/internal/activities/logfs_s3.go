@@ -0,0 +1,433 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// s3FlushInterval bounds how stale an open log object in S3 can get while a
+// long step is still writing to it.
+const s3FlushInterval = 30 * time.Second
+
+// Part sizes of log files and of appended files. A file is stored as rolling
+// part objects: the first under its own name, the next ones as <name>.part000001
+// and so on. Only the unfinished last part is kept in memory and re-uploaded,
+// so memory and upload cost do not grow with the file. Appended files re-upload
+// their last part on every append, so their parts are smaller.
+const (
+	s3PartSize       = 8 << 20
+	s3AppendPartSize = 1 << 20
+)
+
+var (
+	s3FSMu sync.Mutex
+	s3FS   = map[string]*s3LogFS{}
+)
+
+type s3Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// s3LogFS writes log files to S3 (or an S3-compatible store). S3 objects
+// cannot be appended to, so files are written as rolling parts whose last part
+// is re-uploaded every s3FlushInterval, when it fills up and on Close, and
+// events.jsonl is written once per worker process as events-<host>-<pid>.jsonl.
+type s3LogFS struct {
+	bucket   string
+	prefix   string
+	endpoint string
+	region   string
+	creds    s3Credentials
+	client   *http.Client
+
+	mu      sync.Mutex
+	appends map[string]*s3Object
+}
+
+// openS3LogFS returns the shared S3 filesystem for location. Credentials come
+// from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN, the
+// region from AWS_REGION (default us-east-1). TEMPORAL_LOG_S3_ENDPOINT selects
+// an S3-compatible endpoint with path-style addressing.
+func openS3LogFS(location string) (LogFS, error) {
+	s3FSMu.Lock()
+	defer s3FSMu.Unlock()
+	if fs, ok := s3FS[location]; ok {
+		return fs, nil
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid S3 log location %q", location)
+	}
+	creds := s3Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, errors.New("S3 log directory requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	region := envOrDefault("AWS_REGION", envOrDefault("AWS_DEFAULT_REGION", "us-east-1"))
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
+	if custom := strings.TrimRight(os.Getenv("TEMPORAL_LOG_S3_ENDPOINT"), "/"); custom != "" {
+		endpoint = custom + "/" + bucket
+	}
+	fs := &s3LogFS{
+		bucket:   bucket,
+		prefix:   strings.Trim(prefix, "/"),
+		endpoint: endpoint,
+		region:   region,
+		creds:    creds,
+		client:   &http.Client{Timeout: 2 * time.Minute},
+		appends:  map[string]*s3Object{},
+	}
+	s3FS[location] = fs
+	return fs, nil
+}
+
+func (s *s3LogFS) Create(name string) (io.WriteCloser, error) {
+	obj := newS3Object(s, joinKey(s.prefix, name), s3PartSize)
+	go obj.flushLoop()
+	return obj, nil
+}
+
+func (s *s3LogFS) Append(name string, data []byte) error {
+	s.mu.Lock()
+	obj := s.appends[name]
+	if obj == nil {
+		obj = newS3Object(s, joinKey(s.prefix, perWorkerName(name)), s3AppendPartSize)
+		s.appends[name] = obj
+	}
+	s.mu.Unlock()
+	return obj.append(data)
+}
+
+func (s *s3LogFS) Path(name string) string {
+	return "s3://" + s.bucket + "/" + joinKey(s.prefix, name)
+}
+
+// perWorkerName turns events.jsonl into events-<host>-<pid>.jsonl.
+func perWorkerName(name string) string {
+	host, _ := os.Hostname()
+	ext := ""
+	if i := strings.LastIndex(name, "."); i > 0 {
+		name, ext = name[:i], name[i:]
+	}
+	return fmt.Sprintf("%s-%s-%d%s", name, safeName(host), os.Getpid(), ext)
+}
+
+func (s *s3LogFS) put(ctx context.Context, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.endpoint+"/"+awsEscapePath(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	sum := sha256.Sum256(body)
+	signV4(req, hex.EncodeToString(sum[:]), s.creds, s.region, "s3", time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 PUT %s: %d %s", key, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// s3PartKey is the object key of part n of the file stored at key.
+func s3PartKey(key string, n int) string {
+	if n == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s.part%06d", key, n)
+}
+
+// IsLogPart reports whether path is a part object after the first of a log
+// file stored in S3.
+func IsLogPart(path string) bool {
+	i := strings.LastIndex(path, ".part")
+	if i < 0 || len(path)-i != len(".part")+6 {
+		return false
+	}
+	for _, c := range path[i+len(".part"):] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadLogParts reads a log file copied from an S3 log directory, e.g. with
+// aws s3 sync, joining the part objects that follow it. Other log files are
+// read as they are.
+func ReadLogParts(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for n := 1; ; n++ {
+		part, err := os.ReadFile(s3PartKey(path, n))
+		if errors.Is(err, os.ErrNotExist) {
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, part...)
+	}
+}
+
+// s3Object writes one file as rolling parts of about partSize bytes. It keeps
+// the part being written in memory, and its flush loop uploads it
+// periodically and once it is full; a full part is not uploaded again. Writes
+// never wait for S3: while uploads fail the tail grows to at most two parts,
+// and writes beyond that are dropped and reported by Close.
+type s3Object struct {
+	fs       *s3LogFS
+	key      string
+	partSize int
+	// upload serializes uploads, so a part is never replaced by an older
+	// copy of itself.
+	upload sync.Mutex
+	mu     sync.Mutex
+	part   int
+	tail   bytes.Buffer
+	dirty  bool
+	// dropped counts the bytes written while the tail was full, and failed
+	// is the upload error that kept it full.
+	dropped int
+	failed  error
+	wake    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newS3Object(fs *s3LogFS, key string, partSize int) *s3Object {
+	return &s3Object{fs: fs, key: key, partSize: partSize, wake: make(chan struct{}, 1), done: make(chan struct{})}
+}
+
+// Write buffers p and has the flush loop upload the part once it is full.
+// It does not fail, so a step's output keeps flowing while S3 is down; lost
+// output is reported by Close. A write is kept or dropped whole, so an
+// encrypted frame is never cut.
+func (o *s3Object) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.tail.Len()+len(p) > 2*o.partSize && o.tail.Len() > 0 {
+		o.dropped += len(p)
+		return len(p), nil
+	}
+	o.tail.Write(p)
+	o.dirty = true
+	if o.tail.Len() >= o.partSize {
+		select {
+		case o.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// flushLoop uploads the tail every s3FlushInterval and when it fills up. After
+// a failed upload it waits for the next tick rather than retrying on every
+// full tail.
+func (o *s3Object) flushLoop() {
+	ticker := time.NewTicker(s3FlushInterval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-o.wake:
+			if !failing {
+				failing = o.flush() != nil
+			}
+		case <-ticker.C:
+			failing = o.flush() != nil
+		}
+	}
+}
+
+// flush uploads the part being written and starts the next part when it is
+// full.
+func (o *s3Object) flush() error {
+	o.upload.Lock()
+	defer o.upload.Unlock()
+	return o.uploadLocked()
+}
+
+// uploadLocked does the work of flush. It must be called with upload held.
+func (o *s3Object) uploadLocked() error {
+	o.mu.Lock()
+	if !o.dirty {
+		o.mu.Unlock()
+		return nil
+	}
+	part := o.part
+	body := bytes.Clone(o.tail.Bytes())
+	o.dirty = false
+	o.mu.Unlock()
+	if err := o.fs.put(context.Background(), s3PartKey(o.key, part), body); err != nil {
+		o.mu.Lock()
+		o.dirty = true
+		o.failed = err
+		o.mu.Unlock()
+		return err
+	}
+	if len(body) >= o.partSize {
+		// Writes made during the upload stay in the tail for the next part.
+		o.mu.Lock()
+		o.tail.Next(len(body))
+		o.part++
+		o.dirty = o.tail.Len() > 0
+		o.mu.Unlock()
+	}
+	return nil
+}
+
+// append adds data and uploads it at once. When the upload fails data is
+// taken back out, so the caller can retry without writing it twice.
+func (o *s3Object) append(data []byte) error {
+	o.upload.Lock()
+	defer o.upload.Unlock()
+	o.mu.Lock()
+	kept := o.tail.Len()
+	o.tail.Write(data)
+	o.dirty = true
+	o.mu.Unlock()
+	if err := o.uploadLocked(); err != nil {
+		o.mu.Lock()
+		o.tail.Truncate(kept)
+		o.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Close uploads what is left and reports output that did not reach S3.
+func (o *s3Object) Close() error {
+	o.once.Do(func() { close(o.done) })
+	o.mu.Lock()
+	// An empty file is still created.
+	if o.part == 0 {
+		o.dirty = true
+	}
+	o.mu.Unlock()
+	err := o.flush()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.dropped > 0 {
+		err = errors.Join(fmt.Errorf("%s: dropped %d bytes of log output while uploads failed: %w", o.key, o.dropped, o.failed), err)
+	}
+	return err
+}
+
+// signV4 adds AWS Signature Version 4 headers to req. Every header already set
+// on req is signed along with host and the x-amz-* headers added here.
+func signV4(req *http.Request, payloadHash string, creds s3Credentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscapePath URI-encodes every byte of p except unreserved characters and
+// '/', as SigV4 requires for S3 object keys.
+func awsEscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, val := range vals {
+			parts = append(parts, awsEscapePath(key)+"="+strings.ReplaceAll(awsEscapePath(val), "/", "%2F"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
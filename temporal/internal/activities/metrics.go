@@ -0,0 +1,111 @@
+package activities
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+)
+
+// Step metric types. A gauge keeps the last reported value; a counter sums
+// every reported value.
+const (
+	MetricGauge   = "gauge"
+	MetricCounter = "counter"
+)
+
+// maxStepMetrics caps how many distinct metrics a step may report, since
+// metrics travel in the workflow payload.
+const maxStepMetrics = 64
+
+// MetricNamePattern is the form of a step metric name.
+var MetricNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// StepMetric is a named value reported by a step through $SYGALDRY_METRICS.
+type StepMetric struct {
+	Name  string  `json:"name"`
+	Type  string  `json:"type"`
+	Value float64 `json:"value"`
+}
+
+// createMetricsFile creates the file exposed to a step as $SYGALDRY_METRICS.
+func createMetricsFile() (string, error) {
+	file, err := os.CreateTemp("", "sygaldry-metrics-*")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	file.Close()
+	return path, nil
+}
+
+// readMetrics aggregates the JSON lines a step wrote to its metrics file and
+// removes the file.
+func readMetrics(path string) []StepMetric {
+	if path == "" {
+		return nil
+	}
+	defer os.Remove(path)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	return parseMetrics(file)
+}
+
+// parseMetrics reads lines such as {"name":"loss","value":0.42} or
+// {"name":"samples","value":512,"type":"counter"}. The type defaults to gauge.
+// Lines that are not valid metrics, that change a metric's type, or that add
+// a metric beyond maxStepMetrics are ignored. The result is sorted by name.
+func parseMetrics(r io.Reader) []StepMetric {
+	metrics := map[string]*StepMetric{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+	for scanner.Scan() {
+		var line struct {
+			Name  string   `json:"name"`
+			Type  string   `json:"type"`
+			Value *float64 `json:"value"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || line.Value == nil || !MetricNamePattern.MatchString(line.Name) {
+			continue
+		}
+		if math.IsNaN(*line.Value) || math.IsInf(*line.Value, 0) {
+			continue
+		}
+		if line.Type == "" {
+			line.Type = MetricGauge
+		}
+		if line.Type != MetricGauge && line.Type != MetricCounter {
+			continue
+		}
+		metric, ok := metrics[line.Name]
+		if !ok {
+			if len(metrics) == maxStepMetrics {
+				continue
+			}
+			metrics[line.Name] = &StepMetric{Name: line.Name, Type: line.Type, Value: *line.Value}
+			continue
+		}
+		switch {
+		case metric.Type != line.Type:
+		case metric.Type == MetricCounter:
+			metric.Value += *line.Value
+		default:
+			metric.Value = *line.Value
+		}
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	result := make([]StepMetric, 0, len(metrics))
+	for _, metric := range metrics {
+		result = append(result, *metric)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
@@ -0,0 +1,50 @@
+package activities
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseMetrics(t *testing.T) {
+	metrics := parseMetrics(strings.NewReader(strings.Join([]string{
+		`{"name":"loss","value":0.9}`,
+		`{"name":"samples","value":256,"type":"counter"}`,
+		`not json`,
+		`{"name":"loss","value":0.4}`,
+		`{"name":"samples","value":256,"type":"counter"}`,
+		`{"name":"samples","value":1,"type":"gauge"}`,
+		`{"name":"bad name","value":1}`,
+		`{"name":"novalue"}`,
+		`{"name":"rate","value":1,"type":"histogram"}`,
+	}, "\n")))
+	want := []StepMetric{
+		{Name: "loss", Type: MetricGauge, Value: 0.4},
+		{Name: "samples", Type: MetricCounter, Value: 512},
+	}
+	if len(metrics) != len(want) {
+		t.Fatalf("parseMetrics() = %+v, want %+v", metrics, want)
+	}
+	for i := range want {
+		if metrics[i] != want[i] {
+			t.Errorf("metrics[%d] = %+v, want %+v", i, metrics[i], want[i])
+		}
+	}
+	if got := parseMetrics(strings.NewReader("")); got != nil {
+		t.Errorf("expected nil metrics for empty file, got %v", got)
+	}
+}
+
+func TestRunCommandMetrics(t *testing.T) {
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Command: "bash",
+		Args:    []string{"-c", `echo '{"name":"throughput","value":1250.5}' >> "$SYGALDRY_METRICS"`},
+		LogDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Metrics) != 1 || result.Metrics[0].Name != "throughput" || result.Metrics[0].Value != 1250.5 {
+		t.Errorf("metrics = %+v", result.Metrics)
+	}
+}
@@ -0,0 +1,106 @@
+package activities
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+const defaultOutputMaxBytes = 10_000
+
+// outputBuffer keeps the part of a step's stdout or stderr that goes into
+// its result. Full output only goes to the log files, so memory stays
+// bounded however much a step prints: the buffer holds the first bytes, a
+// ring of the last ones, or both.
+type outputBuffer struct {
+	head    []byte
+	headMax int
+	tail    []byte
+	tailMax int
+	// next is the oldest byte of tail once it is full.
+	next    int
+	written int64
+}
+
+// newOutputBuffer returns a buffer keeping maxBytes of output: the first
+// maxBytes in head mode, the last in tail mode, or the first and last half
+// in head_tail mode.
+func newOutputBuffer(maxBytes int64, mode string) *outputBuffer {
+	switch mode {
+	case "tail":
+		return &outputBuffer{tailMax: int(maxBytes)}
+	case "head_tail":
+		tailMax := int(maxBytes / 2)
+		return &outputBuffer{headMax: int(maxBytes) - tailMax, tailMax: tailMax}
+	}
+	return &outputBuffer{headMax: int(maxBytes)}
+}
+
+// newStepOutputBuffer returns a buffer sized by TEMPORAL_LOG_MAX_BYTES
+// (default 10000) in mode, or in the worker's TEMPORAL_LOG_CAPTURE mode if
+// mode is empty.
+func newStepOutputBuffer(mode string) *outputBuffer {
+	maxBytes := int64(defaultOutputMaxBytes)
+	if value := os.Getenv("TEMPORAL_LOG_MAX_BYTES"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			maxBytes = parsed
+		}
+	}
+	if mode == "" {
+		mode = os.Getenv("TEMPORAL_LOG_CAPTURE")
+	}
+	return newOutputBuffer(maxBytes, mode)
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	b.written += int64(n)
+	if room := b.headMax - len(b.head); room > 0 {
+		k := min(room, len(p))
+		b.head = append(b.head, p[:k]...)
+		p = p[k:]
+	}
+	if b.tailMax == 0 || len(p) == 0 {
+		return n, nil
+	}
+	if len(p) >= b.tailMax {
+		b.tail = append(b.tail[:0], p[len(p)-b.tailMax:]...)
+		b.next = 0
+		return n, nil
+	}
+	for len(p) > 0 {
+		if len(b.tail) < b.tailMax {
+			k := min(b.tailMax-len(b.tail), len(p))
+			b.tail = append(b.tail, p[:k]...)
+			p = p[k:]
+			continue
+		}
+		k := copy(b.tail[b.next:], p)
+		b.next = (b.next + k) % b.tailMax
+		p = p[k:]
+	}
+	return n, nil
+}
+
+func (b *outputBuffer) WriteString(s string) (int, error) {
+	return b.Write([]byte(s))
+}
+
+// Truncated reports whether output was dropped.
+func (b *outputBuffer) Truncated() bool {
+	return b.written > int64(len(b.head)+len(b.tail))
+}
+
+// String returns the kept output. In tail and head_tail modes a marker line
+// stands in for the dropped bytes.
+func (b *outputBuffer) String() string {
+	tail := append(append([]byte(nil), b.tail[b.next:]...), b.tail[:b.next]...)
+	if !b.Truncated() || b.tailMax == 0 {
+		return string(b.head) + string(tail)
+	}
+	marker := fmt.Sprintf("... [%d bytes omitted] ...\n", b.written-int64(len(b.head)+len(tail)))
+	if len(b.head) > 0 {
+		marker = "\n" + marker
+	}
+	return string(b.head) + marker + string(tail)
+}
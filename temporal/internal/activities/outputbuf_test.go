@@ -0,0 +1,84 @@
+package activities
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestOutputBufferHeadTail(t *testing.T) {
+	tests := []struct {
+		writes    []string
+		want      string
+		truncated bool
+	}{
+		{[]string{"short"}, "short", false},
+		{[]string{"0123456789"}, "0123456789", false},
+		{[]string{"0123456789ab"}, "01234\n... [2 bytes omitted] ...\n789ab", true},
+		{[]string{"01", "234", "5678", "9abcdef"}, "01234\n... [6 bytes omitted] ...\nbcdef", true},
+		{[]string{"0123456", "x", "y", "z", "w"}, "01234\n... [1 bytes omitted] ...\n6xyzw", true},
+	}
+	for _, tt := range tests {
+		buffer := newOutputBuffer(10, "head_tail")
+		for _, write := range tt.writes {
+			buffer.WriteString(write)
+		}
+		if got, trunc := buffer.String(), buffer.Truncated(); got != tt.want || trunc != tt.truncated {
+			t.Errorf("%q = (%q, %v), want (%q, %v)", tt.writes, got, trunc, tt.want, tt.truncated)
+		}
+	}
+}
+
+func TestOutputBufferStaysBounded(t *testing.T) {
+	buffer := newOutputBuffer(1000, "head_tail")
+	chunk := []byte(strings.Repeat("x", 64<<10))
+	for i := 0; i < 1024; i++ {
+		buffer.Write(chunk)
+	}
+	buffer.WriteString("the error at the end")
+	if size := len(buffer.head) + cap(buffer.tail); size > 2000 {
+		t.Errorf("buffer holds %d bytes after 64 MiB of output", size)
+	}
+	if got := buffer.String(); !strings.HasSuffix(got, "the error at the end") || !buffer.Truncated() {
+		t.Errorf("tail lost: %q", got[len(got)-40:])
+	}
+}
+
+func TestRunCommandHeadTailCapture(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_MAX_BYTES", "100")
+	t.Setenv("TEMPORAL_LOG_CAPTURE", "head_tail")
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		StepID:  "noisy",
+		LogDir:  t.TempDir(),
+		Command: "sh",
+		Args:    []string{"-c", "echo first; yes filler | head -n 100000; echo 'error: last line' >&2; echo last"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.StdoutTruncated || !strings.HasPrefix(result.Stdout, "first\n") || !strings.HasSuffix(result.Stdout, "filler\nlast\n") {
+		t.Errorf("stdout = %q (truncated %v)", result.Stdout, result.StdoutTruncated)
+	}
+	if result.StderrTruncated || result.Stderr != "error: last line\n" {
+		t.Errorf("stderr = %q (truncated %v)", result.Stderr, result.StderrTruncated)
+	}
+}
+
+func TestRunCommandTailTruncation(t *testing.T) {
+	t.Setenv("TEMPORAL_LOG_MAX_BYTES", "32")
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		StepID:   "build",
+		LogDir:   t.TempDir(),
+		Command:  "sh",
+		Args:     []string{"-c", "seq 1 1000 >&2; echo 'error: missing header' >&2; exit 2"},
+		Truncate: "tail",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3915 bytes of output; the last 32 are kept.
+	want := "... [3883 bytes omitted] ...\n\n999\n1000\nerror: missing header\n"
+	if result.Stderr != want || !result.StderrTruncated {
+		t.Errorf("stderr = %q, want %q", result.Stderr, want)
+	}
+}
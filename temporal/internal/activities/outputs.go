@@ -0,0 +1,60 @@
+package activities
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// maxOutputsBytes caps how much of a step's outputs file is read back, since
+// outputs travel in the workflow payload.
+const maxOutputsBytes = 64 * 1024
+
+// createOutputsFile creates the file exposed to a step as $SYGALDRY_OUTPUTS.
+func createOutputsFile() (string, error) {
+	file, err := os.CreateTemp("", "sygaldry-outputs-*")
+	if err != nil {
+		return "", err
+	}
+	path := file.Name()
+	file.Close()
+	return path, nil
+}
+
+// readOutputs parses the key=value lines a step wrote to its outputs file and
+// removes the file. Blank lines and lines starting with # are ignored; later
+// keys override earlier ones.
+func readOutputs(path string) map[string]string {
+	if path == "" {
+		return nil
+	}
+	defer os.Remove(path)
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	return parseOutputs(io.LimitReader(file, maxOutputsBytes))
+}
+
+func parseOutputs(r io.Reader) map[string]string {
+	var outputs map[string]string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			continue
+		}
+		if outputs == nil {
+			outputs = map[string]string{}
+		}
+		outputs[key] = value
+	}
+	return outputs
+}
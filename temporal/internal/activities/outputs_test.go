@@ -0,0 +1,74 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	outputs := parseOutputs(strings.NewReader("offset=42\n# comment\n\nbad line\n=novalue\npath = /data/x=1\noffset=43\n"))
+	want := map[string]string{"offset": "43", "path": " /data/x=1"}
+	if len(outputs) != len(want) {
+		t.Fatalf("parseOutputs() = %v, want %v", outputs, want)
+	}
+	for key, value := range want {
+		if outputs[key] != value {
+			t.Errorf("outputs[%q] = %q, want %q", key, outputs[key], value)
+		}
+	}
+
+	if got := parseOutputs(strings.NewReader("")); got != nil {
+		t.Errorf("expected nil outputs for empty file, got %v", got)
+	}
+}
+
+func TestRunCommandOutputs(t *testing.T) {
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Command: "bash",
+		Args:    []string{"-c", `echo "rows=128" >> "$SYGALDRY_OUTPUTS"; echo "shard=7" >> "$SYGALDRY_OUTPUTS"`},
+		LogDir:  t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Outputs["rows"] != "128" || result.Outputs["shard"] != "7" {
+		t.Errorf("outputs = %v", result.Outputs)
+	}
+}
+
+func TestRunCommandEarlyFailureLeavesNoFiles(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	_, err := runCommand(context.Background(), RunCommandInput{
+		Command: "true",
+		LogDir:  t.TempDir(),
+		acquire: func(ctx context.Context, log io.Writer) (map[string]string, func(), error) {
+			return nil, nil, errors.New("no slot")
+		},
+	})
+	if err == nil {
+		t.Fatal("expected the acquire failure")
+	}
+	if files, _ := filepath.Glob(filepath.Join(tmp, "sygaldry-*")); len(files) > 0 {
+		t.Errorf("left behind %v", files)
+	}
+}
+
+func TestReadOutputsRemovesFile(t *testing.T) {
+	path, err := createOutputsFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("k=v\n"), 0o644)
+	if got := readOutputs(path); got["k"] != "v" {
+		t.Errorf("readOutputs() = %v", got)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("outputs file should be removed after reading")
+	}
+}
@@ -0,0 +1,101 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type RequirementInput struct {
+	Name         string `json:"name"`
+	Kind         string `json:"kind"`
+	URL          string `json:"url"`
+	ExpectStatus int    `json:"expectStatus"`
+	ImageRef     string `json:"imageRef"`
+	RepoID       string `json:"repoId"`
+	RepoType     string `json:"repoType"`
+	Revision     string `json:"revision"`
+}
+
+type RequirementResult struct {
+	Name   string `json:"name"`
+	Met    bool   `json:"met"`
+	Detail string `json:"detail"`
+}
+
+// CheckRequirement evaluates one plan prerequisite. An unmet prerequisite is
+// reported in the result rather than as an error so the workflow can tell
+// "not ready yet" apart from a broken check.
+func CheckRequirement(ctx context.Context, input RequirementInput) (RequirementResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	result := RequirementResult{Name: input.Name}
+	switch input.Kind {
+	case "http":
+		status, err := httpStatus(ctx, input.URL, "")
+		if err != nil {
+			result.Detail = err.Error()
+			return result, nil
+		}
+		result.Met = statusMatches(status, input.ExpectStatus)
+		result.Detail = fmt.Sprintf("GET %s returned %d", input.URL, status)
+	case "image":
+		out, err := exec.CommandContext(ctx, "docker", "manifest", "inspect", input.ImageRef).CombinedOutput()
+		result.Met = err == nil
+		if result.Met {
+			result.Detail = fmt.Sprintf("image %s exists", input.ImageRef)
+		} else {
+			result.Detail = fmt.Sprintf("image %s not found: %s", input.ImageRef, strings.TrimSpace(string(out)))
+		}
+	case "hf_revision":
+		repoType := input.RepoType
+		if repoType == "" {
+			repoType = "dataset"
+		}
+		endpoint := strings.TrimRight(os.Getenv("HF_ENDPOINT"), "/")
+		if endpoint == "" {
+			endpoint = "https://huggingface.co"
+		}
+		target := fmt.Sprintf("%s/api/%ss/%s/revision/%s", endpoint, repoType, input.RepoID, url.PathEscape(input.Revision))
+		status, err := httpStatus(ctx, target, os.Getenv("HF_TOKEN"))
+		if err != nil {
+			result.Detail = err.Error()
+			return result, nil
+		}
+		result.Met = status == http.StatusOK
+		result.Detail = fmt.Sprintf("%s %s@%s: HTTP %d", repoType, input.RepoID, input.Revision, status)
+	default:
+		return result, fmt.Errorf("unknown requirement kind %q", input.Kind)
+	}
+	return result, nil
+}
+
+func httpStatus(ctx context.Context, target, bearerToken string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	if bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+bearerToken)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// statusMatches accepts any 2xx response unless a specific status is expected.
+func statusMatches(status, expect int) bool {
+	if expect == 0 {
+		return status >= 200 && status < 300
+	}
+	return status == expect
+}
@@ -0,0 +1,98 @@
+package activities
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusMatches(t *testing.T) {
+	tests := []struct {
+		status, expect int
+		want           bool
+	}{
+		{200, 0, true},
+		{204, 0, true},
+		{503, 0, false},
+		{404, 404, true},
+		{200, 204, false},
+	}
+	for _, tt := range tests {
+		if got := statusMatches(tt.status, tt.expect); got != tt.want {
+			t.Errorf("statusMatches(%d, %d) = %v, want %v", tt.status, tt.expect, got, tt.want)
+		}
+	}
+}
+
+func TestCheckRequirementHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	result, err := CheckRequirement(context.Background(), RequirementInput{Name: "api", Kind: "http", URL: server.URL + "/healthz"})
+	if err != nil || !result.Met {
+		t.Errorf("healthy endpoint: %+v, %v", result, err)
+	}
+	result, err = CheckRequirement(context.Background(), RequirementInput{Name: "api", Kind: "http", URL: server.URL + "/down"})
+	if err != nil || result.Met || !strings.Contains(result.Detail, "503") {
+		t.Errorf("unhealthy endpoint: %+v, %v", result, err)
+	}
+	result, err = CheckRequirement(context.Background(), RequirementInput{Kind: "http", URL: "http://127.0.0.1:1/unreachable"})
+	if err != nil || result.Met {
+		t.Errorf("unreachable endpoint should be unmet without error: %+v, %v", result, err)
+	}
+}
+
+func TestCheckRequirementHFRevision(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		if r.URL.Path == "/api/datasets/org/ds/revision/v2" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	t.Setenv("HF_ENDPOINT", server.URL)
+	t.Setenv("HF_TOKEN", "hf_secret")
+
+	result, err := CheckRequirement(context.Background(), RequirementInput{Kind: "hf_revision", RepoID: "org/ds", Revision: "v2"})
+	if err != nil || !result.Met {
+		t.Errorf("existing revision: %+v, %v", result, err)
+	}
+	if gotAuth != "Bearer hf_secret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	result, err = CheckRequirement(context.Background(), RequirementInput{Kind: "hf_revision", RepoID: "org/ds", Revision: "v3"})
+	if err != nil || result.Met {
+		t.Errorf("missing revision: %+v, %v", result, err)
+	}
+}
+
+func TestCheckRequirementHTTPDoesNotSendHFToken(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+	t.Setenv("HF_TOKEN", "hf_secret")
+
+	CheckRequirement(context.Background(), RequirementInput{Kind: "http", URL: server.URL + "/api/health"})
+	if gotAuth != "" {
+		t.Errorf("HF token leaked to healthcheck: %q", gotAuth)
+	}
+}
+
+func TestCheckRequirementUnknownKind(t *testing.T) {
+	if _, err := CheckRequirement(context.Background(), RequirementInput{Kind: "ftp"}); err == nil {
+		t.Error("expected error for unknown kind")
+	}
+}
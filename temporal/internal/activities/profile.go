@@ -0,0 +1,295 @@
+package activities
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Profilers of Profile.Tool.
+const (
+	// ProfilerPySpy samples Python processes with py-spy.
+	ProfilerPySpy = "py-spy"
+	// ProfilerPerf samples any process with perf, with call graphs.
+	ProfilerPerf = "perf"
+)
+
+const (
+	// DefaultProfileRate is the sampling rate, per second, of a Profile
+	// without one.
+	DefaultProfileRate = 100
+	// profileHotspots is how many frames StepProfile.Hotspots keeps.
+	profileHotspots = 10
+)
+
+// Profile runs a command step under a sampling profiler.
+type Profile struct {
+	Tool string `json:"tool"`
+	// Rate is the number of samples per second; 0 uses DefaultProfileRate.
+	Rate int `json:"rate,omitempty"`
+}
+
+// StepProfile is the profile of a command step: its stacks, folded one per
+// line with their sample counts as read by flamegraph.pl or speedscope, are
+// in the log directory at Path.
+type StepProfile struct {
+	Tool     string    `json:"tool"`
+	Path     string    `json:"path"`
+	Samples  int       `json:"samples"`
+	Hotspots []Hotspot `json:"hotspots,omitempty"`
+}
+
+// Hotspot is a frame the profiler found on top of the stack, with how many
+// samples it was found in.
+type Hotspot struct {
+	Frame   string  `json:"frame"`
+	Samples int     `json:"samples"`
+	Percent float64 `json:"percent"`
+}
+
+// stepProfiler wraps the invocations of one command step and gathers their
+// samples.
+type stepProfiler struct {
+	tool   string
+	rate   int
+	dir    string
+	stacks map[string]int
+}
+
+// newStepProfiler prepares profile for a step whose scratch files go to dir.
+// A profiler missing from the worker is reported to log and the step runs
+// without it.
+func newStepProfiler(profile *Profile, dir string, log io.Writer) *stepProfiler {
+	if profile == nil {
+		return nil
+	}
+	if _, err := exec.LookPath(profile.Tool); err != nil {
+		fmt.Fprintf(log, "not profiling: %s is not installed on this worker\n", profile.Tool)
+		return nil
+	}
+	rate := profile.Rate
+	if rate <= 0 {
+		rate = DefaultProfileRate
+	}
+	return &stepProfiler{tool: profile.Tool, rate: rate, dir: dir, stacks: map[string]int{}}
+}
+
+func (p *stepProfiler) output(run int) string {
+	name := "profile-" + strconv.Itoa(run) + ".txt"
+	if p.tool == ProfilerPerf {
+		name = "perf-" + strconv.Itoa(run) + ".data"
+	}
+	return filepath.Join(p.dir, name)
+}
+
+// wrap returns the invocation of run under the profiler.
+func (p *stepProfiler) wrap(argv []string, run int) []string {
+	rate := strconv.Itoa(p.rate)
+	switch p.tool {
+	case ProfilerPerf:
+		return append([]string{"perf", "record", "--quiet", "-g", "-F", rate, "-o", p.output(run), "--"}, argv...)
+	default:
+		return append([]string{"py-spy", "record", "--subprocesses", "--format", "raw", "--rate", rate, "--output", p.output(run), "--"}, argv...)
+	}
+}
+
+// collect adds the samples of run, reporting to log what could not be read.
+func (p *stepProfiler) collect(ctx context.Context, run int, log io.Writer) {
+	var stacks map[string]int
+	var err error
+	switch p.tool {
+	case ProfilerPerf:
+		var out []byte
+		out, err = exec.CommandContext(ctx, "perf", "script", "-i", p.output(run)).Output()
+		if err == nil {
+			stacks, err = foldPerfScript(bytes.NewReader(out))
+		}
+	default:
+		var file *os.File
+		if file, err = os.Open(p.output(run)); err == nil {
+			stacks, err = readFoldedStacks(file)
+			file.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(log, "unable to read the %s profile: %v\n", p.tool, err)
+		return
+	}
+	for stack, count := range stacks {
+		p.stacks[stack] += count
+	}
+}
+
+// save writes the folded stacks to the log directory and summarizes them. It
+// returns nil when no samples were taken.
+func (p *stepProfiler) save(logDir, workflowID, runID, stepID string, log io.Writer) *StepProfile {
+	if len(p.stacks) == 0 {
+		return nil
+	}
+	profile := &StepProfile{Tool: p.tool}
+	profile.Samples, profile.Hotspots = hotspots(p.stacks, profileHotspots)
+	path, err := writeFoldedStacks(logDir, LogFilePrefix(workflowID, runID)+safeName(stepID)+"_profile.folded", p.stacks)
+	if err != nil {
+		fmt.Fprintf(log, "unable to save the %s profile: %v\n", p.tool, err)
+	}
+	profile.Path = path
+	return profile
+}
+
+func writeFoldedStacks(logDir, name string, stacks map[string]int) (string, error) {
+	logFS, err := openLogFS(resolveLogDir(logDir))
+	if err != nil {
+		return "", err
+	}
+	key, err := LogEncryptionKey()
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		name += ".enc"
+	}
+	writer, closer, err := createLogFile(logFS, name, key)
+	if err != nil {
+		return "", err
+	}
+	lines := make([]string, 0, len(stacks))
+	for stack := range stacks {
+		lines = append(lines, stack)
+	}
+	sort.Strings(lines)
+	buffered := bufio.NewWriter(writer)
+	for _, stack := range lines {
+		fmt.Fprintf(buffered, "%s %d\n", stack, stacks[stack])
+	}
+	err = buffered.Flush()
+	if closeErr := closer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	return logFS.Path(name), nil
+}
+
+// readFoldedStacks reads stacks folded one per line, "a;b;c 12", as written
+// by py-spy's raw format.
+func readFoldedStacks(r io.Reader) (map[string]int, error) {
+	stacks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		cut := strings.LastIndexByte(line, ' ')
+		if cut <= 0 {
+			continue
+		}
+		count, err := strconv.Atoi(line[cut+1:])
+		if err != nil || count <= 0 {
+			continue
+		}
+		stacks[line[:cut]] += count
+	}
+	return stacks, scanner.Err()
+}
+
+// foldPerfScript folds the samples printed by `perf script`: a header line
+// naming the command, then one frame per line from the top of the stack
+// down, then a blank line.
+func foldPerfScript(r io.Reader) (map[string]int, error) {
+	stacks := map[string]int{}
+	var command string
+	var frames []string
+	flush := func() {
+		if command != "" {
+			stack := []string{command}
+			for i := len(frames) - 1; i >= 0; i-- {
+				stack = append(stack, frames[i])
+			}
+			stacks[strings.Join(stack, ";")]++
+		}
+		command, frames = "", nil
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case line[0] != ' ' && line[0] != '\t':
+			flush()
+			if fields := strings.Fields(line); len(fields) > 0 {
+				command = fields[0]
+			}
+		case command != "":
+			frames = append(frames, perfFrame(strings.Fields(line)))
+		}
+	}
+	flush()
+	return stacks, scanner.Err()
+}
+
+// perfFrame names a frame of `perf script` output, "addr symbol+0x1f (dso)",
+// by its symbol without the offset, or by its object when it has none.
+func perfFrame(fields []string) string {
+	if len(fields) < 2 {
+		return "[unknown]"
+	}
+	fields = fields[1:]
+	object := ""
+	if last := fields[len(fields)-1]; strings.HasPrefix(last, "(") && strings.HasSuffix(last, ")") {
+		object = filepath.Base(strings.Trim(last, "()"))
+		fields = fields[:len(fields)-1]
+	}
+	symbol := strings.Join(fields, " ")
+	if i := strings.LastIndex(symbol, "+0x"); i > 0 {
+		symbol = symbol[:i]
+	}
+	if (symbol == "" || symbol == "[unknown]") && object != "" {
+		return "[" + object + "]"
+	}
+	if symbol == "" {
+		return "[unknown]"
+	}
+	return symbol
+}
+
+// hotspots counts the samples of stacks and returns the top frames by the
+// samples they were on top of the stack in, at most limit of them.
+func hotspots(stacks map[string]int, limit int) (int, []Hotspot) {
+	total := 0
+	self := map[string]int{}
+	for stack, count := range stacks {
+		total += count
+		frame := stack
+		if i := strings.LastIndexByte(stack, ';'); i >= 0 {
+			frame = stack[i+1:]
+		}
+		self[frame] += count
+	}
+	spots := make([]Hotspot, 0, len(self))
+	for frame, count := range self {
+		spots = append(spots, Hotspot{Frame: frame, Samples: count})
+	}
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Samples != spots[j].Samples {
+			return spots[i].Samples > spots[j].Samples
+		}
+		return spots[i].Frame < spots[j].Frame
+	})
+	if len(spots) > limit {
+		spots = spots[:limit]
+	}
+	for i := range spots {
+		spots[i].Percent = float64(spots[i].Samples) * 100 / float64(total)
+	}
+	return total, spots
+}
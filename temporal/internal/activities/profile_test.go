@@ -0,0 +1,111 @@
+package activities
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFoldPerfScript(t *testing.T) {
+	script := `python3 4242 10.000001:     10101 cpu-clock:pppH:
+	    7f01 tokenize+0x1f (/usr/lib/libprep.so)
+	    7f02 main+0x40 (/usr/bin/python3)
+
+python3 4242 10.010001:     10101 cpu-clock:pppH:
+	    7f03 [unknown] (/usr/lib/libc.so.6)
+	    7f02 main+0x40 (/usr/bin/python3)
+
+python3 4242 10.020001:     10101 cpu-clock:pppH:
+	    7f01 tokenize+0x2a (/usr/lib/libprep.so)
+	    7f02 main+0x40 (/usr/bin/python3)
+`
+	stacks, err := foldPerfScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"python3;main;tokenize": 2, "python3;main;[libc.so.6]": 1}
+	if !reflect.DeepEqual(stacks, want) {
+		t.Errorf("stacks = %v, want %v", stacks, want)
+	}
+}
+
+func TestHotspots(t *testing.T) {
+	stacks, err := readFoldedStacks(strings.NewReader("main (a.py:1);load (a.py:5) 30\nmain (a.py:1);parse (a.py:9) 60\nmain (a.py:1);parse (a.py:9);re.sub 10\nnot a stack\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, spots := hotspots(stacks, 2)
+	want := []Hotspot{{Frame: "parse (a.py:9)", Samples: 60, Percent: 60}, {Frame: "load (a.py:5)", Samples: 30, Percent: 30}}
+	if total != 100 || !reflect.DeepEqual(spots, want) {
+		t.Errorf("hotspots = %d %+v, want 100 %+v", total, spots, want)
+	}
+}
+
+func TestRunCommandProfile(t *testing.T) {
+	bin := t.TempDir()
+	fake := `#!/bin/sh
+while [ "$1" != "--" ]; do
+	if [ "$1" = "--output" ]; then out="$2"; fi
+	shift
+done
+shift
+printf 'main (prep.py:1);tokenize (prep.py:40) 3\nmain (prep.py:1);write (prep.py:88) 1\n' > "$out"
+exec "$@"
+`
+	if err := os.WriteFile(filepath.Join(bin, "py-spy"), []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	logDir := t.TempDir()
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Run:        []string{"echo one", "echo two"},
+		WorkflowID: "wf",
+		RunID:      "run",
+		StepID:     "prep",
+		LogDir:     logDir,
+		WorkingDir: t.TempDir(),
+		Profile:    &Profile{Tool: ProfilerPySpy},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || result.Stdout != "one\ntwo\n" {
+		t.Fatalf("result = %d %q", result.ExitCode, result.Stdout)
+	}
+	profile := result.Profile
+	if profile == nil || profile.Samples != 8 || len(profile.Hotspots) != 2 || profile.Hotspots[0].Frame != "tokenize (prep.py:40)" {
+		t.Fatalf("profile = %+v", profile)
+	}
+	if profile.Path != filepath.Join(logDir, "wf_run_prep_profile.folded") {
+		t.Errorf("path = %s", profile.Path)
+	}
+	data, err := os.ReadFile(profile.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "main (prep.py:1);tokenize (prep.py:40) 6\nmain (prep.py:1);write (prep.py:88) 2\n"; string(data) != want {
+		t.Errorf("folded stacks = %q, want %q", data, want)
+	}
+}
+
+func TestRunCommandProfilerMissing(t *testing.T) {
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Command:    "echo",
+		Args:       []string{"ok"},
+		WorkflowID: "wf",
+		RunID:      "run",
+		StepID:     "prep",
+		LogDir:     t.TempDir(),
+		WorkingDir: t.TempDir(),
+		Profile:    &Profile{Tool: "no-such-profiler"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 0 || result.Profile != nil || !strings.Contains(result.Stderr, "not profiling: no-such-profiler is not installed") {
+		t.Errorf("result = %d %+v %q", result.ExitCode, result.Profile, result.Stderr)
+	}
+}
@@ -0,0 +1,168 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// hfRequirements are the packages the HF download scripts need, pinned so
+// every worker bootstraps the same environment.
+var hfRequirements = []string{"huggingface_hub==0.26.2"}
+
+// pythonEnvReady marks a bootstrapped environment; it is written last and
+// holds the requirements it was built with.
+const pythonEnvReady = ".sygaldry-requirements"
+
+// pythonEnvs serialises bootstraps within the worker process. Workers on the
+// same host build in separate directories and keep the first one renamed
+// into place.
+var pythonEnvs sync.Mutex
+
+// hfPython prepares the Python interpreter for an HF step and returns the
+// environment that selects it, for RunCommandInput.acquire. The mode comes
+// from TEMPORAL_HF_PYTHON:
+//
+//   - auto (default): use python3 from PATH if it imports huggingface_hub,
+//     otherwise bootstrap the pinned environment.
+//   - venv: always use the pinned environment.
+//   - system: always use python3 from PATH.
+//
+// The environment is built once per worker host under TEMPORAL_HF_VENV_ROOT
+// (default: the user cache directory), with uv when it is on PATH and
+// `python3 -m venv` and pip otherwise.
+func hfPython(ctx context.Context, log io.Writer) (map[string]string, func(), error) {
+	release := func() {}
+	mode := envOrDefault("TEMPORAL_HF_PYTHON", "auto")
+	switch mode {
+	case "system":
+		return nil, release, nil
+	case "auto":
+		if exec.CommandContext(ctx, "python3", "-c", "import huggingface_hub").Run() == nil {
+			return nil, release, nil
+		}
+	case "venv":
+	default:
+		return nil, nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("TEMPORAL_HF_PYTHON must be auto, venv or system, not %q", mode), "PythonBootstrapFailed", nil)
+	}
+	dir, err := bootstrapPythonEnv(ctx, log, hfPythonRequirements())
+	if err != nil {
+		return nil, nil, err
+	}
+	bin := filepath.Join(dir, "bin")
+	return map[string]string{
+		"VIRTUAL_ENV": dir,
+		"PATH":        bin + string(os.PathListSeparator) + os.Getenv("PATH"),
+	}, release, nil
+}
+
+// hfPythonRequirements returns TEMPORAL_HF_REQUIREMENTS, comma or space
+// separated, or the pinned defaults.
+func hfPythonRequirements() []string {
+	value := os.Getenv("TEMPORAL_HF_REQUIREMENTS")
+	if strings.TrimSpace(value) == "" {
+		return hfRequirements
+	}
+	return strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// bootstrapPythonEnv returns a virtual environment with requirements
+// installed, building it on first use. Environments are named by a hash of
+// their requirements, so changing the pins builds a new one.
+func bootstrapPythonEnv(ctx context.Context, log io.Writer, requirements []string) (string, error) {
+	root := os.Getenv("TEMPORAL_HF_VENV_ROOT")
+	if root == "" {
+		cache, err := os.UserCacheDir()
+		if err != nil {
+			cache = os.TempDir()
+		}
+		root = filepath.Join(cache, "sygaldry")
+	}
+	pins := strings.Join(requirements, "\n")
+	sum := sha256.Sum256([]byte(pins))
+	dir := filepath.Join(root, "hf-python-"+hex.EncodeToString(sum[:6]))
+
+	pythonEnvs.Lock()
+	defer pythonEnvs.Unlock()
+	if _, err := os.Stat(filepath.Join(dir, pythonEnvReady)); err == nil {
+		return dir, nil
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", bootstrapError(err.Error(), false)
+	}
+	// Virtual environments find their packages relative to their
+	// interpreter, so one built aside can be renamed into place.
+	build, err := os.MkdirTemp(root, ".build-")
+	if err != nil {
+		return "", bootstrapError(err.Error(), false)
+	}
+	defer os.RemoveAll(build)
+
+	var steps [][]string
+	python := filepath.Join(build, "bin", "python")
+	if uv, err := exec.LookPath("uv"); err == nil {
+		fmt.Fprintf(log, "bootstrapping Python environment %s with uv: %s\n", dir, strings.Join(requirements, " "))
+		steps = [][]string{
+			{uv, "venv", "--quiet", "--allow-existing", build},
+			append([]string{uv, "pip", "install", "--quiet", "--python", python}, requirements...),
+		}
+	} else if python3, err := exec.LookPath("python3"); err == nil {
+		fmt.Fprintf(log, "bootstrapping Python environment %s with pip: %s\n", dir, strings.Join(requirements, " "))
+		steps = [][]string{
+			{python3, "-m", "venv", build},
+			append([]string{python, "-m", "pip", "install", "--quiet", "--disable-pip-version-check", "--no-input"}, requirements...),
+		}
+	} else {
+		return "", bootstrapError("neither uv nor python3 is on the worker's PATH", false)
+	}
+	for _, argv := range steps {
+		var output bytes.Buffer
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Stdout = io.MultiWriter(log, &output)
+		cmd.Stderr = cmd.Stdout
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			detail := strings.TrimSpace(output.String())
+			if len(detail) > 2000 {
+				detail = "..." + detail[len(detail)-2000:]
+			}
+			// Retryable: the usual cause is a package index the worker
+			// cannot reach yet.
+			return "", bootstrapError(fmt.Sprintf("%s: %v: %s", strings.Join(argv[:2], " "), err, detail), true)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(build, pythonEnvReady), []byte(pins+"\n"), 0o644); err != nil {
+		return "", bootstrapError(err.Error(), false)
+	}
+	if err := os.Rename(build, dir); err != nil {
+		// Another worker on this host finished first; use its environment.
+		if _, statErr := os.Stat(filepath.Join(dir, pythonEnvReady)); statErr == nil {
+			return dir, nil
+		}
+		return "", bootstrapError(err.Error(), false)
+	}
+	return dir, nil
+}
+
+// bootstrapError reports a failed bootstrap as a PythonBootstrapFailed
+// error, retryable when a later attempt may succeed.
+func bootstrapError(detail string, retryable bool) error {
+	message := "python environment bootstrap failed (set TEMPORAL_HF_PYTHON=system to use the worker's python3): " + detail
+	if retryable {
+		return temporal.NewApplicationError(message, "PythonBootstrapFailed")
+	}
+	return temporal.NewNonRetryableApplicationError(message, "PythonBootstrapFailed", nil)
+}
@@ -0,0 +1,79 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// fakeUV builds "environments" whose python3 runs the real interpreter with
+// the environment's own lib directory on PYTHONPATH; `pip install` copies the
+// fake huggingface_hub there, or fails when UV_FAIL is set.
+const fakeUV = `#!/bin/sh
+case "$1" in
+venv)
+    mkdir -p "$4/bin" "$4/lib"
+    printf '#!/bin/sh\nPYTHONPATH="$(dirname "$0")/../lib" exec %s "$@"\n' "$REAL_PYTHON" > "$4/bin/python3"
+    chmod +x "$4/bin/python3"
+    ln -s python3 "$4/bin/python" ;;
+pip)
+    if [ -n "$UV_FAIL" ]; then echo "error: failed to fetch: network unreachable" >&2; exit 2; fi
+    cp "$FAKE_HUB" "$(dirname "$(dirname "$5")")/lib/huggingface_hub.py" ;;
+esac
+`
+
+func TestHFPythonBootstrap(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	modules := t.TempDir()
+	hub := filepath.Join(modules, "huggingface_hub.py")
+	if err := os.WriteFile(hub, []byte(fakeHubModule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "uv"), []byte(fakeUV), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("REAL_PYTHON", python)
+	t.Setenv("FAKE_HUB", hub)
+	t.Setenv("TEMPORAL_HF_PYTHON", "venv")
+	t.Setenv("TEMPORAL_HF_VENV_ROOT", t.TempDir())
+
+	input := HFDownloadDatasetInput{
+		StepID:        "docs",
+		DatasetID:     "org/corpus",
+		AllowPatterns: []string{"*.md"},
+		CacheDir:      t.TempDir(),
+	}
+	for i, bootstraps := range []bool{true, false} {
+		input.LogDir = t.TempDir()
+		result, err := HFDownloadDataset(context.Background(), input)
+		if err != nil || result.ExitCode != 0 || result.Outputs["files"] != "1" {
+			t.Fatalf("run %d: exit %d, outputs %v, err = %v, stderr = %s", i, result.ExitCode, result.Outputs, err, result.Stderr)
+		}
+		if got := strings.Contains(result.Stderr, "bootstrapping Python environment"); got != bootstraps {
+			t.Errorf("run %d: bootstrapped = %v, want %v\n%s", i, got, bootstraps, result.Stderr)
+		}
+	}
+
+	t.Setenv("TEMPORAL_HF_REQUIREMENTS", "huggingface_hub==9.9.9")
+	t.Setenv("UV_FAIL", "1")
+	input.LogDir = t.TempDir()
+	_, err = HFDownloadDataset(context.Background(), input)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != "PythonBootstrapFailed" || appErr.NonRetryable() {
+		t.Fatalf("err = %v, want a retryable PythonBootstrapFailed error", err)
+	}
+	if !strings.Contains(err.Error(), "network unreachable") {
+		t.Errorf("error does not carry the installer output: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// RateLimiterNamePattern is the form of a rate limiter name.
+var RateLimiterNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+var rateUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "second": time.Second,
+	"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+	"h": time.Hour, "hour": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour,
+}
+
+// RateLimit allows Count step starts per Per.
+type RateLimit struct {
+	Count int
+	Per   time.Duration
+}
+
+// rateLimiter spaces acquisitions evenly, Per/Count apart, so no window of
+// length Per ever sees more than Count starts.
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// reserve books the next slot and returns how long to wait for it.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return wait
+}
+
+var (
+	rateLimitersMu sync.RWMutex
+	rateLimiters   = map[string]*rateLimiter{}
+)
+
+// ParseRateLimits parses a worker's rate limiter configuration, a comma
+// separated list of name=count/unit entries such as
+// "hf-api=10/min,dockerhub=100/hour". Units are s, min, hour and day.
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	limits := map[string]RateLimit{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rate, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !RateLimiterNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("rate limit %q: want name=count/unit", entry)
+		}
+		countText, unit, ok := strings.Cut(strings.TrimSpace(rate), "/")
+		count, err := strconv.Atoi(strings.TrimSpace(countText))
+		if !ok || err != nil || count <= 0 {
+			return nil, fmt.Errorf("rate limit %q: count must be a positive integer", entry)
+		}
+		per, ok := rateUnits[strings.TrimSpace(unit)]
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q: unknown unit %q", entry, unit)
+		}
+		if _, dup := limits[name]; dup {
+			return nil, fmt.Errorf("rate limit %s is defined twice", name)
+		}
+		limits[name] = RateLimit{Count: count, Per: per}
+	}
+	return limits, nil
+}
+
+// ConfigureRateLimiters replaces the worker's named rate limiters with those
+// in spec (see ParseRateLimits). Limiters are shared by every step run by this
+// worker process.
+func ConfigureRateLimiters(spec string) (map[string]RateLimit, error) {
+	limits, err := ParseRateLimits(spec)
+	if err != nil {
+		return nil, err
+	}
+	limiters := make(map[string]*rateLimiter, len(limits))
+	for name, limit := range limits {
+		limiters[name] = &rateLimiter{interval: limit.Per / time.Duration(limit.Count)}
+	}
+	rateLimitersMu.Lock()
+	rateLimiters = limiters
+	rateLimitersMu.Unlock()
+	return limits, nil
+}
+
+// waitRateLimits blocks until a slot is free on each named limiter, noting
+// any wait on log. A name this worker does not define fails the step without
+// retries rather than running it unthrottled.
+func waitRateLimits(ctx context.Context, names []string, log io.Writer) error {
+	if len(names) == 0 {
+		return nil
+	}
+	rateLimitersMu.RLock()
+	limiters := make([]*rateLimiter, len(names))
+	var unknown []string
+	for i, name := range names {
+		limiters[i] = rateLimiters[name]
+		if limiters[i] == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	rateLimitersMu.RUnlock()
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("rate limiter %s is not configured on this worker (TEMPORAL_RATE_LIMITS)", strings.Join(unknown, ", ")),
+			"UnknownRateLimiter", nil)
+	}
+	for i, limiter := range limiters {
+		wait := limiter.reserve(time.Now())
+		if wait <= 0 {
+			continue
+		}
+		fmt.Fprintf(log, "waiting %s for rate limiter %s\n", wait.Round(time.Millisecond), names[i])
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := ParseRateLimits("hf-api=10/min, dockerhub=100/hour,s3=5/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RateLimit{
+		"hf-api":    {Count: 10, Per: time.Minute},
+		"dockerhub": {Count: 100, Per: time.Hour},
+		"s3":        {Count: 5, Per: time.Second},
+	}
+	if len(limits) != len(want) {
+		t.Fatalf("limits = %v", limits)
+	}
+	for name, limit := range want {
+		if limits[name] != limit {
+			t.Errorf("%s = %+v, want %+v", name, limits[name], limit)
+		}
+	}
+
+	if limits, err := ParseRateLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("empty spec = %v, %v", limits, err)
+	}
+	for _, spec := range []string{"hf-api", "hf-api=0/min", "hf-api=ten/min", "hf-api=10/week", "HF=1/s", "a=1/s,a=2/s"} {
+		if _, err := ParseRateLimits(spec); err == nil {
+			t.Errorf("ParseRateLimits(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestRateLimiterSpacesStarts(t *testing.T) {
+	limiter := &rateLimiter{interval: 6 * time.Second}
+	now := time.Unix(1000, 0)
+	for i, want := range []time.Duration{0, 6 * time.Second, 12 * time.Second} {
+		if got := limiter.reserve(now); got != want {
+			t.Errorf("reservation %d waits %s, want %s", i, got, want)
+		}
+	}
+	// After an idle period the limiter does not bank slots.
+	later := now.Add(time.Hour)
+	if got := limiter.reserve(later); got != 0 {
+		t.Errorf("wait after idle = %s", got)
+	}
+	if got := limiter.reserve(later); got != 6*time.Second {
+		t.Errorf("second wait after idle = %s", got)
+	}
+}
+
+func TestWaitRateLimits(t *testing.T) {
+	if _, err := ConfigureRateLimiters("fast=20/s"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ConfigureRateLimiters("") })
+
+	var log bytes.Buffer
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := waitRateLimits(context.Background(), []string{"fast"}, &log); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 starts at 20/s took %s, want at least 100ms", elapsed)
+	}
+	if !strings.Contains(log.String(), "for rate limiter fast") {
+		t.Errorf("wait not logged: %q", log.String())
+	}
+
+	err := waitRateLimits(context.Background(), []string{"fast", "hf-api"}, &log)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != "UnknownRateLimiter" || !appErr.NonRetryable() {
+		t.Errorf("unknown limiter error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ConfigureRateLimiters("slow=1/hour")
+	waitRateLimits(ctx, []string{"slow"}, &log)
+	if err := waitRateLimits(ctx, []string{"slow"}, &log); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled wait = %v", err)
+	}
+}
+
+func TestRunCommandWaitsOnRateLimit(t *testing.T) {
+	ConfigureRateLimiters("api=1/hour")
+	t.Cleanup(func() { ConfigureRateLimiters("") })
+	input := RunCommandInput{Command: "true", StepID: "call", LogDir: t.TempDir(), RateLimits: []string{"api"}}
+	if _, err := RunCommand(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := RunCommand(ctx, input); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("second call within the hour = %v, want it to wait until the deadline", err)
+	}
+}
@@ -0,0 +1,88 @@
+package activities
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RunIndexEntry records where a run keeps its logs. The worker running the
+// pipeline appends one when the run starts and one when it ends, so the last
+// entry of a run carries its final status.
+type RunIndexEntry struct {
+	Timestamp  string            `json:"timestamp"`
+	WorkflowID string            `json:"workflowId"`
+	RunID      string            `json:"runId"`
+	Plan       string            `json:"plan,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	LogDir     string            `json:"logDir"`
+	StartedAt  string            `json:"startedAt"`
+	Status     string            `json:"status"`
+	Worker     string            `json:"worker,omitempty"`
+}
+
+type RecordRunIndexInput struct {
+	LogDir string        `json:"logDir"`
+	Entry  RunIndexEntry `json:"entry"`
+}
+
+// RunIndexPath is the run index of a log directory: runs.jsonl in its
+// results store.
+func RunIndexPath(logDir string) string {
+	return filepath.Join(resultsDir(logDir), "runs.jsonl")
+}
+
+// RecordRunIndex appends a run's entry to the run index, stamping it with
+// the time, the resolved log directory and this worker.
+func RecordRunIndex(ctx context.Context, input RecordRunIndexInput) error {
+	entry := input.Entry
+	entry.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	entry.LogDir = resolveLogDir(input.LogDir)
+	entry.Worker = attemptWorker()
+	path := RunIndexPath(input.LogDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return errors.Join(err, file.Close())
+}
+
+// ReadRunIndex returns the latest entry of each run in the index at path, in
+// the order the runs started. Malformed lines are skipped.
+func ReadRunIndex(path string) ([]RunIndexEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var runs []RunIndexEntry
+	index := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry RunIndexEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.WorkflowID == "" {
+			continue
+		}
+		key := entry.WorkflowID + "/" + entry.RunID
+		if i, ok := index[key]; ok {
+			runs[i] = entry
+			continue
+		}
+		index[key] = len(runs)
+		runs = append(runs, entry)
+	}
+	return runs, scanner.Err()
+}
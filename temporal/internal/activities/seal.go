@@ -0,0 +1,151 @@
+package activities
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// SealedPrefix marks a plan value sealed for the workers. The rest is the
+// base64 of an ephemeral X25519 public key, a 12-byte nonce and the AES-GCM
+// ciphertext, keyed by the X25519 shared secret with the workers' key.
+const SealedPrefix = "sygaldry-sealed:v1:"
+
+// IsSealed reports whether value is a sealed plan value.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, SealedPrefix)
+}
+
+// GenerateSealKey returns a new base64 encoded X25519 key pair. Workers hold
+// the private key; anyone sealing plan values needs only the public key.
+func GenerateSealKey() (private, public string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()), base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// SealValue encrypts plaintext for the holder of the private key matching
+// publicKey (base64) and returns it with SealedPrefix.
+func SealValue(publicKey, plaintext string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil {
+		return "", errors.New("seal public key must be base64 encoded")
+	}
+	recipient, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("seal public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	shared, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(sealKey(shared, ephemeral.PublicKey().Bytes(), recipient.Bytes()))
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := append(ephemeral.PublicKey().Bytes(), nonce...)
+	sealed = aead.Seal(sealed, nonce, []byte(plaintext), nil)
+	return SealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenValue decrypts a sealed value with the base64 private key.
+func OpenValue(privateKey, value string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil {
+		return "", errors.New("seal private key must be base64 encoded")
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return "", fmt.Errorf("seal private key: %w", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SealedPrefix))
+	if err != nil || len(data) < 32+12 {
+		return "", errors.New("malformed sealed value")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(data[:32])
+	if err != nil {
+		return "", errors.New("malformed sealed value")
+	}
+	shared, err := key.ECDH(ephemeral)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(sealKey(shared, data[:32], key.PublicKey().Bytes()))
+	if err != nil {
+		return "", err
+	}
+	nonce := data[32 : 32+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, data[32+aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("sealed value was not sealed for this worker's key")
+	}
+	return string(plaintext), nil
+}
+
+func sealKey(shared, ephemeral, recipient []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("sygaldry-seal-v1"))
+	h.Write(shared)
+	h.Write(ephemeral)
+	h.Write(recipient)
+	return h.Sum(nil)
+}
+
+// workerSealKey loads the worker's private key from TEMPORAL_SEAL_PRIVATE_KEY
+// or the file named by TEMPORAL_SEAL_PRIVATE_KEY_FILE.
+func workerSealKey() (string, error) {
+	if key := os.Getenv("TEMPORAL_SEAL_PRIVATE_KEY"); key != "" {
+		return key, nil
+	}
+	path := os.Getenv("TEMPORAL_SEAL_PRIVATE_KEY_FILE")
+	if path == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read seal private key: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// openSealedEnv returns a copy of env with sealed values decrypted by the
+// worker's key. A value that cannot be opened fails the step without
+// retries, naming the variable but never its contents.
+func openSealedEnv(env map[string]string) (map[string]string, error) {
+	opened := make(map[string]string, len(env))
+	for name, value := range env {
+		if !IsSealed(value) {
+			opened[name] = value
+			continue
+		}
+		key, err := workerSealKey()
+		if err == nil && key == "" {
+			err = errors.New("TEMPORAL_SEAL_PRIVATE_KEY is not set on this worker")
+		}
+		if err == nil {
+			opened[name], err = OpenValue(key, value)
+		}
+		if err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("unable to open sealed value of %s: %v", name, err), "SealedValueUnreadable", nil)
+		}
+	}
+	return opened, nil
+}
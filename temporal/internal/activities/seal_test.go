@@ -0,0 +1,68 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestSealValueRoundTrip(t *testing.T) {
+	private, public, err := GenerateSealKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := SealValue(public, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "hunter2") {
+		t.Fatalf("sealed = %q", sealed)
+	}
+	if opened, err := OpenValue(private, sealed); err != nil || opened != "hunter2" {
+		t.Fatalf("OpenValue = %q, %v", opened, err)
+	}
+
+	other, _, _ := GenerateSealKey()
+	if _, err := OpenValue(other, sealed); err == nil {
+		t.Error("opened with another worker's key")
+	}
+	if _, err := SealValue("not base64!", "x"); err == nil {
+		t.Error("sealed with a malformed public key")
+	}
+}
+
+func TestRunCommandOpensSealedEnv(t *testing.T) {
+	private, public, _ := GenerateSealKey()
+	sealed, _ := SealValue(public, "s3cret")
+	input := RunCommandInput{
+		Command:    "sh",
+		Args:       []string{"-c", "echo $TOKEN"},
+		WorkflowID: "test-wf",
+		RunID:      "test-run",
+		StepID:     "sealed",
+		LogDir:     t.TempDir(),
+		Env:        map[string]string{"TOKEN": sealed},
+	}
+
+	t.Setenv("TEMPORAL_SEAL_PRIVATE_KEY", "")
+	_, err := RunCommand(context.Background(), input)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != "SealedValueUnreadable" || !appErr.NonRetryable() {
+		t.Fatalf("without a key: err = %v", err)
+	}
+	if strings.Contains(err.Error(), sealed) {
+		t.Errorf("error leaks the sealed value: %v", err)
+	}
+
+	t.Setenv("TEMPORAL_SEAL_PRIVATE_KEY", private)
+	result, err := RunCommand(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(result.Stdout) != "s3cret" {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+}
@@ -30,18 +30,94 @@ type RunCommandInput struct {
 	RunID       string            `json:"runId"`
 	StepID      string            `json:"stepId"`
 	LogDir      string            `json:"logDir"`
+	Network     string            `json:"network"`
+	// Run lists shell commands run in order with `bash -c` in place of
+	// Command. They share Env, WorkingDir and the timeout; the first failure
+	// stops the list unless ContinueOnFailure is set.
+	Run               []string `json:"run,omitempty"`
+	ContinueOnFailure bool     `json:"continueOnFailure,omitempty"`
+	// RateLimits names worker rate limiters to wait on before starting.
+	RateLimits []string `json:"rateLimits,omitempty"`
+	// Truncate is how stdout and stderr are cut to TEMPORAL_LOG_MAX_BYTES
+	// for the result: head, tail or head_tail. Empty uses the worker's
+	// TEMPORAL_LOG_CAPTURE.
+	Truncate string `json:"truncate,omitempty"`
+	// Locale sets LANG and LC_ALL for the command and decodes its output.
+	Locale *Locale `json:"locale,omitempty"`
+	// VerifyInputs are downloaded files checked against their digests, and
+	// fetched again if they no longer match, before the command starts.
+	VerifyInputs []InputArtifact `json:"verifyInputs,omitempty"`
+	// KeepTmpOnFailure keeps the step's TMPDIR when the command fails, for
+	// debugging. It is removed otherwise.
+	KeepTmpOnFailure bool `json:"keepTmpOnFailure,omitempty"`
+	// Profile, if set, samples the command with a profiler and returns its
+	// hotspots in the result.
+	Profile *Profile `json:"profile,omitempty"`
+	// acquire, if set, claims a shared resource after the rate limits and
+	// returns extra environment for the command; release runs when it ends.
+	acquire func(ctx context.Context, log io.Writer) (env map[string]string, release func(), err error)
+	// tmpOutsideWorkDir puts the step's TMPDIR under the system temp dir
+	// rather than the working directory, when that directory is shipped
+	// somewhere as a whole, like a docker build context.
+	tmpOutsideWorkDir bool
+}
+
+// RunStatus is the outcome of one command of a run list.
+type RunStatus struct {
+	Command     string  `json:"command"`
+	ExitCode    int     `json:"exitCode"`
+	DurationSec float64 `json:"durationSec"`
+	DurationMs  int64   `json:"durationMs"`
+	Skipped     bool    `json:"skipped,omitempty"`
+}
+
+// Timing is when a step ran, as RFC 3339 UTC times with nanoseconds, and
+// for how long in milliseconds. Results and events carry it next to
+// DurationSec, whole seconds that are kept for older readers.
+type Timing struct {
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+func newTiming(start, end time.Time) Timing {
+	return Timing{
+		StartedAt:  start.UTC().Format(time.RFC3339Nano),
+		FinishedAt: end.UTC().Format(time.RFC3339Nano),
+		DurationMs: end.Sub(start).Milliseconds(),
+	}
+}
+
+// DurationSec is the duration in whole seconds.
+func (t Timing) DurationSec() int64 {
+	return t.DurationMs / 1000
 }
 
 type RunCommandResult struct {
-	ExitCode        int    `json:"exitCode"`
-	Stdout          string `json:"stdout"`
-	Stderr          string `json:"stderr"`
-	DurationSec     int64  `json:"durationSec"`
-	StdoutPath      string `json:"stdoutPath"`
-	StderrPath      string `json:"stderrPath"`
-	StructuredPath  string `json:"structuredPath"`
-	StdoutTruncated bool   `json:"stdoutTruncated"`
-	StderrTruncated bool   `json:"stderrTruncated"`
+	ExitCode        int               `json:"exitCode"`
+	Stdout          string            `json:"stdout"`
+	Stderr          string            `json:"stderr"`
+	DurationSec     int64             `json:"durationSec"`
+	StdoutPath      string            `json:"stdoutPath"`
+	StderrPath      string            `json:"stderrPath"`
+	StructuredPath  string            `json:"structuredPath"`
+	StdoutTruncated bool              `json:"stdoutTruncated"`
+	StderrTruncated bool              `json:"stderrTruncated"`
+	WorkerQueue     string            `json:"workerQueue"`
+	Outputs         map[string]string `json:"outputs,omitempty"`
+	Runs            []RunStatus       `json:"runs,omitempty"`
+	Metrics         []StepMetric      `json:"metrics,omitempty"`
+	// Attempts lists every attempt of the step, the last being this one.
+	Attempts []StepAttempt `json:"attempts,omitempty"`
+	// TmpDir is the step's TMPDIR, kept because the step failed with
+	// KeepTmpOnFailure set.
+	TmpDir string `json:"tmpDir,omitempty"`
+	// Profile is what the profiler found, when the input asked for one.
+	Profile *StepProfile `json:"profile,omitempty"`
+	// LogError reports output the step's log files could not store.
+	LogError string `json:"logError,omitempty"`
+	// Timing is when the command ran, to the millisecond.
+	Timing
 }
 
 type StepEvent struct {
@@ -57,6 +133,25 @@ type StepEvent struct {
 	StderrPath     string `json:"stderrPath"`
 	StructuredPath string `json:"structuredPath"`
 	Message        string `json:"message"`
+	// Progress is set on pipeline_progress events.
+	Progress *PipelineProgress `json:"progress,omitempty"`
+	// Timing has StartedAt on step_started events and is complete on
+	// step_finished events.
+	Timing
+}
+
+// PipelineProgress summarises how far a run has got. Done counts finished
+// steps, whether they succeeded, failed or were skipped. EtaSec estimates the
+// remaining time and is omitted when no step has a known duration.
+type PipelineProgress struct {
+	Done       int     `json:"done"`
+	Total      int     `json:"total"`
+	Running    int     `json:"running"`
+	Percent    float64 `json:"percent"`
+	ElapsedSec int64   `json:"elapsedSec"`
+	EtaSec     *int64  `json:"etaSec,omitempty"`
+	// Slow lists running steps that exceed their usual duration.
+	Slow []string `json:"slow,omitempty"`
 }
 
 type structuredLogLine struct {
@@ -68,30 +163,75 @@ type structuredLogLine struct {
 	Stream     string `json:"stream"`
 	Message    string `json:"message"`
 	Partial    bool   `json:"partial"`
+	// Run is the run list index of the command that wrote the line.
+	Run *int `json:"run,omitempty"`
+	// ExitCode and DurationSec are set on "status" lines, which close each
+	// command of a run list.
+	ExitCode    *int    `json:"exitCode,omitempty"`
+	DurationSec float64 `json:"durationSec,omitempty"`
+	DurationMs  int64   `json:"durationMs,omitempty"`
+	Skipped     bool    `json:"skipped,omitempty"`
 }
 
 type structuredLogSink struct {
-	file       *os.File
+	file       io.Writer
 	workflowID string
 	runID      string
 	stepID     string
 	stepName   string
-	mu         sync.Mutex
+	// tail, when set, keeps the last lines for heartbeat details.
+	tail *logTail
+	mu   sync.Mutex
+	run  *int
+	// encoding decodes messages into UTF-8; see Locale.OutputEncoding.
+	encoding string
+}
+
+// setRun tags the following lines with a run list index.
+func (s *structuredLogSink) setRun(index int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.run = &index
 }
 
 func (s *structuredLogSink) write(stream, message string, partial bool) {
-	if s == nil || s.file == nil {
+	if s != nil {
+		message = normalizeOutput(message, s.encoding)
+	}
+	s.writeLine(structuredLogLine{Stream: stream, Message: message, Partial: partial})
+}
+
+// writeStatus records the outcome of one run list command.
+func (s *structuredLogSink) writeStatus(status RunStatus) {
+	exitCode := status.ExitCode
+	s.writeLine(structuredLogLine{
+		Stream:      "status",
+		Message:     status.Command,
+		ExitCode:    &exitCode,
+		DurationSec: status.DurationSec,
+		DurationMs:  status.DurationMs,
+		Skipped:     status.Skipped,
+	})
+}
+
+func (s *structuredLogSink) writeLine(line structuredLogLine) {
+	if s == nil || (s.file == nil && s.tail == nil) {
 		return
 	}
-	line := structuredLogLine{
-		Timestamp:  time.Now().UTC().Format(time.RFC3339Nano),
-		WorkflowID: s.workflowID,
-		RunID:      s.runID,
-		StepID:     s.stepID,
-		StepName:   s.stepName,
-		Stream:     stream,
-		Message:    message,
-		Partial:    partial,
+	s.mu.Lock()
+	line.Run = s.run
+	s.mu.Unlock()
+	line.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
+	line.WorkflowID = s.workflowID
+	line.RunID = s.runID
+	line.StepID = s.stepID
+	line.StepName = s.stepName
+	s.tail.add(line)
+	if s.file == nil {
+		return
 	}
 	data, err := json.Marshal(line)
 	if err != nil {
@@ -102,6 +242,11 @@ func (s *structuredLogSink) write(stream, message string, partial bool) {
 	_, _ = s.file.Write(append(data, '\n'))
 }
 
+// maxLineBuffer bounds how much of an unfinished line is held. Output with
+// longer lines or no newlines at all, such as progress bars redrawn with \r
+// or binary data, is written out as partial lines of this size.
+const maxLineBuffer = 64 << 10
+
 type lineBufferWriter struct {
 	sink   *structuredLogSink
 	stream string
@@ -112,6 +257,16 @@ func (w *lineBufferWriter) Write(p []byte) (int, error) {
 	n := len(p)
 	for len(p) > 0 {
 		idx := bytes.IndexByte(p, '\n')
+		rest := p
+		if idx >= 0 {
+			rest = p[:idx]
+		}
+		if room := maxLineBuffer - w.buf.Len(); len(rest) > room {
+			_, _ = w.buf.Write(p[:room])
+			w.FlushPartial()
+			p = p[room:]
+			continue
+		}
 		if idx < 0 {
 			_, _ = w.buf.Write(p)
 			return n, nil
@@ -135,21 +290,51 @@ func (w *lineBufferWriter) FlushPartial() {
 }
 
 type logWriters struct {
-	logDir                  string
-	stdoutWriter            io.Writer
-	stderrWriter            io.Writer
-	stdoutPath              string
-	stderrPath              string
-	structuredPath          string
-	stdoutStructuredWriter  *lineBufferWriter
-	stderrStructuredWriter  *lineBufferWriter
-	closers                 []io.Closer
+	logDir                 string
+	stdoutWriter           io.Writer
+	stderrWriter           io.Writer
+	stdoutPath             string
+	stderrPath             string
+	structuredPath         string
+	stdoutStructuredWriter *lineBufferWriter
+	stderrStructuredWriter *lineBufferWriter
+	sink                   *structuredLogSink
+	tail                   *logTail
+	closers                []io.Closer
+	closeOnce              sync.Once
+	closeErr               error
 }
 
+// attachStructured routes stdout and stderr lines to sink once.
+func (lw *logWriters) attachStructured(sink *structuredLogSink) {
+	if lw.stdoutStructuredWriter != nil {
+		return
+	}
+	lw.stdoutStructuredWriter = &lineBufferWriter{sink: sink, stream: "stdout"}
+	lw.stderrStructuredWriter = &lineBufferWriter{sink: sink, stream: "stderr"}
+	lw.stdoutWriter = io.MultiWriter(lw.stdoutWriter, lw.stdoutStructuredWriter)
+	lw.stderrWriter = io.MultiWriter(lw.stderrWriter, lw.stderrStructuredWriter)
+}
+
+// Close closes the log files and waits for the step's events to be written,
+// so a finished step's events are in events.jsonl when its activity returns.
 func (lw *logWriters) Close() {
-	for _, c := range lw.closers {
-		c.Close()
+	lw.closeFiles()
+	waitEvents(lw.logDir)
+}
+
+// closeFiles closes the log files once and reports, as the step result's
+// LogError, output they could not store.
+func (lw *logWriters) closeFiles() string {
+	lw.closeOnce.Do(func() {
+		for _, c := range lw.closers {
+			lw.closeErr = errors.Join(lw.closeErr, c.Close())
+		}
+	})
+	if lw.closeErr == nil {
+		return ""
 	}
+	return lw.closeErr.Error()
 }
 
 func (lw *logWriters) FlushPartial() {
@@ -161,29 +346,39 @@ func (lw *logWriters) FlushPartial() {
 	}
 }
 
-func setupLogWriters(stdout, stderr *bytes.Buffer, logDirHint, workflowID, runID, stepID, name string) *logWriters {
+func setupLogWriters(stdout, stderr io.Writer, logDirHint, workflowID, runID, stepID, name string) *logWriters {
 	lw := &logWriters{
 		stdoutWriter: stdout,
 		stderrWriter: stderr,
 	}
 
-	logDir := strings.TrimSpace(logDirHint)
-	if logDir == "" {
-		logDir = os.Getenv("TEMPORAL_LOG_DIR")
+	sink := &structuredLogSink{
+		workflowID: workflowID,
+		runID:      runID,
+		stepID:     stepID,
+		stepName:   name,
 	}
-	if logDir == "" {
-		logDir = "./logs"
+	lw.sink = sink
+	key, keyErr := LogEncryptionKey()
+	if keyErr == nil && key == nil {
+		// The heartbeat tail is kept even without log files, but never when
+		// logs are encrypted at rest: heartbeats are stored by Temporal.
+		lw.tail = newLogTail()
+		sink.tail = lw.tail
 	}
-	if !filepath.IsAbs(logDir) {
-		if cwd, cwdErr := os.Getwd(); cwdErr == nil {
-			logDir = filepath.Join(cwd, logDir)
-		}
+	if lw.tail != nil {
+		lw.attachStructured(sink)
 	}
-	if err := os.MkdirAll(logDir, 0o755); err != nil {
-		logDir = "/tmp/temporal-logs"
-		_ = os.MkdirAll(logDir, 0o755)
+
+	lw.logDir = resolveLogDir(logDirHint)
+	if wanted := logLocation(logDirHint); lw.logDir != wanted {
+		fmt.Fprintf(stderr, "log directory %s unavailable, writing log files to %s\n", wanted, lw.logDir)
+	}
+	fs, err := openLogFS(lw.logDir)
+	if err != nil {
+		fmt.Fprintf(stderr, "log directory unavailable, log files disabled: %v\n", err)
+		return lw
 	}
-	lw.logDir = logDir
 
 	prefix := safeName(workflowID)
 	if runID != "" {
@@ -198,54 +393,76 @@ func setupLogWriters(stdout, stderr *bytes.Buffer, logDirHint, workflowID, runID
 		prefix = "step"
 	}
 
-	lw.stdoutPath = filepath.Join(logDir, prefix+"_stdout.log")
-	lw.stderrPath = filepath.Join(logDir, prefix+"_stderr.log")
+	if keyErr != nil {
+		// Never fall back to plaintext when encryption was requested.
+		fmt.Fprintf(stderr, "log encryption unavailable, log files disabled: %v\n", keyErr)
+		return lw
+	}
+	suffix := ""
+	if key != nil {
+		suffix = ".enc"
+	}
+
+	stdoutName := prefix + "_stdout.log" + suffix
+	stderrName := prefix + "_stderr.log" + suffix
+	lw.stdoutPath = fs.Path(stdoutName)
+	lw.stderrPath = fs.Path(stderrName)
 
-	if file, err := os.Create(lw.stdoutPath); err == nil {
-		lw.closers = append(lw.closers, file)
+	if file, closer, err := createLogFile(fs, stdoutName, key); err == nil {
+		lw.closers = append(lw.closers, closer)
 		lw.stdoutWriter = io.MultiWriter(lw.stdoutWriter, file)
 	} else {
-		stderr.WriteString(fmt.Sprintf("log write failed (stdout): %v\n", err))
+		fmt.Fprintf(stderr, "log write failed (stdout): %v\n", err)
 	}
-	if file, err := os.Create(lw.stderrPath); err == nil {
-		lw.closers = append(lw.closers, file)
+	if file, closer, err := createLogFile(fs, stderrName, key); err == nil {
+		lw.closers = append(lw.closers, closer)
 		lw.stderrWriter = io.MultiWriter(lw.stderrWriter, file)
 	} else {
-		stderr.WriteString(fmt.Sprintf("log write failed (stderr): %v\n", err))
-	}
-
-	structuredCandidate := filepath.Join(logDir, prefix+"_structured.jsonl")
-	if file, err := os.Create(structuredCandidate); err == nil {
-		lw.closers = append(lw.closers, file)
-		lw.structuredPath = structuredCandidate
-		sink := &structuredLogSink{
-			file:       file,
-			workflowID: workflowID,
-			runID:      runID,
-			stepID:     stepID,
-			stepName:   name,
-		}
-		lw.stdoutStructuredWriter = &lineBufferWriter{sink: sink, stream: "stdout"}
-		lw.stderrStructuredWriter = &lineBufferWriter{sink: sink, stream: "stderr"}
-		lw.stdoutWriter = io.MultiWriter(lw.stdoutWriter, lw.stdoutStructuredWriter)
-		lw.stderrWriter = io.MultiWriter(lw.stderrWriter, lw.stderrStructuredWriter)
+		fmt.Fprintf(stderr, "log write failed (stderr): %v\n", err)
+	}
+
+	structuredName := prefix + "_structured.jsonl" + suffix
+	if file, closer, err := createLogFile(fs, structuredName, key); err == nil {
+		lw.closers = append(lw.closers, closer)
+		lw.structuredPath = fs.Path(structuredName)
+		sink.file = file
+		lw.attachStructured(sink)
 	} else {
-		stderr.WriteString(fmt.Sprintf("log write failed (structured): %v\n", err))
+		fmt.Fprintf(stderr, "log write failed (structured): %v\n", err)
 	}
 
 	return lw
 }
 
+// createLogFile creates a log file, wrapping it in an encrypting writer when a
+// log key is configured. Closing an encrypted file writes its final frame.
+func createLogFile(fs LogFS, name string, key []byte) (io.Writer, io.Closer, error) {
+	file, err := fs.Create(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil {
+		return file, file, nil
+	}
+	writer, err := newEncryptingWriter(file, key)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return writer, writer, nil
+}
+
 type DownloadInput struct {
-	Name        string `json:"name"`
-	URL         string `json:"url"`
-	OutputPath  string `json:"outputPath"`
-	Sha256      string `json:"sha256"`
-	TimeoutSecs int    `json:"timeoutSeconds"`
-	WorkflowID  string `json:"workflowId"`
-	RunID       string `json:"runId"`
-	StepID      string `json:"stepId"`
-	LogDir      string `json:"logDir"`
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	OutputPath  string   `json:"outputPath"`
+	Sha256      string   `json:"sha256"`
+	TimeoutSecs int      `json:"timeoutSeconds"`
+	WorkflowID  string   `json:"workflowId"`
+	RunID       string   `json:"runId"`
+	StepID      string   `json:"stepId"`
+	LogDir      string   `json:"logDir"`
+	RateLimits  []string `json:"rateLimits,omitempty"`
 }
 
 type DownloadResult struct {
@@ -256,6 +473,13 @@ type DownloadResult struct {
 	StdoutPath     string `json:"stdoutPath"`
 	StderrPath     string `json:"stderrPath"`
 	StructuredPath string `json:"structuredPath"`
+	WorkerQueue    string `json:"workerQueue"`
+	// Sha256 is the digest of the downloaded file.
+	Sha256 string `json:"sha256,omitempty"`
+	// LogError reports output the step's log files could not store.
+	LogError string `json:"logError,omitempty"`
+	// Timing is when the download ran, to the millisecond.
+	Timing
 }
 
 type DockerBuildInput struct {
@@ -272,29 +496,49 @@ type DockerBuildInput struct {
 	Platform    string            `json:"platform"`
 	Target      string            `json:"target"`
 	TimeoutSecs int               `json:"timeoutSeconds"`
+	Network     string            `json:"network"`
+	RateLimits  []string          `json:"rateLimits,omitempty"`
+	Truncate    string            `json:"truncate,omitempty"`
+	Locale      *Locale           `json:"locale,omitempty"`
+	DockerHost  *DockerHost       `json:"dockerHost,omitempty"`
+	// BuilderPool builds on the worker's buildkit builder pool with docker
+	// buildx instead of the local daemon.
+	BuilderPool  bool            `json:"builderPool,omitempty"`
+	VerifyInputs []InputArtifact `json:"verifyInputs,omitempty"`
 }
 
 type DockerPushInput struct {
-	Name        string `json:"name"`
-	WorkflowID  string `json:"workflowId"`
-	RunID       string `json:"runId"`
-	StepID      string `json:"stepId"`
-	LogDir      string `json:"logDir"`
-	Image       string `json:"image"`
-	TimeoutSecs int    `json:"timeoutSeconds"`
+	Name        string      `json:"name"`
+	WorkflowID  string      `json:"workflowId"`
+	RunID       string      `json:"runId"`
+	StepID      string      `json:"stepId"`
+	LogDir      string      `json:"logDir"`
+	Image       string      `json:"image"`
+	TimeoutSecs int         `json:"timeoutSeconds"`
+	RateLimits  []string    `json:"rateLimits,omitempty"`
+	Truncate    string      `json:"truncate,omitempty"`
+	Locale      *Locale     `json:"locale,omitempty"`
+	DockerHost  *DockerHost `json:"dockerHost,omitempty"`
 }
 
 type PackageBuildInput struct {
-	Name        string            `json:"name"`
-	WorkflowID  string            `json:"workflowId"`
-	RunID       string            `json:"runId"`
-	StepID      string            `json:"stepId"`
-	LogDir      string            `json:"logDir"`
-	Command     string            `json:"command"`
-	Args        []string          `json:"args"`
-	Env         map[string]string `json:"env"`
-	WorkingDir  string            `json:"workingDir"`
-	TimeoutSecs int               `json:"timeoutSeconds"`
+	Name         string            `json:"name"`
+	WorkflowID   string            `json:"workflowId"`
+	RunID        string            `json:"runId"`
+	StepID       string            `json:"stepId"`
+	LogDir       string            `json:"logDir"`
+	Command      string            `json:"command"`
+	Args         []string          `json:"args"`
+	Env          map[string]string `json:"env"`
+	WorkingDir   string            `json:"workingDir"`
+	TimeoutSecs  int               `json:"timeoutSeconds"`
+	Network      string            `json:"network"`
+	RateLimits   []string          `json:"rateLimits,omitempty"`
+	Truncate     string            `json:"truncate,omitempty"`
+	Locale       *Locale           `json:"locale,omitempty"`
+	VerifyInputs []InputArtifact   `json:"verifyInputs,omitempty"`
+	// KeepTmpOnFailure is RunCommandInput.KeepTmpOnFailure.
+	KeepTmpOnFailure bool `json:"keepTmpOnFailure,omitempty"`
 }
 
 type ContainerJobInput struct {
@@ -310,33 +554,62 @@ type ContainerJobInput struct {
 	GPU          bool              `json:"gpu"`
 	TimeoutSecs  int               `json:"timeoutSeconds"`
 	LauncherPath string            `json:"launcherPath"`
+	Network      string            `json:"network"`
+	RateLimits   []string          `json:"rateLimits,omitempty"`
+	Truncate     string            `json:"truncate,omitempty"`
+	Locale       *Locale           `json:"locale,omitempty"`
+	VerifyInputs []InputArtifact   `json:"verifyInputs,omitempty"`
+	// KeepTmpOnFailure is RunCommandInput.KeepTmpOnFailure.
+	KeepTmpOnFailure bool `json:"keepTmpOnFailure,omitempty"`
 }
 
 type HFDownloadDatasetInput struct {
-	Name        string `json:"name"`
-	WorkflowID  string `json:"workflowId"`
-	RunID       string `json:"runId"`
-	StepID      string `json:"stepId"`
-	LogDir      string `json:"logDir"`
-	DatasetID   string `json:"datasetId"`
-	Config      string `json:"config"`
-	Split       string `json:"split"`
-	CacheDir    string `json:"cacheDir"`
-	TimeoutSecs int    `json:"timeoutSeconds"`
+	Name        string   `json:"name"`
+	WorkflowID  string   `json:"workflowId"`
+	RunID       string   `json:"runId"`
+	StepID      string   `json:"stepId"`
+	LogDir      string   `json:"logDir"`
+	DatasetID   string   `json:"datasetId"`
+	Config      string   `json:"config"`
+	Split       string   `json:"split"`
+	CacheDir    string   `json:"cacheDir"`
+	TimeoutSecs int      `json:"timeoutSeconds"`
+	RateLimits  []string `json:"rateLimits,omitempty"`
+	Truncate    string   `json:"truncate,omitempty"`
+	Locale      *Locale  `json:"locale,omitempty"`
+	// Revision pins a branch, tag or commit; AllowPatterns select files by
+	// glob instead of Config and Split. MaxWorkers defaults to 8.
+	Revision      string   `json:"revision,omitempty"`
+	AllowPatterns []string `json:"allowPatterns,omitempty"`
+	MaxWorkers    int      `json:"maxWorkers,omitempty"`
 }
 
 type HFDownloadModelInput struct {
-	Name        string `json:"name"`
-	WorkflowID  string `json:"workflowId"`
-	RunID       string `json:"runId"`
-	StepID      string `json:"stepId"`
-	LogDir      string `json:"logDir"`
-	ModelID     string `json:"modelId"`
-	CacheDir    string `json:"cacheDir"`
-	TimeoutSecs int    `json:"timeoutSeconds"`
+	Name        string   `json:"name"`
+	WorkflowID  string   `json:"workflowId"`
+	RunID       string   `json:"runId"`
+	StepID      string   `json:"stepId"`
+	LogDir      string   `json:"logDir"`
+	ModelID     string   `json:"modelId"`
+	CacheDir    string   `json:"cacheDir"`
+	TimeoutSecs int      `json:"timeoutSeconds"`
+	RateLimits  []string `json:"rateLimits,omitempty"`
+	Truncate    string   `json:"truncate,omitempty"`
+	Locale      *Locale  `json:"locale,omitempty"`
 }
 
 func RunCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, error) {
+	if len(input.Run) > 0 {
+		if strings.TrimSpace(input.Command) != "" {
+			return RunCommandResult{ExitCode: -1}, errors.New("command and run are mutually exclusive")
+		}
+		for i, line := range input.Run {
+			if strings.TrimSpace(line) == "" {
+				return RunCommandResult{ExitCode: -1}, fmt.Errorf("run entry %d is empty", i)
+			}
+		}
+		return runCommand(ctx, input)
+	}
 	if strings.TrimSpace(input.Command) == "" {
 		return RunCommandResult{ExitCode: -1}, errors.New("command is required")
 	}
@@ -359,11 +632,15 @@ func DownloadFile(ctx context.Context, input DownloadInput) (DownloadResult, err
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	lw := setupLogWriters(&stdout, &stderr, input.LogDir, input.WorkflowID, input.RunID, input.StepID, input.Name)
+	stdout := newStepOutputBuffer("")
+	stderr := newStepOutputBuffer("")
+	lw := setupLogWriters(stdout, stderr, input.LogDir, input.WorkflowID, input.RunID, input.StepID, input.Name)
 	defer lw.Close()
 
+	if err := waitRateLimits(ctx, input.RateLimits, lw.stderrWriter); err != nil {
+		return DownloadResult{ExitCode: -1}, err
+	}
+	start := time.Now()
 	emitEvent(lw.logDir, StepEvent{
 		Timestamp:      time.Now().UTC().Format(time.RFC3339Nano),
 		WorkflowID:     input.WorkflowID,
@@ -371,49 +648,21 @@ func DownloadFile(ctx context.Context, input DownloadInput) (DownloadResult, err
 		StepID:         input.StepID,
 		StepName:       input.Name,
 		Status:         "step_started",
+		Timing:         Timing{StartedAt: start.UTC().Format(time.RFC3339Nano)},
 		StructuredPath: lw.structuredPath,
 	})
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, input.URL, nil)
-	if err != nil {
-		return DownloadResult{ExitCode: -1}, err
-	}
-
-	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return DownloadResult{ExitCode: -1}, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return DownloadResult{ExitCode: -1}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
-	}
-
-	if err := os.MkdirAll(filepath.Dir(input.OutputPath), 0o755); err != nil {
-		return DownloadResult{ExitCode: -1}, err
-	}
-
-	file, err := os.Create(input.OutputPath)
+	actual, err := fetchFile(ctx, input.URL, input.OutputPath)
 	if err != nil {
 		return DownloadResult{ExitCode: -1}, err
 	}
-	defer file.Close()
-
-	hash := sha256.New()
-	writer := io.MultiWriter(file, hash)
-	if _, err := io.Copy(writer, resp.Body); err != nil {
-		return DownloadResult{ExitCode: -1}, err
-	}
-
 	if input.Sha256 != "" {
-		actual := hex.EncodeToString(hash.Sum(nil))
 		if !strings.EqualFold(actual, input.Sha256) {
 			return DownloadResult{ExitCode: -1}, fmt.Errorf("sha256 mismatch: expected %s got %s", input.Sha256, actual)
 		}
 	}
 
-	duration := time.Since(start).Seconds()
+	timing := newTiming(start, time.Now())
 	_, _ = fmt.Fprintf(lw.stdoutWriter, "downloaded %s\n", input.OutputPath)
 	lw.FlushPartial()
 	emitEvent(lw.logDir, StepEvent{
@@ -424,7 +673,8 @@ func DownloadFile(ctx context.Context, input DownloadInput) (DownloadResult, err
 		StepName:       input.Name,
 		Status:         "step_finished",
 		ExitCode:       0,
-		DurationSec:    int64(duration),
+		DurationSec:    timing.DurationSec(),
+		Timing:         timing,
 		StdoutPath:     lw.stdoutPath,
 		StderrPath:     lw.stderrPath,
 		StructuredPath: lw.structuredPath,
@@ -433,13 +683,52 @@ func DownloadFile(ctx context.Context, input DownloadInput) (DownloadResult, err
 		ExitCode:       0,
 		Stdout:         stdout.String(),
 		Stderr:         stderr.String(),
-		DurationSec:    int64(duration),
+		DurationSec:    timing.DurationSec(),
+		Timing:         timing,
 		StdoutPath:     lw.stdoutPath,
 		StderrPath:     lw.stderrPath,
 		StructuredPath: lw.structuredPath,
+		WorkerQueue:    workerQueue(),
+		Sha256:         actual,
+		LogError:       lw.closeFiles(),
 	}, nil
 }
 
+// fetchFile downloads url to outputPath and returns the sha256 of its
+// contents.
+func fetchFile(ctx context.Context, url, outputPath string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0o755); err != nil {
+		return "", err
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	writer := io.MultiWriter(file, hash)
+	if _, err := io.Copy(writer, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func DockerBuild(ctx context.Context, input DockerBuildInput) (RunCommandResult, error) {
 	if strings.TrimSpace(input.Image) == "" {
 		return RunCommandResult{ExitCode: -1}, errors.New("image is required")
@@ -450,6 +739,10 @@ func DockerBuild(ctx context.Context, input DockerBuildInput) (RunCommandResult,
 	}
 
 	args := []string{"build", "-t", input.Image}
+	if input.BuilderPool {
+		// --load keeps the image in the local daemon for docker_push.
+		args = []string{"buildx", "build", "--load", "-t", input.Image}
+	}
 	if input.Dockerfile != "" {
 		args = append(args, "-f", input.Dockerfile)
 	}
@@ -465,19 +758,43 @@ func DockerBuild(ctx context.Context, input DockerBuildInput) (RunCommandResult,
 	if input.Target != "" {
 		args = append(args, "--target", input.Target)
 	}
+	switch input.Network {
+	case "", "host":
+	case "none":
+		args = append(args, "--network", "none")
+	default:
+		return RunCommandResult{ExitCode: -1}, fmt.Errorf("docker_build does not support network %q", input.Network)
+	}
 	args = append(args, contextDir)
 
-	return runCommand(ctx, RunCommandInput{
-		Name:        input.Name,
-		WorkflowID:  input.WorkflowID,
-		RunID:       input.RunID,
-		StepID:      input.StepID,
-		LogDir:      input.LogDir,
-		Command:     "docker",
-		Args:        args,
-		WorkingDir:  ".",
-		TimeoutSecs: input.TimeoutSecs,
-	})
+	env, cleanup, err := dockerHostEnv(input.DockerHost)
+	if err != nil {
+		return RunCommandResult{ExitCode: -1}, err
+	}
+	defer cleanup()
+	command := RunCommandInput{
+		Name:         input.Name,
+		WorkflowID:   input.WorkflowID,
+		RunID:        input.RunID,
+		StepID:       input.StepID,
+		LogDir:       input.LogDir,
+		Command:      "docker",
+		Args:         args,
+		Env:          env,
+		WorkingDir:   ".",
+		TimeoutSecs:  input.TimeoutSecs,
+		RateLimits:   input.RateLimits,
+		Truncate:     input.Truncate,
+		Locale:       input.Locale,
+		VerifyInputs: input.VerifyInputs,
+		// The build context is sent to the daemon and hashed into the layer
+		// cache, so temp files stay out of it.
+		tmpOutsideWorkDir: true,
+	}
+	if input.BuilderPool {
+		command.acquire = acquirePoolBuilder
+	}
+	return runCommand(ctx, command)
 }
 
 func DockerPush(ctx context.Context, input DockerPushInput) (RunCommandResult, error) {
@@ -485,6 +802,11 @@ func DockerPush(ctx context.Context, input DockerPushInput) (RunCommandResult, e
 		return RunCommandResult{ExitCode: -1}, errors.New("image is required")
 	}
 
+	env, cleanup, err := dockerHostEnv(input.DockerHost)
+	if err != nil {
+		return RunCommandResult{ExitCode: -1}, err
+	}
+	defer cleanup()
 	return runCommand(ctx, RunCommandInput{
 		Name:        input.Name,
 		WorkflowID:  input.WorkflowID,
@@ -493,7 +815,11 @@ func DockerPush(ctx context.Context, input DockerPushInput) (RunCommandResult, e
 		LogDir:      input.LogDir,
 		Command:     "docker",
 		Args:        []string{"push", input.Image},
+		Env:         env,
 		TimeoutSecs: input.TimeoutSecs,
+		RateLimits:  input.RateLimits,
+		Truncate:    input.Truncate,
+		Locale:      input.Locale,
 	})
 }
 
@@ -503,16 +829,22 @@ func PackageBuild(ctx context.Context, input PackageBuildInput) (RunCommandResul
 	}
 
 	return runCommand(ctx, RunCommandInput{
-		Name:        input.Name,
-		WorkflowID:  input.WorkflowID,
-		RunID:       input.RunID,
-		StepID:      input.StepID,
-		LogDir:      input.LogDir,
-		Command:     input.Command,
-		Args:        input.Args,
-		Env:         input.Env,
-		WorkingDir:  input.WorkingDir,
-		TimeoutSecs: input.TimeoutSecs,
+		Name:             input.Name,
+		WorkflowID:       input.WorkflowID,
+		RunID:            input.RunID,
+		StepID:           input.StepID,
+		LogDir:           input.LogDir,
+		Command:          input.Command,
+		Args:             input.Args,
+		Env:              input.Env,
+		WorkingDir:       input.WorkingDir,
+		TimeoutSecs:      input.TimeoutSecs,
+		Network:          input.Network,
+		RateLimits:       input.RateLimits,
+		Truncate:         input.Truncate,
+		Locale:           input.Locale,
+		VerifyInputs:     input.VerifyInputs,
+		KeepTmpOnFailure: input.KeepTmpOnFailure,
 	})
 }
 
@@ -543,55 +875,58 @@ func ContainerJob(ctx context.Context, input ContainerJobInput) (RunCommandResul
 	if !input.GPU {
 		env["SYGALDRY_GPU"] = "false"
 	}
+	if input.Network != "" {
+		// Enforced by the launcher, which refuses to start on an unknown mode.
+		env["SYGALDRY_NETWORK"] = input.Network
+	}
 
 	return runCommand(ctx, RunCommandInput{
-		Name:        input.Name,
-		WorkflowID:  input.WorkflowID,
-		RunID:       input.RunID,
-		StepID:      input.StepID,
-		LogDir:      input.LogDir,
-		Command:     launcherPath,
-		Args:        args,
-		Env:         env,
-		TimeoutSecs: input.TimeoutSecs,
+		Name:         input.Name,
+		WorkflowID:   input.WorkflowID,
+		RunID:        input.RunID,
+		StepID:       input.StepID,
+		LogDir:       input.LogDir,
+		Command:      launcherPath,
+		Args:         args,
+		Env:          env,
+		TimeoutSecs:  input.TimeoutSecs,
+		RateLimits:   input.RateLimits,
+		Truncate:     input.Truncate,
+		Locale:       input.Locale,
+		VerifyInputs: input.VerifyInputs,
+		// The launcher's TMPDIR, on the worker.
+		KeepTmpOnFailure: input.KeepTmpOnFailure,
 	})
 }
 
+// HFDownloadDataset downloads a dataset's files into the HF cache in
+// parallel; see hfDatasetScript. The result's outputs describe the download.
 func HFDownloadDataset(ctx context.Context, input HFDownloadDatasetInput) (RunCommandResult, error) {
 	if strings.TrimSpace(input.DatasetID) == "" {
 		return RunCommandResult{ExitCode: -1}, errors.New("datasetId is required")
 	}
 
-	config := input.Config
-	if config == "" {
-		config = "default"
-	}
-	split := input.Split
-	if split == "" {
-		split = "train[:100]"
-	}
 	cacheDir := input.CacheDir
 	if cacheDir == "" {
 		cacheDir = "/opt/hf_cache"
 	}
-
-	script := `
-import os
-cache_dir = os.environ['_HF_CACHE_DIR']
-dataset_id = os.environ['_HF_DATASET_ID']
-config = os.environ['_HF_CONFIG']
-split = os.environ['_HF_SPLIT']
-os.environ['HF_HOME'] = cache_dir
-from datasets import load_dataset
-ds = load_dataset(dataset_id, config, split=split, cache_dir=cache_dir)
-print(f'Downloaded {len(ds)} rows from {dataset_id}')
-`
+	maxWorkers := input.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = defaultHFMaxWorkers
+	}
+	patterns, err := json.Marshal(input.AllowPatterns)
+	if err != nil {
+		return RunCommandResult{ExitCode: -1}, err
+	}
 
 	env := map[string]string{
-		"_HF_CACHE_DIR":  cacheDir,
-		"_HF_DATASET_ID": input.DatasetID,
-		"_HF_CONFIG":     config,
-		"_HF_SPLIT":      split,
+		"_HF_CACHE_DIR":      cacheDir,
+		"_HF_DATASET_ID":     input.DatasetID,
+		"_HF_CONFIG":         input.Config,
+		"_HF_SPLIT":          input.Split,
+		"_HF_REVISION":       input.Revision,
+		"_HF_ALLOW_PATTERNS": string(patterns),
+		"_HF_MAX_WORKERS":    strconv.Itoa(maxWorkers),
 	}
 
 	return runCommand(ctx, RunCommandInput{
@@ -600,10 +935,14 @@ print(f'Downloaded {len(ds)} rows from {dataset_id}')
 		RunID:       input.RunID,
 		StepID:      input.StepID,
 		LogDir:      input.LogDir,
-		Command:     "python3",
-		Args:        []string{"-c", script},
+		Command:     "env",
+		Args:        []string{"python3", "-c", hfDatasetScript},
 		Env:         env,
 		TimeoutSecs: input.TimeoutSecs,
+		RateLimits:  input.RateLimits,
+		Truncate:    input.Truncate,
+		Locale:      input.Locale,
+		acquire:     hfPython,
 	})
 }
 
@@ -638,10 +977,14 @@ print(f'Downloaded {model_id} to {path}')
 		RunID:       input.RunID,
 		StepID:      input.StepID,
 		LogDir:      input.LogDir,
-		Command:     "python3",
-		Args:        []string{"-c", script},
+		Command:     "env",
+		Args:        []string{"python3", "-c", script},
 		Env:         env,
 		TimeoutSecs: input.TimeoutSecs,
+		RateLimits:  input.RateLimits,
+		Truncate:    input.Truncate,
+		Locale:      input.Locale,
+		acquire:     hfPython,
 	})
 }
 
@@ -652,26 +995,96 @@ func runCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 	}
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
+	attempts := startAttempt(ctx)
 
-	cmd := exec.CommandContext(ctx, input.Command, input.Args...)
-	if input.WorkingDir != "" {
-		cmd.Dir = input.WorkingDir
+	invocations := [][]string{append([]string{input.Command}, input.Args...)}
+	if len(input.Run) > 0 {
+		invocations = invocations[:0]
+		for _, line := range input.Run {
+			invocations = append(invocations, []string{"bash", "-c", line})
+		}
 	}
-	if len(input.Env) > 0 {
-		env := os.Environ()
-		for key, value := range input.Env {
-			env = append(env, key+"="+value)
+	for i, argv := range invocations {
+		command, args, err := isolateNetwork(argv[0], argv[1:], input.Network)
+		if err != nil {
+			return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, err)
 		}
-		cmd.Env = env
+		invocations[i] = append([]string{command}, args...)
 	}
-
-	var stdout bytes.Buffer
-	var stderr bytes.Buffer
-	lw := setupLogWriters(&stdout, &stderr, input.LogDir, input.WorkflowID, input.RunID, input.StepID, input.Name)
+	stepEnv, sealErr := openSealedEnv(input.Env)
+	if sealErr != nil {
+		return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, sealErr)
+	}
+	// The step's own env overrides the plan's locale.
+	env := append(os.Environ(), input.Locale.env()...)
+	for key, value := range stepEnv {
+		env = append(env, key+"="+value)
+	}
+	stdout := newStepOutputBuffer(input.Truncate)
+	stderr := newStepOutputBuffer(input.Truncate)
+	lw := setupLogWriters(stdout, stderr, input.LogDir, input.WorkflowID, input.RunID, input.StepID, input.Name)
 	defer lw.Close()
-
-	cmd.Stdout = lw.stdoutWriter
-	cmd.Stderr = lw.stderrWriter
+	lw.sink.encoding = input.Locale.encoding()
+
+	stopHeartbeat := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		heartbeatTail(ctx, input.StepID, lw.tail, attempts, stopHeartbeat)
+	}()
+	if err := waitRateLimits(ctx, input.RateLimits, lw.stderrWriter); err != nil {
+		close(stopHeartbeat)
+		<-heartbeatDone
+		return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, err)
+	}
+	if input.acquire != nil {
+		extra, release, err := input.acquire(ctx, lw.stderrWriter)
+		if err != nil {
+			close(stopHeartbeat)
+			<-heartbeatDone
+			return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, err)
+		}
+		defer release()
+		for key, value := range extra {
+			env = append(env, key+"="+value)
+		}
+	}
+	err := verifyInputs(ctx, input.VerifyInputs, lw.stderrWriter, func(artifact InputArtifact, problem string) {
+		emitEvent(lw.logDir, StepEvent{
+			WorkflowID: input.WorkflowID,
+			RunID:      input.RunID,
+			StepID:     input.StepID,
+			StepName:   input.Name,
+			Status:     "input_refetched",
+			Message:    fmt.Sprintf("%s failed verification (%s) and was fetched again from %s", artifact.Path, problem, artifact.URL),
+		})
+	})
+	if err != nil {
+		close(stopHeartbeat)
+		<-heartbeatDone
+		return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, err)
+	}
+	tmpBase := input.WorkingDir
+	if input.tmpOutsideWorkDir {
+		tmpBase = os.TempDir()
+	}
+	tmpDir, err := createStepTmpDir(tmpBase, input.WorkflowID, input.RunID, input.StepID, lw.stderrWriter)
+	if err != nil {
+		close(stopHeartbeat)
+		<-heartbeatDone
+		return RunCommandResult{ExitCode: -1}, attempts.fail(ctx, input.StepID, fmt.Errorf("create TMPDIR: %w", err))
+	}
+	env = append(env, stepTmpEnv(tmpDir, stepEnv)...)
+	// Created once nothing can return early, as reading them back removes them.
+	outputsPath, outputsErr := createOutputsFile()
+	if outputsErr == nil {
+		env = append(env, "SYGALDRY_OUTPUTS="+outputsPath)
+	}
+	metricsPath, metricsErr := createMetricsFile()
+	if metricsErr == nil {
+		env = append(env, "SYGALDRY_METRICS="+metricsPath)
+	}
+	profiler := newStepProfiler(input.Profile, tmpDir, lw.stderrWriter)
 
 	start := time.Now()
 	emitEvent(lw.logDir, StepEvent{
@@ -681,34 +1094,72 @@ func runCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 		StepID:         input.StepID,
 		StepName:       input.Name,
 		Status:         "step_started",
+		Timing:         Timing{StartedAt: start.UTC().Format(time.RFC3339Nano)},
 		StructuredPath: lw.structuredPath,
-		Message:        input.Command,
+		Message:        commandMessage(input),
 	})
-	err := cmd.Run()
-	duration := time.Since(start).Seconds()
-
-	lw.FlushPartial()
-
-	result := RunCommandResult{
-		ExitCode:       exitCode(err),
-		Stdout:         stdout.String(),
-		Stderr:         stderr.String(),
-		DurationSec:    int64(duration),
-		StdoutPath:     lw.stdoutPath,
-		StderrPath:     lw.stderrPath,
-		StructuredPath: lw.structuredPath,
-	}
-
-	maxBytes := int64(10_000)
-	if value := os.Getenv("TEMPORAL_LOG_MAX_BYTES"); value != "" {
-		if parsed, parseErr := strconv.ParseInt(value, 10, 64); parseErr == nil && parsed > 0 {
-			maxBytes = parsed
+	var runs []RunStatus
+	for i, argv := range invocations {
+		if len(input.Run) > 0 {
+			lw.sink.setRun(i)
+			if err != nil && (!input.ContinueOnFailure || ctx.Err() != nil || exitCode(err) < 0) {
+				status := RunStatus{Command: input.Run[i], ExitCode: -1, Skipped: true}
+				lw.sink.writeStatus(status)
+				runs = append(runs, status)
+				continue
+			}
 		}
+		if profiler != nil {
+			argv = profiler.wrap(argv, i)
+		}
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = input.WorkingDir
+		cmd.Env = env
+		cmd.Stdout = lw.stdoutWriter
+		cmd.Stderr = lw.stderrWriter
+		runStart := time.Now()
+		runErr := cmd.Run()
+		lw.FlushPartial()
+		if profiler != nil {
+			profiler.collect(ctx, i, lw.stderrWriter)
+		}
+		if len(input.Run) > 0 {
+			elapsed := time.Since(runStart)
+			status := RunStatus{Command: input.Run[i], ExitCode: exitCode(runErr), DurationSec: elapsed.Seconds(), DurationMs: elapsed.Milliseconds()}
+			lw.sink.writeStatus(status)
+			runs = append(runs, status)
+		}
+		if err == nil {
+			err = runErr
+		}
+	}
+	timing := newTiming(start, time.Now())
+	var profile *StepProfile
+	if profiler != nil {
+		profile = profiler.save(input.LogDir, input.WorkflowID, input.RunID, input.StepID, lw.stderrWriter)
 	}
+	keptTmpDir := removeStepTmpDir(tmpDir, input.KeepTmpOnFailure && err != nil, lw.stderrWriter)
+	close(stopHeartbeat)
+	<-heartbeatDone
 
-	if maxBytes > 0 {
-		result.Stdout, result.StdoutTruncated = truncate(result.Stdout, maxBytes)
-		result.Stderr, result.StderrTruncated = truncate(result.Stderr, maxBytes)
+	result := RunCommandResult{
+		ExitCode:        exitCode(err),
+		Stdout:          normalizeOutput(stdout.String(), input.Locale.encoding()),
+		Stderr:          normalizeOutput(stderr.String(), input.Locale.encoding()),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		DurationSec:     timing.DurationSec(),
+		Timing:          timing,
+		StdoutPath:      lw.stdoutPath,
+		StderrPath:      lw.stderrPath,
+		StructuredPath:  lw.structuredPath,
+		WorkerQueue:     workerQueue(),
+		Outputs:         readOutputs(outputsPath),
+		Runs:            runs,
+		Metrics:         readMetrics(metricsPath),
+		TmpDir:          keptTmpDir,
+		Profile:         profile,
+		LogError:        lw.closeFiles(),
 	}
 
 	emitEvent(lw.logDir, StepEvent{
@@ -720,6 +1171,7 @@ func runCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 		Status:         "step_finished",
 		ExitCode:       result.ExitCode,
 		DurationSec:    result.DurationSec,
+		Timing:         result.Timing,
 		StdoutPath:     result.StdoutPath,
 		StderrPath:     result.StderrPath,
 		StructuredPath: result.StructuredPath,
@@ -727,19 +1179,43 @@ func runCommand(ctx context.Context, input RunCommandInput) (RunCommandResult, e
 
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(ctx.Err(), context.Canceled) {
-			return result, err
+			return result, attempts.fail(ctx, input.StepID, err)
 		}
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			// Non-zero exit code: return result without error so the workflow can decide.
+			result.Attempts = attempts.finish(result.ExitCode, nil)
 			return result, nil
 		}
-		return result, err
+		return result, attempts.fail(ctx, input.StepID, err)
 	}
 
+	result.Attempts = attempts.finish(0, nil)
 	return result, nil
 }
 
+// isolateNetwork wraps a host command so it runs without network access when
+// network is "none", using an unprivileged user+network namespace. There is no
+// fallback: if unshare is unavailable the step fails instead of running online.
+func isolateNetwork(command string, args []string, network string) (string, []string, error) {
+	switch network {
+	case "", "host":
+		return command, args, nil
+	case "none":
+		return "unshare", append([]string{"--net", "--map-root-user", "--", command}, args...), nil
+	default:
+		return "", nil, fmt.Errorf("network %q is only supported for container_job steps", network)
+	}
+}
+
+// commandMessage is the step_started message: the command or the run list.
+func commandMessage(input RunCommandInput) string {
+	if len(input.Run) > 0 {
+		return strings.Join(input.Run, " && ")
+	}
+	return input.Command
+}
+
 func exitCode(err error) int {
 	if err == nil {
 		return 0
@@ -751,11 +1227,10 @@ func exitCode(err error) int {
 	return -1
 }
 
-func truncate(value string, maxBytes int64) (string, bool) {
-	if int64(len(value)) <= maxBytes {
-		return value, false
-	}
-	return value[:maxBytes], true
+// LogFilePrefix returns the file name prefix shared by every log file of one
+// workflow run.
+func LogFilePrefix(workflowID, runID string) string {
+	return safeName(workflowID) + "_" + safeName(runID) + "_"
 }
 
 func safeName(value string) string {
@@ -766,29 +1241,15 @@ func safeName(value string) string {
 	return value
 }
 
-func emitEvent(logDir string, event StepEvent) {
-	if logDir == "" {
-		return
-	}
-	if !filepath.IsAbs(logDir) {
-		if cwd, err := os.Getwd(); err == nil {
-			logDir = filepath.Join(cwd, logDir)
-		}
-	}
-	_ = os.MkdirAll(logDir, 0o755)
-	path := filepath.Join(logDir, "events.jsonl")
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
-	if err != nil {
-		return
-	}
-	defer file.Close()
+type RecordEventInput struct {
+	LogDir string    `json:"logDir"`
+	Event  StepEvent `json:"event"`
+}
 
-	if event.Timestamp == "" {
-		event.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
-	}
-	data, err := json.Marshal(event)
-	if err != nil {
-		return
-	}
-	_, _ = file.Write(append(data, '\n'))
+// RecordEvent appends an event raised by the workflow itself (rather than by a
+// step activity) to events.jsonl. It is meant to run as a local activity.
+func RecordEvent(ctx context.Context, input RecordEventInput) error {
+	emitEvent(input.LogDir, input.Event)
+	waitEvents(input.LogDir)
+	return nil
 }
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // ---------------------------------------------------------------------------
@@ -20,7 +21,7 @@ func TestExitCode(t *testing.T) {
 	}
 }
 
-func TestTruncate(t *testing.T) {
+func TestOutputBufferHead(t *testing.T) {
 	tests := []struct {
 		value     string
 		maxBytes  int64
@@ -34,9 +35,10 @@ func TestTruncate(t *testing.T) {
 		{"abcdefghij", 0, "", true},
 	}
 	for _, tt := range tests {
-		got, trunc := truncate(tt.value, tt.maxBytes)
-		if got != tt.want || trunc != tt.truncated {
-			t.Errorf("truncate(%q, %d) = (%q, %v), want (%q, %v)",
+		buffer := newOutputBuffer(tt.maxBytes, "head")
+		buffer.WriteString(tt.value)
+		if got, trunc := buffer.String(), buffer.Truncated(); got != tt.want || trunc != tt.truncated {
+			t.Errorf("head of %q, %d = (%q, %v), want (%q, %v)",
 				tt.value, tt.maxBytes, got, trunc, tt.want, tt.truncated)
 		}
 	}
@@ -62,6 +64,27 @@ func TestSafeName(t *testing.T) {
 	}
 }
 
+func TestIsolateNetwork(t *testing.T) {
+	cmd, args, err := isolateNetwork("curl", []string{"example.com"}, "none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "unshare" || strings.Join(args, " ") != "--net --map-root-user -- curl example.com" {
+		t.Errorf("isolateNetwork(none) = %s %v", cmd, args)
+	}
+
+	for _, network := range []string{"", "host"} {
+		cmd, args, err := isolateNetwork("curl", []string{"example.com"}, network)
+		if err != nil || cmd != "curl" || len(args) != 1 {
+			t.Errorf("isolateNetwork(%q) = %s %v, %v", network, cmd, args, err)
+		}
+	}
+
+	if _, _, err := isolateNetwork("curl", nil, "proxy-only"); err == nil {
+		t.Error("expected proxy-only to be rejected for host commands")
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Unit tests: logWriters
 // ---------------------------------------------------------------------------
@@ -125,6 +148,15 @@ func TestSetupLogWritersPrefix(t *testing.T) {
 }
 
 func TestSetupLogWritersFallback(t *testing.T) {
+	// The default ./logs is relative to the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
 	var stdout, stderr bytes.Buffer
 	lw := setupLogWriters(&stdout, &stderr, "", "wf", "", "", "")
 	defer lw.Close()
@@ -267,6 +299,45 @@ func TestLineBufferWriterCarriageReturn(t *testing.T) {
 	}
 }
 
+func TestLineBufferWriterSplitsLongLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "structured.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	sink := &structuredLogSink{file: file, workflowID: "wf"}
+	w := &lineBufferWriter{sink: sink, stream: "stdout"}
+	progress := strings.Repeat("downloading... 42%\r", 10000)
+	for i := 0; i < 4; i++ {
+		w.Write([]byte(progress))
+		if w.buf.Len() > maxLineBuffer {
+			t.Fatalf("buffer holds %d bytes of a line without a newline", w.buf.Len())
+		}
+	}
+	w.Write([]byte("done\n"))
+	file.Close()
+
+	data, _ := os.ReadFile(path)
+	var messages strings.Builder
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i, line := range lines {
+		var entry structuredLogLine
+		json.Unmarshal([]byte(line), &entry)
+		if len(entry.Message) > maxLineBuffer || entry.Partial != (i < len(lines)-1) {
+			t.Errorf("line %d: %d bytes, partial %v", i, len(entry.Message), entry.Partial)
+		}
+		messages.WriteString(entry.Message)
+	}
+	// Each partial line may lose the \r it ends with.
+	want := len(progress)*4 + len("done")
+	if got := messages.Len(); len(lines) < 2 || got < want-len(lines) || !strings.HasSuffix(messages.String(), "done") {
+		t.Errorf("got %d lines with %d bytes, want %d bytes", len(lines), got, want)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Unit tests: emitEvent
 // ---------------------------------------------------------------------------
@@ -286,6 +357,7 @@ func TestEmitEvent(t *testing.T) {
 		Status:     "step_finished",
 		ExitCode:   0,
 	})
+	waitEvents(dir)
 
 	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
 	if err != nil {
@@ -349,6 +421,13 @@ func TestDockerBuildValidation(t *testing.T) {
 	}
 }
 
+func TestDockerBuildNetworkValidation(t *testing.T) {
+	_, err := DockerBuild(context.Background(), DockerBuildInput{Image: "img", Network: "proxy-only"})
+	if err == nil || !strings.Contains(err.Error(), "does not support network") {
+		t.Errorf("expected unsupported network error, got: %v", err)
+	}
+}
+
 func TestDockerPushValidation(t *testing.T) {
 	_, err := DockerPush(context.Background(), DockerPushInput{Image: ""})
 	if err == nil {
@@ -434,6 +513,111 @@ func TestRunCommandEcho(t *testing.T) {
 	}
 }
 
+func TestRunCommandTiming(t *testing.T) {
+	dir := t.TempDir()
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Command:    "sleep",
+		Args:       []string{"0.2"},
+		WorkflowID: "test-wf",
+		RunID:      "test-run",
+		StepID:     "sleep-step",
+		LogDir:     dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sub-second steps report 0 whole seconds but their milliseconds.
+	if result.DurationSec != 0 || result.DurationMs < 200 || result.DurationMs > 5000 {
+		t.Errorf("durationSec = %d, durationMs = %d, want 0 and about 200", result.DurationSec, result.DurationMs)
+	}
+	started, err1 := time.Parse(time.RFC3339Nano, result.StartedAt)
+	finished, err2 := time.Parse(time.RFC3339Nano, result.FinishedAt)
+	if err1 != nil || err2 != nil || finished.Sub(started).Milliseconds() != result.DurationMs {
+		t.Errorf("startedAt = %q, finishedAt = %q, durationMs = %d", result.StartedAt, result.FinishedAt, result.DurationMs)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var event StepEvent
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Status != "step_finished" || event.Timing != result.Timing {
+		t.Errorf("step_finished event timing = %+v, want %+v", event.Timing, result.Timing)
+	}
+}
+
+func TestRunCommandTmpDir(t *testing.T) {
+	workDir := t.TempDir()
+	run := func(script string, keep bool) RunCommandResult {
+		t.Helper()
+		result, err := RunCommand(context.Background(), RunCommandInput{
+			Command:          "bash",
+			Args:             []string{"-c", script},
+			WorkflowID:       "wf",
+			RunID:            "run",
+			StepID:           "tmp-step",
+			LogDir:           t.TempDir(),
+			WorkingDir:       workDir,
+			KeepTmpOnFailure: keep,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	result := run(`echo "$TMPDIR"; touch "$TMPDIR/scratch"`, true)
+	tmpDir := strings.TrimSpace(result.Stdout)
+	if filepath.Dir(tmpDir) != filepath.Join(workDir, ".sygaldry-tmp") || !strings.HasPrefix(filepath.Base(tmpDir), "wf_run_tmp-step-") {
+		t.Errorf("TMPDIR = %s, want a step dir under %s/.sygaldry-tmp", tmpDir, workDir)
+	}
+	if _, err := os.Stat(tmpDir); !os.IsNotExist(err) || result.TmpDir != "" {
+		t.Errorf("TMPDIR of a successful step was kept: %v %q", err, result.TmpDir)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".sygaldry-tmp")); !os.IsNotExist(err) {
+		t.Errorf("empty .sygaldry-tmp was left in the working directory: %v", err)
+	}
+
+	result = run(`touch "$TMPDIR/scratch"; exit 3`, true)
+	if _, err := os.Stat(filepath.Join(result.TmpDir, "scratch")); err != nil || result.ExitCode != 3 {
+		t.Errorf("TMPDIR of the failed step was not kept: %v (exit %d)", err, result.ExitCode)
+	}
+
+	root := t.TempDir()
+	t.Setenv("TEMPORAL_STEP_TMP_ROOT", root)
+	result = run(`echo "$TMPDIR"; exit 1`, false)
+	if tmpDir := strings.TrimSpace(result.Stdout); filepath.Dir(tmpDir) != root || result.TmpDir != "" {
+		t.Errorf("TMPDIR = %s (kept %q), want a removed dir under %s", tmpDir, result.TmpDir, root)
+	}
+}
+
+func TestRunCommandTmpDirOutsideWorkDir(t *testing.T) {
+	workDir, systemTmp := t.TempDir(), t.TempDir()
+	t.Setenv("TMPDIR", systemTmp)
+	t.Setenv("TEMPORAL_STEP_TMP_ROOT", "")
+	result, err := runCommand(context.Background(), RunCommandInput{
+		Command:           "bash",
+		Args:              []string{"-c", `echo "$TMPDIR"`},
+		StepID:            "build",
+		LogDir:            t.TempDir(),
+		WorkingDir:        workDir,
+		tmpOutsideWorkDir: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpDir := strings.TrimSpace(result.Stdout); filepath.Dir(tmpDir) != filepath.Join(systemTmp, ".sygaldry-tmp") {
+		t.Errorf("TMPDIR = %s, want a dir under %s/.sygaldry-tmp", tmpDir, systemTmp)
+	}
+	if entries, _ := os.ReadDir(workDir); len(entries) > 0 {
+		t.Errorf("working directory has %v", entries)
+	}
+}
+
 func TestRunCommandNonZeroExit(t *testing.T) {
 	dir := t.TempDir()
 	result, err := RunCommand(context.Background(), RunCommandInput{
@@ -630,3 +814,165 @@ func TestStructuredLogContent(t *testing.T) {
 		t.Errorf("expected at least 1 stderr line, got %d", stderrCount)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Unit tests: artifact leases
+// ---------------------------------------------------------------------------
+
+// setWorkerQueue sets this worker's queue for the duration of the test.
+func setWorkerQueue(t *testing.T, queue string) {
+	t.Helper()
+	SetWorkerQueue(queue)
+	t.Cleanup(func() { SetWorkerQueue("") })
+}
+
+func TestAcquireArtifactLeases(t *testing.T) {
+	dir := t.TempDir()
+	artifact := filepath.Join(dir, "shard-0.bin")
+	if err := os.WriteFile(artifact, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setWorkerQueue(t, "orchestration-host1")
+	t.Setenv("TEMPORAL_RESULTS_DIR", filepath.Join(dir, "results"))
+
+	result, err := AcquireArtifactLeases(context.Background(), ArtifactLeaseInput{
+		WorkflowID: "wf", RunID: "run", StepID: "shards", Paths: []string{artifact},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WorkerQueue != "orchestration-host1" || len(result.Leases) != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Leases[0].SizeBytes != 4 || result.Leases[0].Status != "acquired" {
+		t.Errorf("unexpected lease: %+v", result.Leases[0])
+	}
+
+	if err := ReleaseArtifactLeases(context.Background(), ReleaseLeasesInput{Leases: result.Leases}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "results", "leases.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lease lines, got %d", len(lines))
+	}
+	var released ArtifactLease
+	json.Unmarshal([]byte(lines[1]), &released)
+	if released.Status != "released" || released.Path != artifact {
+		t.Errorf("unexpected release record: %+v", released)
+	}
+}
+
+func TestAcquireArtifactLeasesErrors(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEMPORAL_RESULTS_DIR", dir)
+
+	setWorkerQueue(t, "")
+	if _, err := AcquireArtifactLeases(context.Background(), ArtifactLeaseInput{Paths: []string{dir}}); err == nil {
+		t.Error("expected error without worker queue")
+	}
+
+	setWorkerQueue(t, "q")
+	if _, err := AcquireArtifactLeases(context.Background(), ArtifactLeaseInput{Paths: []string{filepath.Join(dir, "missing")}}); err == nil {
+		t.Error("expected error for missing artifact")
+	}
+}
+
+func TestRunCommandRecordsWorkerQueue(t *testing.T) {
+	setWorkerQueue(t, "orchestration-host1")
+	result, err := RunCommand(context.Background(), RunCommandInput{Command: "true", LogDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.WorkerQueue != "orchestration-host1" {
+		t.Errorf("workerQueue = %q", result.WorkerQueue)
+	}
+}
+
+func TestRunCommandRunList(t *testing.T) {
+	dir := t.TempDir()
+	work := t.TempDir()
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Run:        []string{"echo first > marker", "cat marker; echo $GREETING", "exit 3", "echo never"},
+		Env:        map[string]string{"GREETING": "hi"},
+		WorkingDir: work,
+		WorkflowID: "wf-run",
+		RunID:      "run-1",
+		StepID:     "seq",
+		LogDir:     dir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 3 {
+		t.Errorf("exit code = %d, want 3", result.ExitCode)
+	}
+	if !strings.Contains(result.Stdout, "first\nhi\n") || strings.Contains(result.Stdout, "never") {
+		t.Errorf("stdout = %q", result.Stdout)
+	}
+	wantExit := []int{0, 0, 3, -1}
+	if len(result.Runs) != 4 {
+		t.Fatalf("runs = %+v", result.Runs)
+	}
+	for i, run := range result.Runs {
+		if run.ExitCode != wantExit[i] || run.Skipped != (i == 3) {
+			t.Errorf("run %d = %+v, want exit %d", i, run, wantExit[i])
+		}
+	}
+
+	data, err := os.ReadFile(result.StructuredPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []structuredLogLine
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry structuredLogLine
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("invalid JSONL line: %v", err)
+		}
+		if entry.Run == nil {
+			t.Errorf("line without run index: %s", line)
+			continue
+		}
+		if entry.Stream == "status" {
+			statuses = append(statuses, entry)
+		} else if entry.Message == "hi" && *entry.Run != 1 {
+			t.Errorf("output of command 1 tagged run %d", *entry.Run)
+		}
+	}
+	if len(statuses) != 4 || *statuses[2].ExitCode != 3 || !statuses[3].Skipped || statuses[3].Message != "echo never" {
+		t.Errorf("status lines = %+v", statuses)
+	}
+}
+
+func TestRunCommandRunListContinueOnFailure(t *testing.T) {
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		Run:               []string{"exit 2", "echo after"},
+		ContinueOnFailure: true,
+		StepID:            "seq",
+		LogDir:            t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitCode != 2 {
+		t.Errorf("exit code = %d, want the first failure's 2", result.ExitCode)
+	}
+	if !strings.Contains(result.Stdout, "after") || len(result.Runs) != 2 || result.Runs[1].ExitCode != 0 {
+		t.Errorf("second command did not run: %+v %q", result.Runs, result.Stdout)
+	}
+}
+
+func TestRunCommandRunListValidation(t *testing.T) {
+	for _, input := range []RunCommandInput{
+		{Command: "echo", Run: []string{"echo"}},
+		{Run: []string{"echo", " "}},
+	} {
+		if _, err := RunCommand(context.Background(), input); err == nil {
+			t.Errorf("RunCommand(%+v) succeeded, want a validation error", input)
+		}
+	}
+}
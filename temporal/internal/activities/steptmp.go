@@ -0,0 +1,87 @@
+package activities
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stepTmpDirName is the directory under a step's working directory that
+// holds the private temp dirs of its steps.
+const stepTmpDirName = ".sygaldry-tmp"
+
+// createStepTmpDir creates a private temp dir for one attempt of a step:
+// under TEMPORAL_STEP_TMP_ROOT if set, otherwise under .sygaldry-tmp in base,
+// the step's working directory by default. If that cannot be created, e.g. on
+// a read-only checkout, it falls back to the system temp dir and says so in
+// log.
+func createStepTmpDir(base, workflowID, runID, stepID string, log io.Writer) (string, error) {
+	pattern := LogFilePrefix(workflowID, runID) + safeName(stepID) + "-*"
+	root := strings.TrimSpace(os.Getenv("TEMPORAL_STEP_TMP_ROOT"))
+	createRoot := func() error { return os.MkdirAll(root, 0o755) }
+	if root == "" {
+		if base == "" {
+			base = "."
+		}
+		// Only .sygaldry-tmp itself is created: a missing working directory
+		// is the command's error to report.
+		root = filepath.Join(base, stepTmpDirName)
+		createRoot = func() error {
+			if err := os.Mkdir(root, 0o755); !errors.Is(err, os.ErrExist) {
+				return err
+			}
+			return nil
+		}
+	}
+	// A step that ends meanwhile removes .sygaldry-tmp once it is empty, so
+	// it is created again if it went away before the step's dir was made.
+	for attempt := 0; attempt < 2; attempt++ {
+		if createRoot() != nil {
+			break
+		}
+		dir, err := os.MkdirTemp(root, pattern)
+		if err == nil {
+			return filepath.Abs(dir)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			break
+		}
+	}
+	dir, err := os.MkdirTemp("", "sygaldry-"+pattern)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(log, "unable to create a temp dir under %s; using %s\n", root, dir)
+	return dir, nil
+}
+
+// stepTmpEnv points the usual temp variables at dir, unless the step sets
+// TMPDIR itself.
+func stepTmpEnv(dir string, stepEnv map[string]string) []string {
+	if _, ok := stepEnv["TMPDIR"]; ok {
+		return nil
+	}
+	return []string{"TMPDIR=" + dir, "TMP=" + dir, "TEMP=" + dir}
+}
+
+// removeStepTmpDir deletes a step's temp dir, and the .sygaldry-tmp holding
+// it once no other step uses it, or keeps it when keep is set and returns its
+// path.
+func removeStepTmpDir(dir string, keep bool, log io.Writer) string {
+	if keep {
+		fmt.Fprintf(log, "kept TMPDIR %s of the failed step\n", dir)
+		return dir
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(log, "unable to remove TMPDIR %s: %v\n", dir, err)
+		return ""
+	}
+	if parent := filepath.Dir(dir); filepath.Base(parent) == stepTmpDirName {
+		// Fails, as it should, while it holds other steps' dirs.
+		os.Remove(parent)
+	}
+	return ""
+}
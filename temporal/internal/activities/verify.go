@@ -0,0 +1,91 @@
+package activities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// InputArtifact is a file a step reads that an earlier step downloaded:
+// where it is, the digest it had then and where it came from.
+type InputArtifact struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+	URL    string `json:"url"`
+}
+
+// verifyInputs checks the digest of each input on this worker right before
+// the step uses it. A missing or modified file, e.g. a corrupted cache on a
+// long-lived worker, is fetched again from its URL and must then match.
+func verifyInputs(ctx context.Context, inputs []InputArtifact, log io.Writer, refetched func(InputArtifact, string)) error {
+	for _, input := range inputs {
+		actual, err := fileSha256(input.Path)
+		if err == nil && strings.EqualFold(actual, input.Sha256) {
+			fmt.Fprintf(log, "verified %s (sha256 %s)\n", input.Path, input.Sha256)
+			continue
+		}
+		problem := fmt.Sprintf("sha256 mismatch: expected %s got %s", input.Sha256, actual)
+		if err != nil {
+			problem = err.Error()
+		}
+		if input.URL == "" {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("input %s failed verification (%s) and has no URL to fetch it from", input.Path, problem), "InputVerificationFailed", nil)
+		}
+		fmt.Fprintf(log, "input %s failed verification (%s); fetching %s again\n", input.Path, problem, input.URL)
+		if err := refetch(ctx, input); err != nil {
+			return temporal.NewApplicationError(fmt.Sprintf("input %s: %v", input.Path, err), "InputVerificationFailed")
+		}
+		fmt.Fprintf(log, "fetched %s again (sha256 %s)\n", input.Path, input.Sha256)
+		if refetched != nil {
+			refetched(input, problem)
+		}
+	}
+	return nil
+}
+
+// refetch downloads input next to its path and moves it into place once it
+// matches the expected digest, so a bad fetch leaves no partial file behind.
+func refetch(ctx context.Context, input InputArtifact) error {
+	if err := os.MkdirAll(filepath.Dir(input.Path), 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(input.Path), "."+filepath.Base(input.Path)+".refetch-*")
+	if err != nil {
+		return err
+	}
+	temp.Close()
+	defer os.Remove(temp.Name())
+	actual, err := fetchFile(ctx, input.URL, temp.Name())
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, input.Sha256) {
+		return fmt.Errorf("fetched again but sha256 is %s, expected %s", actual, input.Sha256)
+	}
+	return os.Rename(temp.Name(), input.Path)
+}
+
+func fileSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("file is missing")
+		}
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,108 @@
+package activities
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestVerifyInputs(t *testing.T) {
+	const content = "model weights\n"
+	sum := sha256.Sum256([]byte(content))
+	digest := hex.EncodeToString(sum[:])
+	var fetches atomic.Int32
+	serve := content
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte(serve))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cache", "weights.bin")
+	input := InputArtifact{Path: path, Sha256: digest, URL: server.URL}
+	var log strings.Builder
+	var refetched []string
+	note := func(artifact InputArtifact, problem string) { refetched = append(refetched, problem) }
+
+	// Missing, then intact, then corrupted.
+	if err := verifyInputs(context.Background(), []InputArtifact{input}, &log, note); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyInputs(context.Background(), []InputArtifact{input}, &log, note); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("bit rot\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyInputs(context.Background(), []InputArtifact{input}, &log, note); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("file holds %q after verification", data)
+	}
+	if fetches.Load() != 2 || len(refetched) != 2 || refetched[0] != "file is missing" || !strings.Contains(refetched[1], "sha256 mismatch") {
+		t.Errorf("fetched %d times, refetched %q\n%s", fetches.Load(), refetched, log.String())
+	}
+
+	// The source changed too: the step must not run on the bad copy, and the
+	// cached file is left as it was.
+	serve = "something else\n"
+	os.WriteFile(path, []byte("bit rot\n"), 0o644)
+	err := verifyInputs(context.Background(), []InputArtifact{input}, &log, nil)
+	if err == nil || !strings.Contains(err.Error(), "fetched again but sha256 is") {
+		t.Errorf("err = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "bit rot\n" {
+		t.Errorf("bad fetch replaced the file: %q", data)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*refetch*")); len(matches) > 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+
+	input.URL = ""
+	err = verifyInputs(context.Background(), []InputArtifact{input}, &log, nil)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() {
+		t.Errorf("err = %v, want a non-retryable error without a URL", err)
+	}
+}
+
+func TestRunCommandVerifiesInputs(t *testing.T) {
+	const content = "rows\n"
+	sum := sha256.Sum256([]byte(content))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+	path := filepath.Join(t.TempDir(), "data.csv")
+	os.WriteFile(path, []byte("corrupt"), 0o644)
+
+	logDir := t.TempDir()
+	result, err := RunCommand(context.Background(), RunCommandInput{
+		StepID:       "train",
+		LogDir:       logDir,
+		Command:      "cat",
+		Args:         []string{path},
+		VerifyInputs: []InputArtifact{{Path: path, Sha256: hex.EncodeToString(sum[:]), URL: server.URL}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != content {
+		t.Errorf("step read %q, want the fetched file", result.Stdout)
+	}
+	events, _ := os.ReadFile(filepath.Join(logDir, "events.jsonl"))
+	if !strings.Contains(string(events), `"status":"input_refetched"`) {
+		t.Errorf("no input_refetched event in:\n%s", events)
+	}
+}
@@ -0,0 +1,191 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+)
+
+type WatchPathInput struct {
+	Name       string `json:"name"`
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	StepID     string `json:"stepId"`
+	LogDir     string `json:"logDir"`
+	// Path is a file path or filepath.Match glob.
+	Path string `json:"path"`
+	// Condition is "exists" (default) or "changed".
+	Condition   string `json:"condition"`
+	PollSecs    int    `json:"pollSeconds"`
+	TimeoutSecs int    `json:"timeoutSeconds"`
+}
+
+// fileStamp identifies one version of a watched file.
+type fileStamp struct {
+	ModTime int64 `json:"modTime"`
+	Size    int64 `json:"size"`
+}
+
+// WatchPath polls until Path exists or, with the "changed" condition, until a
+// matching file appears or is modified after the watch started. The baseline
+// is kept in heartbeat details so a retried attempt does not miss a change.
+// On timeout the step finishes with exit code 1 so when: failure branches run.
+func WatchPath(ctx context.Context, input WatchPathInput) (RunCommandResult, error) {
+	if input.Path == "" {
+		return RunCommandResult{ExitCode: -1}, errors.New("path is required")
+	}
+	if _, err := filepath.Match(input.Path, ""); err != nil {
+		return RunCommandResult{ExitCode: -1}, fmt.Errorf("invalid path pattern: %w", err)
+	}
+	condition := input.Condition
+	if condition == "" {
+		condition = "exists"
+	}
+	if condition != "exists" && condition != "changed" {
+		return RunCommandResult{ExitCode: -1}, fmt.Errorf("unknown watch condition %q", condition)
+	}
+	poll := 10 * time.Second
+	if input.PollSecs > 0 {
+		poll = time.Duration(input.PollSecs) * time.Second
+	}
+	timeout := 2 * time.Hour
+	if input.TimeoutSecs > 0 {
+		timeout = time.Duration(input.TimeoutSecs) * time.Second
+	}
+
+	stdout := newStepOutputBuffer("")
+	stderr := newStepOutputBuffer("")
+	lw := setupLogWriters(stdout, stderr, input.LogDir, input.WorkflowID, input.RunID, input.StepID, input.Name)
+	defer lw.Close()
+	start := time.Now()
+	emitEvent(lw.logDir, StepEvent{
+		Timestamp:      time.Now().UTC().Format(time.RFC3339Nano),
+		WorkflowID:     input.WorkflowID,
+		RunID:          input.RunID,
+		StepID:         input.StepID,
+		StepName:       input.Name,
+		Status:         "step_started",
+		Timing:         Timing{StartedAt: start.UTC().Format(time.RFC3339Nano)},
+		StructuredPath: lw.structuredPath,
+	})
+
+	var baseline map[string]fileStamp
+	if activity.HasHeartbeatDetails(ctx) {
+		if err := activity.GetHeartbeatDetails(ctx, &baseline); err != nil {
+			baseline = nil
+		}
+	}
+	if baseline == nil {
+		current, err := statMatches(input.Path)
+		if err != nil {
+			return RunCommandResult{ExitCode: -1}, err
+		}
+		baseline = current
+	}
+	fmt.Fprintf(lw.stdoutWriter, "watching %s (%s, poll %s, timeout %s)\n", input.Path, condition, poll, timeout)
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	exitCode := 1
+	var matched []string
+	for {
+		current, err := statMatches(input.Path)
+		if err != nil {
+			return RunCommandResult{ExitCode: -1}, err
+		}
+		matched = watchSatisfied(condition, baseline, current)
+		if len(matched) > 0 {
+			exitCode = 0
+			break
+		}
+		activity.RecordHeartbeat(ctx, baseline)
+		select {
+		case <-ctx.Done():
+			return RunCommandResult{ExitCode: -1}, ctx.Err()
+		case <-deadline.C:
+			fmt.Fprintf(lw.stderrWriter, "timed out after %s waiting for %s\n", timeout, input.Path)
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	for _, path := range matched {
+		fmt.Fprintln(lw.stdoutWriter, path)
+	}
+	lw.FlushPartial()
+	timing := newTiming(start, time.Now())
+	emitEvent(lw.logDir, StepEvent{
+		Timestamp:      time.Now().UTC().Format(time.RFC3339Nano),
+		WorkflowID:     input.WorkflowID,
+		RunID:          input.RunID,
+		StepID:         input.StepID,
+		StepName:       input.Name,
+		Status:         "step_finished",
+		ExitCode:       exitCode,
+		DurationSec:    timing.DurationSec(),
+		Timing:         timing,
+		StdoutPath:     lw.stdoutPath,
+		StderrPath:     lw.stderrPath,
+		StructuredPath: lw.structuredPath,
+	})
+	result := RunCommandResult{
+		ExitCode:       exitCode,
+		Stdout:         stdout.String(),
+		Stderr:         stderr.String(),
+		DurationSec:    timing.DurationSec(),
+		Timing:         timing,
+		StdoutPath:     lw.stdoutPath,
+		StderrPath:     lw.stderrPath,
+		StructuredPath: lw.structuredPath,
+		WorkerQueue:    workerQueue(),
+		LogError:       lw.closeFiles(),
+	}
+	if len(matched) > 0 {
+		result.Outputs = map[string]string{"path": matched[0], "count": strconv.Itoa(len(matched))}
+	}
+	return result, nil
+}
+
+// statMatches returns the regular files currently matching pattern.
+func statMatches(pattern string) (map[string]fileStamp, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	stamps := make(map[string]fileStamp, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		stamps[path] = fileStamp{ModTime: info.ModTime().UnixNano(), Size: info.Size()}
+	}
+	return stamps, nil
+}
+
+// watchSatisfied returns the sorted paths that satisfy condition.
+func watchSatisfied(condition string, baseline, current map[string]fileStamp) []string {
+	var matched []string
+	for path, stamp := range current {
+		if condition == "exists" {
+			matched = append(matched, path)
+			continue
+		}
+		if before, ok := baseline[path]; !ok || before != stamp {
+			matched = append(matched, path)
+		}
+	}
+	sort.Strings(matched)
+	return matched
+}
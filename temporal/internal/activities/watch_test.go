@@ -0,0 +1,79 @@
+package activities
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestWatchSatisfied(t *testing.T) {
+	baseline := map[string]fileStamp{"a": {ModTime: 1, Size: 10}, "b": {ModTime: 1, Size: 10}}
+	current := map[string]fileStamp{"a": {ModTime: 1, Size: 10}, "b": {ModTime: 2, Size: 10}, "c": {ModTime: 1, Size: 1}}
+
+	if got := watchSatisfied("exists", baseline, current); len(got) != 3 {
+		t.Errorf("exists matched %v, want all files", got)
+	}
+	got := watchSatisfied("changed", baseline, current)
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("changed matched %v, want [b c]", got)
+	}
+	if got := watchSatisfied("changed", baseline, baseline); len(got) != 0 {
+		t.Errorf("unchanged files matched %v", got)
+	}
+}
+
+func TestWatchPathFileAppears(t *testing.T) {
+	dir := t.TempDir()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		os.WriteFile(filepath.Join(dir, "ready.flag"), []byte("ok"), 0o644)
+	}()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(WatchPath)
+	value, err := env.ExecuteActivity(WatchPath, WatchPathInput{
+		StepID:      "wait",
+		LogDir:      t.TempDir(),
+		Path:        filepath.Join(dir, "*.flag"),
+		PollSecs:    1,
+		TimeoutSecs: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result RunCommandResult
+	value.Get(&result)
+	if result.ExitCode != 0 || result.Outputs["path"] != filepath.Join(dir, "ready.flag") || result.Outputs["count"] != "1" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestWatchPathTimeout(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "data.csv")
+	os.WriteFile(existing, []byte("a"), 0o644)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(WatchPath)
+	value, err := env.ExecuteActivity(WatchPath, WatchPathInput{
+		StepID:      "wait",
+		LogDir:      t.TempDir(),
+		Path:        existing,
+		Condition:   "changed",
+		PollSecs:    1,
+		TimeoutSecs: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result RunCommandResult
+	value.Get(&result)
+	if result.ExitCode != 1 {
+		t.Errorf("an unchanged file should time out with exit code 1, got %+v", result)
+	}
+}
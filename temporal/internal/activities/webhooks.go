@@ -0,0 +1,115 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// WebhookSignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" when
+// the webhook has a secret.
+const WebhookSignatureHeader = "X-Sygaldry-Signature"
+
+type WebhookInput struct {
+	URL        string   `json:"url"`
+	SecretEnv  string   `json:"secretEnv"`
+	Fields     []string `json:"fields"`
+	WorkflowID string   `json:"workflowId"`
+	RunID      string   `json:"runId"`
+	// Result is the JSON-encoded pipeline result.
+	Result json.RawMessage `json:"result"`
+}
+
+// WebhookPayload is the body POSTed to a result webhook.
+type WebhookPayload struct {
+	WorkflowID string          `json:"workflowId"`
+	RunID      string          `json:"runId"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// PostWebhook delivers a pipeline result to one webhook. The HMAC secret is
+// read from the worker environment variable named by SecretEnv so it never
+// appears in workflow history. Connection errors, 5xx, 408 and 429 responses
+// are returned as retryable errors; other failures are not retried.
+func PostWebhook(ctx context.Context, input WebhookInput) error {
+	result, err := selectFields(input.Result, input.Fields)
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("select webhook fields: %v", err), "WebhookPayload", err)
+	}
+	body, err := json.Marshal(WebhookPayload{WorkflowID: input.WorkflowID, RunID: input.RunID, Result: result})
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("encode webhook payload: %v", err), "WebhookPayload", err)
+	}
+
+	var secret string
+	if input.SecretEnv != "" {
+		secret = os.Getenv(input.SecretEnv)
+		if secret == "" {
+			return temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("webhook secret env %s is not set on the worker", input.SecretEnv), "WebhookSecretMissing", nil)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, input.URL, bytes.NewReader(body))
+	if err != nil {
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("webhook request: %v", err), "WebhookRequest", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sygaldry-Delivery", input.WorkflowID+"/"+input.RunID)
+	if secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhook([]byte(secret), body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook %s: %w", input.URL, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	message := fmt.Sprintf("webhook %s returned %d", input.URL, resp.StatusCode)
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s", message)
+	}
+	return temporal.NewNonRetryableApplicationError(message, "WebhookRejected", nil)
+}
+
+// SignWebhook returns the signature header value for body.
+func SignWebhook(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// selectFields keeps only the named top-level fields of a JSON object. All
+// fields are kept when fields is empty.
+func selectFields(raw json.RawMessage, fields []string) (json.RawMessage, error) {
+	if len(fields) == 0 {
+		return raw, nil
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return nil, err
+	}
+	subset := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if value, ok := all[field]; ok {
+			subset[field] = value
+		}
+	}
+	return json.Marshal(subset)
+}
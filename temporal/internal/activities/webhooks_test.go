@@ -0,0 +1,81 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+func TestPostWebhookSignsPayload(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		signature = r.Header.Get(WebhookSignatureHeader)
+	}))
+	defer server.Close()
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+
+	err := PostWebhook(context.Background(), WebhookInput{
+		URL:        server.URL,
+		SecretEnv:  "TEST_WEBHOOK_SECRET",
+		Fields:     []string{"status"},
+		WorkflowID: "wf",
+		RunID:      "run",
+		Result:     json.RawMessage(`{"succeeded":true,"status":"succeeded","steps":[{"id":"a"}]}`),
+	})
+	if err != nil {
+		t.Fatalf("PostWebhook: %v", err)
+	}
+	if signature != SignWebhook([]byte("s3cret"), body) {
+		t.Errorf("signature %q does not match body", signature)
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.WorkflowID != "wf" || payload.RunID != "run" || string(payload.Result) != `{"status":"succeeded"}` {
+		t.Errorf("unexpected payload: %s", body)
+	}
+}
+
+func TestPostWebhookRetryability(t *testing.T) {
+	tests := []struct {
+		status    int
+		retryable bool
+	}{
+		{http.StatusInternalServerError, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusBadRequest, false},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		err := PostWebhook(context.Background(), WebhookInput{URL: server.URL, Result: json.RawMessage(`{}`)})
+		server.Close()
+		if err == nil {
+			t.Fatalf("status %d: expected error", tt.status)
+		}
+		var appErr *temporal.ApplicationError
+		nonRetryable := errors.As(err, &appErr) && appErr.NonRetryable()
+		if nonRetryable == tt.retryable {
+			t.Errorf("status %d: retryable = %v, want %v", tt.status, !nonRetryable, tt.retryable)
+		}
+	}
+}
+
+func TestPostWebhookMissingSecret(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "")
+	err := PostWebhook(context.Background(), WebhookInput{URL: "http://127.0.0.1:1", SecretEnv: "TEST_WEBHOOK_SECRET", Result: json.RawMessage(`{}`)})
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != "WebhookSecretMissing" {
+		t.Errorf("expected WebhookSecretMissing, got %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+// Package chaos injects faults into the activities a worker runs, so retry
+// policies, allow_failure and reruns can be exercised deterministically in
+// CI and against a local dev server. It is for testing only: the worker
+// enables it from TEMPORAL_CHAOS and logs a warning when it does.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Actions of a Rule.
+const (
+	// ActionFail fails the attempt with a retryable error before the
+	// activity runs.
+	ActionFail = "fail"
+	// ActionDelay holds the activity back before it runs.
+	ActionDelay = "delay"
+	// ActionKill kills the worker while the activity runs.
+	ActionKill = "kill"
+)
+
+// ErrorType is the application error type of injected failures.
+const ErrorType = "ChaosInjected"
+
+// Rule is one injected fault. Target is a step ID, which pipelines use as
+// the activity ID of the step, or an activity type such as RunCommand.
+type Rule struct {
+	Action string
+	Target string
+	// Attempt limits the rule to one attempt of the activity; 0 matches
+	// every attempt.
+	Attempt int32
+	// After is how long delay holds the activity back, and how long kill
+	// lets it run before killing the worker; kill without After kills the
+	// worker as the attempt starts.
+	After time.Duration
+}
+
+// String formats the rule as Parse reads it.
+func (r Rule) String() string {
+	s := r.Action + ":" + r.Target
+	if r.Attempt > 0 {
+		s += "@" + strconv.Itoa(int(r.Attempt))
+	}
+	if r.After > 0 {
+		s += "=" + r.After.String()
+	}
+	return s
+}
+
+func (r Rule) matches(info activity.Info) bool {
+	if r.Target != info.ActivityID && r.Target != info.ActivityType.Name {
+		return false
+	}
+	return r.Attempt == 0 || r.Attempt == info.Attempt
+}
+
+// Parse reads rules written as action:target[@attempt][=duration] and
+// separated by commas, e.g. "fail:train@1,delay:download=30s,kill:prep@2=10s".
+func Parse(spec string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		action, rest, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("%q: want action:target[@attempt][=duration]", field)
+		}
+		rule := Rule{Action: action}
+		rest, after, timed := strings.Cut(rest, "=")
+		rule.Target, _, _ = strings.Cut(rest, "@")
+		if _, attempt, ok := strings.Cut(rest, "@"); ok {
+			n, err := strconv.ParseInt(attempt, 10, 32)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%q: attempt must be a positive number", field)
+			}
+			rule.Attempt = int32(n)
+		}
+		if rule.Target == "" {
+			return nil, fmt.Errorf("%q: no target", field)
+		}
+		if timed {
+			d, err := time.ParseDuration(after)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("%q: invalid duration %q", field, after)
+			}
+			rule.After = d
+		}
+		switch action {
+		case ActionFail:
+			if timed {
+				return nil, fmt.Errorf("%q: fail takes no duration", field)
+			}
+		case ActionDelay:
+			if !timed {
+				return nil, fmt.Errorf("%q: delay needs a duration, e.g. =30s", field)
+			}
+		case ActionKill:
+		default:
+			return nil, fmt.Errorf("%q: action must be %s, %s or %s", field, ActionFail, ActionDelay, ActionKill)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Injector is a worker interceptor that applies its rules to the activities
+// the worker runs. Every matching rule applies, in order.
+type Injector struct {
+	interceptor.WorkerInterceptorBase
+	rules []Rule
+	// Kill stops the worker for kill rules. It defaults to exiting at once
+	// with status 137, as if the process had been killed, without running
+	// deferred cleanup. If it returns, as test doubles do, the attempt
+	// fails as if its worker had died.
+	Kill func()
+}
+
+func New(rules []Rule) *Injector {
+	return &Injector{rules: rules, Kill: killProcess}
+}
+
+// FromEnv returns the injector configured by TEMPORAL_CHAOS, or nil when it
+// is not set.
+func FromEnv() (*Injector, error) {
+	spec := strings.TrimSpace(os.Getenv("TEMPORAL_CHAOS"))
+	if spec == "" {
+		return nil, nil
+	}
+	rules, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return New(rules), nil
+}
+
+// String lists the rules as Parse reads them.
+func (i *Injector) String() string {
+	rules := make([]string, len(i.rules))
+	for n, rule := range i.rules {
+		rules[n] = rule.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+func (i *Injector) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &activityInbound{ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next}, injector: i}
+}
+
+func killProcess() {
+	log.Printf("chaos: killing the worker")
+	os.Exit(137)
+}
+
+type activityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	injector *Injector
+}
+
+func (a *activityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+	var kill *Rule
+	for _, rule := range a.injector.rules {
+		if !rule.matches(info) {
+			continue
+		}
+		activity.GetLogger(ctx).Warn("chaos: injecting fault", "rule", rule.String(), "activityId", info.ActivityID, "attempt", info.Attempt)
+		switch rule.Action {
+		case ActionFail:
+			return nil, temporal.NewApplicationError(fmt.Sprintf("chaos: %s failed on attempt %d", info.ActivityID, info.Attempt), ErrorType)
+		case ActionDelay:
+			select {
+			case <-time.After(rule.After):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		case ActionKill:
+			kill = &rule
+		}
+	}
+	if kill == nil {
+		return a.Next.ExecuteActivity(ctx, in)
+	}
+	killed := temporal.NewApplicationError(fmt.Sprintf("chaos: worker killed during %s attempt %d", info.ActivityID, info.Attempt), ErrorType)
+	if kill.After == 0 {
+		a.injector.Kill()
+		return nil, killed
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var fired atomic.Bool
+	timer := time.AfterFunc(kill.After, func() {
+		fired.Store(true)
+		a.injector.Kill()
+		cancel()
+	})
+	result, err := a.Next.ExecuteActivity(ctx, in)
+	timer.Stop()
+	if fired.Load() {
+		return nil, killed
+	}
+	return result, err
+}
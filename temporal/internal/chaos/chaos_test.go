@@ -0,0 +1,127 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := Parse("fail:train@1, delay:download=30s,kill:RunCommand@2=10s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{Action: ActionFail, Target: "train", Attempt: 1},
+		{Action: ActionDelay, Target: "download", After: 30 * time.Second},
+		{Action: ActionKill, Target: "RunCommand", Attempt: 2, After: 10 * time.Second},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules = %+v, want %+v", rules, want)
+	}
+	if got := New(rules).String(); got != "fail:train@1,delay:download=30s,kill:RunCommand@2=10s" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for spec, wantErr := range map[string]string{
+		"train":             "want action:target",
+		"explode:train":     "action must be fail, delay or kill",
+		"fail:":             "no target",
+		"fail:train@0":      "attempt must be a positive number",
+		"fail:train=5s":     "fail takes no duration",
+		"delay:train":       "delay needs a duration",
+		"delay:train=soon":  "invalid duration",
+		"kill:train@1=-10s": "invalid duration",
+	} {
+		if _, err := Parse(spec); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("Parse(%q) error = %v, want %q", spec, err, wantErr)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("TEMPORAL_CHAOS", "")
+	if injector, err := FromEnv(); injector != nil || err != nil {
+		t.Errorf("FromEnv() = %v, %v without TEMPORAL_CHAOS", injector, err)
+	}
+	t.Setenv("TEMPORAL_CHAOS", "fail:train@1")
+	if injector, err := FromEnv(); err != nil || injector.String() != "fail:train@1" {
+		t.Errorf("FromEnv() = %v, %v", injector, err)
+	}
+}
+
+// stepWorkflow runs the step activity with the given ID and up to three
+// attempts, and returns the attempt that succeeded.
+func stepWorkflow(ctx workflow.Context, id string) (int32, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		ActivityID:          id,
+		StartToCloseTimeout: time.Hour,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 3},
+	})
+	var attempt int32
+	err := workflow.ExecuteActivity(ctx, "Step").Get(ctx, &attempt)
+	return attempt, err
+}
+
+func runStep(t *testing.T, injector *Injector, id string) (int32, error) {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{injector}})
+	env.RegisterWorkflow(stepWorkflow)
+	env.RegisterActivityWithOptions(func(ctx context.Context) (int32, error) {
+		info := activity.GetInfo(ctx)
+		if info.ActivityID == "slow" && info.Attempt == 1 {
+			select {
+			case <-ctx.Done():
+				return 0, ctx.Err()
+			case <-time.After(10 * time.Second):
+			}
+		}
+		return info.Attempt, nil
+	}, activity.RegisterOptions{Name: "Step"})
+	env.ExecuteWorkflow(stepWorkflow, id)
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	var attempt int32
+	err := env.GetWorkflowError()
+	if err == nil {
+		env.GetWorkflowResult(&attempt)
+	}
+	return attempt, err
+}
+
+func TestInjector(t *testing.T) {
+	rules, _ := Parse("fail:train@1,fail:lint,kill:eval@1,kill:slow@1=50ms")
+	injector := New(rules)
+	kills := 0
+	injector.Kill = func() { kills++ }
+
+	if attempt, err := runStep(t, injector, "train"); err != nil || attempt != 2 {
+		t.Errorf("train = attempt %d, %v; want it to succeed on attempt 2", attempt, err)
+	}
+	var applicationErr *temporal.ApplicationError
+	if _, err := runStep(t, injector, "lint"); !errors.As(err, &applicationErr) || applicationErr.Type() != ErrorType {
+		t.Errorf("lint error = %v, want every attempt to fail with %s", err, ErrorType)
+	}
+	if attempt, err := runStep(t, injector, "eval"); err != nil || attempt != 2 || kills != 1 {
+		t.Errorf("eval = attempt %d, %v after %d kills; want the worker killed once", attempt, err, kills)
+	}
+	if attempt, err := runStep(t, injector, "slow"); err != nil || attempt != 2 || kills != 2 {
+		t.Errorf("slow = attempt %d, %v after %d kills; want the worker killed mid-step", attempt, err, kills)
+	}
+	if attempt, err := runStep(t, injector, "other"); err != nil || attempt != 1 {
+		t.Errorf("other = attempt %d, %v; want it untouched", attempt, err)
+	}
+}
@@ -0,0 +1,299 @@
+// Package metrics collects Temporal SDK and step metrics in memory and
+// exposes them in the Prometheus text format, either on an HTTP endpoint or
+// as a file for node_exporter's textfile collector.
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// timerBuckets are the histogram bounds, in seconds, of timer metrics such as
+// schedule-to-start and workflow task latency.
+var timerBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600}
+
+const (
+	kindCounter = "counter"
+	kindGauge   = "gauge"
+	kindTimer   = "histogram"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Registry keeps every metric series in memory. Handler exposes it to the
+// Temporal SDK.
+type Registry struct {
+	mu     sync.Mutex
+	series map[string]*series
+	kinds  map[string]string
+}
+
+type series struct {
+	name    string
+	labels  string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+func NewRegistry() *Registry {
+	return &Registry{series: map[string]*series{}, kinds: map[string]string{}}
+}
+
+// handler is a view of the registry with a fixed set of tags.
+type handler struct {
+	registry *Registry
+	tags     map[string]string
+}
+
+// Handler returns the registry as a Temporal metrics handler.
+func (r *Registry) Handler() client.MetricsHandler {
+	return &handler{registry: r}
+}
+
+func (h *handler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := make(map[string]string, len(h.tags)+len(tags))
+	for key, value := range h.tags {
+		merged[key] = value
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return &handler{registry: h.registry, tags: merged}
+}
+
+func (h *handler) Counter(name string) client.MetricsCounter {
+	return counter{h.registry.lookup(name, kindCounter, h.tags)}
+}
+
+func (h *handler) Gauge(name string) client.MetricsGauge {
+	return gauge{h.registry.lookup(name, kindGauge, h.tags)}
+}
+
+func (h *handler) Timer(name string) client.MetricsTimer {
+	return timer{h.registry.lookup(name, kindTimer, h.tags)}
+}
+
+type counter struct{ s *seriesRef }
+type gauge struct{ s *seriesRef }
+type timer struct{ s *seriesRef }
+
+func (c counter) Inc(delta int64) {
+	c.s.apply(func(s *series) { s.value += float64(delta) })
+}
+
+func (g gauge) Update(value float64) {
+	g.s.apply(func(s *series) { s.value = value })
+}
+
+func (t timer) Record(d time.Duration) {
+	seconds := d.Seconds()
+	t.s.apply(func(s *series) {
+		for i, bound := range timerBuckets {
+			if seconds <= bound {
+				s.buckets[i]++
+			}
+		}
+		s.count++
+		s.value += seconds
+	})
+}
+
+// seriesRef applies changes to one series under the registry lock. A metric
+// name reused with another kind has no series and its updates are dropped
+// rather than corrupting the output.
+type seriesRef struct {
+	registry *Registry
+	series   *series
+}
+
+func (u *seriesRef) apply(change func(*series)) {
+	if u.series == nil {
+		return
+	}
+	u.registry.mu.Lock()
+	change(u.series)
+	u.registry.mu.Unlock()
+}
+
+func (r *Registry) lookup(name, kind string, tags map[string]string) *seriesRef {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	labels := formatLabels(tags)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.kinds[name]; ok && existing != kind {
+		return &seriesRef{registry: r}
+	}
+	r.kinds[name] = kind
+	key := name + labels
+	s, ok := r.series[key]
+	if !ok {
+		s = &series{name: name, labels: labels}
+		if kind == kindTimer {
+			s.buckets = make([]uint64, len(timerBuckets))
+		}
+		r.series[key] = s
+	}
+	return &seriesRef{registry: r, series: s}
+}
+
+func formatLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(tags[key])
+		parts[i] = invalidNameChars.ReplaceAllString(key, "_") + `="` + value + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// WritePrometheus writes every series in the Prometheus text format.
+func (r *Registry) WritePrometheus(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]string, 0, len(r.series))
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	typed := map[string]bool{}
+	for _, key := range keys {
+		s := r.series[key]
+		kind := r.kinds[s.name]
+		if !typed[s.name] {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", s.name, kind)
+			typed[s.name] = true
+		}
+		if kind != kindTimer {
+			fmt.Fprintf(&b, "%s%s %s\n", s.name, s.labels, formatValue(s.value))
+			continue
+		}
+		for i, bound := range timerBuckets {
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", formatValue(bound)), s.buckets[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(&b, "%s_sum%s %s\n", s.name, s.labels, formatValue(s.value))
+		fmt.Fprintf(&b, "%s_count%s %d\n", s.name, s.labels, s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func withLabel(labels, key, value string) string {
+	label := key + `="` + value + `"`
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return labels[:len(labels)-1] + "," + label + "}"
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return fmt.Sprintf("%g", value)
+}
+
+// ServeHTTP serves the registry in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WritePrometheus(w)
+}
+
+// WriteFile atomically replaces path with the registry's current contents.
+func (r *Registry) WriteFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = errors.Join(r.WritePrometheus(file), file.Close())
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Exporter is the metrics setup of one process.
+type Exporter struct {
+	registry *Registry
+	file     string
+	tags     map[string]string
+}
+
+// FromEnv sets up metrics export for component (worker, orchestrate, run).
+// TEMPORAL_METRICS_ADDR serves /metrics on that address, and
+// TEMPORAL_METRICS_FILE writes the metrics to a file when Close is called.
+// Without either, it returns nil and clients use the SDK's no-op handler.
+func FromEnv(component string) (*Exporter, error) {
+	addr := os.Getenv("TEMPORAL_METRICS_ADDR")
+	file := os.Getenv("TEMPORAL_METRICS_FILE")
+	if addr == "" && file == "" {
+		return nil, nil
+	}
+	exporter := &Exporter{
+		registry: NewRegistry(),
+		file:     file,
+		tags:     map[string]string{"component": component},
+	}
+	if addr != "" {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("metrics endpoint: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", exporter.registry)
+		go func() {
+			if err := http.Serve(listener, mux); err != nil {
+				log.Printf("metrics endpoint stopped: %v", err)
+			}
+		}()
+		log.Printf("serving metrics on http://%s/metrics", listener.Addr())
+	}
+	return exporter, nil
+}
+
+// Handler returns the handler to set in client.Options; it is nil (the SDK
+// default) when e is nil.
+func (e *Exporter) Handler() client.MetricsHandler {
+	if e == nil {
+		return nil
+	}
+	return e.registry.Handler().WithTags(e.tags)
+}
+
+// Close writes TEMPORAL_METRICS_FILE, if set.
+func (e *Exporter) Close() {
+	if e == nil || e.file == "" {
+		return
+	}
+	if err := e.registry.WriteFile(e.file); err != nil {
+		log.Printf("unable to write metrics file: %v", err)
+	}
+}
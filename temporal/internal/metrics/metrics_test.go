@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	registry := NewRegistry()
+	handler := registry.Handler().WithTags(map[string]string{"component": "worker"})
+	failed := handler.WithTags(map[string]string{"activity_type": "RunCommand"}).Counter("temporal_activity_execution_failed")
+	failed.Inc(1)
+	failed.Inc(2)
+	handler.WithTags(map[string]string{"step_id": "train"}).Gauge("sygaldry_step_loss").Update(0.25)
+	latency := handler.Timer("temporal_workflow_task_schedule_to_start_latency")
+	latency.Record(20 * time.Millisecond)
+	latency.Record(2 * time.Minute)
+	// Reusing a name with another kind is ignored.
+	handler.Gauge("temporal_activity_execution_failed").Update(9)
+
+	var b strings.Builder
+	if err := registry.WritePrometheus(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# TYPE temporal_activity_execution_failed counter\n",
+		`temporal_activity_execution_failed{activity_type="RunCommand",component="worker"} 3` + "\n",
+		`sygaldry_step_loss{component="worker",step_id="train"} 0.25` + "\n",
+		"# TYPE temporal_workflow_task_schedule_to_start_latency histogram\n",
+		`temporal_workflow_task_schedule_to_start_latency_bucket{component="worker",le="0.025"} 1` + "\n",
+		`temporal_workflow_task_schedule_to_start_latency_bucket{component="worker",le="300"} 2` + "\n",
+		`temporal_workflow_task_schedule_to_start_latency_bucket{component="worker",le="+Inf"} 2` + "\n",
+		`temporal_workflow_task_schedule_to_start_latency_sum{component="worker"} 120.02` + "\n",
+		`temporal_workflow_task_schedule_to_start_latency_count{component="worker"} 2` + "\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, " 9\n") {
+		t.Errorf("conflicting gauge was exported:\n%s", out)
+	}
+}
+
+func TestLabelValuesAreEscaped(t *testing.T) {
+	registry := NewRegistry()
+	registry.Handler().WithTags(map[string]string{"step-id": `say "hi"`}).Counter("steps").Inc(1)
+	var b strings.Builder
+	registry.WritePrometheus(&b)
+	if want := `steps{step_id="say \"hi\""} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("output = %q, want %q", b.String(), want)
+	}
+}
+
+func TestServeHTTPAndWriteFile(t *testing.T) {
+	registry := NewRegistry()
+	registry.Handler().Counter("temporal_request").Inc(4)
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(recorder.Body.String(), "temporal_request 4") {
+		t.Errorf("body = %q", recorder.Body.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "textfile", "orchestrate.prom")
+	if err := registry.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(data), "temporal_request 4") {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
+
+func TestFromEnvDisabled(t *testing.T) {
+	t.Setenv("TEMPORAL_METRICS_ADDR", "")
+	t.Setenv("TEMPORAL_METRICS_FILE", "")
+	exporter, err := FromEnv("worker")
+	if err != nil || exporter != nil {
+		t.Fatalf("FromEnv() = %v, %v", exporter, err)
+	}
+	if exporter.Handler() != nil {
+		t.Error("disabled exporter must leave the SDK default handler")
+	}
+	exporter.Close()
+}
+
+func TestFromEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.prom")
+	t.Setenv("TEMPORAL_METRICS_ADDR", "")
+	t.Setenv("TEMPORAL_METRICS_FILE", path)
+	exporter, err := FromEnv("run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	exporter.Handler().Counter("temporal_request").Inc(1)
+	exporter.Close()
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `temporal_request{component="run"} 1`) {
+		t.Errorf("file = %q", data)
+	}
+}
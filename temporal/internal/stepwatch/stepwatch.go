@@ -0,0 +1,130 @@
+// Package stepwatch follows a running Pipeline or Orchestrate workflow from
+// the client side, reporting the lifecycle of its steps as they change.
+package stepwatch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+
+	"go.temporal.io/sdk/converter"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+// Querier is the part of client.Client a Watcher uses.
+type Querier interface {
+	QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...interface{}) (converter.EncodedValue, error)
+}
+
+// Watcher turns the step states of a run into step lifecycle events, as
+// recorded in events.jsonl, by polling workflows.StepsQuery.
+type Watcher struct {
+	c          Querier
+	workflowID string
+	runID      string
+	last       map[string]string
+}
+
+func NewWatcher(c Querier, workflowID, runID string) *Watcher {
+	return &Watcher{c: c, workflowID: workflowID, runID: runID, last: map[string]string{}}
+}
+
+// Watch polls every interval until ctx is done, passing new events to emit.
+// Failed queries are retried at the next poll.
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration, emit func(activities.StepEvent)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		w.Poll(ctx, emit)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll queries the run once and passes the events since the last poll to
+// emit: step_started when a step starts running, then step_finished,
+// step_skipped or step_cancelled. A step seen only after it ended gets
+// both. A rerun step starts over.
+func (w *Watcher) Poll(ctx context.Context, emit func(activities.StepEvent)) error {
+	value, err := w.c.QueryWorkflow(ctx, w.workflowID, w.runID, workflows.StepsQuery)
+	if err != nil {
+		return err
+	}
+	var states []workflows.StepState
+	if err := value.Get(&states); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, state := range states {
+		previous := w.last[state.ID]
+		if previous == state.State {
+			continue
+		}
+		w.last[state.ID] = state.State
+		event := activities.StepEvent{Timestamp: now, WorkflowID: w.workflowID, RunID: w.runID, StepID: state.ID}
+		switch state.State {
+		case "pending":
+		case "running":
+			event.Status = "step_started"
+			emit(event)
+		case "skipped":
+			event.Status, event.Message = "step_skipped", state.Message
+			emit(event)
+		case "cancelled":
+			event.Status, event.Message = "step_cancelled", state.Message
+			emit(event)
+		default:
+			if previous != "running" {
+				event.Status = "step_started"
+				emit(event)
+			}
+			event.Status, event.ExitCode, event.Message = "step_finished", state.ExitCode, state.Message
+			if state.State == "failed" && event.ExitCode == 0 {
+				// Failed without a command exit code, e.g. an activity error.
+				event.ExitCode = -1
+			}
+			emit(event)
+		}
+	}
+	return nil
+}
+
+// Stream writes the step events of a run to w as JSON lines while
+// it runs, after a pipeline_started event, polling every second. The
+// returned finish stops the stream after a last poll and writes a
+// pipeline_finished event whose message is the run's status.
+func Stream(c Querier, workflowID, runID string, w io.Writer) (finish func(status string)) {
+	encoder := json.NewEncoder(w)
+	emit := func(event activities.StepEvent) { encoder.Encode(event) }
+	event := func(status, message string) activities.StepEvent {
+		return activities.StepEvent{
+			Timestamp:  time.Now().UTC().Format(time.RFC3339Nano),
+			WorkflowID: workflowID,
+			RunID:      runID,
+			Status:     status,
+			Message:    message,
+		}
+	}
+	emit(event("pipeline_started", ""))
+	watcher := NewWatcher(c, workflowID, runID)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Watch(ctx, time.Second, emit)
+	}()
+	return func(status string) {
+		cancel()
+		<-done
+		pollCtx, cancelPoll := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancelPoll()
+		watcher.Poll(pollCtx, emit)
+		emit(event("pipeline_finished", status))
+	}
+}
@@ -0,0 +1,82 @@
+package stepwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/converter"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/workflows"
+)
+
+type jsonValue struct{ data []byte }
+
+func (v jsonValue) HasValue() bool                 { return true }
+func (v jsonValue) Get(valuePtr interface{}) error { return json.Unmarshal(v.data, valuePtr) }
+
+// scriptedQuerier answers each query with the next set of step states.
+type scriptedQuerier struct{ answers [][]workflows.StepState }
+
+func (q *scriptedQuerier) QueryWorkflow(ctx context.Context, workflowID, runID, queryType string, args ...interface{}) (converter.EncodedValue, error) {
+	data, _ := json.Marshal(q.answers[0])
+	if len(q.answers) > 1 {
+		q.answers = q.answers[1:]
+	}
+	return jsonValue{data}, nil
+}
+
+func TestWatcherPoll(t *testing.T) {
+	querier := &scriptedQuerier{answers: [][]workflows.StepState{
+		{{ID: "a", State: "running"}, {ID: "b", State: "pending"}, {ID: "c", State: "pending"}},
+		{{ID: "a", State: "success"}, {ID: "b", State: "failed", Message: "boom"}, {ID: "c", State: "skipped", Message: "when condition not met"}},
+		{{ID: "a", State: "running"}, {ID: "b", State: "failed", Message: "boom"}, {ID: "c", State: "skipped", Message: "when condition not met"}},
+		{{ID: "a", State: "success"}, {ID: "b", State: "failed", Message: "boom"}, {ID: "c", State: "skipped", Message: "when condition not met"}},
+	}}
+	watcher := NewWatcher(querier, "wf", "run")
+	var got []string
+	emit := func(event activities.StepEvent) {
+		got = append(got, fmt.Sprintf("%s %s %d", event.StepID, event.Status, event.ExitCode))
+	}
+	for i := 0; i < 5; i++ {
+		if err := watcher.Poll(context.Background(), emit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"a step_started 0",
+		"a step_finished 0",
+		"b step_started 0",
+		"b step_finished -1",
+		"c step_skipped 0",
+		"a step_started 0",
+		"a step_finished 0",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestStream(t *testing.T) {
+	querier := &scriptedQuerier{answers: [][]workflows.StepState{{{ID: "a", State: "success"}}}}
+	var out bytes.Buffer
+	finish := Stream(querier, "wf", "run", &out)
+	finish(workflows.StatusSucceeded)
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event activities.StepEvent
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("bad line %q: %v", line, err)
+		}
+		got = append(got, strings.TrimSpace(event.StepID+" "+event.Status+" "+event.Message))
+	}
+	want := "pipeline_started|a step_started|a step_finished|pipeline_finished " + workflows.StatusSucceeded
+	if strings.Join(got, "|") != want {
+		t.Errorf("events = %q, want %q", strings.Join(got, "|"), want)
+	}
+}
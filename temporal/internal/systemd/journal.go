@@ -0,0 +1,170 @@
+package systemd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+// journalSocket is where journald receives entries in its native protocol.
+var journalSocket = "/run/systemd/journal/socket"
+
+// Journal priorities, as in syslog.
+const (
+	PriorityErr     = 3
+	PriorityWarning = 4
+	PriorityInfo    = 6
+	PriorityDebug   = 7
+)
+
+// Journal writes log entries to the systemd journal, with their priority and
+// attributes as fields that journalctl can filter on, e.g.
+// journalctl -u sygaldry-worker PRIORITY=4 or WORKFLOWID=nightly.
+type Journal struct {
+	conn       *net.UnixConn
+	identifier string
+}
+
+// OpenJournal connects to journald. identifier is the SYSLOG_IDENTIFIER of
+// the entries.
+func OpenJournal(identifier string) (*Journal, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("systemd journal unavailable: %w", err)
+	}
+	return &Journal{conn: conn, identifier: identifier}, nil
+}
+
+func (j *Journal) Close() error {
+	return j.conn.Close()
+}
+
+// Send writes one entry. Field names are upper-cased, with characters other
+// than letters, digits and underscores replaced by underscores.
+func (j *Journal) Send(priority int, message string, fields map[string]string) error {
+	var entry bytes.Buffer
+	writeJournalField(&entry, "MESSAGE", message)
+	writeJournalField(&entry, "PRIORITY", fmt.Sprint(priority))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", j.identifier)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeJournalField(&entry, journalFieldName(name), fields[name])
+	}
+	_, err := j.conn.Write(entry.Bytes())
+	return err
+}
+
+// Write sends p, one message of the standard log package, as an info entry.
+// A message the journal does not take is written to stderr instead.
+func (j *Journal) Write(p []byte) (int, error) {
+	if err := j.Send(PriorityInfo, strings.TrimSuffix(string(p), "\n"), nil); err != nil {
+		return os.Stderr.Write(p)
+	}
+	return len(p), nil
+}
+
+// writeJournalField encodes a field in the native protocol: KEY=value, or
+// for values spanning lines KEY, the value's length as 64-bit little endian
+// and the value.
+func writeJournalField(entry *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(entry, "%s=%s\n", name, value)
+		return
+	}
+	entry.WriteString(name + "\n")
+	binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value + "\n")
+}
+
+func journalFieldName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	field := strings.TrimLeft(b.String(), "_")
+	// Fields starting with an underscore are reserved for journald.
+	if field == "" || field[0] >= '0' && field[0] <= '9' {
+		field = "F_" + field
+	}
+	return field
+}
+
+// Handler returns a slog handler that writes records at level and above to
+// the journal. Attributes become fields and are also appended to the message
+// as key=value.
+func (j *Journal) Handler(level slog.Leveler) slog.Handler {
+	return &journalHandler{journal: j, level: level}
+}
+
+type journalHandler struct {
+	journal *Journal
+	level   slog.Leveler
+	prefix  string
+	attrs   []slog.Attr
+}
+
+func (h *journalHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *journalHandler) Handle(ctx context.Context, record slog.Record) error {
+	fields := map[string]string{}
+	var message strings.Builder
+	message.WriteString(record.Message)
+	add := func(key string, value slog.Value) {
+		fields[key] = value.Resolve().String()
+		fmt.Fprintf(&message, " %s=%s", key, fields[key])
+	}
+	for _, attr := range h.attrs {
+		add(attr.Key, attr.Value)
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		add(h.prefix+attr.Key, attr.Value)
+		return true
+	})
+	return h.journal.Send(journalPriority(record.Level), message.String(), fields)
+}
+
+func (h *journalHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, slog.Attr{Key: h.prefix + attr.Key, Value: attr.Value})
+	}
+	return &clone
+}
+
+func (h *journalHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.prefix = h.prefix + name + "_"
+	return &clone
+}
+
+func journalPriority(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return PriorityErr
+	case level >= slog.LevelWarn:
+		return PriorityWarning
+	case level >= slog.LevelInfo:
+		return PriorityInfo
+	}
+	return PriorityDebug
+}
@@ -0,0 +1,182 @@
+// Package systemd runs the worker as a systemd service: readiness and
+// watchdog notifications, logging to the journal and unit file generation.
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// PollGrace is how long the worker may go without an answered Temporal poll
+// before it stops pinging the watchdog. Idle long polls are answered about
+// once a minute.
+const PollGrace = 2 * time.Minute
+
+// Notify sends state, e.g. "READY=1", to the service manager through
+// NOTIFY_SOCKET. It does nothing when the process is not run by systemd
+// with Type=notify.
+func Notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if socket[0] == '@' {
+		// An abstract socket.
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// WatchdogInterval is the timeout within which systemd expects watchdog
+// pings (WatchdogSec=). ok is false when the watchdog is off for this
+// process.
+func WatchdogInterval() (timeout time.Duration, ok bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Watchdog pings the systemd watchdog at half its timeout while healthy
+// returns nil, until ctx is done. While unhealthy it stops pinging and says
+// why in the service status, so systemd restarts the worker once the
+// timeout passes.
+func Watchdog(ctx context.Context, timeout time.Duration, healthy func() error) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	var failing bool
+	for {
+		if err := healthy(); err != nil {
+			if !failing {
+				log.Printf("not pinging the systemd watchdog: %v", err)
+				Notify("STATUS=unhealthy: " + err.Error())
+				failing = true
+			}
+		} else {
+			if failing {
+				log.Printf("Temporal polls answered again; pinging the systemd watchdog")
+				Notify("STATUS=polling")
+				failing = false
+			}
+			if err := Notify("WATCHDOG=1"); err != nil {
+				log.Printf("unable to ping the systemd watchdog: %v", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollMetrics are recorded by the Temporal SDK only when the server answered
+// a poll, with or without a task.
+var pollMetrics = map[string]bool{
+	"temporal_workflow_task_queue_poll_empty":     true,
+	"temporal_workflow_task_queue_poll_succeed":   true,
+	"temporal_activity_poll_no_task":              true,
+	"temporal_activity_schedule_to_start_latency": true,
+}
+
+// PollTracker records when a worker's polls were last answered, by watching
+// the metrics the SDK reports. It counts from its creation until the first
+// poll is answered.
+type PollTracker struct {
+	last atomic.Int64
+}
+
+func NewPollTracker() *PollTracker {
+	tracker := &PollTracker{}
+	tracker.answered()
+	return tracker
+}
+
+func (t *PollTracker) answered() {
+	t.last.Store(time.Now().UnixNano())
+}
+
+// LastAnswered is when a poll was last answered.
+func (t *PollTracker) LastAnswered() time.Time {
+	return time.Unix(0, t.last.Load())
+}
+
+// Healthy returns an error when no poll was answered within grace.
+func (t *PollTracker) Healthy(grace time.Duration) error {
+	if since := time.Since(t.LastAnswered()); since > grace {
+		return fmt.Errorf("no Temporal poll answered for %s", since.Round(time.Second))
+	}
+	return nil
+}
+
+// Handler wraps the metrics handler given to the Temporal client so the
+// tracker sees the worker's polls. A nil inner handler reports nowhere else.
+func (t *PollTracker) Handler(inner client.MetricsHandler) client.MetricsHandler {
+	if inner == nil {
+		inner = client.MetricsNopHandler
+	}
+	return pollHandler{MetricsHandler: inner, tracker: t}
+}
+
+type pollHandler struct {
+	client.MetricsHandler
+	tracker *PollTracker
+}
+
+func (h pollHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	return pollHandler{MetricsHandler: h.MetricsHandler.WithTags(tags), tracker: h.tracker}
+}
+
+func (h pollHandler) Counter(name string) client.MetricsCounter {
+	counter := h.MetricsHandler.Counter(name)
+	if !pollMetrics[name] {
+		return counter
+	}
+	return pollCounter{MetricsCounter: counter, tracker: h.tracker}
+}
+
+func (h pollHandler) Timer(name string) client.MetricsTimer {
+	timer := h.MetricsHandler.Timer(name)
+	if !pollMetrics[name] {
+		return timer
+	}
+	return pollTimer{MetricsTimer: timer, tracker: h.tracker}
+}
+
+type pollCounter struct {
+	client.MetricsCounter
+	tracker *PollTracker
+}
+
+func (c pollCounter) Inc(delta int64) {
+	c.tracker.answered()
+	c.MetricsCounter.Inc(delta)
+}
+
+type pollTimer struct {
+	client.MetricsTimer
+	tracker *PollTracker
+}
+
+func (t pollTimer) Record(d time.Duration) {
+	t.tracker.answered()
+	t.MetricsTimer.Record(d)
+}
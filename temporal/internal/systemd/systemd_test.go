@@ -0,0 +1,188 @@
+package systemd
+
+import (
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/client"
+)
+
+// listen opens a datagram socket standing in for systemd.
+func listen(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sd.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+func receive(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	buf := make([]byte, 64*1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify("READY=1"); err != nil {
+		t.Errorf("Notify without NOTIFY_SOCKET = %v", err)
+	}
+	path, conn := listen(t)
+	t.Setenv("NOTIFY_SOCKET", path)
+	if err := Notify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := receive(t, conn); got != "READY=1" {
+		t.Errorf("received %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "180000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if timeout, ok := WatchdogInterval(); !ok || timeout != 3*time.Minute {
+		t.Errorf("WatchdogInterval() = %s, %v", timeout, ok)
+	}
+	t.Setenv("WATCHDOG_PID", "1")
+	if _, ok := WatchdogInterval(); ok {
+		t.Error("watchdog of another process enabled")
+	}
+	t.Setenv("WATCHDOG_USEC", "")
+	if _, ok := WatchdogInterval(); ok {
+		t.Error("watchdog enabled without WATCHDOG_USEC")
+	}
+}
+
+func TestWatchdogFollowsPollHealth(t *testing.T) {
+	path, conn := listen(t)
+	t.Setenv("NOTIFY_SOCKET", path)
+	tracker := NewPollTracker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watchdog(ctx, 40*time.Millisecond, func() error { return tracker.Healthy(100 * time.Millisecond) })
+
+	if got := receive(t, conn); got != "WATCHDOG=1" {
+		t.Fatalf("first message = %q", got)
+	}
+	for {
+		if got := receive(t, conn); strings.HasPrefix(got, "STATUS=unhealthy: no Temporal poll answered") {
+			break
+		} else if got != "WATCHDOG=1" {
+			t.Fatalf("unexpected message %q", got)
+		}
+	}
+	tracker.Handler(nil).WithTags(map[string]string{"poller_type": "activity_task"}).Counter("temporal_activity_poll_no_task").Inc(1)
+	if got := receive(t, conn); got != "STATUS=polling" {
+		t.Errorf("message after recovery = %q", got)
+	}
+	if got := receive(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("message after recovery = %q", got)
+	}
+}
+
+func TestPollTrackerSeesOnlyPolls(t *testing.T) {
+	tracker := NewPollTracker()
+	tracker.last.Store(0)
+	handler := tracker.Handler(client.MetricsNopHandler)
+	handler.Counter("temporal_request").Inc(1)
+	handler.Timer("temporal_workflow_task_execution_latency").Record(time.Second)
+	if !tracker.LastAnswered().Equal(time.Unix(0, 0)) {
+		t.Errorf("other metrics counted as polls: %s", tracker.LastAnswered())
+	}
+	handler.WithTags(map[string]string{"activity_type": "RunCommand"}).Timer("temporal_activity_schedule_to_start_latency").Record(time.Second)
+	if err := tracker.Healthy(time.Minute); err != nil {
+		t.Errorf("Healthy() after a polled task = %v", err)
+	}
+}
+
+func TestJournalEntries(t *testing.T) {
+	path, conn := listen(t)
+	saved := journalSocket
+	journalSocket = path
+	t.Cleanup(func() { journalSocket = saved })
+	journal, err := OpenJournal("sygaldry-worker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+
+	logger := slog.New(journal.Handler(slog.LevelInfo)).With("Namespace", "default").WithGroup("step")
+	logger.Debug("dropped")
+	logger.Warn("step failed", "id", "train", "error", "exit 1\nCUDA out of memory")
+	entry := receive(t, conn)
+	for _, want := range []string{"PRIORITY=4\n", "SYSLOG_IDENTIFIER=sygaldry-worker\n", "NAMESPACE=default\n", "STEP_ID=train\n"} {
+		if !strings.Contains(entry, want) {
+			t.Errorf("entry missing %q:\n%q", want, entry)
+		}
+	}
+	value := "exit 1\nCUDA out of memory"
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(value)))
+	if !strings.Contains(entry, "STEP_ERROR\n"+string(size)+value+"\n") {
+		t.Errorf("multi-line field not length-prefixed:\n%q", entry)
+	}
+	if !strings.HasPrefix(entry, "MESSAGE\n") {
+		t.Errorf("multi-line message not length-prefixed:\n%q", entry)
+	}
+
+	journal.Write([]byte("worker started\n"))
+	if entry := receive(t, conn); !strings.Contains(entry, "MESSAGE=worker started\nPRIORITY=6\n") {
+		t.Errorf("log entry = %q", entry)
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	for name, want := range map[string]string{"workflowId": "WORKFLOWID", "step.id": "STEP_ID", "_private": "PRIVATE", "1st": "F_1ST"} {
+		if got := journalFieldName(name); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestUnitRender(t *testing.T) {
+	unit, err := Unit{
+		ExecStart:        "/opt/sygaldry/bin/worker",
+		User:             "sygaldry",
+		WorkingDirectory: "/var/lib/sygaldry",
+		EnvironmentFile:  "/etc/sygaldry/worker.env",
+		Environment:      map[string]string{"TEMPORAL_TASK_QUEUE": "gpu", "TEMPORAL_RATE_LIMITS": "hf-api=100%"},
+		WatchdogSec:      3 * time.Minute,
+		Journal:          true,
+	}.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Type=notify\n",
+		"ExecStart=/opt/sygaldry/bin/worker\n",
+		"User=sygaldry\n",
+		"EnvironmentFile=-/etc/sygaldry/worker.env\n",
+		"Environment=\"TEMPORAL_LOG_JOURNAL=1\"\nEnvironment=\"TEMPORAL_RATE_LIMITS=hf-api=100%%\"\nEnvironment=\"TEMPORAL_TASK_QUEUE=gpu\"\n",
+		"WatchdogSec=180\n",
+		"TimeoutStopSec=300\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+	if _, err := (Unit{ExecStart: "worker"}).Render(); err == nil {
+		t.Error("relative ExecStart accepted")
+	}
+}
@@ -0,0 +1,101 @@
+package systemd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Unit describes the systemd service of a worker.
+type Unit struct {
+	Description string
+	// ExecStart is the worker binary, an absolute path.
+	ExecStart        string
+	User             string
+	Group            string
+	WorkingDirectory string
+	// EnvironmentFile holds the worker's TEMPORAL_* settings and secrets.
+	// It may be missing.
+	EnvironmentFile string
+	Environment     map[string]string
+	// WatchdogSec restarts a worker whose Temporal polls go unanswered; zero
+	// turns the watchdog off.
+	WatchdogSec time.Duration
+	// Journal logs to the journal with priorities and fields
+	// (TEMPORAL_LOG_JOURNAL=1).
+	Journal bool
+	// StopTimeout is how long running steps get to stop on shutdown.
+	StopTimeout time.Duration
+}
+
+// Render returns the unit file. The service is Type=notify: systemd
+// considers it started once the worker polls its task queues.
+func (u Unit) Render() (string, error) {
+	if !strings.HasPrefix(u.ExecStart, "/") {
+		return "", fmt.Errorf("ExecStart must be an absolute path, got %q", u.ExecStart)
+	}
+	description := u.Description
+	if description == "" {
+		description = "Sygaldry Temporal worker"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\nWants=network-online.target\nAfter=network-online.target\n\n", description)
+	b.WriteString("[Service]\nType=notify\nNotifyAccess=main\n")
+	execStart := unitEscape(u.ExecStart)
+	if strings.ContainsAny(u.ExecStart, " \t") {
+		execStart = quoteUnitValue(u.ExecStart)
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\n", execStart)
+	if u.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", u.User)
+	}
+	if u.Group != "" {
+		fmt.Fprintf(&b, "Group=%s\n", u.Group)
+	}
+	if u.WorkingDirectory != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", unitEscape(u.WorkingDirectory))
+	}
+	if u.EnvironmentFile != "" {
+		fmt.Fprintf(&b, "EnvironmentFile=-%s\n", unitEscape(u.EnvironmentFile))
+	}
+	env := map[string]string{}
+	for name, value := range u.Environment {
+		env[name] = value
+	}
+	if u.Journal {
+		env["TEMPORAL_LOG_JOURNAL"] = "1"
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "Environment=%s\n", quoteUnitValue(name+"="+env[name]))
+	}
+	if u.WatchdogSec > 0 {
+		fmt.Fprintf(&b, "WatchdogSec=%d\n", int64(u.WatchdogSec.Round(time.Second)/time.Second))
+	}
+	stopTimeout := u.StopTimeout
+	if stopTimeout <= 0 {
+		stopTimeout = 5 * time.Minute
+	}
+	// SIGTERM goes to the worker alone, which cancels its steps; whatever
+	// is left when the stop timeout passes is killed.
+	fmt.Fprintf(&b, "KillMode=mixed\nTimeoutStopSec=%d\n", int64(stopTimeout.Round(time.Second)/time.Second))
+	b.WriteString("Restart=on-failure\nRestartSec=10\nLimitNOFILE=65536\n\n")
+	b.WriteString("[Install]\nWantedBy=multi-user.target\n")
+	return b.String(), nil
+}
+
+// unitEscape escapes the specifier character % of unit files.
+func unitEscape(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
+
+// quoteUnitValue quotes an Environment= assignment.
+func quoteUnitValue(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + unitEscape(s) + `"`
+}
@@ -0,0 +1,169 @@
+package workflows
+
+import (
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+)
+
+// CanaryParam is the param a step with a canary receives: the canary's
+// percent in its canary run and 100 in its full run. Steps read it as
+// $SYGALDRY_PARAM_CANARY_PERCENT to scale what they touch.
+const CanaryParam = "canary_percent"
+
+// CanarySpec runs a deploy-like step first with reduced scope. The canary
+// run is a copy of the step (see CanaryStepID) with ParamOverrides applied;
+// the Evaluate step runs after it, and the step itself only runs once
+// Evaluate succeeded and, if set, its outputs meet Promote. Otherwise the
+// step fails without running.
+type CanarySpec struct {
+	Percent        int               `json:"percent" yaml:"percent"`
+	ParamOverrides map[string]string `json:"paramOverrides,omitempty" yaml:"param_overrides"`
+	Evaluate       string            `json:"evaluate" yaml:"evaluate"`
+	// Promote is a condition on an output of Evaluate, e.g.
+	// "error_rate < 0.01".
+	Promote string `json:"promote,omitempty" yaml:"promote"`
+}
+
+// CanaryStepID is the ID of the canary run of a step.
+func CanaryStepID(id string) string {
+	return id + "-canary"
+}
+
+// ValidateCanaries checks the canaries of a plan's steps before they are
+// expanded by ExpandCanaries.
+func ValidateCanaries(steps []PipelineStep) error {
+	byID := make(map[string]PipelineStep, len(steps))
+	for _, step := range steps {
+		byID[step.ID] = step
+	}
+	for _, step := range steps {
+		canary := step.Canary
+		if canary == nil {
+			continue
+		}
+		switch step.Type {
+		case "command", "container_job":
+		default:
+			return fmt.Errorf("step %s: %s steps cannot use canary", step.ID, step.Type)
+		}
+		if canary.Percent < 1 || canary.Percent > 99 {
+			return fmt.Errorf("step %s: canary.percent must be between 1 and 99", step.ID)
+		}
+		if _, ok := byID[CanaryStepID(step.ID)]; ok {
+			return fmt.Errorf("step %s: its canary run would be %s, which is already a step id", step.ID, CanaryStepID(step.ID))
+		}
+		evaluate, ok := byID[canary.Evaluate]
+		if !ok || canary.Evaluate == step.ID {
+			return fmt.Errorf("step %s: canary.evaluate must name another step", step.ID)
+		}
+		for _, dep := range evaluate.DependsOn {
+			if dep == step.ID {
+				return fmt.Errorf("step %s: canary.evaluate step %s cannot depend on it", step.ID, evaluate.ID)
+			}
+		}
+		if canary.Promote != "" {
+			if _, err := ParseMetricCondition(canary.Promote); err != nil {
+				return fmt.Errorf("step %s: canary.promote: %w", step.ID, err)
+			}
+		}
+		for name := range canary.ParamOverrides {
+			if name == "" || name == CanaryParam {
+				return fmt.Errorf("step %s: canary.param_overrides has invalid name %q", step.ID, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandCanaries adds the canary run of each step with a canary right before
+// it, makes the evaluation step depend on the canary run and the step on the
+// evaluation step. The canary run has the step's dependencies and settings
+// but no canary of its own.
+func ExpandCanaries(steps []PipelineStep) []PipelineStep {
+	evaluates := map[string][]string{}
+	for _, step := range steps {
+		if step.Canary != nil {
+			evaluates[step.Canary.Evaluate] = append(evaluates[step.Canary.Evaluate], CanaryStepID(step.ID))
+		}
+	}
+	if len(evaluates) == 0 {
+		return steps
+	}
+	expanded := make([]PipelineStep, 0, len(steps)+len(evaluates))
+	for _, step := range steps {
+		if canaries := evaluates[step.ID]; len(canaries) > 0 {
+			step.DependsOn = append(append([]string(nil), step.DependsOn...), canaries...)
+		}
+		if step.Canary != nil {
+			canary := step
+			canary.ID = CanaryStepID(step.ID)
+			canary.Name = stepName(step) + " (canary)"
+			canary.Canary = nil
+			expanded = append(expanded, canary)
+			step.DependsOn = append(append([]string(nil), step.DependsOn...), step.Canary.Evaluate)
+		}
+		expanded = append(expanded, step)
+	}
+	return expanded
+}
+
+// canaryParams returns the params a step runs with: those of the plan with
+// CanaryParam, plus the overrides for a canary run. Other steps get params
+// as they are.
+func canaryParams(steps []PipelineStep, step PipelineStep, params map[string]string) map[string]string {
+	if step.Canary != nil {
+		merged := maps.Clone(params)
+		if merged == nil {
+			merged = map[string]string{}
+		}
+		merged[CanaryParam] = "100"
+		return merged
+	}
+	for _, full := range steps {
+		if full.Canary == nil || CanaryStepID(full.ID) != step.ID {
+			continue
+		}
+		merged := maps.Clone(params)
+		if merged == nil {
+			merged = map[string]string{}
+		}
+		maps.Copy(merged, full.Canary.ParamOverrides)
+		merged[CanaryParam] = strconv.Itoa(full.Canary.Percent)
+		return merged
+	}
+	return params
+}
+
+// canaryPromoted reports whether the full run of a step may proceed: it has
+// no canary, or its evaluation step succeeded and its outputs meet Promote.
+func canaryPromoted(step PipelineStep, outcomes map[string]StepOutcome) (bool, string) {
+	canary := step.Canary
+	if canary == nil {
+		return true, ""
+	}
+	evaluation := outcomes[canary.Evaluate]
+	if evaluation.State != "success" {
+		return false, fmt.Sprintf("canary not promoted: %s did not succeed", canary.Evaluate)
+	}
+	if canary.Promote == "" {
+		return true, ""
+	}
+	condition, err := ParseMetricCondition(canary.Promote)
+	if err != nil {
+		return false, err.Error()
+	}
+	raw, ok := evaluation.Result.Outputs[condition.Name]
+	if !ok {
+		return false, fmt.Sprintf("canary not promoted: %s did not output %s", canary.Evaluate, condition.Name)
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return false, fmt.Sprintf("canary not promoted: %s output %s=%q is not a number", canary.Evaluate, condition.Name, raw)
+	}
+	if !condition.Holds(value) {
+		return false, fmt.Sprintf("canary not promoted: %s output %s=%g, want %s", canary.Evaluate, condition.Name, value, canary.Promote)
+	}
+	return true, ""
+}
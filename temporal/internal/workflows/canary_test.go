@@ -0,0 +1,83 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestExpandCanaries(t *testing.T) {
+	steps := ExpandCanaries([]PipelineStep{
+		{ID: "build", Type: "command", Command: "make"},
+		{ID: "deploy", Type: "command", Command: "deploy", DependsOn: []string{"build"}, Canary: &CanarySpec{Percent: 10, Evaluate: "check"}},
+		{ID: "check", Type: "command", Command: "check"},
+	})
+	var got []string
+	for _, step := range steps {
+		got = append(got, step.ID+"<"+strings.Join(step.DependsOn, ","))
+	}
+	want := "build< deploy-canary<build deploy<build,check check<deploy-canary"
+	if strings.Join(got, " ") != want {
+		t.Errorf("expanded = %s, want %s", strings.Join(got, " "), want)
+	}
+	if steps[1].Canary != nil || steps[1].Name != "deploy (canary)" || steps[2].Canary == nil {
+		t.Errorf("canary run = %+v, full run = %+v", steps[1], steps[2])
+	}
+}
+
+func runCanaryPlan(t *testing.T, errorRate string) (*testsuite.TestWorkflowEnvironment, map[string]map[string]string) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	ran := map[string]map[string]string{}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		ran[input.StepID] = input.Env
+		if input.StepID == "check" {
+			return activities.RunCommandResult{Outputs: map[string]string{"error_rate": errorRate}}, nil
+		}
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Params: map[string]string{"replicas": "20"},
+		Steps: []PipelineStep{
+			{ID: "deploy", Type: "command", Command: "deploy.sh", Canary: &CanarySpec{
+				Percent:        10,
+				ParamOverrides: map[string]string{"replicas": "2"},
+				Evaluate:       "check",
+				Promote:        "error_rate < 0.01",
+			}},
+			{ID: "check", Type: "command", Command: "check.sh"},
+		},
+	})
+	return env, ran
+}
+
+func TestCanaryPromotesFullRun(t *testing.T) {
+	env, ran := runCanaryPlan(t, "0.002")
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	canary, full := ran["deploy-canary"], ran["deploy"]
+	if canary["SYGALDRY_PARAM_CANARY_PERCENT"] != "10" || canary["SYGALDRY_PARAM_REPLICAS"] != "2" {
+		t.Errorf("canary env = %v, want 10%% with 2 replicas", canary)
+	}
+	if full["SYGALDRY_PARAM_CANARY_PERCENT"] != "100" || full["SYGALDRY_PARAM_REPLICAS"] != "20" {
+		t.Errorf("full env = %v, want 100%% with 20 replicas", full)
+	}
+}
+
+func TestCanaryNotPromoted(t *testing.T) {
+	env, ran := runCanaryPlan(t, "0.2")
+	err := env.GetWorkflowError()
+	if err == nil || !strings.Contains(err.Error(), "error_rate=0.2, want error_rate < 0.01") {
+		t.Fatalf("error = %v, want the canary not promoted", err)
+	}
+	if _, ok := ran["deploy"]; ok {
+		t.Error("full run started after the canary was rejected")
+	}
+}
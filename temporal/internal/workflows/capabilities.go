@@ -0,0 +1,63 @@
+package workflows
+
+import (
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// stepActivities maps step types to the activity that runs them. Types run
+// by the workflow itself (approval, join) need none.
+var stepActivities = map[string]any{
+	"command":             activities.RunCommand,
+	"download":            activities.DownloadFile,
+	"docker_build":        activities.DockerBuild,
+	"docker_push":         activities.DockerPush,
+	"package_build":       activities.PackageBuild,
+	"container_job":       activities.ContainerJob,
+	"hf_download_dataset": activities.HFDownloadDataset,
+	"hf_download_model":   activities.HFDownloadModel,
+	"watch_path":          activities.WatchPath,
+}
+
+// ActivityName returns the activity type Temporal registers fn under, the
+// function name without its package.
+func ActivityName(fn any) string {
+	name := runtime.FuncForPC(reflect.ValueOf(fn).Pointer()).Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// RequiredActivities maps each step type of steps to the activity type a
+// worker must register to run it, plus RecordEvent, which every run uses.
+func RequiredActivities(steps []PipelineStep) map[string]string {
+	required := map[string]string{"events": ActivityName(activities.RecordEvent)}
+	for _, step := range steps {
+		if fn, ok := stepActivities[step.Type]; ok {
+			required[step.Type] = ActivityName(fn)
+		}
+	}
+	return required
+}
+
+var workerActivities []string
+
+// SetWorkerCapabilities records the activity types this worker process
+// registers, reported by WorkerCapabilities.
+func SetWorkerCapabilities(names []string) {
+	workerActivities = append([]string(nil), names...)
+	sort.Strings(workerActivities)
+}
+
+// WorkerCapabilities reports the activity types registered by the worker
+// that runs it. orchestrate starts it on a plan's task queue before
+// submitting, so a plan needing an activity no worker registers fails at
+// once rather than timing out at that step.
+func WorkerCapabilities(ctx workflow.Context) ([]string, error) {
+	return workerActivities, nil
+}
@@ -0,0 +1,46 @@
+package workflows
+
+import (
+	"slices"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestRequiredActivities(t *testing.T) {
+	if got := ActivityName(activities.HFDownloadModel); got != "HFDownloadModel" {
+		t.Errorf("ActivityName = %q", got)
+	}
+	required := RequiredActivities([]PipelineStep{
+		{ID: "a", Type: "command"},
+		{ID: "b", Type: "approval"},
+		{ID: "c", Type: "watch_path"},
+	})
+	want := map[string]string{"events": "RecordEvent", "command": "RunCommand", "watch_path": "WatchPath"}
+	if len(required) != len(want) {
+		t.Fatalf("required = %v", required)
+	}
+	for stepType, activity := range want {
+		if required[stepType] != activity {
+			t.Errorf("required[%s] = %q, want %q", stepType, required[stepType], activity)
+		}
+	}
+}
+
+func TestWorkerCapabilities(t *testing.T) {
+	SetWorkerCapabilities([]string{"RunCommand", "RecordEvent"})
+	defer SetWorkerCapabilities(nil)
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(WorkerCapabilities)
+	var registered []string
+	if err := env.GetWorkflowResult(&registered); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(registered, []string{"RecordEvent", "RunCommand"}) {
+		t.Errorf("registered = %v", registered)
+	}
+}
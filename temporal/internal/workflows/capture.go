@@ -0,0 +1,39 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// captureFailure packs a failed step's capture_on_failure paths into a failure
+// artifact on the worker that ran it. queue is that worker's queue; when it is
+// unknown the capture runs on the pipeline's queue and may land on another
+// worker. Capture is best effort: errors are logged and nil is returned.
+func captureFailure(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, queue string) *activities.FailureArtifact {
+	options := workflow.ActivityOptions{
+		StartToCloseTimeout: 30 * time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 2},
+	}
+	if queue != "" {
+		options.TaskQueue = queue
+		options.ScheduleToStartTimeout = leaseScheduleToStartTimeout
+	}
+	captureCtx := workflow.WithActivityOptions(ctx, options)
+	var artifact activities.FailureArtifact
+	err := workflow.ExecuteActivity(captureCtx, activities.CaptureFailureArtifacts, activities.CaptureFailureInput{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		StepID:     step.ID,
+		LogDir:     logDir,
+		Paths:      step.CaptureOnFailure,
+	}).Get(captureCtx, &artifact)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("failure artifact capture failed", "id", step.ID, "error", err)
+		return nil
+	}
+	return &artifact
+}
@@ -0,0 +1,232 @@
+package workflows
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// ResumeStepSignal lets a step paused by an open circuit start anyway. The
+// payload is the step ID.
+const ResumeStepSignal = "resume-step"
+
+// CircuitOpenMetric counts steps paused by an open circuit.
+const CircuitOpenMetric = "sygaldry_circuit_open"
+
+const (
+	defaultCircuitFailures    = 3
+	defaultCircuitWindowHours = 24
+	// circuitRecheck is how often a paused step looks at its circuit again,
+	// in case it opened further or was closed by a run that succeeded.
+	circuitRecheck = 15 * time.Minute
+)
+
+// CircuitBreaker pauses a run before a step whose target failed in the last
+// Failures runs that used it within WindowHours, instead of retrying against
+// a dependency that is known to be down. Targets are derived from the step
+// (see circuitKey).
+type CircuitBreaker struct {
+	Failures    int     `json:"failures" yaml:"failures"`
+	WindowHours float64 `json:"windowHours" yaml:"window_hours"`
+}
+
+func (b *CircuitBreaker) check() activities.CheckCircuitInput {
+	input := activities.CheckCircuitInput{Failures: b.Failures, WindowSec: int64(b.WindowHours * 3600)}
+	if input.Failures <= 0 {
+		input.Failures = defaultCircuitFailures
+	}
+	if input.WindowSec <= 0 {
+		input.WindowSec = defaultCircuitWindowHours * 3600
+	}
+	return input
+}
+
+// circuitKey is the target a step's failures are counted against: its
+// circuit_key, the host of a download, the registry of a pushed image or the
+// HF hub. Other steps have none.
+func circuitKey(step PipelineStep) string {
+	if step.CircuitKey != "" {
+		return step.CircuitKey
+	}
+	switch step.Type {
+	case "download":
+		if step.Download != nil {
+			if parsed, err := url.Parse(step.Download.URL); err == nil && parsed.Host != "" {
+				return "host:" + parsed.Host
+			}
+		}
+	case "docker_push":
+		if step.DockerPush != nil && step.DockerPush.Image != "" {
+			return "registry:" + imageRegistry(step.DockerPush.Image)
+		}
+	case "hf_download_dataset", "hf_download_model":
+		return "hf-hub"
+	}
+	return ""
+}
+
+// imageRegistry is the registry host of an image reference, docker.io for
+// Docker Hub images.
+func imageRegistry(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return "docker.io"
+}
+
+// circuitBook pauses steps on open circuits and collects resume-step
+// signals for them.
+type circuitBook struct {
+	breaker *CircuitBreaker
+	resumed map[string]bool
+}
+
+func newCircuitBook(ctx workflow.Context, breaker *CircuitBreaker) *circuitBook {
+	if breaker == nil {
+		return nil
+	}
+	book := &circuitBook{breaker: breaker, resumed: map[string]bool{}}
+	ch := workflow.GetSignalChannel(ctx, ResumeStepSignal)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var stepID string
+			ch.Receive(ctx, &stepID)
+			book.resumed[stepID] = true
+		}
+	})
+	return book
+}
+
+// guard starts step with start once its circuit is closed, or at once if the
+// plan has no circuit breaker or the step no target.
+func (b *circuitBook) guard(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, start func(ctx workflow.Context) workflow.Future) workflow.Future {
+	key := circuitKey(step)
+	if b == nil || key == "" {
+		return start(ctx)
+	}
+	future, settable := workflow.NewFuture(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		if err := b.wait(ctx, info, logDir, step, key); err != nil {
+			settable.SetError(err)
+			return
+		}
+		settable.Chain(start(ctx))
+	})
+	return future
+}
+
+// wait returns once the circuit of key is closed or the step is resumed by
+// signal. The first time it finds the circuit open it records a
+// circuit_open event and counts CircuitOpenMetric.
+func (b *circuitBook) wait(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, key string) error {
+	logger := workflow.GetLogger(ctx)
+	event := func(status, message string) {
+		recordEvent(ctx, logDir, activities.StepEvent{
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			StepID:     step.ID,
+			StepName:   stepName(step),
+			Status:     status,
+			Message:    message,
+		})
+	}
+	paused := false
+	for {
+		state := checkCircuit(ctx, logDir, key, b.breaker)
+		if !state.Open {
+			if paused {
+				logger.Info("circuit closed; starting step", "id", step.ID, "key", key)
+				event("circuit_closed", fmt.Sprintf("%s is no longer failing; starting %s", key, step.ID))
+			}
+			return nil
+		}
+		if !paused {
+			paused = true
+			message := fmt.Sprintf("%s failed in the last %d runs that used it (last error: %s); %s is paused until %s or a %s signal",
+				key, state.Failures, state.LastError, step.ID, state.RetryAt, ResumeStepSignal)
+			logger.Warn("circuit open; pausing step", "id", step.ID, "key", key, "failures", state.Failures, "retryAt", state.RetryAt)
+			workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"step_type": step.Type}).Counter(CircuitOpenMetric).Inc(1)
+			event("circuit_open", message)
+		}
+		timeout := circuitRecheck
+		if retryAt, err := time.Parse(time.RFC3339, state.RetryAt); err == nil {
+			timeout = min(timeout, max(retryAt.Sub(workflow.Now(ctx)), time.Second))
+		}
+		resumed, err := workflow.AwaitWithTimeout(ctx, timeout, func() bool { return b.resumed[step.ID] })
+		if err != nil {
+			return err
+		}
+		if resumed {
+			delete(b.resumed, step.ID)
+			logger.Info("step resumed by signal despite open circuit", "id", step.ID, "key", key)
+			event("circuit_resumed", fmt.Sprintf("%s started by %s signal while %s is failing", step.ID, ResumeStepSignal, key))
+			return nil
+		}
+	}
+}
+
+// checkCircuit looks up the circuit of key. A failed lookup is logged and
+// treated as closed, so a broken results store never blocks runs.
+func checkCircuit(ctx workflow.Context, logDir, key string, breaker *CircuitBreaker) activities.CircuitState {
+	checkCtx := workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+	input := breaker.check()
+	input.LogDir, input.Key = logDir, key
+	var state activities.CircuitState
+	if err := workflow.ExecuteLocalActivity(checkCtx, activities.CheckCircuit, input).Get(checkCtx, &state); err != nil {
+		workflow.GetLogger(ctx).Warn("unable to check circuit", "key", key, "error", err)
+		return activities.CircuitState{}
+	}
+	return state
+}
+
+// recordCircuits adds how the run's steps with a target ended to the circuit
+// log, whether or not the plan has a circuit breaker, so other plans' runs
+// see the failures. Skipped and cancelled steps say nothing about their
+// target and are left out. It runs as a local activity on a disconnected
+// context; failures are only logged.
+func recordCircuits(ctx workflow.Context, info *workflow.Info, logDir string, steps []PipelineStep, outcomes map[string]StepOutcome) {
+	var records []activities.CircuitRecord
+	for _, step := range steps {
+		key := circuitKey(step)
+		outcome, ok := outcomes[step.ID]
+		if key == "" || !ok || (outcome.State != "success" && outcome.State != "failed") {
+			continue
+		}
+		record := activities.CircuitRecord{
+			Key:        key,
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			StepID:     step.ID,
+			Failed:     outcome.State == "failed",
+		}
+		if record.Failed {
+			record.Error = outcome.Result.Error
+			if record.Error == "" {
+				record.Error = fmt.Sprintf("exit code %d", outcome.Result.ExitCode)
+			}
+		}
+		records = append(records, record)
+	}
+	if len(records) == 0 || !hasChange(ctx, circuitRecordsChange) {
+		return
+	}
+	recordCtx, _ := workflow.NewDisconnectedContext(ctx)
+	recordCtx = workflow.WithLocalActivityOptions(recordCtx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+	err := workflow.ExecuteLocalActivity(recordCtx, activities.RecordCircuits, activities.RecordCircuitsInput{
+		LogDir:  logDir,
+		Records: records,
+	}).Get(recordCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to record circuit outcomes", "error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package workflows
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestCircuitKey(t *testing.T) {
+	tests := []struct {
+		step PipelineStep
+		want string
+	}{
+		{PipelineStep{Type: "download", Download: &DownloadSpec{URL: "https://data.example.com/x.csv"}}, "host:data.example.com"},
+		{PipelineStep{Type: "docker_push", DockerPush: &DockerPushSpec{Image: "ghcr.io/org/app:1"}}, "registry:ghcr.io"},
+		{PipelineStep{Type: "docker_push", DockerPush: &DockerPushSpec{Image: "localhost:5000/app"}}, "registry:localhost:5000"},
+		{PipelineStep{Type: "docker_push", DockerPush: &DockerPushSpec{Image: "org/app"}}, "registry:docker.io"},
+		{PipelineStep{Type: "hf_download_model"}, "hf-hub"},
+		{PipelineStep{Type: "command", Command: "make"}, ""},
+		{PipelineStep{Type: "command", Command: "deploy", CircuitKey: "internal-api"}, "internal-api"},
+	}
+	for _, tt := range tests {
+		if got := circuitKey(tt.step); got != tt.want {
+			t.Errorf("circuitKey(%s) = %q, want %q", tt.step.Type, got, tt.want)
+		}
+	}
+}
+
+func TestOpenCircuitPausesStepUntilResumed(t *testing.T) {
+	logDir := t.TempDir()
+	for _, run := range []string{"r1", "r2", "r3"} {
+		activities.RecordCircuits(context.Background(), activities.RecordCircuitsInput{LogDir: logDir, Records: []activities.CircuitRecord{
+			{Key: "registry:ghcr.io", WorkflowID: "nightly", RunID: run, StepID: "push", Failed: true, Error: "503 Service Unavailable"},
+		}})
+	}
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var pushedAt time.Time
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.DockerPushInput) (activities.RunCommandResult, error) {
+		pushedAt = env.Now()
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "DockerPush"})
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ResumeStepSignal, "push")
+	}, 2*time.Hour)
+
+	start := env.Now()
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:         logDir,
+		CircuitBreaker: &CircuitBreaker{Failures: 3},
+		Steps: []PipelineStep{
+			{ID: "build", Type: "command", Command: "make"},
+			{ID: "push", Type: "docker_push", DependsOn: []string{"build"}, DockerPush: &DockerPushSpec{Image: "ghcr.io/org/app:1"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if waited := pushedAt.Sub(start); waited < 2*time.Hour {
+		t.Errorf("push started after %s, want it paused until the resume signal", waited)
+	}
+	events, _ := os.ReadFile(filepath.Join(logDir, "events.jsonl"))
+	for _, status := range []string{"circuit_open", "circuit_resumed"} {
+		if !strings.Contains(string(events), `"status":"`+status+`"`) {
+			t.Errorf("no %s event in:\n%s", status, events)
+		}
+	}
+	// The successful push closes the circuit for later runs.
+	state, err := activities.CheckCircuit(context.Background(), activities.CheckCircuitInput{LogDir: logDir, Key: "registry:ghcr.io", Failures: 3, WindowSec: 3600})
+	if err != nil || state.Open {
+		t.Errorf("circuit after a successful push = %+v, %v", state, err)
+	}
+}
+
+func TestClosedCircuitDoesNotPause(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.DockerPushInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{ExitCode: 1}, nil
+	}, activity.RegisterOptions{Name: "DockerPush"})
+
+	logDir := t.TempDir()
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:         logDir,
+		CircuitBreaker: &CircuitBreaker{},
+		Steps:          []PipelineStep{{ID: "push", Type: "docker_push", DockerPush: &DockerPushSpec{Image: "ghcr.io/org/app:1"}}},
+	})
+	if err := env.GetWorkflowError(); err == nil {
+		t.Fatal("expected the failed push to fail the run")
+	}
+	state, _ := activities.CheckCircuit(context.Background(), activities.CheckCircuitInput{LogDir: logDir, Key: "registry:ghcr.io", Failures: 1, WindowSec: 3600})
+	if state.Failures != 1 || state.LastError != "exit code 1" {
+		t.Errorf("recorded failure = %+v", state)
+	}
+}
@@ -0,0 +1,111 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// collectScheduleToStart bounds how long collection waits for a worker that
+// ran steps to pick up its collector; a worker gone by then is reported as
+// missing.
+const collectScheduleToStart = 2 * time.Minute
+
+// LogCollection is where the logs of a run were gathered when its plan sets
+// collect_logs.
+type LogCollection struct {
+	Manifest string `json:"manifest,omitempty"`
+	Files    int    `json:"files"`
+	// Missing lists workers whose files could not be collected.
+	Missing []string `json:"missing,omitempty"`
+}
+
+// collectLogs gathers the log files and events of the run from every worker
+// that took part into destination, and writes a manifest next to them. The
+// worker running the pipeline collects first, through a local activity; then
+// each worker that ran a step collects on its own worker queue. A worker that
+// is gone or fails is listed as missing rather than failing the run. It uses
+// a disconnected context so cancelled runs are collected too.
+func collectLogs(ctx workflow.Context, info *workflow.Info, logDir, destination string, outcomes map[string]StepOutcome) *LogCollection {
+	logger := workflow.GetLogger(ctx)
+	collectCtx, _ := workflow.NewDisconnectedContext(ctx)
+	input := activities.CollectRunLogsInput{
+		LogDir:      logDir,
+		Destination: destination,
+		WorkflowID:  info.WorkflowExecution.ID,
+		RunID:       info.WorkflowExecution.RunID,
+	}
+	manifest := activities.RunLogManifest{WorkflowID: input.WorkflowID, RunID: input.RunID}
+	collected := func(result activities.CollectRunLogsResult) {
+		manifest.Workers = append(manifest.Workers, result.Worker)
+		manifest.Files = append(manifest.Files, result.Files...)
+		if result.Remote != "" {
+			manifest.Remote = append(manifest.Remote, result.Remote)
+		}
+	}
+
+	localCtx := workflow.WithLocalActivityOptions(collectCtx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 2},
+	})
+	var local activities.CollectRunLogsResult
+	if err := workflow.ExecuteLocalActivity(localCtx, activities.CollectRunLogs, input).Get(localCtx, &local); err != nil {
+		logger.Warn("unable to collect the pipeline worker's logs", "error", err)
+		manifest.Missing = append(manifest.Missing, fmt.Sprintf("pipeline worker: %v", err))
+	} else {
+		collected(local)
+	}
+
+	queues := map[string]bool{}
+	for _, outcome := range outcomes {
+		queues[outcome.Result.WorkerQueue] = true
+		if outcome.FailureArtifact != nil {
+			queues[outcome.FailureArtifact.WorkerQueue] = true
+		}
+	}
+	delete(queues, "")
+	delete(queues, local.Worker)
+	sorted := make([]string, 0, len(queues))
+	for queue := range queues {
+		sorted = append(sorted, queue)
+	}
+	sort.Strings(sorted)
+	futures := make([]workflow.Future, len(sorted))
+	for i, queue := range sorted {
+		queueCtx := workflow.WithActivityOptions(collectCtx, workflow.ActivityOptions{
+			TaskQueue:              queue,
+			ScheduleToStartTimeout: collectScheduleToStart,
+			StartToCloseTimeout:    10 * time.Minute,
+			RetryPolicy:            &temporal.RetryPolicy{MaximumAttempts: 2},
+		})
+		futures[i] = workflow.ExecuteActivity(queueCtx, activities.CollectRunLogs, input)
+	}
+	for i, future := range futures {
+		var result activities.CollectRunLogsResult
+		if err := future.Get(collectCtx, &result); err != nil {
+			logger.Warn("unable to collect a worker's logs", "queue", sorted[i], "error", err)
+			manifest.Missing = append(manifest.Missing, fmt.Sprintf("%s: %v", sorted[i], err))
+			continue
+		}
+		collected(result)
+	}
+
+	collection := &LogCollection{Files: len(manifest.Files), Missing: manifest.Missing}
+	writeCtx := workflow.WithActivityOptions(collectCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	err := workflow.ExecuteActivity(writeCtx, activities.WriteRunLogManifest, activities.WriteRunLogManifestInput{
+		Destination: destination,
+		Manifest:    manifest,
+	}).Get(writeCtx, &collection.Manifest)
+	if err != nil {
+		logger.Warn("unable to write the log manifest", "destination", destination, "error", err)
+	}
+	return collection
+}
@@ -0,0 +1,73 @@
+package workflows
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestPipelineCollectsLogsFromEveryWorker(t *testing.T) {
+	activities.SetWorkerQueue("orchestration-host1")
+	t.Cleanup(func() { activities.SetWorkerQueue("") })
+	logDir, dest := t.TempDir(), t.TempDir()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		queue := map[string]string{"train": "gpu-1", "eval": "gpu-2"}[input.StepID]
+		return activities.RunCommandResult{WorkerQueue: queue}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	var collectedFrom []string
+	var runDir string
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.CollectRunLogsInput) (activities.CollectRunLogsResult, error) {
+		queue := activity.GetInfo(ctx).TaskQueue
+		collectedFrom = append(collectedFrom, queue)
+		runDir = activities.RunLogsDir(input.WorkflowID, input.RunID)
+		if queue == "gpu-2" {
+			return activities.CollectRunLogsResult{}, errors.New("worker is gone")
+		}
+		return activities.CollectRunLogsResult{Worker: queue, Files: []activities.CollectedFile{{Name: queue + "/train.log", Worker: queue}}}, nil
+	}, activity.RegisterOptions{Name: "CollectRunLogs"})
+	env.RegisterActivity(activities.WriteRunLogManifest)
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:      logDir,
+		CollectLogs: dest,
+		Steps: []PipelineStep{
+			{ID: "train", Type: "command", Command: "train.sh"},
+			{ID: "eval", Type: "command", Command: "eval.sh", DependsOn: []string{"train"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Logs == nil {
+		t.Fatal("no log collection in the result")
+	}
+	if want := filepath.Join(dest, runDir, "manifest.json"); result.Logs.Manifest != want {
+		t.Errorf("manifest = %q, want %q", result.Logs.Manifest, want)
+	}
+	if _, err := os.Stat(result.Logs.Manifest); err != nil {
+		t.Error(err)
+	}
+	// The pipeline worker collects its own events through a local activity;
+	// each step worker is asked on its own queue, and gpu-2 once more after
+	// it fails.
+	if got := strings.Join(collectedFrom, " "); got != "gpu-1 gpu-2 gpu-2" {
+		t.Errorf("collected from queues %s, want gpu-1 gpu-2 gpu-2", got)
+	}
+	if result.Logs.Files < 2 || len(result.Logs.Missing) != 1 {
+		t.Errorf("collection = %+v, want the pipeline worker's events, gpu-1's log and gpu-2 missing", result.Logs)
+	}
+}
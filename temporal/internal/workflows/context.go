@@ -0,0 +1,43 @@
+package workflows
+
+import (
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// ContextQuery returns a run's context values. With a key it returns just
+// that value and fails if the key is unset; with "" it returns them all.
+const ContextQuery = "run_context"
+
+// runContext is a run's key/value context: the outputs of its finished steps
+// as "<step-id>.<key>". External tools read it with ContextQuery instead of
+// parsing the run's result.
+type runContext struct {
+	values map[string]string
+}
+
+func newRunContext(ctx workflow.Context) *runContext {
+	runCtx := &runContext{values: map[string]string{}}
+	err := workflow.SetQueryHandler(ctx, ContextQuery, func(key string) (map[string]string, error) {
+		if key == "" {
+			return runCtx.values, nil
+		}
+		value, ok := runCtx.values[key]
+		if !ok {
+			return nil, fmt.Errorf("no context value %s", key)
+		}
+		return map[string]string{key: value}, nil
+	})
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to register context query", "error", err)
+	}
+	return runCtx
+}
+
+// record adds the outputs of a finished step.
+func (c *runContext) record(stepID string, outputs map[string]string) {
+	for key, value := range outputs {
+		c.values[stepID+"."+key] = value
+	}
+}
@@ -0,0 +1,57 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestRunContextQuery(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		if input.StepID == "train" {
+			return activities.RunCommandResult{Outputs: map[string]string{"checkpoint": "s3://ckpt/42", "loss": "0.31"}}, nil
+		}
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "train", Type: "command", Command: "train"},
+			{ID: "eval", Type: "command", Command: "eval", DependsOn: []string{"train"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := env.QueryWorkflow(ContextQuery, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all map[string]string
+	if err := value.Get(&all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || all["train.checkpoint"] != "s3://ckpt/42" || all["train.loss"] != "0.31" {
+		t.Errorf("context = %v", all)
+	}
+
+	value, err = env.QueryWorkflow(ContextQuery, "train.checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var one map[string]string
+	if err := value.Get(&one); err != nil || len(one) != 1 || one["train.checkpoint"] != "s3://ckpt/42" {
+		t.Errorf("train.checkpoint = %v, %v", one, err)
+	}
+	if _, err := env.QueryWorkflow(ContextQuery, "eval.score"); err == nil {
+		t.Error("expected an error for an unset key")
+	}
+}
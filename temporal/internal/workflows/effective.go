@@ -0,0 +1,182 @@
+package workflows
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// RedactedValue replaces secret values in an effective plan.
+const RedactedValue = "[redacted]"
+
+// secretNameParts mark env vars, build args and params whose values are
+// secrets, matched against the upper-cased name.
+var secretNameParts = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "CREDENTIAL", "API_KEY", "APIKEY", "PRIVATE_KEY", "ACCESS_KEY"}
+
+// EffectivePlan is the plan as a run executed it: params after defaults and
+// carried outputs, canaries expanded, locales inherited, docker_build specs
+// as their templates resolved, and secrets redacted. Path is its copy next to
+// the run's logs.
+type EffectivePlan struct {
+	Path   string        `json:"path,omitempty"`
+	Sha256 string        `json:"sha256,omitempty"`
+	Plan   PipelineInput `json:"plan"`
+}
+
+// resolvedStep is step as it is launched, with its templates expanded. A
+// step whose templates do not resolve is kept as written.
+func resolvedStep(step PipelineStep, params map[string]string, outcomes map[string]StepOutcome) PipelineStep {
+	if step.Type == "docker_build" && step.DockerBuild != nil {
+		if spec, err := resolveDockerBuild(*step.DockerBuild, params, outcomes); err == nil {
+			step.DockerBuild = &spec
+		}
+	}
+	return step
+}
+
+// effectivePlan is the redacted plan of a run. executed holds the steps that
+// were started, as resolvedStep returned them; the others are as planned.
+func effectivePlan(input PipelineInput, logDir string, params map[string]string, executed map[string]PipelineStep) PipelineInput {
+	plan := input
+	plan.LogDir = logDir
+	if plan.ExecutionMode == "" {
+		plan.ExecutionMode = ExecutionAtLeastOnce
+	}
+	plan.Params = params
+	plan.Steps = make([]PipelineStep, len(input.Steps))
+	for i, step := range input.Steps {
+		if resolved, ok := executed[step.ID]; ok {
+			step = resolved
+		}
+		step.Name = stepName(step)
+		plan.Steps[i] = step
+	}
+	return redactPlan(plan)
+}
+
+// redactPlan returns a copy of plan with sealed values, values of secret
+// looking names, URL passwords and webhook URLs replaced by RedactedValue.
+// Only the names of secret envs are kept, as in the plan.
+func redactPlan(plan PipelineInput) PipelineInput {
+	plan.Params = redactValues(plan.Params)
+	plan.Parameters = slices.Clone(plan.Parameters)
+	for i, spec := range plan.Parameters {
+		if isSecretName(spec.Name) || activities.IsSealed(spec.Default) {
+			plan.Parameters[i].Default = RedactedValue
+		}
+	}
+	plan.Webhooks = slices.Clone(plan.Webhooks)
+	for i, hook := range plan.Webhooks {
+		// Webhook URLs commonly carry their token in the path or query.
+		if u, err := url.Parse(hook.URL); err == nil && u.Host != "" {
+			plan.Webhooks[i].URL = u.Scheme + "://" + u.Host + "/" + RedactedValue
+		} else {
+			plan.Webhooks[i].URL = RedactedValue
+		}
+	}
+	plan.Steps = slices.Clone(plan.Steps)
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		step.Env = redactValues(step.Env)
+		if step.Download != nil {
+			download := *step.Download
+			download.URL = redactURL(download.URL)
+			step.Download = &download
+		}
+		if step.DockerBuild != nil {
+			build := *step.DockerBuild
+			build.BuildArgs = redactValues(build.BuildArgs)
+			step.DockerBuild = &build
+		}
+		if step.PackageBuild != nil {
+			build := *step.PackageBuild
+			build.Env = redactValues(build.Env)
+			step.PackageBuild = &build
+		}
+		if step.ContainerJob != nil {
+			job := *step.ContainerJob
+			job.Env = redactValues(job.Env)
+			step.ContainerJob = &job
+		}
+		if step.Canary != nil {
+			canary := *step.Canary
+			canary.ParamOverrides = redactValues(canary.ParamOverrides)
+			step.Canary = &canary
+		}
+	}
+	return plan
+}
+
+func redactValues(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(values))
+	for name, value := range values {
+		if isSecretName(name) || activities.IsSealed(value) {
+			value = RedactedValue
+		}
+		redacted[name] = value
+	}
+	return redacted
+}
+
+func isSecretName(name string) bool {
+	name = strings.ToUpper(name)
+	for _, part := range secretNameParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL hides the password of a URL with one.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// recordEffectivePlan writes plan next to the run's logs through a local
+// activity on the pipeline's worker. The plan is returned for the result
+// even when it cannot be written.
+func recordEffectivePlan(ctx workflow.Context, info *workflow.Info, logDir string, plan PipelineInput) *EffectivePlan {
+	effective := &EffectivePlan{Plan: plan}
+	if !hasChange(ctx, effectivePlanChange) {
+		return effective
+	}
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to encode the effective plan", "error", err)
+		return effective
+	}
+	writeCtx, _ := workflow.NewDisconnectedContext(ctx)
+	writeCtx = workflow.WithLocalActivityOptions(writeCtx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+	})
+	var written activities.WriteEffectivePlanResult
+	err = workflow.ExecuteLocalActivity(writeCtx, activities.WriteEffectivePlan, activities.WriteEffectivePlanInput{
+		LogDir:     logDir,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Plan:       append(data, '\n'),
+	}).Get(writeCtx, &written)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to write the effective plan", "error", err)
+		return effective
+	}
+	effective.Path, effective.Sha256 = written.Path, written.Sha256
+	return effective
+}
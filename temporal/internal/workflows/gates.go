@@ -0,0 +1,170 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// ApproveStepSignal resolves a waiting approval step. The payload is an
+// ApprovalDecision.
+const ApproveStepSignal = "approve-step"
+
+// ApprovalTimeoutError is the application error type of a gate that the idle
+// policy gave up on.
+const ApprovalTimeoutError = "ApprovalTimeout"
+
+type ApprovalSpec struct {
+	Prompt string `json:"prompt" yaml:"prompt"`
+}
+
+type ApprovalDecision struct {
+	StepID   string `json:"stepId"`
+	Approved bool   `json:"approved"`
+	Actor    string `json:"actor"`
+	Comment  string `json:"comment"`
+}
+
+// IdlePolicy ends a run that has been blocked on an approval gate for too
+// long, so forgotten runs do not hold leases forever.
+type IdlePolicy struct {
+	TimeoutHours float64 `json:"timeoutHours" yaml:"timeout_hours"`
+	// Action is "cancel" (default) or "notify_then_cancel".
+	Action     string  `json:"action" yaml:"action"`
+	GraceHours float64 `json:"graceHours" yaml:"grace_hours"`
+}
+
+// gateBook collects approve-step signals for the approval steps of a plan.
+// A decision sent before its gate starts waiting is kept until it does.
+type gateBook struct {
+	gates     map[string]bool
+	waiting   map[string]bool
+	decisions map[string]ApprovalDecision
+	// cancelRun cancels the run's steps when the idle policy gives up on a
+	// gate, and timedOut is the ApprovalTimeout error it gave up with.
+	cancelRun workflow.CancelFunc
+	timedOut  error
+}
+
+func newGateBook(ctx workflow.Context, steps []PipelineStep, cancelRun workflow.CancelFunc) *gateBook {
+	gates := &gateBook{gates: map[string]bool{}, waiting: map[string]bool{}, decisions: map[string]ApprovalDecision{}, cancelRun: cancelRun}
+	for _, step := range steps {
+		if step.Type == "approval" {
+			gates.gates[step.ID] = true
+		}
+	}
+	ch := workflow.GetSignalChannel(ctx, ApproveStepSignal)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			var decision ApprovalDecision
+			ch.Receive(ctx, &decision)
+			if !gates.gates[decision.StepID] {
+				workflow.GetLogger(ctx).Warn("ignoring decision for step that is not an approval step", "id", decision.StepID)
+				continue
+			}
+			if !gates.waiting[decision.StepID] {
+				workflow.GetLogger(ctx).Info("keeping decision until the step awaits approval", "id", decision.StepID)
+			}
+			gates.decisions[decision.StepID] = decision
+		}
+	})
+	return gates
+}
+
+// await returns a future that resolves with the approval outcome of step as a
+// command result: exit code 0 when approved, 1 when rejected. When the idle
+// policy expires the run's steps are cancelled and the future fails with an
+// ApprovalTimeout application error.
+func (g *gateBook) await(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, policy *IdlePolicy) workflow.Future {
+	future, settable := workflow.NewFuture(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		settable.Set(g.wait(ctx, info, logDir, step, policy))
+	})
+	return future
+}
+
+func (g *gateBook) wait(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, policy *IdlePolicy) (activities.RunCommandResult, error) {
+	logger := workflow.GetLogger(ctx)
+	g.waiting[step.ID] = true
+	defer delete(g.waiting, step.ID)
+	prompt := ""
+	if step.Approval != nil {
+		prompt = step.Approval.Prompt
+	}
+	logger.Info("waiting for approval", "id", step.ID, "prompt", prompt)
+
+	decided := func() bool {
+		_, ok := g.decisions[step.ID]
+		return ok
+	}
+	if policy == nil || policy.TimeoutHours <= 0 {
+		if err := workflow.Await(ctx, decided); err != nil {
+			return activities.RunCommandResult{ExitCode: -1}, err
+		}
+	} else {
+		ok, err := workflow.AwaitWithTimeout(ctx, hours(policy.TimeoutHours), decided)
+		if err != nil {
+			return activities.RunCommandResult{ExitCode: -1}, err
+		}
+		if !ok && policy.Action == "notify_then_cancel" {
+			message := fmt.Sprintf("waiting for approval for %.1fh; cancelling in %.1fh", policy.TimeoutHours, policy.GraceHours)
+			logger.Warn("pipeline idle", "id", step.ID, "message", message)
+			recordEvent(ctx, logDir, activities.StepEvent{
+				WorkflowID: info.WorkflowExecution.ID,
+				RunID:      info.WorkflowExecution.RunID,
+				StepID:     step.ID,
+				StepName:   stepName(step),
+				Status:     "pipeline_idle_warning",
+				Message:    message,
+			})
+			ok, err = workflow.AwaitWithTimeout(ctx, hours(policy.GraceHours), decided)
+			if err != nil {
+				return activities.RunCommandResult{ExitCode: -1}, err
+			}
+		}
+		if !ok {
+			g.timedOut = temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("idle policy: step %s waited for approval longer than allowed", step.ID), ApprovalTimeoutError, nil)
+			logger.Warn("cancelling idle run", "id", step.ID)
+			g.cancelRun()
+			return activities.RunCommandResult{ExitCode: -1}, g.timedOut
+		}
+	}
+
+	decision := g.decisions[step.ID]
+	delete(g.decisions, step.ID)
+	verdict := "approved"
+	code := 0
+	if !decision.Approved {
+		verdict = "rejected"
+		code = 1
+	}
+	stdout := fmt.Sprintf("%s by %s", verdict, decision.Actor)
+	if decision.Comment != "" {
+		stdout += ": " + decision.Comment
+	}
+	return activities.RunCommandResult{ExitCode: code, Stdout: stdout + "\n"}, nil
+}
+
+// recordEvent appends a workflow-level event to events.jsonl via a local
+// activity. Failures are logged and otherwise ignored.
+func recordEvent(ctx workflow.Context, logDir string, event activities.StepEvent) {
+	eventCtx := workflow.WithLocalActivityOptions(ctx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+	err := workflow.ExecuteLocalActivity(eventCtx, activities.RecordEvent, activities.RecordEventInput{
+		LogDir: logDir,
+		Event:  event,
+	}).Get(eventCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("failed to record event", "status", event.Status, "error", err)
+	}
+}
+
+func hours(value float64) time.Duration {
+	return time.Duration(value * float64(time.Hour))
+}
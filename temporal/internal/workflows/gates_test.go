@@ -0,0 +1,172 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func gatePlan(dir string, policy *IdlePolicy) PipelineInput {
+	return PipelineInput{
+		LogDir:     dir,
+		IdlePolicy: policy,
+		Steps: []PipelineStep{
+			{ID: "deploy-gate", Type: "approval", Approval: &ApprovalSpec{Prompt: "ship it?"}},
+		},
+	}
+}
+
+func TestApprovalGateApproved(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ApproveStepSignal, ApprovalDecision{StepID: "deploy-gate", Approved: true, Actor: "alice"})
+	}, time.Hour)
+
+	env.ExecuteWorkflow(Pipeline, gatePlan(t.TempDir(), nil))
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	if !result.Succeeded || result.Steps[0].State != "success" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Steps[0].Result.Stdout != "approved by alice\n" {
+		t.Errorf("stdout = %q", result.Steps[0].Result.Stdout)
+	}
+}
+
+func TestApprovalGateRejected(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ApproveStepSignal, ApprovalDecision{StepID: "deploy-gate", Approved: false, Actor: "bob", Comment: "not today"})
+	}, time.Minute)
+
+	env.ExecuteWorkflow(Pipeline, gatePlan(t.TempDir(), nil))
+
+	if err := env.GetWorkflowError(); err == nil {
+		t.Fatal("expected rejected gate to fail the pipeline")
+	}
+}
+
+func TestApprovalGateEarlyDecision(t *testing.T) {
+	plan := gatePlan(t.TempDir(), nil)
+	plan.Steps = []PipelineStep{
+		{ID: "build", Command: "make"},
+		{ID: "deploy-gate", Type: "approval", DependsOn: []string{"build"}},
+	}
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		// The gate is approved while the step before it still runs.
+		env.SignalWorkflow(ApproveStepSignal, ApprovalDecision{StepID: "deploy-gate", Approved: true, Actor: "dave"})
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, plan)
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	if !result.Succeeded || result.Steps[1].Result.Stdout != "approved by dave\n" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+}
+
+func TestIdlePolicyTimesOut(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	env.ExecuteWorkflow(Pipeline, gatePlan(t.TempDir(), &IdlePolicy{TimeoutHours: 2}))
+
+	err := env.GetWorkflowError()
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != ApprovalTimeoutError {
+		t.Fatalf("expected %s error, got %v", ApprovalTimeoutError, err)
+	}
+}
+
+func TestIdlePolicyNotifyThenCancel(t *testing.T) {
+	dir := t.TempDir()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ApproveStepSignal, ApprovalDecision{StepID: "deploy-gate", Approved: true, Actor: "carol"})
+	}, 90*time.Minute)
+
+	env.ExecuteWorkflow(Pipeline, gatePlan(dir, &IdlePolicy{TimeoutHours: 1, Action: "notify_then_cancel", GraceHours: 1}))
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("approval within the grace period should succeed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"status":"pipeline_idle_warning"`) {
+		t.Errorf("missing idle warning event: %s", data)
+	}
+}
+
+func TestIdlePolicyTimeoutCancelsRun(t *testing.T) {
+	logDir := t.TempDir()
+	plan := gatePlan(logDir, &IdlePolicy{TimeoutHours: 2})
+	plan.Steps = []PipelineStep{
+		{ID: "deploy-gate", Type: "approval", AllowFailure: true},
+		{ID: "soak", Command: "sleep infinity"},
+	}
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	// The parallel step outlives the gate's idle timeout on the workflow clock.
+	env.OnActivity("RunCommand", mock.Anything, mock.Anything).After(24*time.Hour).Return(activities.RunCommandResult{}, nil)
+
+	env.ExecuteWorkflow(Pipeline, plan)
+
+	err := env.GetWorkflowError()
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || appErr.Type() != ApprovalTimeoutError {
+		t.Fatalf("expected %s error, got %v", ApprovalTimeoutError, err)
+	}
+	runs, err := activities.ReadRunIndex(activities.RunIndexPath(logDir))
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	archived, err := activities.ReadArchivedRun(activities.ArchivedRunPath(logDir, runs[0].WorkflowID, runs[0].RunID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived.Status != StatusCancelled {
+		t.Fatalf("status = %q, want %q", archived.Status, StatusCancelled)
+	}
+	var result PipelineResult
+	if err := json.Unmarshal(archived.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	states := map[string]string{}
+	for _, step := range result.Steps {
+		states[step.ID] = step.State
+	}
+	if states["deploy-gate"] != "cancelled" || states["soak"] != "cancelled" {
+		t.Errorf("step states = %v", states)
+	}
+}
@@ -0,0 +1,164 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// GoldenPolicy tunes how a named plan's runs are compared with its golden
+// baseline. DurationTolerance is the allowed relative change in a step's
+// duration (default 0.5); changes under goldenMinDurationDelta are ignored.
+// IgnoreOutputs lists step output keys expected to differ between runs.
+type GoldenPolicy struct {
+	DurationTolerance float64  `json:"durationTolerance" yaml:"duration_tolerance"`
+	IgnoreOutputs     []string `json:"ignoreOutputs" yaml:"ignore_outputs"`
+}
+
+const (
+	defaultGoldenDurationTolerance = 0.5
+	goldenMinDurationDelta         = 10
+)
+
+// BaselineComparison lists how a run deviates from its plan's golden run.
+type BaselineComparison struct {
+	Plan           string      `json:"plan"`
+	GoldenWorkflow string      `json:"goldenWorkflowId"`
+	GoldenRun      string      `json:"goldenRunId"`
+	Deviations     []Deviation `json:"deviations"`
+}
+
+// Deviation is one difference from the golden run. Kind is one of state,
+// duration, output, digest, missing_step or new_step.
+type Deviation struct {
+	StepID  string `json:"stepId"`
+	Kind    string `json:"kind"`
+	Key     string `json:"key,omitempty"`
+	Golden  string `json:"golden"`
+	Current string `json:"current"`
+}
+
+// GoldenFromResult builds the baseline that pins result as plan's golden run.
+func GoldenFromResult(plan, workflowID, runID string, result PipelineResult) activities.GoldenBaseline {
+	baseline := activities.GoldenBaseline{
+		Plan:       plan,
+		WorkflowID: workflowID,
+		RunID:      runID,
+		Steps:      map[string]activities.GoldenStep{},
+	}
+	for _, step := range result.Steps {
+		baseline.Steps[step.ID] = activities.GoldenStep{
+			State:       step.State,
+			DurationSec: step.Result.DurationSec,
+			Outputs:     step.Result.Outputs,
+			Digests:     step.Result.Digests,
+		}
+	}
+	return baseline
+}
+
+// loadGolden fetches the plan's golden baseline, if one is pinned. A failed
+// lookup is logged and skips the comparison.
+func loadGolden(ctx workflow.Context, logDir, plan string) *activities.GoldenBaseline {
+	loadCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	var result activities.LoadGoldenResult
+	err := workflow.ExecuteActivity(loadCtx, activities.LoadGoldenBaseline, activities.LoadGoldenInput{
+		LogDir: logDir,
+		Plan:   plan,
+	}).Get(loadCtx, &result)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to load golden baseline; skipping comparison", "plan", plan, "error", err)
+		return nil
+	}
+	if !result.Found {
+		return nil
+	}
+	return &result.Baseline
+}
+
+// compareToGolden reports every deviation of steps from baseline.
+func compareToGolden(baseline activities.GoldenBaseline, steps []StepOutcome, policy *GoldenPolicy) *BaselineComparison {
+	tolerance := defaultGoldenDurationTolerance
+	ignored := map[string]bool{}
+	if policy != nil {
+		if policy.DurationTolerance > 0 {
+			tolerance = policy.DurationTolerance
+		}
+		for _, key := range policy.IgnoreOutputs {
+			ignored[key] = true
+		}
+	}
+	comparison := &BaselineComparison{
+		Plan:           baseline.Plan,
+		GoldenWorkflow: baseline.WorkflowID,
+		GoldenRun:      baseline.RunID,
+		Deviations:     []Deviation{},
+	}
+	add := func(stepID, kind, key, golden, current string) {
+		comparison.Deviations = append(comparison.Deviations, Deviation{StepID: stepID, Kind: kind, Key: key, Golden: golden, Current: current})
+	}
+
+	seen := map[string]bool{}
+	for _, step := range steps {
+		seen[step.ID] = true
+		golden, ok := baseline.Steps[step.ID]
+		if !ok {
+			add(step.ID, "new_step", "", "", step.State)
+			continue
+		}
+		if step.State != golden.State {
+			add(step.ID, "state", "", golden.State, step.State)
+			continue
+		}
+		delta := step.Result.DurationSec - golden.DurationSec
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta >= goldenMinDurationDelta && float64(delta) > tolerance*float64(golden.DurationSec) {
+			add(step.ID, "duration", "", fmt.Sprintf("%ds", golden.DurationSec), fmt.Sprintf("%ds", step.Result.DurationSec))
+		}
+		for _, key := range unionKeys(golden.Outputs, step.Result.Outputs) {
+			if !ignored[key] && golden.Outputs[key] != step.Result.Outputs[key] {
+				add(step.ID, "output", key, golden.Outputs[key], step.Result.Outputs[key])
+			}
+		}
+		for _, key := range unionKeys(golden.Digests, step.Result.Digests) {
+			if golden.Digests[key] != step.Result.Digests[key] {
+				add(step.ID, "digest", key, golden.Digests[key], step.Result.Digests[key])
+			}
+		}
+	}
+	missing := make([]string, 0)
+	for id := range baseline.Steps {
+		if !seen[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Strings(missing)
+	for _, id := range missing {
+		add(id, "missing_step", "", baseline.Steps[id].State, "")
+	}
+	return comparison
+}
+
+func unionKeys(a, b map[string]string) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package workflows
+
+import (
+	"reflect"
+	"testing"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestCompareToGolden(t *testing.T) {
+	baseline := activities.GoldenBaseline{
+		Plan:  "release",
+		RunID: "golden-run",
+		Steps: map[string]activities.GoldenStep{
+			"build":   {State: "success", DurationSec: 100, Outputs: map[string]string{"image": "app@sha256:aa", "built_at": "mon"}},
+			"fetch":   {State: "success", DurationSec: 5, Digests: map[string]string{"/data/x": "abc"}},
+			"test":    {State: "success", DurationSec: 20},
+			"publish": {State: "success", DurationSec: 3},
+		},
+	}
+	steps := []StepOutcome{
+		{ID: "build", State: "success", Result: PipelineStepResult{DurationSec: 140, Outputs: map[string]string{"image": "app@sha256:bb", "built_at": "tue"}}},
+		{ID: "fetch", State: "success", Result: PipelineStepResult{DurationSec: 9, Digests: map[string]string{"/data/x": "abd"}}},
+		{ID: "test", State: "failed", Result: PipelineStepResult{DurationSec: 90}},
+		{ID: "lint", State: "success"},
+	}
+	got := compareToGolden(baseline, steps, &GoldenPolicy{DurationTolerance: 0.3, IgnoreOutputs: []string{"built_at"}})
+	want := []Deviation{
+		{StepID: "build", Kind: "duration", Golden: "100s", Current: "140s"},
+		{StepID: "build", Kind: "output", Key: "image", Golden: "app@sha256:aa", Current: "app@sha256:bb"},
+		{StepID: "fetch", Kind: "digest", Key: "/data/x", Golden: "abc", Current: "abd"},
+		{StepID: "test", Kind: "state", Golden: "success", Current: "failed"},
+		{StepID: "lint", Kind: "new_step", Current: "success"},
+		{StepID: "publish", Kind: "missing_step", Golden: "success"},
+	}
+	if !reflect.DeepEqual(got.Deviations, want) {
+		t.Errorf("deviations =\n%+v\nwant\n%+v", got.Deviations, want)
+	}
+	if got.GoldenRun != "golden-run" || got.Plan != "release" {
+		t.Errorf("comparison header = %+v", got)
+	}
+
+	// Within the default 50% tolerance, and the 4s change in fetch is below
+	// the minimum delta.
+	same := compareToGolden(baseline, []StepOutcome{
+		{ID: "build", State: "success", Result: PipelineStepResult{DurationSec: 140, Outputs: baseline.Steps["build"].Outputs}},
+		{ID: "fetch", State: "success", Result: PipelineStepResult{DurationSec: 9, Digests: baseline.Steps["fetch"].Digests}},
+		{ID: "test", State: "success", Result: PipelineStepResult{DurationSec: 20}},
+		{ID: "publish", State: "success", Result: PipelineStepResult{DurationSec: 3}},
+	}, nil)
+	if len(same.Deviations) != 0 {
+		t.Errorf("unexpected deviations: %+v", same.Deviations)
+	}
+}
+
+func TestGoldenFromResult(t *testing.T) {
+	baseline := GoldenFromResult("release", "wf", "run", PipelineResult{Steps: []StepOutcome{
+		{ID: "build", State: "success", Result: PipelineStepResult{DurationSec: 7, Outputs: map[string]string{"k": "v"}}},
+	}})
+	step := baseline.Steps["build"]
+	if baseline.Plan != "release" || baseline.RunID != "run" || step.State != "success" || step.DurationSec != 7 || step.Outputs["k"] != "v" {
+		t.Errorf("baseline = %+v", baseline)
+	}
+}
@@ -0,0 +1,82 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// SlowStepsMetric counts steps that ran well past their usual duration.
+const SlowStepsMetric = "sygaldry_steps_slow"
+
+const (
+	// slowStepMinSamples is how many past runs a step needs before it can be
+	// reported as slow.
+	slowStepMinSamples = 3
+	// A step is slow once it has run slowStepFactor times its median and at
+	// least slowStepMinExtra longer than it.
+	slowStepFactor   = 2
+	slowStepMinExtra = 60
+)
+
+// slowThreshold is how long a step with the given history may run before it
+// is reported as slow; ok is false without enough history.
+func slowThreshold(stats activities.StepDurationStats) (time.Duration, bool) {
+	if stats.Samples < slowStepMinSamples {
+		return 0, false
+	}
+	seconds := max(slowStepFactor*stats.MedianSec, stats.MedianSec+slowStepMinExtra)
+	return time.Duration(seconds) * time.Second, true
+}
+
+// loadDurationHistory fetches the recent step durations of a named plan. A
+// failed lookup is logged and leaves the run without history.
+func loadDurationHistory(ctx workflow.Context, logDir, plan string) map[string]activities.StepDurationStats {
+	loadCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	var result activities.DurationHistoryResult
+	err := workflow.ExecuteActivity(loadCtx, activities.LoadDurationHistory, activities.DurationHistoryInput{
+		LogDir: logDir,
+		Plan:   plan,
+	}).Get(loadCtx, &result)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to load duration history", "plan", plan, "error", err)
+		return nil
+	}
+	return result.Steps
+}
+
+// recordDurationHistory adds the run's successful step durations to the
+// plan's history. It uses a disconnected context so cancelled runs still
+// record the steps they finished; failures are only logged.
+func recordDurationHistory(ctx workflow.Context, info *workflow.Info, logDir, plan string, steps []StepOutcome) {
+	durations := map[string]int64{}
+	for _, step := range steps {
+		if step.State == "success" && step.Result.DurationSec > 0 {
+			durations[step.ID] = step.Result.DurationSec
+		}
+	}
+	if len(durations) == 0 {
+		return
+	}
+	recordCtx, _ := workflow.NewDisconnectedContext(ctx)
+	recordCtx = workflow.WithActivityOptions(recordCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	err := workflow.ExecuteActivity(recordCtx, activities.RecordDurationHistory, activities.RecordDurationsInput{
+		LogDir:     logDir,
+		Plan:       plan,
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Durations:  durations,
+	}).Get(recordCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to record duration history", "plan", plan, "error", err)
+	}
+}
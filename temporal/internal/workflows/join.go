@@ -0,0 +1,254 @@
+package workflows
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// JoinSpec builds a join step's outputs from the outputs of the steps it
+// depends on, e.g. the total of per-shard row counts.
+type JoinSpec struct {
+	Outputs map[string]JoinOutput `json:"outputs" yaml:"outputs"`
+}
+
+// JoinOutput collects output From of Steps (entries may be globs; default
+// all of depends_on) and reduces the values with Aggregate. Steps without
+// the output fail the join unless IgnoreMissing is set.
+//
+// Aggregate is a pipeline of stages separated by "|", applied to the values
+// in depends_on order, e.g. "sum", "unique | join(;)" or "max". A list left
+// at the end is joined with commas. The default is concat.
+//
+//	sum, min, max, mean  numeric reduction
+//	count                number of values
+//	first, last          one value
+//	concat, join(SEP)    values joined with "," or SEP
+//	json                 values as a JSON array of strings
+//	sort, unique         reorder or deduplicate the list
+type JoinOutput struct {
+	From          string   `json:"from" yaml:"from"`
+	Steps         []string `json:"steps" yaml:"steps"`
+	Aggregate     string   `json:"aggregate" yaml:"aggregate"`
+	IgnoreMissing bool     `json:"ignoreMissing" yaml:"ignore_missing"`
+}
+
+const defaultAggregate = "concat"
+
+type aggregateStage struct {
+	name string
+	arg  string
+}
+
+var listStages = map[string]bool{"sort": true, "unique": true}
+
+var reduceStages = map[string]bool{
+	"sum": true, "min": true, "max": true, "mean": true, "count": true,
+	"first": true, "last": true, "concat": true, "join": true, "json": true,
+}
+
+// ValidateAggregate reports whether expr is a valid join aggregate.
+func ValidateAggregate(expr string) error {
+	_, err := parseAggregate(expr)
+	return err
+}
+
+// parseAggregate splits an aggregate into stages. Stages after one that
+// reduces the list to a single value are an error.
+func parseAggregate(expr string) ([]aggregateStage, error) {
+	if strings.TrimSpace(expr) == "" {
+		expr = defaultAggregate
+	}
+	var stages []aggregateStage
+	reduced := false
+	for _, part := range strings.Split(expr, "|") {
+		part = strings.TrimSpace(part)
+		stage := aggregateStage{name: part}
+		if part == "join" {
+			stage.arg = ","
+		}
+		if name, arg, ok := strings.Cut(part, "("); ok {
+			if !strings.HasSuffix(arg, ")") {
+				return nil, fmt.Errorf("aggregate %q: unterminated %s(", expr, name)
+			}
+			stage = aggregateStage{name: strings.TrimSpace(name), arg: strings.TrimSuffix(arg, ")")}
+			if stage.name != "join" {
+				return nil, fmt.Errorf("aggregate %q: %s takes no argument", expr, stage.name)
+			}
+		}
+		if !listStages[stage.name] && !reduceStages[stage.name] {
+			return nil, fmt.Errorf("aggregate %q: unknown stage %q", expr, stage.name)
+		}
+		if reduced {
+			return nil, fmt.Errorf("aggregate %q: %s follows a stage that already produced a single value", expr, stage.name)
+		}
+		reduced = reduceStages[stage.name]
+		stages = append(stages, stage)
+	}
+	return stages, nil
+}
+
+// aggregate applies stages to values.
+func aggregate(stages []aggregateStage, values []string) (string, error) {
+	for _, stage := range stages {
+		switch stage.name {
+		case "sort":
+			values = append([]string(nil), values...)
+			sort.Strings(values)
+		case "unique":
+			seen := map[string]bool{}
+			var kept []string
+			for _, value := range values {
+				if !seen[value] {
+					seen[value] = true
+					kept = append(kept, value)
+				}
+			}
+			values = kept
+		case "count":
+			return strconv.Itoa(len(values)), nil
+		case "first", "last":
+			if len(values) == 0 {
+				return "", fmt.Errorf("%s of no values", stage.name)
+			}
+			if stage.name == "first" {
+				return values[0], nil
+			}
+			return values[len(values)-1], nil
+		case "concat":
+			return strings.Join(values, ","), nil
+		case "join":
+			return strings.Join(values, stage.arg), nil
+		case "json":
+			data, err := json.Marshal(append([]string{}, values...))
+			return string(data), err
+		default:
+			return reduceNumbers(stage.name, values)
+		}
+	}
+	return strings.Join(values, ","), nil
+}
+
+func reduceNumbers(op string, values []string) (string, error) {
+	if len(values) == 0 {
+		if op == "sum" {
+			return "0", nil
+		}
+		return "", fmt.Errorf("%s of no values", op)
+	}
+	var result float64
+	for i, value := range values {
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return "", fmt.Errorf("%s: %q is not a number", op, value)
+		}
+		switch {
+		case i == 0:
+			result = number
+		case op == "min":
+			result = math.Min(result, number)
+		case op == "max":
+			result = math.Max(result, number)
+		default:
+			result += number
+		}
+	}
+	if op == "mean" {
+		result /= float64(len(values))
+	}
+	return strconv.FormatFloat(result, 'f', -1, 64), nil
+}
+
+// joinSteps resolves an output's step list against depends_on, expanding
+// globs in depends_on order.
+func joinSteps(step PipelineStep, output JoinOutput) []string {
+	if len(output.Steps) == 0 {
+		return step.DependsOn
+	}
+	var ids []string
+	for _, pattern := range output.Steps {
+		for _, dep := range step.DependsOn {
+			if matched, _ := path.Match(pattern, dep); matched && !slices.Contains(ids, dep) {
+				ids = append(ids, dep)
+			}
+		}
+	}
+	return ids
+}
+
+// runJoin computes a join step's outputs from finished upstream steps. It
+// runs in the workflow, so the result is available without a worker.
+func runJoin(step PipelineStep, outcomes map[string]StepOutcome) (activities.RunCommandResult, error) {
+	if step.Join == nil || len(step.Join.Outputs) == 0 {
+		return activities.RunCommandResult{ExitCode: 1}, fmt.Errorf("join step %s has no outputs", step.ID)
+	}
+	names := make([]string, 0, len(step.Join.Outputs))
+	for name := range step.Join.Outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := activities.RunCommandResult{Outputs: map[string]string{}}
+	var stdout strings.Builder
+	for _, name := range names {
+		output := step.Join.Outputs[name]
+		stages, err := parseAggregate(output.Aggregate)
+		if err != nil {
+			return activities.RunCommandResult{ExitCode: 1}, fmt.Errorf("output %s: %w", name, err)
+		}
+		var values []string
+		for _, id := range joinSteps(step, output) {
+			value, ok := outcomes[id].Result.Outputs[output.From]
+			if !ok {
+				if output.IgnoreMissing {
+					continue
+				}
+				return activities.RunCommandResult{ExitCode: 1}, fmt.Errorf("output %s: step %s has no output %s", name, id, output.From)
+			}
+			values = append(values, value)
+		}
+		value, err := aggregate(stages, values)
+		if err != nil {
+			return activities.RunCommandResult{ExitCode: 1}, fmt.Errorf("output %s: %w", name, err)
+		}
+		result.Outputs[name] = value
+		fmt.Fprintf(&stdout, "%s=%s\n", name, value)
+	}
+	result.Stdout = stdout.String()
+	return result, nil
+}
+
+// startJoin resolves a join step at once and records its step_finished
+// event. An aggregation error fails the step.
+func startJoin(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, outcomes map[string]StepOutcome) workflow.Future {
+	result, err := runJoin(step, outcomes)
+	event := activities.StepEvent{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		StepID:     step.ID,
+		StepName:   stepName(step),
+		Status:     "step_finished",
+		Message:    strings.TrimSpace(result.Stdout),
+	}
+	if err != nil {
+		event.ExitCode = 1
+		event.Message = err.Error()
+		recordEvent(ctx, logDir, event)
+		return failedFuture(ctx, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("step %s: %v", step.ID, err), "JoinError", nil))
+	}
+	recordEvent(ctx, logDir, event)
+	future, settable := workflow.NewFuture(ctx)
+	settable.Set(result, nil)
+	return future
+}
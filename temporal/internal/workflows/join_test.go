@@ -0,0 +1,135 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestAggregate(t *testing.T) {
+	values := []string{"12", "30", "12", "4.5"}
+	cases := map[string]string{
+		"":                   "12,30,12,4.5",
+		"sum":                "58.5",
+		"min":                "4.5",
+		"max":                "30",
+		"mean":               "14.625",
+		"count":              "4",
+		"first":              "12",
+		"last":               "4.5",
+		"unique | join(;)":   "12;30;4.5",
+		"sort | unique":      "12,30,4.5",
+		"unique | json":      `["12","30","4.5"]`,
+		"sort | join( + )":   "12 + 12 + 30 + 4.5",
+		"unique | count":     "3",
+		"sort|unique|concat": "12,30,4.5",
+	}
+	for expr, want := range cases {
+		stages, err := parseAggregate(expr)
+		if err != nil {
+			t.Errorf("parseAggregate(%q): %v", expr, err)
+			continue
+		}
+		if got, err := aggregate(stages, values); err != nil || got != want {
+			t.Errorf("aggregate(%q) = %q, %v, want %q", expr, got, err, want)
+		}
+	}
+
+	stages, _ := parseAggregate("sum")
+	if _, err := aggregate(stages, []string{"1", "n/a"}); err == nil || !strings.Contains(err.Error(), "not a number") {
+		t.Errorf("sum of non-number: err = %v", err)
+	}
+	if got, err := aggregate(stages, nil); err != nil || got != "0" {
+		t.Errorf("sum of nothing = %q, %v", got, err)
+	}
+	for _, expr := range []string{"median", "sum(1)", "join(;", "count | sort"} {
+		if err := ValidateAggregate(expr); err == nil {
+			t.Errorf("ValidateAggregate(%q) accepted an invalid aggregate", expr)
+		}
+	}
+}
+
+func TestRunJoinMissingOutputs(t *testing.T) {
+	step := PipelineStep{ID: "total", Type: "join", DependsOn: []string{"shard-0", "shard-1"}, Join: &JoinSpec{
+		Outputs: map[string]JoinOutput{"rows": {From: "rows", Aggregate: "sum"}},
+	}}
+	outcomes := map[string]StepOutcome{
+		"shard-0": {ID: "shard-0", State: "success", Result: PipelineStepResult{Outputs: map[string]string{"rows": "10"}}},
+		"shard-1": {ID: "shard-1", State: "success"},
+	}
+	if _, err := runJoin(step, outcomes); err == nil || !strings.Contains(err.Error(), "step shard-1 has no output rows") {
+		t.Errorf("runJoin() error = %v", err)
+	}
+	step.Join.Outputs["rows"] = JoinOutput{From: "rows", Aggregate: "sum", IgnoreMissing: true}
+	if result, err := runJoin(step, outcomes); err != nil || result.Outputs["rows"] != "10" {
+		t.Errorf("runJoin() = %+v, %v", result, err)
+	}
+}
+
+func TestPipelineJoinStep(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		switch input.StepID {
+		case "shard-0":
+			return activities.RunCommandResult{Outputs: map[string]string{"rows": "120", "schema": "v2"}}, nil
+		case "shard-1":
+			return activities.RunCommandResult{Outputs: map[string]string{"rows": "80", "schema": "v2"}}, nil
+		}
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "shard-0", Type: "command", Command: "count"},
+			{ID: "shard-1", Type: "command", Command: "count"},
+			{ID: "eval", Type: "command", Command: "eval"},
+			{ID: "total", Type: "join", DependsOn: []string{"shard-0", "shard-1", "eval"}, Join: &JoinSpec{Outputs: map[string]JoinOutput{
+				"rows":    {From: "rows", Steps: []string{"shard-*"}, Aggregate: "sum"},
+				"schemas": {From: "schema", Steps: []string{"shard-*"}, Aggregate: "unique"},
+			}}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	total := result.Steps[len(result.Steps)-1]
+	if total.ID != "total" || total.State != "success" {
+		t.Fatalf("join outcome = %+v", total)
+	}
+	if total.Result.Outputs["rows"] != "200" || total.Result.Outputs["schemas"] != "v2" || total.Result.Stdout != "rows=200\nschemas=v2\n" {
+		t.Errorf("join result = %+v", total.Result)
+	}
+}
+
+func TestPipelineJoinFailure(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{Outputs: map[string]string{"rows": "many"}}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "shard-0", Type: "command", Command: "count"},
+			{ID: "total", Type: "join", DependsOn: []string{"shard-0"}, Join: &JoinSpec{Outputs: map[string]JoinOutput{
+				"rows": {From: "rows", Aggregate: "sum"},
+			}}},
+		},
+	})
+	err := env.GetWorkflowError()
+	if err == nil || !strings.Contains(err.Error(), `"many" is not a number`) {
+		t.Errorf("workflow error = %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package workflows
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// leaseScheduleToStartTimeout bounds how long a step pinned to a lease-holding
+// worker may wait to be picked up before the worker is considered gone.
+const leaseScheduleToStartTimeout = 10 * time.Minute
+
+// pinnedQueue returns the worker queue that holds local artifacts consumed by
+// step, along with the upstream step that owns them. Artifacts reach step
+// through its whole dependency closure, so upstream steps are searched nearest
+// first, in depends_on order. When upstream steps hold artifacts on different
+// workers the first one found wins and conflict is true.
+func pinnedQueue(step PipelineStep, steps []PipelineStep, outcomes map[string]StepOutcome) (queue string, owner string, conflict bool) {
+	byID := make(map[string]PipelineStep, len(steps))
+	for _, s := range steps {
+		byID[s.ID] = s
+	}
+	seen := map[string]bool{}
+	upstream := append([]string(nil), step.DependsOn...)
+	for len(upstream) > 0 {
+		dep := upstream[0]
+		upstream = upstream[1:]
+		if seen[dep] {
+			continue
+		}
+		seen[dep] = true
+		upstream = append(upstream, byID[dep].DependsOn...)
+		outcome, ok := outcomes[dep]
+		if !ok || len(outcome.Leases) == 0 {
+			continue
+		}
+		depQueue := outcome.Leases[0].WorkerQueue
+		if queue == "" {
+			queue, owner = depQueue, dep
+			continue
+		}
+		if depQueue != queue {
+			conflict = true
+		}
+	}
+	return queue, owner, conflict
+}
+
+// acquireLeases records leases for a step's local artifacts on the worker that
+// produced them.
+func acquireLeases(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, queue string) ([]activities.ArtifactLease, error) {
+	if queue == "" {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("step %s declares local_artifacts but its worker has no dedicated queue", step.ID),
+			"LeaseUnavailable", nil)
+	}
+	leaseCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		TaskQueue:              queue,
+		StartToCloseTimeout:    time.Minute,
+		ScheduleToStartTimeout: leaseScheduleToStartTimeout,
+		RetryPolicy:            &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	var result activities.ArtifactLeaseResult
+	err := workflow.ExecuteActivity(leaseCtx, activities.AcquireArtifactLeases, activities.ArtifactLeaseInput{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		StepID:     step.ID,
+		LogDir:     logDir,
+		Paths:      step.LocalArtifacts,
+	}).Get(leaseCtx, &result)
+	return result.Leases, err
+}
+
+// releaseLeases marks every lease taken by this run as released. It uses a
+// disconnected context so leases are released even when the run is cancelled.
+func releaseLeases(ctx workflow.Context, info *workflow.Info, logDir string, leases []activities.ArtifactLease) {
+	if len(leases) == 0 {
+		return
+	}
+	releaseCtx, _ := workflow.NewDisconnectedContext(ctx)
+	releaseCtx = workflow.WithActivityOptions(releaseCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 3},
+	})
+	err := workflow.ExecuteActivity(releaseCtx, activities.ReleaseArtifactLeases, activities.ReleaseLeasesInput{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		LogDir:     logDir,
+		Leases:     leases,
+	}).Get(releaseCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("failed to release artifact leases", "error", err)
+	}
+}
+
+// leaseHolderGone reports whether err means a pinned step was never picked up
+// by the worker holding its input artifacts.
+func leaseHolderGone(err error) bool {
+	var timeoutErr *temporal.TimeoutError
+	return errors.As(err, &timeoutErr) && timeoutErr.TimeoutType() == enumspb.TIMEOUT_TYPE_SCHEDULE_TO_START
+}
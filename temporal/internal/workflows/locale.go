@@ -0,0 +1,57 @@
+package workflows
+
+import (
+	"fmt"
+	"strings"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// LocaleSpec forces the locale of steps, e.g. {lang: C.UTF-8}, and how their
+// output is decoded: utf-8, latin-1 or auto (see activities.Locale). Set on
+// the plan it applies to every step without its own.
+type LocaleSpec struct {
+	Lang           string `json:"lang,omitempty" yaml:"lang"`
+	LCAll          string `json:"lcAll,omitempty" yaml:"lc_all"`
+	OutputEncoding string `json:"outputEncoding,omitempty" yaml:"output_encoding"`
+}
+
+// Validate checks the locale names and the output encoding.
+func (spec *LocaleSpec) Validate() error {
+	if spec == nil {
+		return nil
+	}
+	for name, value := range map[string]string{"lang": spec.Lang, "lc_all": spec.LCAll} {
+		if strings.ContainsAny(value, " \t\n=") {
+			return fmt.Errorf("locale.%s %q is not a locale name", name, value)
+		}
+	}
+	switch spec.OutputEncoding {
+	case "", activities.EncodingUTF8, activities.EncodingLatin1, activities.EncodingAuto:
+	default:
+		return fmt.Errorf("locale.output_encoding must be %s, %s or %s", activities.EncodingUTF8, activities.EncodingLatin1, activities.EncodingAuto)
+	}
+	return nil
+}
+
+func (spec *LocaleSpec) activityInput() *activities.Locale {
+	if spec == nil {
+		return nil
+	}
+	return &activities.Locale{Lang: spec.Lang, LCAll: spec.LCAll, OutputEncoding: spec.OutputEncoding}
+}
+
+// inheritLocale gives the plan's locale to the steps without their own.
+func inheritLocale(steps []PipelineStep, locale *LocaleSpec) []PipelineStep {
+	if locale == nil {
+		return steps
+	}
+	inherited := make([]PipelineStep, len(steps))
+	for i, step := range steps {
+		if step.Locale == nil {
+			step.Locale = locale
+		}
+		inherited[i] = step
+	}
+	return inherited
+}
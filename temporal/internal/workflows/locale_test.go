@@ -0,0 +1,38 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestStepsInheritPlanLocale(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	locales := map[string]*activities.Locale{}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		locales[input.StepID] = input.Locale
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Locale: &LocaleSpec{Lang: "C.UTF-8", OutputEncoding: activities.EncodingAuto},
+		Steps: []PipelineStep{
+			{ID: "build", Type: "command", Command: "make"},
+			{ID: "legacy", Type: "command", Command: "report.sh", Locale: &LocaleSpec{LCAll: "de_DE.ISO-8859-1", OutputEncoding: activities.EncodingLatin1}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if got := locales["build"]; got == nil || got.Lang != "C.UTF-8" || got.OutputEncoding != activities.EncodingAuto {
+		t.Errorf("build locale = %+v, want the plan's", got)
+	}
+	if got := locales["legacy"]; got == nil || got.Lang != "" || got.OutputEncoding != activities.EncodingLatin1 {
+		t.Errorf("legacy locale = %+v, want its own", got)
+	}
+}
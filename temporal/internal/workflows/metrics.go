@@ -0,0 +1,100 @@
+package workflows
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// StepMetricPrefix prefixes the exported name of every step-reported metric,
+// e.g. a step's "loss" gauge is exported as sygaldry_step_loss.
+const StepMetricPrefix = "sygaldry_step_"
+
+// emitStepMetrics exports a step's reported metrics through the worker's
+// metrics handler, tagged with the step's id and type. Counter values are
+// rounded to whole numbers.
+func emitStepMetrics(ctx workflow.Context, step PipelineStep, metrics []activities.StepMetric) {
+	if len(metrics) == 0 {
+		return
+	}
+	handler := workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"step_id": step.ID, "step_type": step.Type})
+	for _, metric := range metrics {
+		if metric.Type == activities.MetricCounter {
+			handler.Counter(StepMetricPrefix + metric.Name).Inc(int64(math.Round(metric.Value)))
+			continue
+		}
+		handler.Gauge(StepMetricPrefix + metric.Name).Update(metric.Value)
+	}
+}
+
+// MetricCondition is a parsed when.metric expression, "<name> <op> <number>".
+type MetricCondition struct {
+	Name  string
+	Op    string
+	Value float64
+}
+
+var metricOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// ParseMetricCondition parses expressions such as "loss < 0.5" or
+// "throughput>=1200".
+func ParseMetricCondition(expr string) (MetricCondition, error) {
+	for _, op := range metricOps {
+		name, value, ok := strings.Cut(expr, op)
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !activities.MetricNamePattern.MatchString(name) {
+			return MetricCondition{}, fmt.Errorf("metric condition %q: invalid metric name %q", expr, name)
+		}
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return MetricCondition{}, fmt.Errorf("metric condition %q: %q is not a number", expr, strings.TrimSpace(value))
+		}
+		return MetricCondition{Name: name, Op: op, Value: parsed}, nil
+	}
+	return MetricCondition{}, fmt.Errorf("metric condition %q: want <name> <op> <number> with op one of %s", expr, strings.Join(metricOps, " "))
+}
+
+// Holds reports whether value satisfies the condition.
+func (c MetricCondition) Holds(value float64) bool {
+	switch c.Op {
+	case "<":
+		return value < c.Value
+	case "<=":
+		return value <= c.Value
+	case ">":
+		return value > c.Value
+	case ">=":
+		return value >= c.Value
+	case "==":
+		return value == c.Value
+	case "!=":
+		return value != c.Value
+	}
+	return false
+}
+
+// metricConditionMet evaluates when.metric against the metrics reported by
+// the referenced step. A metric the step did not report is not met.
+func metricConditionMet(when *When, metrics []activities.StepMetric) (bool, string) {
+	condition, err := ParseMetricCondition(when.Metric)
+	if err != nil {
+		return false, err.Error()
+	}
+	for _, metric := range metrics {
+		if metric.Name == condition.Name {
+			if condition.Holds(metric.Value) {
+				return true, ""
+			}
+			return false, fmt.Sprintf("when condition not met: %s reported %s=%g, want %s", when.Step, metric.Name, metric.Value, when.Metric)
+		}
+	}
+	return false, fmt.Sprintf("when condition not met: %s did not report metric %s", when.Step, condition.Name)
+}
@@ -0,0 +1,104 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestParseMetricCondition(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    MetricCondition
+		wantErr string
+	}{
+		{"loss < 0.5", MetricCondition{Name: "loss", Op: "<", Value: 0.5}, ""},
+		{"throughput>=1200", MetricCondition{Name: "throughput", Op: ">=", Value: 1200}, ""},
+		{"accuracy != 1e-3", MetricCondition{Name: "accuracy", Op: "!=", Value: 0.001}, ""},
+		{"loss ~ 0.5", MetricCondition{}, "want <name> <op> <number>"},
+		{"val-loss < 1", MetricCondition{}, "invalid metric name"},
+		{"loss < low", MetricCondition{}, "not a number"},
+	}
+	for _, tt := range tests {
+		got, err := ParseMetricCondition(tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: error = %v, want containing %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestShouldSkipOnMetric(t *testing.T) {
+	outcomes := map[string]StepOutcome{
+		"train": {ID: "train", State: "success", Result: PipelineStepResult{Metrics: []activities.StepMetric{{Name: "loss", Type: "gauge", Value: 0.7}}}},
+	}
+	tests := []struct {
+		when       When
+		wantSkip   bool
+		wantReason string
+	}{
+		{When{Step: "train", Metric: "loss < 1"}, false, ""},
+		{When{Step: "train", Status: "success", Metric: "loss < 0.5"}, true, "train reported loss=0.7, want loss < 0.5"},
+		{When{Step: "train", Status: "failure", Metric: "loss < 1"}, true, "train is failure"},
+		{When{Step: "train", Metric: "accuracy > 0.9"}, true, "did not report metric accuracy"},
+	}
+	for _, tt := range tests {
+		skip, reason := shouldSkip(PipelineStep{ID: "publish", When: &tt.when}, outcomes)
+		if skip != tt.wantSkip || !strings.Contains(reason, tt.wantReason) {
+			t.Errorf("%+v: shouldSkip() = %v, %q", tt.when, skip, reason)
+		}
+	}
+}
+
+func TestStepMetricsAreExportedAndRouteSteps(t *testing.T) {
+	metrics := newCounterHandler()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(metrics)
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		if input.StepID != "train" {
+			return activities.RunCommandResult{}, nil
+		}
+		return activities.RunCommandResult{Metrics: []activities.StepMetric{
+			{Name: "loss", Type: activities.MetricGauge, Value: 0.3},
+			{Name: "samples", Type: activities.MetricCounter, Value: 512},
+		}}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "train", Type: "command", Command: "train"},
+			{ID: "publish", Type: "command", Command: "publish", DependsOn: []string{"train"}, When: &When{Step: "train", Metric: "loss < 0.5"}},
+			{ID: "retrain", Type: "command", Command: "retrain", DependsOn: []string{"train"}, When: &When{Step: "train", Metric: "loss >= 0.5"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	states := map[string]string{}
+	for _, step := range result.Steps {
+		states[step.ID] = step.State
+	}
+	if states["publish"] != "success" || states["retrain"] != "skipped" {
+		t.Errorf("states = %v", states)
+	}
+	if len(result.Steps) == 0 || len(result.Steps[0].Result.Metrics) != 2 {
+		t.Errorf("train metrics = %+v", result.Steps)
+	}
+	if got := metrics.counts[StepMetricPrefix+"samples/command"]; got != 512 {
+		t.Errorf("samples counter = %d, want 512 (counts: %v)", got, metrics.counts)
+	}
+}
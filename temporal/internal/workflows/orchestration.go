@@ -37,6 +37,7 @@ type StepResult struct {
 	Succeeded       bool   `json:"succeeded"`
 	DurationSec     int64  `json:"durationSec"`
 	Error           string `json:"error"`
+	activities.Timing
 }
 
 type OrchestrationResult struct {
@@ -52,6 +53,7 @@ func Orchestrate(ctx workflow.Context, input OrchestrationInput) (OrchestrationR
 		logDir = input.LogDir
 	}
 	results := make([]StepResult, 0, len(input.Steps))
+	setStepsQuery(ctx, func() []StepState { return orchestrationStepStates(input.Steps, results) })
 
 	baseOptions := workflow.ActivityOptions{
 		StartToCloseTimeout: 2 * time.Hour,
@@ -106,6 +108,7 @@ func Orchestrate(ctx workflow.Context, input OrchestrationInput) (OrchestrationR
 				StderrTruncated: activityResult.StderrTruncated,
 				Succeeded:       false,
 				DurationSec:     activityResult.DurationSec,
+				Timing:          activityResult.Timing,
 				Error:           err.Error(),
 			})
 			if !step.AllowFailure {
@@ -126,6 +129,7 @@ func Orchestrate(ctx workflow.Context, input OrchestrationInput) (OrchestrationR
 			StderrTruncated: activityResult.StderrTruncated,
 			Succeeded:       activityResult.ExitCode == 0,
 			DurationSec:     activityResult.DurationSec,
+			Timing:          activityResult.Timing,
 			Error:           "",
 		})
 
@@ -136,3 +140,24 @@ func Orchestrate(ctx workflow.Context, input OrchestrationInput) (OrchestrationR
 
 	return OrchestrationResult{Succeeded: true, Steps: results}, nil
 }
+
+// orchestrationStepStates reports the steps of an Orchestrate run: those
+// with results, the one running after them and the pending rest.
+func orchestrationStepStates(steps []Step, results []StepResult) []StepState {
+	states := make([]StepState, len(steps))
+	for i, step := range steps {
+		states[i] = StepState{ID: step.Name, State: "pending"}
+		switch {
+		case i < len(results):
+			states[i].ExitCode = results[i].ExitCode
+			states[i].Message = results[i].Error
+			states[i].State = "failed"
+			if results[i].Succeeded {
+				states[i].State = "success"
+			}
+		case i == len(results):
+			states[i].State = "running"
+		}
+	}
+	return states
+}
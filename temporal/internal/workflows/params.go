@@ -0,0 +1,189 @@
+package workflows
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// ScheduleSpec runs the plan as a cron workflow. CarryOutputs maps a parameter
+// name to a "<step-id>.<output>" reference resolved against the outputs of the
+// previous successful run, enabling simple incremental pipelines.
+//
+// Cron is evaluated in Timezone (an IANA name, default UTC). Runs that start
+// inside a blackout window or on a holiday are skipped, or deferred until the
+// blackout ends when OnBlackout is "defer" (see schedule.go).
+type ScheduleSpec struct {
+	Cron         string            `json:"cron" yaml:"cron"`
+	CarryOutputs map[string]string `json:"carryOutputs" yaml:"carry_outputs"`
+	Timezone     string            `json:"timezone" yaml:"timezone"`
+	Blackouts    []BlackoutWindow  `json:"blackouts" yaml:"blackouts"`
+	Holidays     []string          `json:"holidays" yaml:"holidays"`
+	// HolidayCalendar is a file of holidays, one per line, relative to the
+	// plan. The CLI reads it into Holidays when the plan is loaded.
+	HolidayCalendar string `json:"holidayCalendar" yaml:"holiday_calendar"`
+	OnBlackout      string `json:"onBlackout" yaml:"on_blackout"`
+}
+
+// resolveParams returns the plan parameters for this run: the plan's own
+// values overridden by any outputs carried over from the last successful run
+// of a scheduled plan.
+func resolveParams(ctx workflow.Context, input PipelineInput) map[string]string {
+	params := map[string]string{}
+	for name, value := range input.Params {
+		params[name] = value
+	}
+	if input.Schedule == nil || len(input.Schedule.CarryOutputs) == 0 || !workflow.HasLastCompletionResult(ctx) {
+		return params
+	}
+	var previous PipelineResult
+	if err := workflow.GetLastCompletionResult(ctx, &previous); err != nil {
+		workflow.GetLogger(ctx).Warn("unable to read previous run result; using plan params", "error", err)
+		return params
+	}
+	if previous.Status == StatusBlackoutSkipped {
+		// A skipped run ran no steps; pass on the values it was given.
+		for name := range input.Schedule.CarryOutputs {
+			if value, ok := previous.Params[name]; ok {
+				params[name] = value
+			}
+		}
+		return params
+	}
+	for name, value := range carriedParams(previous, input.Schedule.CarryOutputs) {
+		params[name] = value
+	}
+	return params
+}
+
+// carriedParams picks the referenced step outputs out of a previous result.
+// References that cannot be resolved are left out so plan defaults apply.
+func carriedParams(previous PipelineResult, carry map[string]string) map[string]string {
+	carried := map[string]string{}
+	for name, ref := range carry {
+		stepID, key, ok := strings.Cut(ref, ".")
+		if !ok {
+			continue
+		}
+		for _, outcome := range previous.Steps {
+			if outcome.ID != stepID {
+				continue
+			}
+			if value, ok := outcome.Result.Outputs[key]; ok {
+				carried[name] = value
+			}
+		}
+	}
+	return carried
+}
+
+// stepEnv merges plan parameters, exported as SYGALDRY_PARAM_<NAME>, into a
+// step's environment. Variables set on the step win.
+func stepEnv(env map[string]string, params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return env
+	}
+	merged := make(map[string]string, len(env)+len(params))
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		merged[paramEnvName(name)] = params[name]
+	}
+	for key, value := range env {
+		merged[key] = value
+	}
+	return merged
+}
+
+func paramEnvName(name string) string {
+	var b strings.Builder
+	b.WriteString("SYGALDRY_PARAM_")
+	for _, r := range strings.ToUpper(name) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// ParamSpec declares a plan parameter. Type is string (default), int, float or
+// bool; values are always passed to steps as strings.
+type ParamSpec struct {
+	Name        string   `json:"name" yaml:"name"`
+	Type        string   `json:"type" yaml:"type"`
+	Default     string   `json:"default" yaml:"default"`
+	Enum        []string `json:"enum" yaml:"enum"`
+	Required    bool     `json:"required" yaml:"required"`
+	Description string   `json:"description" yaml:"description"`
+}
+
+// ParamTypes lists the supported parameter types.
+var ParamTypes = map[string]bool{"": true, "string": true, "int": true, "float": true, "bool": true}
+
+// ApplyParamSchema fills in defaults and checks values against the declared
+// parameters. Undeclared values are rejected so typos do not go unnoticed.
+// Without a schema values are returned unchanged.
+func ApplyParamSchema(schema []ParamSpec, values map[string]string) (map[string]string, error) {
+	if len(schema) == 0 {
+		return values, nil
+	}
+	declared := make(map[string]bool, len(schema))
+	resolved := make(map[string]string, len(schema))
+	for _, spec := range schema {
+		declared[spec.Name] = true
+		value, ok := values[spec.Name]
+		if !ok {
+			if spec.Required {
+				return nil, fmt.Errorf("param %s is required", spec.Name)
+			}
+			if spec.Default == "" {
+				continue
+			}
+			value = spec.Default
+		}
+		if err := CheckParamValue(spec, value); err != nil {
+			return nil, err
+		}
+		resolved[spec.Name] = value
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return nil, fmt.Errorf("undeclared params: %s", strings.Join(names, ", "))
+	}
+	return resolved, nil
+}
+
+// CheckParamValue reports whether value satisfies spec's type and enum.
+func CheckParamValue(spec ParamSpec, value string) error {
+	var err error
+	switch spec.Type {
+	case "int":
+		_, err = strconv.ParseInt(value, 10, 64)
+	case "float":
+		_, err = strconv.ParseFloat(value, 64)
+	case "bool":
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("param %s: %q is not a valid %s", spec.Name, value, spec.Type)
+	}
+	if len(spec.Enum) > 0 && !slices.Contains(spec.Enum, value) {
+		return fmt.Errorf("param %s: %q is not one of %s", spec.Name, value, strings.Join(spec.Enum, ", "))
+	}
+	return nil
+}
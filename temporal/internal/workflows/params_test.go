@@ -0,0 +1,94 @@
+package workflows
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCarriedParams(t *testing.T) {
+	previous := PipelineResult{
+		Succeeded: true,
+		Steps: []StepOutcome{
+			{ID: "ingest", Result: PipelineStepResult{Outputs: map[string]string{"offset": "1200"}}},
+			{ID: "report"},
+		},
+	}
+	carry := map[string]string{
+		"start_offset": "ingest.offset",
+		"missing":      "ingest.nope",
+		"no_step":      "ghost.offset",
+		"malformed":    "ingest",
+	}
+
+	got := carriedParams(previous, carry)
+	if len(got) != 1 || got["start_offset"] != "1200" {
+		t.Errorf("carriedParams() = %v, want only start_offset=1200", got)
+	}
+}
+
+func TestStepEnv(t *testing.T) {
+	if got := stepEnv(map[string]string{"A": "1"}, nil); len(got) != 1 || got["A"] != "1" {
+		t.Errorf("stepEnv without params = %v", got)
+	}
+
+	got := stepEnv(
+		map[string]string{"SYGALDRY_PARAM_SHARD": "override", "A": "1"},
+		map[string]string{"shard": "3", "start-offset": "10"},
+	)
+	want := map[string]string{
+		"A":                           "1",
+		"SYGALDRY_PARAM_SHARD":        "override",
+		"SYGALDRY_PARAM_START_OFFSET": "10",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("stepEnv() = %v, want %v", got, want)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("env[%s] = %q, want %q", key, got[key], value)
+		}
+	}
+}
+
+func TestApplyParamSchema(t *testing.T) {
+	schema := []ParamSpec{
+		{Name: "shard", Type: "int", Required: true},
+		{Name: "split", Enum: []string{"train", "test"}, Default: "train"},
+		{Name: "dry_run", Type: "bool"},
+	}
+	tests := []struct {
+		name    string
+		values  map[string]string
+		want    map[string]string
+		wantErr string
+	}{
+		{"defaults applied", map[string]string{"shard": "3"}, map[string]string{"shard": "3", "split": "train"}, ""},
+		{"all set", map[string]string{"shard": "0", "split": "test", "dry_run": "true"}, map[string]string{"shard": "0", "split": "test", "dry_run": "true"}, ""},
+		{"missing required", map[string]string{}, nil, "required"},
+		{"bad int", map[string]string{"shard": "x"}, nil, "not a valid int"},
+		{"bad enum", map[string]string{"shard": "1", "split": "dev"}, nil, "not one of"},
+		{"undeclared", map[string]string{"shard": "1", "shrad": "2"}, nil, "undeclared params: shrad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyParamSchema(schema, tt.values)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package workflows
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"go.temporal.io/sdk/temporal"
@@ -11,9 +12,12 @@ import (
 	"temporal-orchestration/internal/activities"
 )
 
+// When runs a step only if Step finished with Status (success or failure)
+// and, if set, Step reported a metric satisfying Metric, e.g. "loss < 0.5".
 type When struct {
 	Step   string `json:"step" yaml:"step"`
 	Status string `json:"status" yaml:"status"`
+	Metric string `json:"metric" yaml:"metric"`
 }
 
 type DownloadSpec struct {
@@ -30,10 +34,32 @@ type DockerBuildSpec struct {
 	Labels     map[string]string `json:"labels" yaml:"labels"`
 	Platform   string            `json:"platform" yaml:"platform"`
 	Target     string            `json:"target" yaml:"target"`
+	DockerHost *DockerHostSpec   `json:"dockerHost" yaml:"docker_host"`
+	// BuilderPool builds on the worker's buildkit builder pool
+	// (TEMPORAL_BUILDKIT_POOL) instead of the local daemon.
+	BuilderPool bool `json:"builderPool" yaml:"builder_pool"`
 }
 
 type DockerPushSpec struct {
-	Image string `json:"image" yaml:"image"`
+	Image      string          `json:"image" yaml:"image"`
+	DockerHost *DockerHostSpec `json:"dockerHost" yaml:"docker_host"`
+}
+
+// DockerHostSpec runs a docker step against a remote daemon, e.g.
+// ssh://builder@build-01 or tcp://build-01:2376. tcp:// hosts need TLS: the
+// *_env fields name worker environment variables holding the PEM files.
+type DockerHostSpec struct {
+	URL     string `json:"url" yaml:"url"`
+	CAEnv   string `json:"caEnv" yaml:"ca_env"`
+	CertEnv string `json:"certEnv" yaml:"cert_env"`
+	KeyEnv  string `json:"keyEnv" yaml:"key_env"`
+}
+
+func (spec *DockerHostSpec) activityInput() *activities.DockerHost {
+	if spec == nil {
+		return nil
+	}
+	return &activities.DockerHost{URL: spec.URL, CAEnv: spec.CAEnv, CertEnv: spec.CertEnv, KeyEnv: spec.KeyEnv}
 }
 
 type PackageBuildSpec struct {
@@ -57,6 +83,20 @@ type HFDownloadDatasetSpec struct {
 	Config    string `json:"config" yaml:"config"`
 	Split     string `json:"split" yaml:"split"`
 	CacheDir  string `json:"cacheDir" yaml:"cache_dir"`
+	// Revision pins a branch, tag or commit. AllowPatterns select files by
+	// glob instead of Config and Split. MaxWorkers (default 8) files are
+	// downloaded at once.
+	Revision      string   `json:"revision" yaml:"revision"`
+	AllowPatterns []string `json:"allowPatterns" yaml:"allow_patterns"`
+	MaxWorkers    int      `json:"maxWorkers" yaml:"max_workers"`
+}
+
+// WatchPathSpec waits for a file or glob to appear ("exists") or to be
+// created or modified after the step starts ("changed").
+type WatchPathSpec struct {
+	Path        string `json:"path" yaml:"path"`
+	Condition   string `json:"condition" yaml:"condition"`
+	PollSeconds int    `json:"pollSeconds" yaml:"poll_seconds"`
 }
 
 type HFDownloadModelSpec struct {
@@ -65,17 +105,20 @@ type HFDownloadModelSpec struct {
 }
 
 type PipelineStep struct {
-	ID             string            `json:"id" yaml:"id"`
-	Name           string            `json:"name" yaml:"name"`
-	Type           string            `json:"type" yaml:"type"`
-	DependsOn      []string          `json:"dependsOn" yaml:"depends_on"`
-	When           *When             `json:"when" yaml:"when"`
-	Command        string            `json:"command" yaml:"command"`
-	Args           []string          `json:"args" yaml:"args"`
-	Env            map[string]string `json:"env" yaml:"env"`
-	WorkingDir     string            `json:"workingDir" yaml:"working_dir"`
-	TimeoutSeconds int               `json:"timeoutSeconds" yaml:"timeout_seconds"`
-	AllowFailure   bool              `json:"allowFailure" yaml:"allow_failure"`
+	ID                string                 `json:"id" yaml:"id"`
+	Name              string                 `json:"name" yaml:"name"`
+	Type              string                 `json:"type" yaml:"type"`
+	DependsOn         []string               `json:"dependsOn" yaml:"depends_on"`
+	When              *When                  `json:"when" yaml:"when"`
+	Command           string                 `json:"command" yaml:"command"`
+	Args              []string               `json:"args" yaml:"args"`
+	Env               map[string]string      `json:"env" yaml:"env"`
+	WorkingDir        string                 `json:"workingDir" yaml:"working_dir"`
+	TimeoutSeconds    int                    `json:"timeoutSeconds" yaml:"timeout_seconds"`
+	AllowFailure      bool                   `json:"allowFailure" yaml:"allow_failure"`
+	LocalArtifacts    []string               `json:"localArtifacts" yaml:"local_artifacts"`
+	Network           string                 `json:"network" yaml:"network"`
+	Approval          *ApprovalSpec          `json:"approval" yaml:"approval"`
 	Download          *DownloadSpec          `json:"download" yaml:"download"`
 	DockerBuild       *DockerBuildSpec       `json:"dockerBuild" yaml:"docker_build"`
 	DockerPush        *DockerPushSpec        `json:"dockerPush" yaml:"docker_push"`
@@ -83,39 +126,164 @@ type PipelineStep struct {
 	ContainerJob      *ContainerJobSpec      `json:"containerJob" yaml:"container_job"`
 	HFDownloadDataset *HFDownloadDatasetSpec `json:"hfDownloadDataset" yaml:"hf_download_dataset"`
 	HFDownloadModel   *HFDownloadModelSpec   `json:"hfDownloadModel" yaml:"hf_download_model"`
+	WatchPath         *WatchPathSpec         `json:"watchPath" yaml:"watch_path"`
+	Join              *JoinSpec              `json:"join" yaml:"join"`
+	// CaptureOnFailure lists debug paths (files, directories, globs or
+	// docker-logs:<container>) packed into a failure artifact when the step
+	// fails.
+	CaptureOnFailure []string `json:"captureOnFailure" yaml:"capture_on_failure"`
+	// Run replaces Command with shell commands run in order in one activity.
+	// StopOnFailure (default true) stops the list at the first failure.
+	Run           []string `json:"run" yaml:"run"`
+	StopOnFailure *bool    `json:"stopOnFailure" yaml:"stop_on_failure"`
+	// RateLimits names worker rate limiters the step waits on before it
+	// starts, e.g. [hf-api].
+	RateLimits []string `json:"rateLimits" yaml:"rate_limits"`
+	// Destructive marks steps such as production deploys that the run must
+	// be explicitly confirmed for (see PipelineInput.ConfirmDestructive).
+	Destructive bool `json:"destructive" yaml:"destructive"`
+	// OutputTruncation is how stdout and stderr are cut for the result:
+	// head, tail or head_tail. Empty uses the worker's default.
+	OutputTruncation string `json:"outputTruncation" yaml:"output_truncation"`
+	// Resources are what the step claims of the plan's
+	// SchedulingSpec.Resources while it runs, e.g. {gpu: 2}.
+	Resources map[string]int `json:"resources,omitempty" yaml:"resources"`
+	// VerifyBeforeUse re-checks the files downloaded by the step's
+	// download dependencies against their digests on the worker running the
+	// step, and fetches them again if they do not match.
+	VerifyBeforeUse bool `json:"verifyBeforeUse,omitempty" yaml:"verify_before_use"`
+	// CircuitKey names the target the step's failures count against for
+	// the circuit breaker, e.g. "internal-api", instead of the one derived
+	// from the step.
+	CircuitKey string `json:"circuitKey,omitempty" yaml:"circuit_key"`
+	// Canary runs the step with reduced scope and has it evaluated before
+	// the full run.
+	Canary *CanarySpec `json:"canary,omitempty" yaml:"canary"`
+	// KeepTmpOnFailure keeps the step's private TMPDIR on the worker when
+	// it fails. It is removed after the step otherwise.
+	KeepTmpOnFailure bool `json:"keepTmpOnFailure,omitempty" yaml:"keep_tmp_on_failure"`
+	// Locale overrides the plan's locale for the step.
+	Locale *LocaleSpec `json:"locale,omitempty" yaml:"locale"`
+	// Profile runs a command step under a sampling profiler.
+	Profile *ProfileSpec `json:"profile,omitempty" yaml:"profile"`
 }
 
 type PipelineInput struct {
-	LogDir string         `json:"logDir" yaml:"log_dir"`
-	Steps  []PipelineStep `json:"steps" yaml:"steps"`
+	// Name identifies the plan across runs, e.g. for golden baselines.
+	Name   string `json:"name" yaml:"name"`
+	LogDir string `json:"logDir" yaml:"log_dir"`
+	// ExecutionMode is at_least_once (default) or at_most_once, which never
+	// retries a step so non-idempotent operations fail instead of re-running.
+	ExecutionMode string            `json:"executionMode" yaml:"execution_mode"`
+	IdlePolicy    *IdlePolicy       `json:"idlePolicy" yaml:"idle_policy"`
+	Params        map[string]string `json:"params" yaml:"params"`
+	Parameters    []ParamSpec       `json:"parameters" yaml:"parameters"`
+	Schedule      *ScheduleSpec     `json:"schedule" yaml:"schedule"`
+	Require       []Requirement     `json:"require" yaml:"require"`
+	Webhooks      []WebhookSpec     `json:"webhooks" yaml:"webhooks"`
+	Golden        *GoldenPolicy     `json:"golden" yaml:"golden"`
+	Steps         []PipelineStep    `json:"steps" yaml:"steps"`
+	// Labels describe the run, e.g. {dataset: fineweb}, for finding it in
+	// the run index.
+	Labels map[string]string `json:"labels,omitempty" yaml:"labels"`
+	// Namespace and TaskQueue route the plan unless the caller passes
+	// -namespace or -task-queue. orchestrate sets them to where the plan
+	// was submitted.
+	Namespace string `json:"namespace,omitempty" yaml:"namespace"`
+	TaskQueue string `json:"taskQueue,omitempty" yaml:"task_queue"`
+	// Scheduling limits how many steps start together and which go first.
+	Scheduling *SchedulingSpec `json:"scheduling,omitempty" yaml:"scheduling"`
+	// CircuitBreaker pauses the run before steps whose target keeps
+	// failing across runs.
+	CircuitBreaker *CircuitBreaker `json:"circuitBreaker,omitempty" yaml:"circuit_breaker"`
+	// CollectLogs is a log location shared by the workers, local or s3://,
+	// where the run's logs and events are gathered from every worker that
+	// took part when it ends.
+	CollectLogs string `json:"collectLogs,omitempty" yaml:"collect_logs"`
+	// Locale sets LANG and LC_ALL for the steps and decodes their output
+	// into UTF-8.
+	Locale *LocaleSpec `json:"locale,omitempty" yaml:"locale"`
+	// ConfirmDestructive must be set to run a plan with destructive steps.
+	// It is set by the caller, never by the plan file.
+	ConfirmDestructive bool `json:"confirmDestructive" yaml:"-"`
 }
 
 type PipelineStepResult struct {
-	Name            string `json:"name"`
-	ExitCode        int    `json:"exitCode"`
-	Stdout          string `json:"stdout"`
-	Stderr          string `json:"stderr"`
-	StdoutPath      string `json:"stdoutPath"`
-	StderrPath      string `json:"stderrPath"`
-	StructuredPath  string `json:"structuredPath"`
-	StdoutTruncated bool   `json:"stdoutTruncated"`
-	StderrTruncated bool   `json:"stderrTruncated"`
-	Succeeded       bool   `json:"succeeded"`
-	DurationSec     int64  `json:"durationSec"`
-	Error           string `json:"error"`
+	Name            string                 `json:"name"`
+	ExitCode        int                    `json:"exitCode"`
+	Stdout          string                 `json:"stdout"`
+	Stderr          string                 `json:"stderr"`
+	StdoutPath      string                 `json:"stdoutPath"`
+	StderrPath      string                 `json:"stderrPath"`
+	StructuredPath  string                 `json:"structuredPath"`
+	StdoutTruncated bool                   `json:"stdoutTruncated"`
+	StderrTruncated bool                   `json:"stderrTruncated"`
+	Succeeded       bool                   `json:"succeeded"`
+	DurationSec     int64                  `json:"durationSec"`
+	Error           string                 `json:"error"`
+	WorkerQueue     string                 `json:"workerQueue,omitempty"`
+	Outputs         map[string]string      `json:"outputs,omitempty"`
+	Runs            []activities.RunStatus `json:"runs,omitempty"`
+	// Digests maps files the step produced to their sha256.
+	Digests map[string]string `json:"digests,omitempty"`
+	// Metrics are the values the step reported through $SYGALDRY_METRICS.
+	Metrics []activities.StepMetric `json:"metrics,omitempty"`
+	// Attempts is the step activity's attempt timeline.
+	Attempts []activities.StepAttempt `json:"attempts,omitempty"`
+	// TmpDir is the TMPDIR kept on the worker by keep_tmp_on_failure.
+	TmpDir string `json:"tmpDir,omitempty"`
+	// Profile is the step's profile when it sets profile.
+	Profile *activities.StepProfile `json:"profile,omitempty"`
+	// LogError reports output the step's log files could not store.
+	LogError string `json:"logError,omitempty"`
+	// Timing is when the step ran, to the millisecond. DurationSec is kept
+	// for older readers.
+	activities.Timing
 }
 
 type StepOutcome struct {
-	ID         string             `json:"id"`
-	Name       string             `json:"name"`
-	State      string             `json:"state"`
-	Result     PipelineStepResult `json:"result"`
-	SkipReason string             `json:"skipReason,omitempty"`
+	ID         string                     `json:"id"`
+	Name       string                     `json:"name"`
+	State      string                     `json:"state"`
+	Result     PipelineStepResult         `json:"result"`
+	SkipReason string                     `json:"skipReason,omitempty"`
+	Leases     []activities.ArtifactLease `json:"leases,omitempty"`
+	Reruns     int                        `json:"reruns,omitempty"`
+	// FailureArtifact holds the capture_on_failure bundle of a failed step.
+	FailureArtifact *activities.FailureArtifact `json:"failureArtifact,omitempty"`
 }
 
+// Pipeline result statuses. prerequisites_not_met means no step ran because a
+// require check failed, and blackout_skipped that the run started inside a
+// schedule blackout; both are reported without a workflow error.
+const (
+	StatusSucceeded           = "succeeded"
+	StatusFailed              = "failed"
+	StatusCancelled           = "cancelled"
+	StatusPrerequisitesNotMet = "prerequisites_not_met"
+	StatusBlackoutSkipped     = "blackout_skipped"
+)
+
+// StepsSkippedMetric counts steps skipped by depends_on or when conditions.
+const StepsSkippedMetric = "sygaldry_steps_skipped"
+
+// Execution modes.
+const (
+	ExecutionAtLeastOnce = "at_least_once"
+	ExecutionAtMostOnce  = "at_most_once"
+)
+
 type PipelineResult struct {
-	Succeeded bool          `json:"succeeded"`
-	Steps     []StepOutcome `json:"steps"`
+	Succeeded     bool                           `json:"succeeded"`
+	Status        string                         `json:"status"`
+	Steps         []StepOutcome                  `json:"steps"`
+	Params        map[string]string              `json:"params,omitempty"`
+	Prerequisites []activities.RequirementResult `json:"prerequisites,omitempty"`
+	Baseline      *BaselineComparison            `json:"baseline,omitempty"`
+	Blackout      *BlackoutRecord                `json:"blackout,omitempty"`
+	Logs          *LogCollection                 `json:"logs,omitempty"`
+	// EffectivePlan is the plan as this run executed it.
+	EffectivePlan *EffectivePlan `json:"effectivePlan,omitempty"`
 }
 
 func Pipeline(ctx workflow.Context, input PipelineInput) (PipelineResult, error) {
@@ -125,9 +293,109 @@ func Pipeline(ctx workflow.Context, input PipelineInput) (PipelineResult, error)
 	if input.LogDir != "" {
 		logDir = input.LogDir
 	}
+	canaryErr := ValidateCanaries(input.Steps)
+	if canaryErr == nil {
+		input.Steps = ExpandCanaries(input.Steps)
+	}
+	input.Steps = inheritLocale(input.Steps, input.Locale)
+	recordStepTypeUsage(ctx, input.Steps)
 	outcomes := map[string]StepOutcome{}
+	executed := map[string]PipelineStep{}
 	pending := map[string]PipelineStep{}
 	order := make([]string, 0, len(input.Steps))
+	var leases []activities.ArtifactLease
+	defer func() { releaseLeases(ctx, info, logDir, leases) }()
+	params := resolveParams(ctx, input)
+	var prerequisites []activities.RequirementResult
+	var golden *activities.GoldenBaseline
+	var blackout *BlackoutRecord
+	progress := newProgressTracker(ctx, input.Steps)
+	runCtx := newRunContext(ctx)
+	indexRun(ctx, info, logDir, input, indexRunning)
+	// finish builds the final result, records the final progress, the
+	// effective plan and the circuit outcomes, collects the logs, compares
+	// the result with the plan's golden run, reports it to the plan's
+	// webhooks and keeps a copy in the results store.
+	finish := func(status string) PipelineResult {
+		result := PipelineResult{
+			Succeeded:     status == StatusSucceeded,
+			Status:        status,
+			Steps:         ordered(outcomes, order),
+			Params:        params,
+			Prerequisites: prerequisites,
+			Blackout:      blackout,
+		}
+		if len(order) > 0 {
+			progress.report(ctx, info, logDir, outcomes, nil)
+		}
+		result.EffectivePlan = recordEffectivePlan(ctx, info, logDir, effectivePlan(input, logDir, params, executed))
+		if input.Name != "" {
+			recordDurationHistory(ctx, info, logDir, input.Name, result.Steps)
+		}
+		recordCircuits(ctx, info, logDir, input.Steps, outcomes)
+		if input.CollectLogs != "" {
+			result.Logs = collectLogs(ctx, info, logDir, input.CollectLogs, outcomes)
+		}
+		if golden != nil && len(result.Steps) > 0 {
+			result.Baseline = compareToGolden(*golden, result.Steps, input.Golden)
+			if n := len(result.Baseline.Deviations); n > 0 {
+				logger.Warn("run deviates from golden baseline", "plan", input.Name, "deviations", n, "goldenRunId", golden.RunID)
+			}
+		}
+		notifyWebhooks(ctx, info, input.Webhooks, result)
+		archiveRun(ctx, info, logDir, status, result)
+		indexRun(ctx, info, logDir, input, status)
+		return result
+	}
+
+	params, err := ApplyParamSchema(input.Parameters, params)
+	if err != nil {
+		return finish(StatusFailed), temporal.NewNonRetryableApplicationError(err.Error(), "InvalidParams", nil)
+	}
+
+	if err := input.Scheduling.Validate(input.Steps); err != nil {
+		return finish(StatusFailed), temporal.NewNonRetryableApplicationError(err.Error(), "InvalidScheduling", nil)
+	}
+
+	if canaryErr != nil {
+		return finish(StatusFailed), temporal.NewNonRetryableApplicationError(canaryErr.Error(), "InvalidCanary", nil)
+	}
+
+	if destructive := DestructiveSteps(input.Steps); len(destructive) > 0 && !input.ConfirmDestructive {
+		return finish(StatusFailed), temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("plan has destructive steps (%s); start it with confirmDestructive set", strings.Join(destructive, ", ")),
+			"DestructiveNotConfirmed", nil)
+	}
+
+	if input.Schedule != nil {
+		var skip bool
+		blackout, skip, err = awaitBlackout(ctx, info, logDir, input.Schedule)
+		if temporal.IsCanceledError(err) {
+			return finish(StatusCancelled), err
+		}
+		if err != nil {
+			return finish(StatusFailed), temporal.NewNonRetryableApplicationError(err.Error(), "InvalidSchedule", nil)
+		}
+		if skip {
+			logger.Warn("run skipped by schedule blackout", "reason", blackout.Reason)
+			return finish(StatusBlackoutSkipped), nil
+		}
+	}
+
+	if input.Name != "" {
+		golden = loadGolden(ctx, logDir, input.Name)
+		progress.useGolden(golden)
+		progress.useHistory(loadDurationHistory(ctx, logDir, input.Name))
+	}
+
+	if len(input.Require) > 0 {
+		var met bool
+		prerequisites, met = checkRequirements(ctx, input.Require)
+		if !met {
+			logger.Warn("prerequisites not met; no steps were run")
+			return finish(StatusPrerequisitesNotMet), nil
+		}
+	}
 
 	for _, step := range input.Steps {
 		pending[step.ID] = step
@@ -143,22 +411,44 @@ func Pipeline(ctx workflow.Context, input PipelineInput) (PipelineResult, error)
 			MaximumAttempts:    3,
 		},
 	}
+	if input.ExecutionMode == ExecutionAtMostOnce {
+		baseOptions.RetryPolicy.MaximumAttempts = 1
+	}
+
+	running := map[string]bool{}
+	setStepsQuery(ctx, func() []StepState { return pipelineStepStates(input.Steps, outcomes, running) })
 
-	for len(pending) > 0 {
+	rerunCh := workflow.GetSignalChannel(ctx, RerunStepSignal)
+	reruns := map[string]int{}
+	// Steps run under stepsCtx, which an idle approval gate cancels.
+	stepsCtx, cancelSteps := workflow.WithCancel(ctx)
+	gates := newGateBook(ctx, input.Steps, cancelSteps)
+	circuits := newCircuitBook(ctx, input.CircuitBreaker)
+
+	// stale holds running steps that a rerun invalidated.
+	stale := map[string]bool{}
+	for {
+		applyReruns(ctx, rerunCh, input.Steps, outcomes, pending, reruns, running, stale)
+		if len(pending) == 0 {
+			break
+		}
 		progressed := false
 		runnable := make([]PipelineStep, 0)
 
-		for id, step := range pending {
-			if !depsCompleted(step, outcomes) {
+		for _, id := range order {
+			step, ok := pending[id]
+			if !ok || !depsCompleted(step, outcomes) {
 				continue
 			}
 			if skip, reason := shouldSkip(step, outcomes); skip {
+				recordSkip(ctx, info, logDir, step, reason)
 				outcomes[id] = StepOutcome{
 					ID:         step.ID,
 					Name:       stepName(step),
 					State:      "skipped",
 					Result:     PipelineStepResult{Name: stepName(step)},
 					SkipReason: reason,
+					Reruns:     reruns[id],
 				}
 				delete(pending, id)
 				progressed = true
@@ -171,46 +461,134 @@ func Pipeline(ctx workflow.Context, input PipelineInput) (PipelineResult, error)
 			if progressed {
 				continue
 			}
-			return PipelineResult{Succeeded: false, Steps: ordered(outcomes, order)}, temporal.NewNonRetryableApplicationError("pipeline deadlock: check dependencies and conditions", "PipelineDeadlock", nil)
+			return finish(StatusFailed), temporal.NewNonRetryableApplicationError("pipeline deadlock: check dependencies and conditions", "PipelineDeadlock", nil)
 		}
+		runnable = input.Scheduling.pick(runnable, input.Steps, progress.weight(outcomes))
 
-		running := make([]runningStep, 0, len(runnable))
+		launched := make([]runningStep, 0, len(runnable))
 		for _, step := range runnable {
 			logger.Info("running step", "id", step.ID, "type", step.Type)
 			stepTimeout := baseOptions.StartToCloseTimeout
 			if step.TimeoutSeconds > 0 {
 				stepTimeout = time.Duration(step.TimeoutSeconds) * time.Second
 			}
-			stepCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			stepOptions := workflow.ActivityOptions{
 				StartToCloseTimeout: stepTimeout,
 				RetryPolicy:         baseOptions.RetryPolicy,
 				ActivityID:          step.ID,
-			})
+			}
+			queue, owner, conflict := pinnedQueue(step, input.Steps, outcomes)
+			if queue != "" {
+				if conflict {
+					logger.Warn("step consumes local artifacts from several workers; routing to the first owner", "id", step.ID, "owner", owner)
+				}
+				logger.Info("routing step to lease-holding worker", "id", step.ID, "owner", owner, "queue", queue)
+				stepOptions.TaskQueue = queue
+				stepOptions.ScheduleToStartTimeout = leaseScheduleToStartTimeout
+			}
+			stepCtx := workflow.WithActivityOptions(stepsCtx, stepOptions)
 			workflow.UpsertSearchAttributes(ctx, map[string]interface{}{
 				"CustomStringField":  stepName(step),
 				"CustomKeywordField": step.ID,
 			})
 
-			activityFuture := startActivity(stepCtx, info, logDir, step)
-			running = append(running, runningStep{step: step, ctx: stepCtx, future: activityFuture})
+			var activityFuture workflow.Future
+			if step.Type == "approval" {
+				activityFuture = gates.await(stepCtx, info, logDir, step, input.IdlePolicy)
+			} else if promoted, reason := canaryPromoted(step, outcomes); !promoted {
+				activityFuture = failedFuture(stepCtx, temporal.NewNonRetryableApplicationError(
+					fmt.Sprintf("step %s: %s", step.ID, reason), "CanaryNotPromoted", nil))
+			} else {
+				inputs := inputArtifacts(step, input.Steps, outcomes)
+				stepParams := canaryParams(input.Steps, step, params)
+				executed[step.ID] = resolvedStep(step, stepParams, outcomes)
+				activityFuture = circuits.guard(stepCtx, info, logDir, step, func(ctx workflow.Context) workflow.Future {
+					return startActivity(ctx, info, logDir, step, stepParams, outcomes, inputs)
+				})
+			}
+			launched = append(launched, runningStep{step: step, ctx: stepCtx, future: activityFuture, pinnedQueue: queue})
+			running[step.ID] = true
+			progress.launched(ctx, info, logDir, step)
 		}
 
-		for _, run := range running {
+		for i, run := range launched {
+			progress.report(ctx, info, logDir, outcomes, launched[i:])
 			result, err := waitActivity(run)
+			// Reruns signalled while the step ran may have invalidated it.
+			applyReruns(ctx, rerunCh, input.Steps, outcomes, pending, reruns, running, stale)
+			progress.finished(run.step.ID)
+			delete(running, run.step.ID)
+			if stale[run.step.ID] && gates.timedOut == nil {
+				logger.Info("discarding result of step invalidated by a rerun", "id", run.step.ID)
+				delete(stale, run.step.ID)
+				progressed = true
+				continue
+			}
 			outcome := StepOutcome{
 				ID:     run.step.ID,
 				Name:   stepName(run.step),
 				Result: result,
+				Reruns: reruns[run.step.ID],
+			}
+			if err != nil && run.pinnedQueue != "" && leaseHolderGone(err) {
+				logger.Warn("lease-holding worker did not pick up step; it may have disappeared", "id", run.step.ID, "queue", run.pinnedQueue)
+				err = temporal.NewNonRetryableApplicationError(
+					fmt.Sprintf("lease-holding worker on queue %s did not pick up step within %s", run.pinnedQueue, leaseScheduleToStartTimeout),
+					"LeaseHolderUnavailable", err)
+			}
+			if err == nil && result.ExitCode == 0 && len(run.step.LocalArtifacts) > 0 {
+				stepLeases, leaseErr := acquireLeases(ctx, info, logDir, run.step, result.WorkerQueue)
+				if leaseErr != nil {
+					err = leaseErr
+				} else {
+					outcome.Leases = stepLeases
+					leases = append(leases, stepLeases...)
+				}
+			}
+			emitStepMetrics(ctx, run.step, result.Metrics)
+			stepFailed := (err != nil && !temporal.IsCanceledError(err)) || (err == nil && result.ExitCode != 0)
+			if stepFailed && len(run.step.CaptureOnFailure) > 0 {
+				queue := result.WorkerQueue
+				if queue == "" {
+					queue = run.pinnedQueue
+				}
+				outcome.FailureArtifact = captureFailure(ctx, info, logDir, run.step, queue)
 			}
 			if err != nil {
 				outcome.State = "failed"
 				outcome.Result.Succeeded = false
 				outcome.Result.Error = err.Error()
+				if temporal.IsCanceledError(err) || gates.timedOut != nil {
+					// A gate the idle policy gave up on cancels the whole
+					// run, whatever allow_failure says.
+					outcome.State = "cancelled"
+					outcomes[run.step.ID] = outcome
+					if gates.timedOut != nil {
+						// The steps still in flight were cancelled with
+						// the run; record how each of them ended.
+						for _, rest := range launched[i+1:] {
+							restResult, restErr := waitActivity(rest)
+							delete(running, rest.step.ID)
+							restOutcome := StepOutcome{ID: rest.step.ID, Name: stepName(rest.step), Result: restResult, Reruns: reruns[rest.step.ID], State: "success"}
+							switch {
+							case restErr != nil:
+								restOutcome.State = "cancelled"
+								restOutcome.Result.Succeeded = false
+								restOutcome.Result.Error = restErr.Error()
+							case restResult.ExitCode != 0:
+								restOutcome.State = "failed"
+							}
+							outcomes[rest.step.ID] = restOutcome
+						}
+						err = gates.timedOut
+					}
+					return finish(StatusCancelled), err
+				}
 				outcomes[run.step.ID] = outcome
 				delete(pending, run.step.ID)
 				progressed = true
 				if !run.step.AllowFailure {
-					return PipelineResult{Succeeded: false, Steps: ordered(outcomes, order)}, err
+					return finish(StatusFailed), err
 				}
 				continue
 			}
@@ -224,27 +602,29 @@ func Pipeline(ctx workflow.Context, input PipelineInput) (PipelineResult, error)
 					outcomes[run.step.ID] = outcome
 					delete(pending, run.step.ID)
 					progressed = true
-					return PipelineResult{Succeeded: false, Steps: ordered(outcomes, order)}, temporal.NewNonRetryableApplicationError("step returned non-zero exit code", "StepFailed", nil)
+					return finish(StatusFailed), temporal.NewNonRetryableApplicationError("step returned non-zero exit code", "StepFailed", nil)
 				}
 			}
 
 			outcomes[run.step.ID] = outcome
+			runCtx.record(run.step.ID, outcome.Result.Outputs)
 			delete(pending, run.step.ID)
 			progressed = true
 		}
 
 		if !progressed {
-			return PipelineResult{Succeeded: false, Steps: ordered(outcomes, order)}, temporal.NewNonRetryableApplicationError("pipeline stalled", "PipelineStalled", nil)
+			return finish(StatusFailed), temporal.NewNonRetryableApplicationError("pipeline stalled", "PipelineStalled", nil)
 		}
 	}
 
-	return PipelineResult{Succeeded: true, Steps: ordered(outcomes, order)}, nil
+	return finish(StatusSucceeded), nil
 }
 
 type runningStep struct {
-	step   PipelineStep
-	ctx    workflow.Context
-	future workflow.Future
+	step        PipelineStep
+	ctx         workflow.Context
+	future      workflow.Future
+	pinnedQueue string
 }
 
 func depsCompleted(step PipelineStep, outcomes map[string]StepOutcome) bool {
@@ -262,13 +642,18 @@ func shouldSkip(step PipelineStep, outcomes map[string]StepOutcome) (bool, strin
 		if !ok {
 			return false, ""
 		}
-		if step.When.Status == "success" && outcome.State == "success" {
-			return false, ""
+		statusMet := step.When.Status == "" ||
+			(step.When.Status == "success" && outcome.State == "success") ||
+			(step.When.Status == "failure" && outcome.State == "failed")
+		if !statusMet {
+			return true, fmt.Sprintf("when condition not met: %s is %s", step.When.Step, step.When.Status)
 		}
-		if step.When.Status == "failure" && outcome.State == "failed" {
-			return false, ""
+		if step.When.Metric != "" {
+			if met, reason := metricConditionMet(step.When, outcome.Result.Metrics); !met {
+				return true, reason
+			}
 		}
-		return true, fmt.Sprintf("when condition not met: %s is %s", step.When.Step, step.When.Status)
+		return false, ""
 	}
 
 	for _, dep := range step.DependsOn {
@@ -279,20 +664,29 @@ func shouldSkip(step PipelineStep, outcomes map[string]StepOutcome) (bool, strin
 	return false, ""
 }
 
-func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep) workflow.Future {
+func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, params map[string]string, outcomes map[string]StepOutcome, inputs []activities.InputArtifact) workflow.Future {
 	switch step.Type {
 	case "command":
 		return workflow.ExecuteActivity(ctx, activities.RunCommand, activities.RunCommandInput{
-			Name:        stepName(step),
-			WorkflowID:  info.WorkflowExecution.ID,
-			RunID:       info.WorkflowExecution.RunID,
-			StepID:      step.ID,
-			LogDir:      logDir,
-			Command:     step.Command,
-			Args:        step.Args,
-			Env:         step.Env,
-			WorkingDir:  step.WorkingDir,
-			TimeoutSecs: step.TimeoutSeconds,
+			Name:              stepName(step),
+			WorkflowID:        info.WorkflowExecution.ID,
+			RunID:             info.WorkflowExecution.RunID,
+			StepID:            step.ID,
+			LogDir:            logDir,
+			Command:           step.Command,
+			Args:              step.Args,
+			Run:               step.Run,
+			ContinueOnFailure: step.StopOnFailure != nil && !*step.StopOnFailure,
+			Env:               stepEnv(step.Env, params),
+			WorkingDir:        step.WorkingDir,
+			RateLimits:        step.RateLimits,
+			Truncate:          step.OutputTruncation,
+			Locale:            step.Locale.activityInput(),
+			TimeoutSecs:       step.TimeoutSeconds,
+			Network:           step.Network,
+			VerifyInputs:      inputs,
+			KeepTmpOnFailure:  step.KeepTmpOnFailure,
+			Profile:           step.Profile.activityInput(),
 		})
 	case "download":
 		spec := step.Download
@@ -308,6 +702,7 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			URL:         spec.URL,
 			OutputPath:  spec.Output,
 			Sha256:      spec.Sha256,
+			RateLimits:  step.RateLimits,
 			TimeoutSecs: step.TimeoutSeconds,
 		})
 	case "docker_build":
@@ -315,6 +710,12 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 		if spec == nil {
 			spec = &DockerBuildSpec{}
 		}
+		resolved, err := resolveDockerBuild(*spec, params, outcomes)
+		if err != nil {
+			return failedFuture(ctx, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("step %s: %v", step.ID, err), "TemplateError", nil))
+		}
+		spec = &resolved
 		return workflow.ExecuteActivity(ctx, activities.DockerBuild, activities.DockerBuildInput{
 			Name:        stepName(step),
 			WorkflowID:  info.WorkflowExecution.ID,
@@ -328,7 +729,15 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			Labels:      spec.Labels,
 			Platform:    spec.Platform,
 			Target:      spec.Target,
+			RateLimits:  step.RateLimits,
+			Truncate:    step.OutputTruncation,
+			Locale:      step.Locale.activityInput(),
 			TimeoutSecs: step.TimeoutSeconds,
+			Network:     step.Network,
+			DockerHost:  spec.DockerHost.activityInput(),
+			BuilderPool: spec.BuilderPool,
+			// The build context may hold downloaded files.
+			VerifyInputs: inputs,
 		})
 	case "docker_push":
 		spec := step.DockerPush
@@ -342,7 +751,11 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			StepID:      step.ID,
 			LogDir:      logDir,
 			Image:       spec.Image,
+			RateLimits:  step.RateLimits,
+			Truncate:    step.OutputTruncation,
+			Locale:      step.Locale.activityInput(),
 			TimeoutSecs: step.TimeoutSeconds,
+			DockerHost:  spec.DockerHost.activityInput(),
 		})
 	case "package_build":
 		spec := step.PackageBuild
@@ -350,16 +763,22 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			spec = &PackageBuildSpec{}
 		}
 		return workflow.ExecuteActivity(ctx, activities.PackageBuild, activities.PackageBuildInput{
-			Name:        stepName(step),
-			WorkflowID:  info.WorkflowExecution.ID,
-			RunID:       info.WorkflowExecution.RunID,
-			StepID:      step.ID,
-			LogDir:      logDir,
-			Command:     spec.Command,
-			Args:        spec.Args,
-			Env:         spec.Env,
-			WorkingDir:  spec.WorkingDir,
-			TimeoutSecs: step.TimeoutSeconds,
+			Name:             stepName(step),
+			WorkflowID:       info.WorkflowExecution.ID,
+			RunID:            info.WorkflowExecution.RunID,
+			StepID:           step.ID,
+			LogDir:           logDir,
+			Command:          spec.Command,
+			Args:             spec.Args,
+			Env:              stepEnv(spec.Env, params),
+			WorkingDir:       spec.WorkingDir,
+			RateLimits:       step.RateLimits,
+			Truncate:         step.OutputTruncation,
+			Locale:           step.Locale.activityInput(),
+			TimeoutSecs:      step.TimeoutSeconds,
+			Network:          step.Network,
+			VerifyInputs:     inputs,
+			KeepTmpOnFailure: step.KeepTmpOnFailure,
 		})
 	case "container_job":
 		spec := step.ContainerJob
@@ -367,18 +786,24 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			spec = &ContainerJobSpec{}
 		}
 		return workflow.ExecuteActivity(ctx, activities.ContainerJob, activities.ContainerJobInput{
-			Name:         stepName(step),
-			WorkflowID:   info.WorkflowExecution.ID,
-			RunID:        info.WorkflowExecution.RunID,
-			StepID:       step.ID,
-			LogDir:       logDir,
-			ProjectID:    spec.ProjectID,
-			Entrypoint:   spec.Entrypoint,
-			Command:      spec.Command,
-			Env:          spec.Env,
-			GPU:          spec.GPU,
-			LauncherPath: spec.LauncherPath,
-			TimeoutSecs:  step.TimeoutSeconds,
+			Name:             stepName(step),
+			WorkflowID:       info.WorkflowExecution.ID,
+			RunID:            info.WorkflowExecution.RunID,
+			StepID:           step.ID,
+			LogDir:           logDir,
+			ProjectID:        spec.ProjectID,
+			Entrypoint:       spec.Entrypoint,
+			Command:          spec.Command,
+			Env:              stepEnv(spec.Env, params),
+			GPU:              spec.GPU,
+			LauncherPath:     spec.LauncherPath,
+			RateLimits:       step.RateLimits,
+			Truncate:         step.OutputTruncation,
+			Locale:           step.Locale.activityInput(),
+			TimeoutSecs:      step.TimeoutSeconds,
+			Network:          step.Network,
+			VerifyInputs:     inputs,
+			KeepTmpOnFailure: step.KeepTmpOnFailure,
 		})
 	case "hf_download_dataset":
 		spec := step.HFDownloadDataset
@@ -386,30 +811,57 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			spec = &HFDownloadDatasetSpec{}
 		}
 		return workflow.ExecuteActivity(ctx, activities.HFDownloadDataset, activities.HFDownloadDatasetInput{
+			Name:          stepName(step),
+			WorkflowID:    info.WorkflowExecution.ID,
+			RunID:         info.WorkflowExecution.RunID,
+			StepID:        step.ID,
+			LogDir:        logDir,
+			DatasetID:     spec.DatasetID,
+			Config:        spec.Config,
+			Split:         spec.Split,
+			CacheDir:      spec.CacheDir,
+			RateLimits:    step.RateLimits,
+			Truncate:      step.OutputTruncation,
+			Locale:        step.Locale.activityInput(),
+			TimeoutSecs:   step.TimeoutSeconds,
+			Revision:      spec.Revision,
+			AllowPatterns: spec.AllowPatterns,
+			MaxWorkers:    spec.MaxWorkers,
+		})
+	case "hf_download_model":
+		spec := step.HFDownloadModel
+		if spec == nil {
+			spec = &HFDownloadModelSpec{}
+		}
+		return workflow.ExecuteActivity(ctx, activities.HFDownloadModel, activities.HFDownloadModelInput{
 			Name:        stepName(step),
 			WorkflowID:  info.WorkflowExecution.ID,
 			RunID:       info.WorkflowExecution.RunID,
 			StepID:      step.ID,
 			LogDir:      logDir,
-			DatasetID:   spec.DatasetID,
-			Config:      spec.Config,
-			Split:       spec.Split,
+			ModelID:     spec.ModelID,
 			CacheDir:    spec.CacheDir,
+			RateLimits:  step.RateLimits,
+			Truncate:    step.OutputTruncation,
+			Locale:      step.Locale.activityInput(),
 			TimeoutSecs: step.TimeoutSeconds,
 		})
-	case "hf_download_model":
-		spec := step.HFDownloadModel
+	case "join":
+		return startJoin(ctx, info, logDir, step, outcomes)
+	case "watch_path":
+		spec := step.WatchPath
 		if spec == nil {
-			spec = &HFDownloadModelSpec{}
+			spec = &WatchPathSpec{}
 		}
-		return workflow.ExecuteActivity(ctx, activities.HFDownloadModel, activities.HFDownloadModelInput{
+		return workflow.ExecuteActivity(watchOptions(ctx, spec), activities.WatchPath, activities.WatchPathInput{
 			Name:        stepName(step),
 			WorkflowID:  info.WorkflowExecution.ID,
 			RunID:       info.WorkflowExecution.RunID,
 			StepID:      step.ID,
 			LogDir:      logDir,
-			ModelID:     spec.ModelID,
-			CacheDir:    spec.CacheDir,
+			Path:        spec.Path,
+			Condition:   spec.Condition,
+			PollSecs:    spec.PollSeconds,
 			TimeoutSecs: step.TimeoutSeconds,
 		})
 	default:
@@ -421,13 +873,55 @@ func startActivity(ctx workflow.Context, info *workflow.Info, logDir string, ste
 			LogDir:      logDir,
 			Command:     step.Command,
 			Args:        step.Args,
-			Env:         step.Env,
+			Env:         stepEnv(step.Env, params),
 			WorkingDir:  step.WorkingDir,
+			RateLimits:  step.RateLimits,
+			Truncate:    step.OutputTruncation,
+			Locale:      step.Locale.activityInput(),
 			TimeoutSecs: step.TimeoutSeconds,
+			Network:     step.Network,
 		})
 	}
 }
 
+// watchOptions gives a watch_path activity a heartbeat timeout and leaves it
+// time to report its own timeout as a failed step before StartToClose fires.
+func watchOptions(ctx workflow.Context, spec *WatchPathSpec) workflow.Context {
+	poll := 10 * time.Second
+	if spec.PollSeconds > 0 {
+		poll = time.Duration(spec.PollSeconds) * time.Second
+	}
+	options := workflow.GetActivityOptions(ctx)
+	options.HeartbeatTimeout = 2*poll + 30*time.Second
+	options.StartToCloseTimeout += time.Minute
+	return workflow.WithActivityOptions(ctx, options)
+}
+
+// recordSkip reports a skipped step, which has no activity of its own to emit
+// events, as a step_skipped event and in the steps_skipped metric.
+func recordSkip(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep, reason string) {
+	workflow.GetLogger(ctx).Info("skipping step", "id", step.ID, "reason", reason)
+	workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"step_type": step.Type}).Counter(StepsSkippedMetric).Inc(1)
+	if !hasChange(ctx, skipEventsChange) {
+		return
+	}
+	recordEvent(ctx, logDir, activities.StepEvent{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		StepID:     step.ID,
+		StepName:   stepName(step),
+		Status:     "step_skipped",
+		Message:    reason,
+	})
+}
+
+// failedFuture returns a future that is already resolved with err.
+func failedFuture(ctx workflow.Context, err error) workflow.Future {
+	future, settable := workflow.NewFuture(ctx)
+	settable.SetError(err)
+	return future
+}
+
 func waitActivity(run runningStep) (PipelineStepResult, error) {
 	name := stepName(run.step)
 
@@ -444,12 +938,16 @@ func waitActivity(run runningStep) (PipelineStepResult, error) {
 			StructuredPath: result.StructuredPath,
 			Succeeded:      result.ExitCode == 0,
 			DurationSec:    result.DurationSec,
+			Timing:         result.Timing,
+			WorkerQueue:    result.WorkerQueue,
+			Digests:        downloadDigests(run.step, result.Sha256),
+			LogError:       result.LogError,
 		}, err
 	}
 
 	var result activities.RunCommandResult
 	err := run.future.Get(run.ctx, &result)
-	return PipelineStepResult{
+	stepResult := PipelineStepResult{
 		Name:            name,
 		ExitCode:        result.ExitCode,
 		Stdout:          result.Stdout,
@@ -461,7 +959,48 @@ func waitActivity(run runningStep) (PipelineStepResult, error) {
 		StderrTruncated: result.StderrTruncated,
 		Succeeded:       result.ExitCode == 0,
 		DurationSec:     result.DurationSec,
-	}, err
+		Timing:          result.Timing,
+		WorkerQueue:     result.WorkerQueue,
+		Outputs:         result.Outputs,
+		Runs:            result.Runs,
+		Metrics:         result.Metrics,
+		Attempts:        result.Attempts,
+		TmpDir:          result.TmpDir,
+		Profile:         result.Profile,
+		LogError:        result.LogError,
+	}
+	if err != nil {
+		stepResult.Attempts = activities.AttemptsFromError(err)
+	}
+	return stepResult, err
+}
+
+func downloadDigests(step PipelineStep, sha256 string) map[string]string {
+	if sha256 == "" || step.Download == nil {
+		return nil
+	}
+	return map[string]string{step.Download.Output: sha256}
+}
+
+// inputArtifacts lists the files step's download dependencies fetched, with
+// their digests, if step verifies them before use.
+func inputArtifacts(step PipelineStep, steps []PipelineStep, outcomes map[string]StepOutcome) []activities.InputArtifact {
+	if !step.VerifyBeforeUse {
+		return nil
+	}
+	var inputs []activities.InputArtifact
+	for _, dep := range step.DependsOn {
+		digests := outcomes[dep].Result.Digests
+		for _, source := range steps {
+			if source.ID != dep || source.Download == nil {
+				continue
+			}
+			if digest, ok := digests[source.Download.Output]; ok {
+				inputs = append(inputs, activities.InputArtifact{Path: source.Download.Output, Sha256: digest, URL: source.Download.URL})
+			}
+		}
+	}
+	return inputs
 }
 
 func ordered(outcomes map[string]StepOutcome, order []string) []StepOutcome {
@@ -490,6 +1029,17 @@ func ordered(outcomes map[string]StepOutcome, order []string) []StepOutcome {
 	return ordered
 }
 
+// DestructiveSteps returns the IDs of the steps marked destructive.
+func DestructiveSteps(steps []PipelineStep) []string {
+	var ids []string
+	for _, step := range steps {
+		if step.Destructive {
+			ids = append(ids, step.ID)
+		}
+	}
+	return ids
+}
+
 func stepName(step PipelineStep) string {
 	if step.Name != "" {
 		return step.Name
@@ -1,7 +1,27 @@
 package workflows
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+
+	"temporal-orchestration/internal/activities"
+	"temporal-orchestration/internal/chaos"
 )
 
 // ---------------------------------------------------------------------------
@@ -266,3 +286,518 @@ func TestStepOutcomeFields(t *testing.T) {
 		t.Error("skipped StepOutcome fields not correctly set")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// pinnedQueue
+// ---------------------------------------------------------------------------
+
+func TestPinnedQueue(t *testing.T) {
+	outcomes := map[string]StepOutcome{
+		"plain":  {ID: "plain", State: "success"},
+		"shards": {ID: "shards", State: "success", Leases: []activities.ArtifactLease{{WorkerQueue: "q-host1"}}},
+		"other":  {ID: "other", State: "success", Leases: []activities.ArtifactLease{{WorkerQueue: "q-host2"}}},
+		"eval":   {ID: "eval", State: "success"},
+	}
+	steps := []PipelineStep{
+		{ID: "plain"},
+		{ID: "shards"},
+		{ID: "other"},
+		{ID: "eval", DependsOn: []string{"shards"}},
+	}
+
+	tests := []struct {
+		name         string
+		step         PipelineStep
+		wantQueue    string
+		wantOwner    string
+		wantConflict bool
+	}{
+		{"no deps", PipelineStep{ID: "c"}, "", "", false},
+		{"dep without leases", PipelineStep{ID: "c", DependsOn: []string{"plain"}}, "", "", false},
+		{"dep with leases", PipelineStep{ID: "c", DependsOn: []string{"plain", "shards"}}, "q-host1", "shards", false},
+		{"conflicting owners", PipelineStep{ID: "c", DependsOn: []string{"other", "shards"}}, "q-host2", "other", true},
+		{"transitive owner", PipelineStep{ID: "c", DependsOn: []string{"plain", "eval"}}, "q-host1", "shards", false},
+		{"nearest owner first", PipelineStep{ID: "c", DependsOn: []string{"eval", "other"}}, "q-host2", "other", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue, owner, conflict := pinnedQueue(tt.step, steps, outcomes)
+			if queue != tt.wantQueue || owner != tt.wantOwner || conflict != tt.wantConflict {
+				t.Errorf("pinnedQueue() = (%q, %q, %v), want (%q, %q, %v)",
+					queue, owner, conflict, tt.wantQueue, tt.wantOwner, tt.wantConflict)
+			}
+		})
+	}
+}
+
+func TestLeaseHolderGone(t *testing.T) {
+	if leaseHolderGone(nil) {
+		t.Error("nil error should not be a lease holder loss")
+	}
+	if leaseHolderGone(errors.New("boom")) {
+		t.Error("plain error should not be a lease holder loss")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// rerun-step invalidation
+// ---------------------------------------------------------------------------
+
+func rerunPlan() []PipelineStep {
+	return []PipelineStep{
+		{ID: "fetch"},
+		{ID: "clean", DependsOn: []string{"fetch"}},
+		{ID: "train", DependsOn: []string{"clean"}},
+		{ID: "notify", When: &When{Step: "train", Status: "failure"}},
+		{ID: "lint"},
+	}
+}
+
+func TestDependents(t *testing.T) {
+	got := dependents("clean", rerunPlan())
+	want := []string{"train", "notify"}
+	if len(got) != len(want) {
+		t.Fatalf("dependents() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("dependents()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if got := dependents("lint", rerunPlan()); len(got) != 0 {
+		t.Errorf("dependents(lint) = %v, want none", got)
+	}
+}
+
+func TestInvalidate(t *testing.T) {
+	outcomes := map[string]StepOutcome{
+		"fetch": {ID: "fetch", State: "success"},
+		"clean": {ID: "clean", State: "success"},
+		"train": {ID: "train", State: "success"},
+		"lint":  {ID: "lint", State: "success"},
+	}
+
+	invalidated, err := invalidate("clean", rerunPlan(), outcomes, map[string]bool{}, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalidated) != 2 || invalidated[0] != "clean" || invalidated[1] != "train" {
+		t.Errorf("invalidated = %v, want [clean train]", invalidated)
+	}
+	for _, id := range []string{"fetch", "lint"} {
+		if _, ok := outcomes[id]; !ok {
+			t.Errorf("outcome %s should be kept", id)
+		}
+	}
+	if _, ok := outcomes["train"]; ok {
+		t.Error("outcome train should be dropped")
+	}
+
+	if _, err := invalidate("clean", rerunPlan(), outcomes, map[string]bool{}, map[string]bool{}); err == nil {
+		t.Error("expected error for step without outcome")
+	}
+}
+
+func TestInvalidateMarksRunningDependentsStale(t *testing.T) {
+	outcomes := map[string]StepOutcome{
+		"fetch": {ID: "fetch", State: "success"},
+		"clean": {ID: "clean", State: "success"},
+	}
+	stale := map[string]bool{}
+
+	invalidated, err := invalidate("clean", rerunPlan(), outcomes, map[string]bool{"train": true, "lint": true}, stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalidated) != 2 || invalidated[1] != "train" {
+		t.Errorf("invalidated = %v, want [clean train]", invalidated)
+	}
+	if !stale["train"] || stale["lint"] || stale["notify"] {
+		t.Errorf("stale = %v, want only train", stale)
+	}
+}
+
+func TestRerunDiscardsResultOfRunningDependent(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	var mu sync.Mutex
+	calls := map[string]int{}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		mu.Lock()
+		calls[input.Command]++
+		n := calls[input.Command]
+		mu.Unlock()
+		if input.Command == "train" && n == 1 {
+			// clean is rerun while train, which depends on it, still runs.
+			env.SignalWorkflow(RerunStepSignal, "clean")
+		}
+		return activities.RunCommandResult{Stdout: fmt.Sprintf("%s run %d", input.Command, n)}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "clean", Command: "clean"},
+			{ID: "train", Command: "train", DependsOn: []string{"clean"}},
+		},
+	})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	if calls["clean"] != 2 || calls["train"] != 2 {
+		t.Fatalf("calls = %v, want clean and train to run twice", calls)
+	}
+	if len(result.Steps) != 2 || result.Steps[1].Result.Stdout != "train run 2" {
+		t.Errorf("steps = %+v, want the result of the second train run", result.Steps)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// skip events and metrics
+// ---------------------------------------------------------------------------
+
+// counterHandler is a minimal metrics handler that records counter totals.
+type counterHandler struct {
+	mu     *sync.Mutex
+	tags   map[string]string
+	counts map[string]int64
+}
+
+func newCounterHandler() *counterHandler {
+	return &counterHandler{mu: &sync.Mutex{}, counts: map[string]int64{}}
+}
+
+func (h *counterHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := map[string]string{}
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &counterHandler{mu: h.mu, tags: merged, counts: h.counts}
+}
+
+func (h *counterHandler) Counter(name string) client.MetricsCounter {
+	key := name
+	if stepType := h.tags["step_type"]; stepType != "" {
+		key += "/" + stepType
+	}
+	return counterFunc(func(delta int64) {
+		h.mu.Lock()
+		h.counts[key] += delta
+		h.mu.Unlock()
+	})
+}
+
+func (h *counterHandler) Gauge(string) client.MetricsGauge { return client.MetricsNopHandler.Gauge("") }
+func (h *counterHandler) Timer(string) client.MetricsTimer { return client.MetricsNopHandler.Timer("") }
+
+type counterFunc func(int64)
+
+func (f counterFunc) Inc(delta int64) { f(delta) }
+
+func TestSkippedStepsAreRecorded(t *testing.T) {
+	dir := t.TempDir()
+	metrics := newCounterHandler()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(metrics)
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(ApproveStepSignal, ApprovalDecision{StepID: "gate", Approved: false, Actor: "bob"})
+	}, time.Minute)
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: dir,
+		Steps: []PipelineStep{
+			{ID: "gate", Type: "approval", AllowFailure: true},
+			{ID: "deploy", Type: "approval", DependsOn: []string{"gate"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skipped []activities.StepEvent
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event activities.StepEvent
+		json.Unmarshal([]byte(line), &event)
+		if event.Status == "step_skipped" {
+			skipped = append(skipped, event)
+		}
+	}
+	if len(skipped) != 1 || skipped[0].StepID != "deploy" || skipped[0].Message != "dependency gate did not succeed" {
+		t.Errorf("step_skipped events = %+v", skipped)
+	}
+	if got := metrics.counts[StepsSkippedMetric+"/approval"]; got != 1 {
+		t.Errorf("%s = %d, want 1 (counts: %v)", StepsSkippedMetric, got, metrics.counts)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// execution mode
+// ---------------------------------------------------------------------------
+
+func TestExecutionModeAttempts(t *testing.T) {
+	for mode, want := range map[string]int{"": 3, ExecutionAtMostOnce: 1} {
+		t.Run("mode="+mode, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+			attempts := 0
+			env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+				attempts++
+				return activities.RunCommandResult{ExitCode: -1}, errors.New("connection reset")
+			}, activity.RegisterOptions{Name: "RunCommand"})
+
+			env.ExecuteWorkflow(Pipeline, PipelineInput{
+				LogDir:        t.TempDir(),
+				ExecutionMode: mode,
+				Steps:         []PipelineStep{{ID: "charge-card", Type: "command", Command: "charge"}},
+			})
+			if env.GetWorkflowError() == nil {
+				t.Fatal("expected the step to fail")
+			}
+			if attempts != want {
+				t.Errorf("attempts = %d, want %d", attempts, want)
+			}
+		})
+	}
+}
+
+func TestDestructiveStepsNeedConfirmation(t *testing.T) {
+	for _, confirmed := range []bool{false, true} {
+		var suite testsuite.WorkflowTestSuite
+		env := suite.NewTestWorkflowEnvironment()
+		ran := 0
+		env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+			ran++
+			return activities.RunCommandResult{}, nil
+		}, activity.RegisterOptions{Name: "RunCommand"})
+
+		env.ExecuteWorkflow(Pipeline, PipelineInput{
+			LogDir:             t.TempDir(),
+			ConfirmDestructive: confirmed,
+			Steps: []PipelineStep{
+				{ID: "build", Type: "command", Command: "make"},
+				{ID: "deploy-prod", Type: "command", Command: "deploy", DependsOn: []string{"build"}, Destructive: true},
+			},
+		})
+		err := env.GetWorkflowError()
+		if confirmed && (err != nil || ran != 2) {
+			t.Errorf("confirmed run: error = %v, ran %d steps", err, ran)
+		}
+		if !confirmed && (err == nil || !strings.Contains(err.Error(), "destructive steps (deploy-prod)") || ran != 0) {
+			t.Errorf("unconfirmed run: error = %v, ran %d steps", err, ran)
+		}
+	}
+}
+
+func TestFailedStepKeepsAttempts(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	attempts := []activities.StepAttempt{
+		{Attempt: 1, Worker: "gpu-2", StartedAt: "2025-01-01T00:00:00Z", EndedAt: "2025-01-01T00:01:00Z", ExitCode: -1, Error: "disk full"},
+	}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{}, temporal.NewApplicationErrorWithOptions("disk full", "StepAttemptError",
+			temporal.ApplicationErrorOptions{NonRetryable: true, Details: []any{attempts}})
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps:  []PipelineStep{{ID: "train", Type: "command", Command: "train", AllowFailure: true}},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Steps[0].Result.Attempts; len(got) != 1 || got[0].Worker != "gpu-2" || got[0].Error != "disk full" {
+		t.Errorf("attempts = %+v", got)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// failure capture
+// ---------------------------------------------------------------------------
+
+func TestFailedStepCapturesArtifact(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		if input.StepID == "train" {
+			return activities.RunCommandResult{ExitCode: 137, WorkerQueue: "gpu-1"}, nil
+		}
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	var captured []activities.CaptureFailureInput
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.CaptureFailureInput) (activities.FailureArtifact, error) {
+		captured = append(captured, input)
+		return activities.FailureArtifact{Path: "logs/wf_run_train_failure.tar.gz", Files: 2, WorkerQueue: activity.GetInfo(ctx).TaskQueue}, nil
+	}, activity.RegisterOptions{Name: "CaptureFailureArtifacts"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "prep", Type: "command", Command: "true", CaptureOnFailure: []string{"/tmp/prep"}},
+			{ID: "train", Type: "command", Command: "train", DependsOn: []string{"prep"}, AllowFailure: true,
+				CaptureOnFailure: []string{"/tmp/core.*", "docker-logs:trainer"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+
+	if len(captured) != 1 || captured[0].StepID != "train" || len(captured[0].Paths) != 2 {
+		t.Fatalf("captures = %+v, want one for train", captured)
+	}
+	for _, outcome := range result.Steps {
+		switch outcome.ID {
+		case "prep":
+			if outcome.FailureArtifact != nil {
+				t.Errorf("successful step has a failure artifact: %+v", outcome.FailureArtifact)
+			}
+		case "train":
+			if outcome.FailureArtifact == nil || outcome.FailureArtifact.Files != 2 {
+				t.Fatalf("train failure artifact = %+v", outcome.FailureArtifact)
+			}
+			if outcome.FailureArtifact.WorkerQueue != "gpu-1" {
+				t.Errorf("capture ran on %q, want the step's worker queue gpu-1", outcome.FailureArtifact.WorkerQueue)
+			}
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// golden baseline
+// ---------------------------------------------------------------------------
+
+func TestNamedPlanIsComparedWithGolden(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{Outputs: map[string]string{"version": "1.3"}}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	var loaded string
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.LoadGoldenInput) (activities.LoadGoldenResult, error) {
+		loaded = input.Plan
+		return activities.LoadGoldenResult{Found: true, Baseline: activities.GoldenBaseline{
+			Plan:  input.Plan,
+			RunID: "golden-run",
+			Steps: map[string]activities.GoldenStep{"build": {State: "success", Outputs: map[string]string{"version": "1.2"}}},
+		}}, nil
+	}, activity.RegisterOptions{Name: "LoadGoldenBaseline"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		Name:   "release",
+		LogDir: t.TempDir(),
+		Steps:  []PipelineStep{{ID: "build", Type: "command", Command: "make"}},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	if loaded != "release" {
+		t.Errorf("loaded baseline of %q", loaded)
+	}
+	if !result.Succeeded {
+		t.Error("deviations must not fail the run")
+	}
+	if result.Baseline == nil || len(result.Baseline.Deviations) != 1 || result.Baseline.Deviations[0].Key != "version" {
+		t.Errorf("baseline comparison = %+v", result.Baseline)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// verify_before_use
+// ---------------------------------------------------------------------------
+
+func TestVerifyBeforeUsePassesDownloadDigests(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.DownloadInput) (activities.DownloadResult, error) {
+		return activities.DownloadResult{Sha256: "abc123"}, nil
+	}, activity.RegisterOptions{Name: "DownloadFile"})
+	verify := map[string][]activities.InputArtifact{}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		verify[input.StepID] = input.VerifyInputs
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "fetch", Type: "download", Download: &DownloadSpec{URL: "https://example.com/data.bin", Output: "data/data.bin"}},
+			{ID: "train", Type: "command", Command: "train", DependsOn: []string{"fetch"}, VerifyBeforeUse: true},
+			{ID: "report", Type: "command", Command: "report", DependsOn: []string{"fetch"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	want := []activities.InputArtifact{{Path: "data/data.bin", Sha256: "abc123", URL: "https://example.com/data.bin"}}
+	if !reflect.DeepEqual(verify["train"], want) {
+		t.Errorf("train verifies %+v, want %+v", verify["train"], want)
+	}
+	if len(verify["report"]) != 0 {
+		t.Errorf("report verifies %+v without verify_before_use", verify["report"])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// fault injection
+// ---------------------------------------------------------------------------
+
+func TestPipelineUnderInjectedFaults(t *testing.T) {
+	rules, err := chaos.Parse("fail:prep@1,fail:lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{chaos.New(rules)}})
+	ran := map[string][]int32{}
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		ran[input.StepID] = append(ran[input.StepID], activity.GetInfo(ctx).Attempt)
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "prep", Type: "command", Command: "prep"},
+			{ID: "lint", Type: "command", Command: "lint", AllowFailure: true},
+			{ID: "train", Type: "command", Command: "train", DependsOn: []string{"prep"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatal(err)
+	}
+	states := map[string]string{}
+	for _, step := range result.Steps {
+		states[step.ID] = step.State
+	}
+	if states["prep"] != "success" || states["lint"] != "failed" || states["train"] != "success" || result.Status != StatusSucceeded {
+		t.Errorf("status = %s, states = %v", result.Status, states)
+	}
+	// prep ran once, on the attempt after the injected failure; lint never ran.
+	if !reflect.DeepEqual(ran, map[string][]int32{"prep": {2}, "train": {1}}) {
+		t.Errorf("ran = %v", ran)
+	}
+}
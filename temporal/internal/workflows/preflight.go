@@ -0,0 +1,78 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// Requirement is a prerequisite checked before any step runs. Exactly one of
+// HTTP, Image or HFRevision is set.
+type Requirement struct {
+	Name       string                 `json:"name" yaml:"name"`
+	HTTP       *HTTPRequirement       `json:"http" yaml:"http"`
+	Image      *ImageRequirement      `json:"image" yaml:"image"`
+	HFRevision *HFRevisionRequirement `json:"hfRevision" yaml:"hf_revision"`
+}
+
+type HTTPRequirement struct {
+	URL    string `json:"url" yaml:"url"`
+	Status int    `json:"status" yaml:"status"`
+}
+
+type ImageRequirement struct {
+	Ref string `json:"ref" yaml:"ref"`
+}
+
+type HFRevisionRequirement struct {
+	RepoID   string `json:"repoId" yaml:"repo_id"`
+	RepoType string `json:"repoType" yaml:"repo_type"`
+	Revision string `json:"revision" yaml:"revision"`
+}
+
+func (r Requirement) input() activities.RequirementInput {
+	input := activities.RequirementInput{Name: r.Name}
+	switch {
+	case r.HTTP != nil:
+		input.Kind = "http"
+		input.URL = r.HTTP.URL
+		input.ExpectStatus = r.HTTP.Status
+	case r.Image != nil:
+		input.Kind = "image"
+		input.ImageRef = r.Image.Ref
+	case r.HFRevision != nil:
+		input.Kind = "hf_revision"
+		input.RepoID = r.HFRevision.RepoID
+		input.RepoType = r.HFRevision.RepoType
+		input.Revision = r.HFRevision.Revision
+	}
+	return input
+}
+
+// checkRequirements runs every prerequisite check in parallel. A check whose
+// activity fails is reported as unmet.
+func checkRequirements(ctx workflow.Context, requirements []Requirement) ([]activities.RequirementResult, bool) {
+	checkCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 2 * time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 2},
+	})
+	futures := make([]workflow.Future, len(requirements))
+	for i, requirement := range requirements {
+		futures[i] = workflow.ExecuteActivity(checkCtx, activities.CheckRequirement, requirement.input())
+	}
+
+	results := make([]activities.RequirementResult, len(requirements))
+	allMet := true
+	for i, future := range futures {
+		if err := future.Get(checkCtx, &results[i]); err != nil {
+			results[i] = activities.RequirementResult{Name: requirements[i].Name, Detail: err.Error()}
+		}
+		if !results[i].Met {
+			allMet = false
+		}
+	}
+	return results, allMet
+}
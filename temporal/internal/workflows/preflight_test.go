@@ -0,0 +1,54 @@
+package workflows
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestRequirementInput(t *testing.T) {
+	input := Requirement{Name: "ds", HFRevision: &HFRevisionRequirement{RepoID: "org/ds", Revision: "v2"}}.input()
+	if input.Kind != "hf_revision" || input.RepoID != "org/ds" || input.Revision != "v2" || input.Name != "ds" {
+		t.Errorf("unexpected input: %+v", input)
+	}
+	input = Requirement{HTTP: &HTTPRequirement{URL: "http://x", Status: 204}}.input()
+	if input.Kind != "http" || input.ExpectStatus != 204 {
+		t.Errorf("unexpected input: %+v", input)
+	}
+}
+
+func TestPipelinePrerequisitesNotMet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(activities.CheckRequirement)
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:  t.TempDir(),
+		Require: []Requirement{{Name: "api", HTTP: &HTTPRequirement{URL: server.URL}}},
+		Steps:   []PipelineStep{{ID: "never", Type: "command", Command: "false"}},
+	})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("unmet prerequisites should not fail the workflow: %v", err)
+	}
+	var result PipelineResult
+	env.GetWorkflowResult(&result)
+	if result.Status != StatusPrerequisitesNotMet || result.Succeeded {
+		t.Errorf("status = %q, succeeded = %v", result.Status, result.Succeeded)
+	}
+	if len(result.Steps) != 0 {
+		t.Errorf("no step should run, got %d outcomes", len(result.Steps))
+	}
+	if len(result.Prerequisites) != 1 || result.Prerequisites[0].Met {
+		t.Errorf("prerequisites = %+v", result.Prerequisites)
+	}
+}
@@ -0,0 +1,40 @@
+package workflows
+
+import (
+	"fmt"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// ProfileSpec samples a command step with a profiler, e.g. {tool: py-spy},
+// to find where a slow step spends its time. The folded stacks are kept in
+// the log directory and the top frames are returned in the step's result.
+type ProfileSpec struct {
+	// Tool is py-spy, for Python, or perf.
+	Tool string `json:"tool" yaml:"tool"`
+	// Rate is the number of samples per second, 100 by default.
+	Rate int `json:"rate,omitempty" yaml:"rate"`
+}
+
+// Validate checks the profiler and its rate.
+func (spec *ProfileSpec) Validate() error {
+	if spec == nil {
+		return nil
+	}
+	switch spec.Tool {
+	case activities.ProfilerPySpy, activities.ProfilerPerf:
+	default:
+		return fmt.Errorf("profile.tool must be %s or %s", activities.ProfilerPySpy, activities.ProfilerPerf)
+	}
+	if spec.Rate < 0 || spec.Rate > 10000 {
+		return fmt.Errorf("profile.rate must be between 1 and 10000 samples per second")
+	}
+	return nil
+}
+
+func (spec *ProfileSpec) activityInput() *activities.Profile {
+	if spec == nil {
+		return nil
+	}
+	return &activities.Profile{Tool: spec.Tool, Rate: spec.Rate}
+}
@@ -0,0 +1,254 @@
+package workflows
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// ProgressQuery returns the run's latest activities.PipelineProgress.
+const ProgressQuery = "pipeline_progress"
+
+// progressTracker estimates how far a run has got and watches for slow
+// steps. Expected step durations come from the plan's duration history, or
+// its golden run; steps without either are assumed to take the mean of the
+// known durations.
+type progressTracker struct {
+	steps    []PipelineStep
+	expected map[string]int64
+	history  map[string]activities.StepDurationStats
+	started  map[string]time.Time
+	watches  map[string]workflow.CancelFunc
+	slow     map[string]bool
+	start    time.Time
+	last     activities.PipelineProgress
+	reported bool
+}
+
+func newProgressTracker(ctx workflow.Context, steps []PipelineStep) *progressTracker {
+	tracker := &progressTracker{
+		steps:    steps,
+		expected: map[string]int64{},
+		started:  map[string]time.Time{},
+		watches:  map[string]workflow.CancelFunc{},
+		slow:     map[string]bool{},
+		start:    workflow.Now(ctx),
+		last:     activities.PipelineProgress{Total: len(steps)},
+	}
+	err := workflow.SetQueryHandler(ctx, ProgressQuery, func() (activities.PipelineProgress, error) {
+		return tracker.last, nil
+	})
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to register progress query", "error", err)
+	}
+	return tracker
+}
+
+// useGolden takes expected durations from the plan's golden run.
+func (p *progressTracker) useGolden(golden *activities.GoldenBaseline) {
+	if golden == nil {
+		return
+	}
+	for id, step := range golden.Steps {
+		if step.State == "success" {
+			p.expected[id] = step.DurationSec
+		}
+	}
+}
+
+// useHistory takes expected durations from the plan's duration history,
+// which is preferred over the golden run.
+func (p *progressTracker) useHistory(history map[string]activities.StepDurationStats) {
+	p.history = history
+	for id, stats := range history {
+		if stats.Samples > 0 {
+			p.expected[id] = stats.MedianSec
+		}
+	}
+}
+
+// launched notes that step started and, if its history allows, reports it as
+// slow should it run well past its median duration. Approval gates wait on
+// people and are never reported.
+func (p *progressTracker) launched(ctx workflow.Context, info *workflow.Info, logDir string, step PipelineStep) {
+	p.started[step.ID] = workflow.Now(ctx)
+	delete(p.slow, step.ID)
+	threshold, ok := slowThreshold(p.history[step.ID])
+	if !ok || step.Type == "approval" {
+		return
+	}
+	timerCtx, cancel := workflow.WithCancel(ctx)
+	p.watches[step.ID] = cancel
+	usual := time.Duration(p.history[step.ID].MedianSec) * time.Second
+	workflow.Go(timerCtx, func(ctx workflow.Context) {
+		if err := workflow.NewTimer(ctx, threshold).Get(ctx, nil); err != nil {
+			return
+		}
+		p.slow[step.ID] = true
+		message := fmt.Sprintf("%s has run %s; it usually takes %s", step.ID, threshold, usual)
+		workflow.GetLogger(ctx).Warn("slow step", "id", step.ID, "running", threshold, "usual", usual)
+		workflow.GetMetricsHandler(ctx).WithTags(map[string]string{"step_type": step.Type}).Counter(SlowStepsMetric).Inc(1)
+		recordEvent(ctx, logDir, activities.StepEvent{
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			StepID:     step.ID,
+			StepName:   stepName(step),
+			Status:     "step_slow",
+			Message:    message,
+		})
+	})
+}
+
+// finished stops watching a step that completed.
+func (p *progressTracker) finished(id string) {
+	if cancel, ok := p.watches[id]; ok {
+		cancel()
+		delete(p.watches, id)
+	}
+}
+
+// report records a pipeline_progress event when the number of finished or
+// running steps changed since the last report.
+func (p *progressTracker) report(ctx workflow.Context, info *workflow.Info, logDir string, outcomes map[string]StepOutcome, running []runningStep) {
+	now := workflow.Now(ctx)
+	for _, run := range running {
+		if _, ok := p.started[run.step.ID]; !ok {
+			p.started[run.step.ID] = now
+		}
+	}
+	progress := p.snapshot(now, outcomes, running)
+	if p.reported && progress.Done == p.last.Done && progress.Running == p.last.Running {
+		p.last = progress
+		return
+	}
+	p.last, p.reported = progress, true
+	if !hasChange(ctx, progressEventsChange) {
+		return
+	}
+	message := fmt.Sprintf("%d of %d steps done", progress.Done, progress.Total)
+	if progress.EtaSec != nil {
+		message += fmt.Sprintf(", ETA %s", time.Duration(*progress.EtaSec)*time.Second)
+	}
+	recordEvent(ctx, logDir, activities.StepEvent{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Status:     "pipeline_progress",
+		Message:    message,
+		Progress:   &progress,
+	})
+}
+
+func (p *progressTracker) snapshot(now time.Time, outcomes map[string]StepOutcome, running []runningStep) activities.PipelineProgress {
+	progress := activities.PipelineProgress{
+		Total:      len(p.steps),
+		Running:    len(running),
+		ElapsedSec: int64(now.Sub(p.start).Seconds()),
+	}
+	for _, step := range p.steps {
+		if _, ok := outcomes[step.ID]; ok {
+			progress.Done++
+		}
+	}
+	if progress.Total > 0 {
+		progress.Percent = math.Round(1000*float64(progress.Done)/float64(progress.Total)) / 10
+	}
+	isRunning := map[string]bool{}
+	for _, run := range running {
+		isRunning[run.step.ID] = true
+		if p.slow[run.step.ID] {
+			progress.Slow = append(progress.Slow, run.step.ID)
+		}
+	}
+	sort.Strings(progress.Slow)
+	if eta, ok := p.remaining(now, outcomes, isRunning); ok {
+		progress.EtaSec = &eta
+	}
+	return progress
+}
+
+// remaining estimates the time left as the longest chain of unfinished steps
+// through depends_on, crediting running steps with the time they have run.
+func (p *progressTracker) remaining(now time.Time, outcomes map[string]StepOutcome, running map[string]bool) (int64, bool) {
+	mean, ok := p.meanDuration(outcomes)
+	if !ok {
+		return 0, false
+	}
+
+	byID := make(map[string]PipelineStep, len(p.steps))
+	for _, step := range p.steps {
+		byID[step.ID] = step
+	}
+	finish := map[string]int64{}
+	var finishOf func(id string, depth int) int64
+	finishOf = func(id string, depth int) int64 {
+		if value, ok := finish[id]; ok {
+			return value
+		}
+		step, ok := byID[id]
+		if _, done := outcomes[id]; done || !ok || depth > len(p.steps) {
+			return 0
+		}
+		left, ok := p.expected[id]
+		if !ok {
+			left = mean
+		}
+		if running[id] {
+			left = max(0, left-int64(now.Sub(p.started[id]).Seconds()))
+		}
+		var deps int64
+		for _, dep := range step.DependsOn {
+			deps = max(deps, finishOf(dep, depth+1))
+		}
+		finish[id] = deps + left
+		return finish[id]
+	}
+	var eta int64
+	for _, step := range p.steps {
+		eta = max(eta, finishOf(step.ID, 0))
+	}
+	return eta, true
+}
+
+// meanDuration is the mean of the expected durations and those of the steps
+// that succeeded in this run, which replace them.
+func (p *progressTracker) meanDuration(outcomes map[string]StepOutcome) (int64, bool) {
+	var known, sum int64
+	durations := map[string]int64{}
+	for id, seconds := range p.expected {
+		durations[id] = seconds
+	}
+	for id, outcome := range outcomes {
+		if outcome.State == "success" {
+			durations[id] = outcome.Result.DurationSec
+		}
+	}
+	for _, seconds := range durations {
+		known++
+		sum += seconds
+	}
+	if known == 0 {
+		return 0, false
+	}
+	return sum / known, true
+}
+
+// weight is the expected duration of each step for scheduling: its known
+// duration, else the mean, else 1 so that chains of unknown steps compare by
+// length.
+func (p *progressTracker) weight(outcomes map[string]StepOutcome) func(id string) int64 {
+	mean, ok := p.meanDuration(outcomes)
+	if !ok {
+		mean = 1
+	}
+	return func(id string) int64 {
+		if seconds, ok := p.expected[id]; ok {
+			return max(seconds, 1)
+		}
+		return max(mean, 1)
+	}
+}
@@ -0,0 +1,201 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestProgressRemainingFollowsLongestChain(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := &progressTracker{
+		steps: []PipelineStep{
+			{ID: "fetch"},
+			{ID: "train", DependsOn: []string{"fetch"}},
+			{ID: "lint", DependsOn: []string{"fetch"}},
+			{ID: "publish", DependsOn: []string{"train", "lint"}},
+		},
+		expected: map[string]int64{"fetch": 60, "train": 600, "lint": 30},
+		started:  map[string]time.Time{"train": start},
+		start:    start,
+	}
+	outcomes := map[string]StepOutcome{"fetch": {ID: "fetch", State: "success", Result: PipelineStepResult{DurationSec: 90}}}
+	now := start.Add(100 * time.Second)
+	// train has 500s left; publish has no history and takes the mean of
+	// fetch (90, this run), train (600) and lint (30).
+	eta, ok := tracker.remaining(now, outcomes, map[string]bool{"train": true, "lint": true})
+	if !ok || eta != 500+240 {
+		t.Errorf("remaining() = %d, %v, want 740", eta, ok)
+	}
+
+	progress := tracker.snapshot(now, outcomes, nil)
+	if progress.Done != 1 || progress.Total != 4 || progress.Percent != 25 || progress.ElapsedSec != 100 {
+		t.Errorf("snapshot() = %+v", progress)
+	}
+}
+
+func TestProgressWithoutHistoryHasNoETA(t *testing.T) {
+	tracker := &progressTracker{steps: []PipelineStep{{ID: "a"}}, expected: map[string]int64{}, started: map[string]time.Time{}}
+	if _, ok := tracker.remaining(time.Now(), map[string]StepOutcome{}, nil); ok {
+		t.Error("expected no ETA without any known duration")
+	}
+}
+
+func TestPipelineRecordsProgressEvents(t *testing.T) {
+	dir := t.TempDir()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{DurationSec: 30}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: dir,
+		Steps: []PipelineStep{
+			{ID: "a", Type: "command", Command: "a"},
+			{ID: "b", Type: "command", Command: "b", DependsOn: []string{"a"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reports []activities.PipelineProgress
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var event activities.StepEvent
+		json.Unmarshal([]byte(line), &event)
+		if event.Status == "pipeline_progress" && event.Progress != nil {
+			reports = append(reports, *event.Progress)
+		}
+	}
+	if len(reports) != 3 {
+		t.Fatalf("progress reports = %+v, want 3", reports)
+	}
+	if reports[0].Done != 0 || reports[0].Running != 1 || reports[0].EtaSec != nil {
+		t.Errorf("first report = %+v", reports[0])
+	}
+	if reports[1].Done != 1 || reports[1].EtaSec == nil || *reports[1].EtaSec != 30 {
+		t.Errorf("second report = %+v", reports[1])
+	}
+	if last := reports[2]; last.Done != 2 || last.Percent != 100 || *last.EtaSec != 0 {
+		t.Errorf("final report = %+v", last)
+	}
+
+	value, err := env.QueryWorkflow(ProgressQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var queried activities.PipelineProgress
+	if err := value.Get(&queried); err != nil || queried.Done != 2 {
+		t.Errorf("query = %+v, %v", queried, err)
+	}
+}
+
+func TestProgressPrefersDurationHistory(t *testing.T) {
+	tracker := &progressTracker{steps: []PipelineStep{{ID: "train"}}, expected: map[string]int64{}, started: map[string]time.Time{}}
+	tracker.useGolden(&activities.GoldenBaseline{Steps: map[string]activities.GoldenStep{"train": {State: "success", DurationSec: 900}}})
+	tracker.useHistory(map[string]activities.StepDurationStats{"train": {Samples: 4, MedianSec: 300, MaxSec: 420}})
+	if eta, ok := tracker.remaining(time.Now(), map[string]StepOutcome{}, nil); !ok || eta != 300 {
+		t.Errorf("remaining() = %d, %v, want the history median 300", eta, ok)
+	}
+}
+
+func TestSlowThreshold(t *testing.T) {
+	cases := []struct {
+		stats activities.StepDurationStats
+		want  time.Duration
+		ok    bool
+	}{
+		{activities.StepDurationStats{Samples: 2, MedianSec: 600}, 0, false},
+		{activities.StepDurationStats{Samples: 3, MedianSec: 600}, 20 * time.Minute, true},
+		{activities.StepDurationStats{Samples: 5, MedianSec: 10}, 70 * time.Second, true},
+	}
+	for _, tc := range cases {
+		got, ok := slowThreshold(tc.stats)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("slowThreshold(%+v) = %s, %v, want %s, %v", tc.stats, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestProgressReportsSlowSteps(t *testing.T) {
+	dir := t.TempDir()
+	metrics := newCounterHandler()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(metrics)
+	env := suite.NewTestWorkflowEnvironment()
+	steps := []PipelineStep{{ID: "train", Type: "command"}, {ID: "lint", Type: "command"}}
+	env.ExecuteWorkflow(func(ctx workflow.Context) ([]string, error) {
+		tracker := newProgressTracker(ctx, steps)
+		tracker.useHistory(map[string]activities.StepDurationStats{
+			"train": {Samples: 5, MedianSec: 300},
+			"lint":  {Samples: 5, MedianSec: 300},
+		})
+		info := workflow.GetInfo(ctx)
+		tracker.launched(ctx, info, dir, steps[0])
+		tracker.launched(ctx, info, dir, steps[1])
+		workflow.Sleep(ctx, 8*time.Minute)
+		tracker.finished("lint")
+		workflow.Sleep(ctx, 5*time.Minute)
+		running := []runningStep{{step: steps[0]}}
+		return tracker.snapshot(workflow.Now(ctx), map[string]StepOutcome{"lint": {ID: "lint", State: "success"}}, running).Slow, nil
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	var slow []string
+	if err := env.GetWorkflowResult(&slow); err != nil || len(slow) != 1 || slow[0] != "train" {
+		t.Errorf("slow = %v, %v, want [train]", slow, err)
+	}
+	if got := metrics.counts[SlowStepsMetric+"/command"]; got != 1 {
+		t.Errorf("%s = %d, want 1", SlowStepsMetric, got)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"status":"step_slow"`) || !strings.Contains(string(data), "train has run 10m0s; it usually takes 5m0s") {
+		t.Errorf("events = %s", data)
+	}
+}
+
+func TestPipelineRecordsDurationHistory(t *testing.T) {
+	dir := t.TempDir()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{DurationSec: 42}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.RegisterActivity(activities.LoadDurationHistory)
+	env.RegisterActivity(activities.RecordDurationHistory)
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		Name:   "nightly",
+		LogDir: dir,
+		Steps:  []PipelineStep{{ID: "a", Type: "command", Command: "a"}},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	history, err := activities.LoadDurationHistory(context.Background(), activities.DurationHistoryInput{LogDir: dir, Plan: "nightly"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := history.Steps["a"]; stats.Samples != 1 || stats.MedianSec != 42 {
+		t.Errorf("history = %+v", history.Steps)
+	}
+}
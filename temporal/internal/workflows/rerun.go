@@ -0,0 +1,96 @@
+package workflows
+
+import (
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// RerunStepSignal re-executes a completed step of a running pipeline. The
+// signal payload is the step ID.
+const RerunStepSignal = "rerun-step"
+
+// dependents returns the IDs of every step that transitively depends on
+// stepID through depends_on or a when condition, in plan order.
+func dependents(stepID string, steps []PipelineStep) []string {
+	affected := map[string]bool{stepID: true}
+	for changed := true; changed; {
+		changed = false
+		for _, step := range steps {
+			if affected[step.ID] {
+				continue
+			}
+			upstream := step.DependsOn
+			if step.When != nil {
+				upstream = append(append([]string{}, upstream...), step.When.Step)
+			}
+			for _, dep := range upstream {
+				if affected[dep] {
+					affected[step.ID] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(affected)-1)
+	for _, step := range steps {
+		if step.ID != stepID && affected[step.ID] {
+			ids = append(ids, step.ID)
+		}
+	}
+	return ids
+}
+
+// invalidate drops the outcome of stepID and of every completed step
+// downstream of it so the scheduler runs them again. Downstream steps still
+// running are marked stale: they ran against the old outcome, so their result
+// is discarded when it arrives. It returns the IDs that were invalidated, in
+// plan order.
+func invalidate(stepID string, steps []PipelineStep, outcomes map[string]StepOutcome, running, stale map[string]bool) ([]string, error) {
+	if _, ok := outcomes[stepID]; !ok {
+		return nil, fmt.Errorf("step %s has not completed", stepID)
+	}
+	invalidated := []string{stepID}
+	delete(outcomes, stepID)
+	for _, id := range dependents(stepID, steps) {
+		if _, ok := outcomes[id]; ok {
+			delete(outcomes, id)
+			invalidated = append(invalidated, id)
+		} else if running[id] {
+			stale[id] = true
+			invalidated = append(invalidated, id)
+		}
+	}
+	return invalidated, nil
+}
+
+// applyReruns drains pending rerun-step signals and moves the invalidated
+// steps back to pending. Invalidated steps still in running are added to
+// stale; they stay pending once their result is discarded.
+func applyReruns(ctx workflow.Context, ch workflow.ReceiveChannel, steps []PipelineStep, outcomes map[string]StepOutcome, pending map[string]PipelineStep, reruns map[string]int, running, stale map[string]bool) {
+	logger := workflow.GetLogger(ctx)
+	byID := make(map[string]PipelineStep, len(steps))
+	for _, step := range steps {
+		byID[step.ID] = step
+	}
+
+	var stepID string
+	for ch.ReceiveAsync(&stepID) {
+		if _, ok := byID[stepID]; !ok {
+			logger.Warn("ignoring rerun of unknown step", "id", stepID)
+			continue
+		}
+		invalidated, err := invalidate(stepID, steps, outcomes, running, stale)
+		if err != nil {
+			logger.Warn("ignoring rerun", "id", stepID, "error", err)
+			continue
+		}
+		for _, id := range invalidated {
+			pending[id] = byID[id]
+		}
+		reruns[stepID]++
+		logger.Info("rerunning step", "id", stepID, "invalidated", invalidated)
+	}
+}
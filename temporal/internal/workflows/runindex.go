@@ -0,0 +1,75 @@
+package workflows
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// indexRunning is the run index status of a run that has not ended.
+const indexRunning = "running"
+
+// indexRun records the run in the run index of its log directory with
+// status, so `orchestrate logs locate` can find its logs by plan, label or
+// outcome. It runs as a local activity on the pipeline's worker, also when
+// the run is cancelled; failures are only logged.
+func indexRun(ctx workflow.Context, info *workflow.Info, logDir string, input PipelineInput, status string) {
+	if !hasChange(ctx, runIndexChange) {
+		return
+	}
+	indexCtx, _ := workflow.NewDisconnectedContext(ctx)
+	indexCtx = workflow.WithLocalActivityOptions(indexCtx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 10 * time.Second,
+	})
+	err := workflow.ExecuteLocalActivity(indexCtx, activities.RecordRunIndex, activities.RecordRunIndexInput{
+		LogDir: logDir,
+		Entry: activities.RunIndexEntry{
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			Plan:       input.Name,
+			Labels:     input.Labels,
+			StartedAt:  info.WorkflowStartTime.UTC().Format(time.RFC3339),
+			Status:     status,
+		},
+	}).Get(indexCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to record run in the run index", "status", status, "error", err)
+	}
+}
+
+// archiveRun keeps a copy of the finished run's result, with secret params
+// redacted, in the results store, for `orchestrate status` and `report` once
+// Temporal's retention has removed the run's history. Like indexRun it runs
+// as a local activity and failures are only logged.
+func archiveRun(ctx workflow.Context, info *workflow.Info, logDir, status string, result PipelineResult) {
+	if !hasChange(ctx, archiveRunChange) {
+		return
+	}
+	result.Params = redactValues(result.Params)
+	data, err := json.Marshal(result)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to encode the run for the results store", "error", err)
+		return
+	}
+	archiveCtx, _ := workflow.NewDisconnectedContext(ctx)
+	archiveCtx = workflow.WithLocalActivityOptions(archiveCtx, workflow.LocalActivityOptions{
+		StartToCloseTimeout: 30 * time.Second,
+	})
+	err = workflow.ExecuteLocalActivity(archiveCtx, activities.ArchiveRun, activities.ArchiveRunInput{
+		LogDir: logDir,
+		Run: activities.ArchivedRun{
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			Status:     status,
+			StartedAt:  info.WorkflowStartTime.UTC().Format(time.RFC3339),
+			ClosedAt:   workflow.Now(ctx).UTC().Format(time.RFC3339),
+			Result:     data,
+		},
+	}).Get(archiveCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to keep the run in the results store", "error", err)
+	}
+}
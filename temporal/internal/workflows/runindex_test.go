@@ -0,0 +1,74 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestPipelineRecordsRunIndex(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{ExitCode: 1}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	logDir := t.TempDir()
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: logDir,
+		Labels: map[string]string{"dataset": "fineweb"},
+		Steps:  []PipelineStep{{ID: "train", Type: "command", Command: "train"}},
+	})
+	runs, err := activities.ReadRunIndex(activities.RunIndexPath(logDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 {
+		t.Fatalf("runs = %+v, want one", runs)
+	}
+	run := runs[0]
+	if run.Status != StatusFailed || run.LogDir != logDir || run.Labels["dataset"] != "fineweb" || run.StartedAt == "" {
+		t.Errorf("run = %+v", run)
+	}
+}
+
+func TestPipelineArchivesRun(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{ExitCode: 0, Stdout: "done"}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	logDir := t.TempDir()
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: logDir,
+		Params: map[string]string{"dataset": "fineweb", "hf_token": "hf_abc"},
+		Steps:  []PipelineStep{{ID: "prep", Type: "command", Command: "prep"}},
+	})
+	runs, err := activities.ReadRunIndex(activities.RunIndexPath(logDir))
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("runs = %+v, %v", runs, err)
+	}
+	archived, err := activities.ReadArchivedRun(activities.ArchivedRunPath(logDir, runs[0].WorkflowID, runs[0].RunID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result PipelineResult
+	if err := json.Unmarshal(archived.Result, &result); err != nil {
+		t.Fatal(err)
+	}
+	if archived.Status != StatusSucceeded || archived.ClosedAt == "" || len(result.Steps) != 1 || result.Steps[0].Result.Stdout != "done" {
+		t.Errorf("archived = %+v, result = %+v", archived, result)
+	}
+	if result.Params["dataset"] != "fineweb" || result.Params["hf_token"] != RedactedValue {
+		t.Errorf("params = %v, want hf_token redacted", result.Params)
+	}
+	if result.EffectivePlan == nil || result.EffectivePlan.Plan.Steps[0].ID != "prep" {
+		t.Errorf("effective plan = %+v", result.EffectivePlan)
+	}
+}
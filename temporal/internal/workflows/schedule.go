@@ -0,0 +1,260 @@
+package workflows
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// Blackout actions.
+const (
+	BlackoutSkip  = "skip"
+	BlackoutDefer = "defer"
+)
+
+// BlackoutWindow is a recurring period in which scheduled runs must not
+// execute. Start and End are "<weekday> HH:MM" (e.g. "Fri 18:00" to
+// "Mon 06:00") for a weekly window, or "HH:MM" for a daily one, in the
+// schedule's timezone. A window may wrap past the end of the week or day.
+type BlackoutWindow struct {
+	Start  string `json:"start" yaml:"start"`
+	End    string `json:"end" yaml:"end"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// BlackoutRecord notes why a run was skipped or deferred. DeferredUntil is set
+// when a deferred run resumed.
+type BlackoutRecord struct {
+	Action        string `json:"action"`
+	Reason        string `json:"reason"`
+	DeferredUntil string `json:"deferredUntil,omitempty"`
+}
+
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+// parseWeekday accepts a full or three-letter English weekday name.
+func parseWeekday(value string) (time.Weekday, bool) {
+	value = strings.ToLower(value)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if value == name || value == name[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// windowTime is a parsed window bound: minutes since Sunday 00:00 for weekly
+// windows, or since midnight for daily ones.
+type windowTime struct {
+	weekly bool
+	minute int
+}
+
+func parseWindowTime(value string) (windowTime, error) {
+	fields := strings.Fields(value)
+	var parsed windowTime
+	var clock string
+	switch len(fields) {
+	case 1:
+		clock = fields[0]
+	case 2:
+		day, ok := parseWeekday(fields[0])
+		if !ok {
+			return parsed, fmt.Errorf("unknown weekday %q", fields[0])
+		}
+		parsed.weekly = true
+		parsed.minute = int(day) * minutesPerDay
+		clock = fields[1]
+	default:
+		return parsed, fmt.Errorf("%q is not \"[weekday] HH:MM\"", value)
+	}
+	at, err := time.Parse("15:04", clock)
+	if err != nil {
+		return parsed, fmt.Errorf("%q is not \"[weekday] HH:MM\"", value)
+	}
+	parsed.minute += at.Hour()*60 + at.Minute()
+	return parsed, nil
+}
+
+// Validate checks the window's bounds.
+func (w BlackoutWindow) Validate() error {
+	start, err := parseWindowTime(w.Start)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	end, err := parseWindowTime(w.End)
+	if err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if start.weekly != end.weekly {
+		return fmt.Errorf("start and end must both name a weekday or neither")
+	}
+	if start.minute == end.minute {
+		return fmt.Errorf("start and end must differ")
+	}
+	return nil
+}
+
+// contains reports whether local falls inside the window and, if so, when the
+// window ends.
+func (w BlackoutWindow) contains(local time.Time) (time.Time, bool) {
+	start, err := parseWindowTime(w.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseWindowTime(w.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	period, now := minutesPerDay, local.Hour()*60+local.Minute()
+	if start.weekly {
+		period = minutesPerWeek
+		now += int(local.Weekday()) * minutesPerDay
+	}
+	var inside bool
+	if start.minute < end.minute {
+		inside = now >= start.minute && now < end.minute
+	} else {
+		inside = now >= start.minute || now < end.minute
+	}
+	if !inside {
+		return time.Time{}, false
+	}
+	// Build the end as a wall-clock time so DST changes inside the window are
+	// honoured.
+	days := ((end.minute/minutesPerDay - now/minutesPerDay) + period/minutesPerDay) % (period / minutesPerDay)
+	clock := end.minute % minutesPerDay
+	until := time.Date(local.Year(), local.Month(), local.Day()+days, clock/60, clock%60, 0, 0, local.Location())
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+days+period/minutesPerDay, clock/60, clock%60, 0, 0, local.Location())
+	}
+	return until, true
+}
+
+// ParseHoliday splits a holiday entry, "YYYY-MM-DD" optionally followed by a
+// name, into its date and name.
+func ParseHoliday(entry string) (string, string, error) {
+	date, name, _ := strings.Cut(strings.TrimSpace(entry), " ")
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return "", "", fmt.Errorf("holiday %q must start with a YYYY-MM-DD date", entry)
+	}
+	return date, strings.TrimSpace(name), nil
+}
+
+// ReadHolidayCalendar reads holiday entries, one per line. Blank lines and
+// lines starting with # are ignored.
+func ReadHolidayCalendar(r io.Reader) ([]string, error) {
+	var holidays []string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		if _, _, err := ParseHoliday(entry); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		holidays = append(holidays, entry)
+	}
+	return holidays, scanner.Err()
+}
+
+// Location returns the schedule's timezone.
+func (s *ScheduleSpec) Location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("schedule.timezone: %w", err)
+	}
+	return loc, nil
+}
+
+// CronSchedule is the Temporal cron expression for the schedule, with its
+// timezone applied.
+func (s *ScheduleSpec) CronSchedule() string {
+	if s.Timezone == "" {
+		return s.Cron
+	}
+	return "CRON_TZ=" + s.Timezone + " " + s.Cron
+}
+
+// Blackout reports whether now falls on a holiday or inside a blackout
+// window, with the reason and the time the blackout ends.
+func (s *ScheduleSpec) Blackout(now time.Time, loc *time.Location) (string, time.Time, bool) {
+	local := now.In(loc)
+	today := local.Format(time.DateOnly)
+	for _, entry := range s.Holidays {
+		date, name, err := ParseHoliday(entry)
+		if err != nil || date != today {
+			continue
+		}
+		reason := "holiday " + date
+		if name != "" {
+			reason += " (" + name + ")"
+		}
+		return reason, time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc), true
+	}
+	for _, window := range s.Blackouts {
+		if until, ok := window.contains(local); ok {
+			reason := "blackout " + window.Start + " - " + window.End
+			if window.Reason != "" {
+				reason += " (" + window.Reason + ")"
+			}
+			return reason, until, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// awaitBlackout checks whether the run starts inside a blackout. Skipped runs
+// return skip; deferred runs sleep until every overlapping blackout has ended.
+// Either way the reason is recorded in events.jsonl and returned.
+func awaitBlackout(ctx workflow.Context, info *workflow.Info, logDir string, spec *ScheduleSpec) (*BlackoutRecord, bool, error) {
+	if len(spec.Blackouts) == 0 && len(spec.Holidays) == 0 {
+		return nil, false, nil
+	}
+	loc, err := spec.Location()
+	if err != nil {
+		return nil, false, err
+	}
+	reason, until, blocked := spec.Blackout(workflow.Now(ctx), loc)
+	if !blocked {
+		return nil, false, nil
+	}
+	event := activities.StepEvent{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Message:    reason,
+	}
+	if spec.OnBlackout != BlackoutDefer {
+		event.Status = "run_skipped"
+		recordEvent(ctx, logDir, event)
+		return &BlackoutRecord{Action: BlackoutSkip, Reason: reason}, true, nil
+	}
+
+	record := &BlackoutRecord{Action: BlackoutDefer, Reason: reason}
+	for blocked {
+		workflow.GetLogger(ctx).Info("deferring run until blackout ends", "reason", reason, "until", until)
+		event.Status = "run_deferred"
+		event.Message = reason + "; deferred until " + until.Format(time.RFC3339)
+		recordEvent(ctx, logDir, event)
+		if err := workflow.Sleep(ctx, until.Sub(workflow.Now(ctx))); err != nil {
+			return record, false, err
+		}
+		record.DeferredUntil = until.Format(time.RFC3339)
+		reason, until, blocked = spec.Blackout(workflow.Now(ctx), loc)
+	}
+	return record, false, nil
+}
@@ -0,0 +1,192 @@
+package workflows
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestBlackout(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	spec := &ScheduleSpec{
+		Blackouts: []BlackoutWindow{
+			{Start: "Fri 18:00", End: "Mon 06:00", Reason: "weekend freeze"},
+			{Start: "23:30", End: "00:30"},
+		},
+		Holidays: []string{"2025-12-25 Christmas"},
+	}
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantIn    bool
+		wantUntil time.Time
+		wantWhy   string
+	}{
+		{"weekday", time.Date(2025, 6, 11, 12, 0, 0, 0, berlin), false, time.Time{}, ""},
+		{"friday evening", time.Date(2025, 6, 13, 18, 0, 0, 0, berlin), true, time.Date(2025, 6, 16, 6, 0, 0, 0, berlin), "weekend freeze"},
+		{"sunday", time.Date(2025, 6, 15, 9, 0, 0, 0, berlin), true, time.Date(2025, 6, 16, 6, 0, 0, 0, berlin), "weekend freeze"},
+		{"monday morning", time.Date(2025, 6, 16, 6, 0, 0, 0, berlin), false, time.Time{}, ""},
+		{"utc input", time.Date(2025, 6, 13, 16, 30, 0, 0, time.UTC), true, time.Date(2025, 6, 16, 6, 0, 0, 0, berlin), "weekend freeze"},
+		{"nightly", time.Date(2025, 6, 11, 23, 45, 0, 0, berlin), true, time.Date(2025, 6, 12, 0, 30, 0, 0, berlin), "23:30 - 00:30"},
+		{"after midnight", time.Date(2025, 6, 12, 0, 10, 0, 0, berlin), true, time.Date(2025, 6, 12, 0, 30, 0, 0, berlin), "23:30 - 00:30"},
+		{"holiday", time.Date(2025, 12, 25, 10, 0, 0, 0, berlin), true, time.Date(2025, 12, 26, 0, 0, 0, 0, berlin), "Christmas"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			why, until, in := spec.Blackout(tt.now, berlin)
+			if in != tt.wantIn {
+				t.Fatalf("in blackout = %v, want %v", in, tt.wantIn)
+			}
+			if !until.Equal(tt.wantUntil) {
+				t.Errorf("until = %v, want %v", until, tt.wantUntil)
+			}
+			if !strings.Contains(why, tt.wantWhy) {
+				t.Errorf("reason = %q, want containing %q", why, tt.wantWhy)
+			}
+		})
+	}
+}
+
+func TestBlackoutEndAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Clocks go forward on Sunday 2025-03-30; the window still ends at 06:00
+	// local time.
+	spec := &ScheduleSpec{Blackouts: []BlackoutWindow{{Start: "Sat 00:00", End: "Mon 06:00"}}}
+	_, until, in := spec.Blackout(time.Date(2025, 3, 29, 12, 0, 0, 0, berlin), berlin)
+	if !in || !until.Equal(time.Date(2025, 3, 31, 6, 0, 0, 0, berlin)) {
+		t.Errorf("Blackout() = %v, %v", until, in)
+	}
+}
+
+func TestBlackoutWindowValidate(t *testing.T) {
+	tests := []struct {
+		window  BlackoutWindow
+		wantErr string
+	}{
+		{BlackoutWindow{Start: "Friday 18:00", End: "mon 06:00"}, ""},
+		{BlackoutWindow{Start: "22:00", End: "06:00"}, ""},
+		{BlackoutWindow{Start: "Fri 18:00", End: "06:00"}, "both"},
+		{BlackoutWindow{Start: "Fry 18:00", End: "Mon 06:00"}, "weekday"},
+		{BlackoutWindow{Start: "Fri 25:00", End: "Mon 06:00"}, "HH:MM"},
+		{BlackoutWindow{Start: "06:00", End: "06:00"}, "differ"},
+	}
+	for _, tt := range tests {
+		err := tt.window.Validate()
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("%+v: unexpected error %v", tt.window, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%+v: error = %v, want containing %q", tt.window, err, tt.wantErr)
+		}
+	}
+}
+
+func TestReadHolidayCalendar(t *testing.T) {
+	holidays, err := ReadHolidayCalendar(strings.NewReader("# 2025\n2025-01-01 New Year\n\n2025-12-25\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holidays) != 2 || holidays[0] != "2025-01-01 New Year" {
+		t.Errorf("holidays = %q", holidays)
+	}
+	if _, err := ReadHolidayCalendar(strings.NewReader("2025-01-01\nxmas\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error = %v, want line 2", err)
+	}
+}
+
+func TestCronScheduleTimezone(t *testing.T) {
+	spec := &ScheduleSpec{Cron: "0 2 * * *"}
+	if got := spec.CronSchedule(); got != "0 2 * * *" {
+		t.Errorf("CronSchedule() = %q", got)
+	}
+	spec.Timezone = "America/New_York"
+	if got := spec.CronSchedule(); got != "CRON_TZ=America/New_York 0 2 * * *" {
+		t.Errorf("CronSchedule() = %q", got)
+	}
+}
+
+func TestBlackoutSkipsOrDefersRun(t *testing.T) {
+	friday := time.Date(2025, 6, 13, 20, 0, 0, 0, time.UTC)
+	for _, action := range []string{BlackoutSkip, BlackoutDefer} {
+		t.Run(action, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+			env.SetStartTime(friday)
+			var startedAt time.Time
+			env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+				startedAt = env.Now()
+				return activities.RunCommandResult{}, nil
+			}, activity.RegisterOptions{Name: "RunCommand"})
+
+			env.ExecuteWorkflow(Pipeline, PipelineInput{
+				LogDir: t.TempDir(),
+				Schedule: &ScheduleSpec{
+					Cron:       "@hourly",
+					Blackouts:  []BlackoutWindow{{Start: "Fri 18:00", End: "Mon 06:00"}},
+					OnBlackout: action,
+				},
+				Steps: []PipelineStep{{ID: "deploy", Type: "command", Command: "deploy"}},
+			})
+			if err := env.GetWorkflowError(); err != nil {
+				t.Fatal(err)
+			}
+			var result PipelineResult
+			env.GetWorkflowResult(&result)
+			if result.Blackout == nil || result.Blackout.Action != action || !strings.Contains(result.Blackout.Reason, "Fri 18:00") {
+				t.Fatalf("blackout = %+v", result.Blackout)
+			}
+			if action == BlackoutSkip {
+				if result.Status != StatusBlackoutSkipped || !startedAt.IsZero() {
+					t.Errorf("status = %s, step started at %v", result.Status, startedAt)
+				}
+				return
+			}
+			monday := time.Date(2025, 6, 16, 6, 0, 0, 0, time.UTC)
+			if !result.Succeeded || startedAt.Before(monday) {
+				t.Errorf("status = %s, step started at %v, want after %v", result.Status, startedAt, monday)
+			}
+			if result.Blackout.DeferredUntil != monday.Format(time.RFC3339) {
+				t.Errorf("deferred until %s", result.Blackout.DeferredUntil)
+			}
+		})
+	}
+}
+
+func TestCarriedParamsSurviveSkippedRun(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetLastCompletionResult(PipelineResult{Status: StatusBlackoutSkipped, Params: map[string]string{"offset": "1200"}})
+	var got string
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		got = input.Env["SYGALDRY_PARAM_OFFSET"]
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:   t.TempDir(),
+		Params:   map[string]string{"offset": "0"},
+		Schedule: &ScheduleSpec{Cron: "@daily", CarryOutputs: map[string]string{"offset": "ingest.offset"}},
+		Steps:    []PipelineStep{{ID: "ingest", Type: "command", Command: "ingest"}},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if got != "1200" {
+		t.Errorf("offset = %q, want the value carried through the skipped run", got)
+	}
+}
@@ -0,0 +1,169 @@
+package workflows
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Scheduling strategies, which decide the steps started first when not all
+// runnable steps can start at once.
+const (
+	ScheduleBreadthFirst    = "breadth_first"
+	ScheduleCriticalPath    = "critical_path"
+	ScheduleResourcePacking = "resource_packing"
+)
+
+// SchedulingSpec limits how many steps of a run start together and picks
+// them by Strategy:
+//   - breadth_first (default) starts runnable steps in plan order;
+//   - critical_path starts the steps heading the longest chains of remaining
+//     work first, by expected duration (see progressTracker);
+//   - resource_packing starts the steps claiming most of Resources first and
+//     fills the capacity left with smaller ones.
+//
+// Approval steps wait on people and take no slot.
+type SchedulingSpec struct {
+	Strategy string `json:"strategy" yaml:"strategy"`
+	// MaxParallel caps the steps started per scheduling round; 0 is no cap.
+	MaxParallel int `json:"maxParallel" yaml:"max_parallel"`
+	// Resources is the capacity shared by the steps' resources, e.g.
+	// {gpu: 4, cpu: 32}.
+	Resources map[string]int `json:"resources" yaml:"resources"`
+}
+
+// Validate checks the spec against the steps of the plan. Steps may only
+// claim resources the spec declares, and no more than its capacity.
+func (s *SchedulingSpec) Validate(steps []PipelineStep) error {
+	var spec SchedulingSpec
+	if s != nil {
+		spec = *s
+	}
+	switch spec.Strategy {
+	case "", ScheduleBreadthFirst, ScheduleCriticalPath, ScheduleResourcePacking:
+	default:
+		return fmt.Errorf("scheduling.strategy must be %s, %s or %s", ScheduleBreadthFirst, ScheduleCriticalPath, ScheduleResourcePacking)
+	}
+	if spec.MaxParallel < 0 {
+		return fmt.Errorf("scheduling.max_parallel must not be negative")
+	}
+	for name, capacity := range spec.Resources {
+		if capacity <= 0 {
+			return fmt.Errorf("scheduling.resources.%s must be positive", name)
+		}
+	}
+	if spec.Strategy == ScheduleResourcePacking && len(spec.Resources) == 0 {
+		return fmt.Errorf("scheduling.strategy %s requires scheduling.resources", ScheduleResourcePacking)
+	}
+	for _, step := range steps {
+		if len(step.Resources) > 0 && (step.Type == "approval" || step.Type == "join") {
+			return fmt.Errorf("step %s: %s steps cannot claim resources", step.ID, step.Type)
+		}
+		for name, amount := range step.Resources {
+			capacity, ok := spec.Resources[name]
+			if !ok {
+				return fmt.Errorf("step %s claims resource %s, which scheduling.resources does not declare", step.ID, name)
+			}
+			if amount < 0 || amount > capacity {
+				return fmt.Errorf("step %s claims %d %s; it must be between 0 and the capacity %d", step.ID, amount, name, capacity)
+			}
+		}
+	}
+	return nil
+}
+
+// pick returns the runnable steps to start this round, in the order to start
+// them. runnable is in plan order; weight gives expected step durations for
+// critical_path.
+func (s *SchedulingSpec) pick(runnable, steps []PipelineStep, weight func(id string) int64) []PipelineStep {
+	if s == nil || len(runnable) <= 1 {
+		return runnable
+	}
+	candidates := append([]PipelineStep(nil), runnable...)
+	switch s.Strategy {
+	case ScheduleCriticalPath:
+		chains := chainLengths(steps, weight)
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return chains[candidates[i].ID] > chains[candidates[j].ID]
+		})
+	case ScheduleResourcePacking:
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return s.share(candidates[i]) > s.share(candidates[j])
+		})
+	}
+
+	free := make(map[string]int, len(s.Resources))
+	for name, capacity := range s.Resources {
+		free[name] = capacity
+	}
+	picked := make([]PipelineStep, 0, len(candidates))
+	slots := 0
+	for _, step := range candidates {
+		if step.Type == "approval" {
+			picked = append(picked, step)
+			continue
+		}
+		if s.MaxParallel > 0 && slots == s.MaxParallel {
+			continue
+		}
+		if !fits(step.Resources, free) {
+			continue
+		}
+		for name, amount := range step.Resources {
+			free[name] -= amount
+		}
+		picked = append(picked, step)
+		slots++
+	}
+	return picked
+}
+
+// share is the fraction of the capacity a step claims, summed over
+// resources.
+func (s *SchedulingSpec) share(step PipelineStep) float64 {
+	var share float64
+	for name, amount := range step.Resources {
+		if capacity := s.Resources[name]; capacity > 0 {
+			share += float64(amount) / float64(capacity)
+		}
+	}
+	return share
+}
+
+func fits(claims, free map[string]int) bool {
+	for name, amount := range claims {
+		if amount > free[name] {
+			return false
+		}
+	}
+	return true
+}
+
+// chainLengths maps each step to the expected duration of the longest chain
+// of steps through depends_on that starts with it.
+func chainLengths(steps []PipelineStep, weight func(id string) int64) map[string]int64 {
+	dependents := map[string][]string{}
+	for _, step := range steps {
+		for _, dep := range step.DependsOn {
+			dependents[dep] = append(dependents[dep], step.ID)
+		}
+	}
+	chains := map[string]int64{}
+	var chainOf func(id string, depth int) int64
+	chainOf = func(id string, depth int) int64 {
+		if value, ok := chains[id]; ok {
+			return value
+		}
+		var rest int64
+		if depth <= len(steps) {
+			for _, next := range dependents[id] {
+				rest = max(rest, chainOf(next, depth+1))
+			}
+		}
+		chains[id] = weight(id) + rest
+		return chains[id]
+	}
+	for _, step := range steps {
+		chainOf(step.ID, 0)
+	}
+	return chains
+}
@@ -0,0 +1,94 @@
+package workflows
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func stepIDs(steps []PipelineStep) []string {
+	ids := make([]string, 0, len(steps))
+	for _, step := range steps {
+		ids = append(ids, step.ID)
+	}
+	return ids
+}
+
+func TestSchedulingPickCriticalPath(t *testing.T) {
+	steps := []PipelineStep{
+		{ID: "lint"},
+		{ID: "docs"},
+		{ID: "fetch"},
+		{ID: "train", DependsOn: []string{"fetch"}},
+		{ID: "gate", Type: "approval"},
+	}
+	durations := map[string]int64{"lint": 60, "docs": 300, "fetch": 120, "train": 3600}
+	weight := func(id string) int64 { return durations[id] }
+	runnable := []PipelineStep{steps[0], steps[1], steps[2], steps[4]}
+
+	spec := &SchedulingSpec{Strategy: ScheduleCriticalPath, MaxParallel: 2}
+	// fetch heads the longest chain (fetch, train); the gate takes no slot.
+	if got, want := stepIDs(spec.pick(runnable, steps, weight)), []string{"fetch", "docs", "gate"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("critical_path picked %v, want %v", got, want)
+	}
+	spec.Strategy = ScheduleBreadthFirst
+	if got, want := stepIDs(spec.pick(runnable, steps, weight)), []string{"lint", "docs", "gate"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("breadth_first picked %v, want %v", got, want)
+	}
+	var none *SchedulingSpec
+	if got := none.pick(runnable, steps, weight); len(got) != len(runnable) {
+		t.Errorf("without scheduling picked %v, want all", stepIDs(got))
+	}
+}
+
+func TestSchedulingPickResourcePacking(t *testing.T) {
+	gpus := func(id string, n int) PipelineStep { return PipelineStep{ID: id, Resources: map[string]int{"gpu": n}} }
+	runnable := []PipelineStep{gpus("eval-a", 1), gpus("train", 3), gpus("eval-b", 1), gpus("sweep", 2), {ID: "report"}}
+	spec := &SchedulingSpec{Strategy: ScheduleResourcePacking, Resources: map[string]int{"gpu": 4}}
+	if got, want := stepIDs(spec.pick(runnable, runnable, nil)), []string{"train", "eval-a", "report"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resource_packing picked %v, want %v", got, want)
+	}
+	// breadth_first honours the capacity too, but in plan order.
+	spec.Strategy = ""
+	if got, want := stepIDs(spec.pick(runnable, runnable, nil)), []string{"eval-a", "train", "report"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("breadth_first picked %v, want %v", got, want)
+	}
+}
+
+func TestPipelineSchedulesCriticalPathFirst(t *testing.T) {
+	var mu sync.Mutex
+	var started []string
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		mu.Lock()
+		started = append(started, input.StepID)
+		mu.Unlock()
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:     t.TempDir(),
+		Scheduling: &SchedulingSpec{Strategy: ScheduleCriticalPath, MaxParallel: 1},
+		Steps: []PipelineStep{
+			{ID: "lint", Type: "command", Command: "lint"},
+			{ID: "fetch", Type: "command", Command: "fetch"},
+			{ID: "train", Type: "command", Command: "train", DependsOn: []string{"fetch"}},
+			{ID: "publish", Type: "command", Command: "publish", DependsOn: []string{"train"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	// Without durations, chains compare by length: fetch heads three steps,
+	// train two. Then lint and publish tie and go in plan order.
+	if want := []string{"fetch", "train", "lint", "publish"}; !reflect.DeepEqual(started, want) {
+		t.Errorf("steps started in order %v, want %v", started, want)
+	}
+}
@@ -0,0 +1,48 @@
+package workflows
+
+import "go.temporal.io/sdk/workflow"
+
+// StepsQuery returns the StepState of each step of a Pipeline or Orchestrate
+// run, in plan order.
+const StepsQuery = "step_states"
+
+// StepState is where a step of a run is: pending, running, or its outcome
+// (success, failed, skipped or cancelled).
+type StepState struct {
+	ID       string `json:"id"`
+	State    string `json:"state"`
+	ExitCode int    `json:"exitCode"`
+	// Message is the skip reason or the error of a failed step.
+	Message string `json:"message,omitempty"`
+}
+
+// setStepsQuery registers the StepsQuery handler, which reports states().
+func setStepsQuery(ctx workflow.Context, states func() []StepState) {
+	err := workflow.SetQueryHandler(ctx, StepsQuery, func() ([]StepState, error) {
+		return states(), nil
+	})
+	if err != nil {
+		workflow.GetLogger(ctx).Warn("unable to register step states query", "error", err)
+	}
+}
+
+// pipelineStepStates reports the steps of a Pipeline run from its outcomes
+// and the steps running now.
+func pipelineStepStates(steps []PipelineStep, outcomes map[string]StepOutcome, running map[string]bool) []StepState {
+	states := make([]StepState, 0, len(steps))
+	for _, step := range steps {
+		state := StepState{ID: step.ID, State: "pending"}
+		if outcome, ok := outcomes[step.ID]; ok {
+			state.State = outcome.State
+			state.ExitCode = outcome.Result.ExitCode
+			state.Message = outcome.SkipReason
+			if outcome.Result.Error != "" {
+				state.Message = outcome.Result.Error
+			}
+		} else if running[step.ID] {
+			state.State = "running"
+		}
+		states = append(states, state)
+	}
+	return states
+}
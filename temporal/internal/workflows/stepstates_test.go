@@ -0,0 +1,38 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestPipelineStepStatesQuery(t *testing.T) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{ExitCode: 3}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "lint", Type: "command", Command: "lint", AllowFailure: true},
+			{ID: "deploy", Type: "command", Command: "deploy", DependsOn: []string{"lint"}, When: &When{Step: "lint", Status: "success"}},
+		},
+	})
+	value, err := env.QueryWorkflow(StepsQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []StepState
+	if err := value.Get(&states); err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 || states[0].State != "failed" || states[0].ExitCode != 3 || states[1].State != "skipped" || states[1].Message == "" {
+		t.Errorf("states = %+v", states)
+	}
+}
@@ -0,0 +1,176 @@
+package workflows
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// Step type usage metrics, counted once per step of each run and tagged
+// step_type. Deprecated uses are also tagged field, empty for the type
+// itself.
+const (
+	StepTypeUsageMetric   = "sygaldry_step_type_usage"
+	DeprecatedUsageMetric = "sygaldry_deprecated_usage"
+)
+
+// Deprecation marks a step type or field that is going away.
+type Deprecation struct {
+	// Since is the release that deprecated it and Removal the first one
+	// expected to reject it.
+	Since   string
+	Removal string
+	// Replacement says what to use instead.
+	Replacement string
+}
+
+// StepType is an entry of the step type registry.
+type StepType struct {
+	Deprecated *Deprecation
+	// DeprecatedFields maps the YAML path of a field, relative to the step,
+	// e.g. "hf_download_dataset.config", to its deprecation.
+	DeprecatedFields map[string]Deprecation
+}
+
+// stepTypes registers every step type a plan may use. Mark a type or one of
+// its fields deprecated here at least one release before it is removed, so
+// plans using it are warned at validation and its use shows in the metrics.
+var stepTypes = map[string]StepType{
+	"command":             {},
+	"download":            {},
+	"docker_build":        {},
+	"docker_push":         {},
+	"package_build":       {},
+	"container_job":       {},
+	"hf_download_dataset": {},
+	"hf_download_model":   {},
+	"approval":            {},
+	"watch_path":          {},
+	"join":                {},
+}
+
+// IsStepType reports whether name is a registered step type.
+func IsStepType(name string) bool {
+	_, ok := stepTypes[name]
+	return ok
+}
+
+// StepTypes returns the registered step types, sorted.
+func StepTypes() []string {
+	names := make([]string, 0, len(stepTypes))
+	for name := range stepTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DeprecationWarning is a use of a deprecated step type or field by a step.
+type DeprecationWarning struct {
+	StepID      string `json:"stepId"`
+	StepType    string `json:"stepType"`
+	Field       string `json:"field,omitempty"`
+	Since       string `json:"since,omitempty"`
+	Removal     string `json:"removal,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+func (w DeprecationWarning) String() string {
+	subject := "step type " + w.StepType
+	if w.Field != "" {
+		subject = "field " + w.Field + " of " + w.StepType + " steps"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "step %s: %s is deprecated", w.StepID, subject)
+	if w.Since != "" {
+		fmt.Fprintf(&b, " since %s", w.Since)
+	}
+	if w.Removal != "" {
+		fmt.Fprintf(&b, " and will be removed in %s", w.Removal)
+	}
+	if w.Replacement != "" {
+		fmt.Fprintf(&b, "; use %s instead", w.Replacement)
+	}
+	return b.String()
+}
+
+// DeprecationWarnings lists the deprecated step types and set deprecated
+// fields the steps use, in step order.
+func DeprecationWarnings(steps []PipelineStep) []DeprecationWarning {
+	var warnings []DeprecationWarning
+	for _, step := range steps {
+		registered := stepTypes[step.Type]
+		warn := func(field string, deprecation Deprecation) {
+			warnings = append(warnings, DeprecationWarning{
+				StepID:      step.ID,
+				StepType:    step.Type,
+				Field:       field,
+				Since:       deprecation.Since,
+				Removal:     deprecation.Removal,
+				Replacement: deprecation.Replacement,
+			})
+		}
+		if registered.Deprecated != nil {
+			warn("", *registered.Deprecated)
+		}
+		fields := make([]string, 0, len(registered.DeprecatedFields))
+		for field := range registered.DeprecatedFields {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			if fieldSet(step, field) {
+				warn(field, registered.DeprecatedFields[field])
+			}
+		}
+	}
+	return warnings
+}
+
+// fieldSet reports whether the field at a dotted YAML path of step is set.
+func fieldSet(step PipelineStep, path string) bool {
+	value := reflect.ValueOf(step)
+	for _, name := range strings.Split(path, ".") {
+		for value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				return false
+			}
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Struct {
+			return false
+		}
+		found := false
+		for i := 0; i < value.NumField(); i++ {
+			if tag, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("yaml"), ","); tag == name {
+				value, found = value.Field(i), true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return !value.IsZero()
+}
+
+// recordStepTypeUsage counts the run's steps by type, and its deprecated
+// uses, in the worker's metrics, and logs each deprecated use.
+func recordStepTypeUsage(ctx workflow.Context, steps []PipelineStep) {
+	handler := workflow.GetMetricsHandler(ctx)
+	counts := map[string]int64{}
+	for _, step := range steps {
+		counts[step.Type]++
+	}
+	for stepType, count := range counts {
+		handler.WithTags(map[string]string{"step_type": stepType}).Counter(StepTypeUsageMetric).Inc(count)
+	}
+	for _, warning := range DeprecationWarnings(steps) {
+		handler.WithTags(map[string]string{"step_type": warning.StepType, "field": warning.Field}).Counter(DeprecatedUsageMetric).Inc(1)
+		workflow.GetLogger(ctx).Warn("deprecated step type or field", "id", warning.StepID, "type", warning.StepType,
+			"field", warning.Field, "removal", warning.Removal, "replacement", warning.Replacement)
+	}
+}
@@ -0,0 +1,80 @@
+package workflows
+
+import (
+	"context"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// deprecate marks a step type and one of its fields deprecated for the test.
+func deprecate(t *testing.T, stepType, field string) {
+	t.Helper()
+	saved := stepTypes[stepType]
+	t.Cleanup(func() { stepTypes[stepType] = saved })
+	stepTypes[stepType] = StepType{
+		Deprecated:       &Deprecation{Since: "v1.4", Removal: "v2.0", Replacement: "command"},
+		DeprecatedFields: map[string]Deprecation{field: {Since: "v1.4", Replacement: "hf_download_dataset.allow_patterns"}},
+	}
+}
+
+func TestDeprecationWarnings(t *testing.T) {
+	deprecate(t, "hf_download_dataset", "hf_download_dataset.config")
+	steps := []PipelineStep{
+		{ID: "build", Type: "command", Command: "make"},
+		{ID: "subset", Type: "hf_download_dataset", HFDownloadDataset: &HFDownloadDatasetSpec{DatasetID: "ns/ds", Config: "en"}},
+		{ID: "full", Type: "hf_download_dataset", HFDownloadDataset: &HFDownloadDatasetSpec{DatasetID: "ns/ds"}},
+	}
+	warnings := DeprecationWarnings(steps)
+	if len(warnings) != 3 {
+		t.Fatalf("warnings = %+v, want the type twice and the field once", warnings)
+	}
+	if got := warnings[0].String(); got != "step subset: step type hf_download_dataset is deprecated since v1.4 and will be removed in v2.0; use command instead" {
+		t.Errorf("type warning = %q", got)
+	}
+	if got := warnings[1]; got.StepID != "subset" || got.Field != "hf_download_dataset.config" {
+		t.Errorf("field warning = %+v", got)
+	}
+	if got := warnings[1].String(); got != "step subset: field hf_download_dataset.config of hf_download_dataset steps is deprecated since v1.4; use hf_download_dataset.allow_patterns instead" {
+		t.Errorf("field warning = %q", got)
+	}
+	if got := warnings[2]; got.StepID != "full" || got.Field != "" {
+		t.Errorf("unset field warned: %+v", got)
+	}
+}
+
+func TestPipelineCountsStepTypes(t *testing.T) {
+	deprecate(t, "command", "env")
+	metrics := newCounterHandler()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(metrics)
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: t.TempDir(),
+		Steps: []PipelineStep{
+			{ID: "a", Type: "command", Command: "true", Env: map[string]string{"MODE": "fast"}},
+			{ID: "b", Type: "command", Command: "true"},
+			{ID: "gate", Type: "approval", When: &When{Step: "a", Status: "failure"}, DependsOn: []string{"a"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{
+		StepTypeUsageMetric + "/command":  2,
+		StepTypeUsageMetric + "/approval": 1,
+		// Both steps use the type; only a sets the field.
+		DeprecatedUsageMetric + "/command": 3,
+	}
+	for key, count := range want {
+		if got := metrics.counts[key]; got != count {
+			t.Errorf("%s = %d, want %d (counts: %v)", key, got, count, metrics.counts)
+		}
+	}
+}
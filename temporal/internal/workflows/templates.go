@@ -0,0 +1,124 @@
+package workflows
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// templateRef matches ${params.<name>} and ${steps.<id>.outputs.<key>}.
+var templateRef = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+var (
+	platformPattern = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9_]+(/[a-z0-9]+)?$`)
+	targetPattern   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+// TemplateRefs returns the references used in s, e.g. "params.env" or
+// "steps.version.outputs.tag".
+func TemplateRefs(s string) []string {
+	var refs []string
+	for _, match := range templateRef.FindAllStringSubmatch(s, -1) {
+		refs = append(refs, strings.TrimSpace(match[1]))
+	}
+	return refs
+}
+
+// ParseTemplateRef splits a reference into its kind ("params" or "steps"),
+// step ID (steps only) and name. ok is false for malformed references.
+func ParseTemplateRef(ref string) (kind, stepID, name string, ok bool) {
+	parts := strings.Split(ref, ".")
+	switch {
+	case len(parts) == 2 && parts[0] == "params" && parts[1] != "":
+		return "params", "", parts[1], true
+	case len(parts) == 4 && parts[0] == "steps" && parts[2] == "outputs" && parts[1] != "" && parts[3] != "":
+		return "steps", parts[1], parts[3], true
+	}
+	return "", "", "", false
+}
+
+// expandTemplate substitutes references in s with parameter values and
+// outputs of completed steps. Unresolvable references are an error.
+func expandTemplate(s string, params map[string]string, outcomes map[string]StepOutcome) (string, error) {
+	var firstErr error
+	expanded := templateRef.ReplaceAllStringFunc(s, func(match string) string {
+		ref := strings.TrimSpace(match[2 : len(match)-1])
+		kind, stepID, name, ok := ParseTemplateRef(ref)
+		var value string
+		var found bool
+		switch {
+		case !ok:
+		case kind == "params":
+			value, found = params[name]
+		default:
+			value, found = outcomes[stepID].Result.Outputs[name]
+		}
+		if !found && firstErr == nil {
+			firstErr = fmt.Errorf("unresolved reference ${%s}", ref)
+		}
+		return value
+	})
+	return expanded, firstErr
+}
+
+// resolveDockerBuild expands templates in a docker_build spec and validates
+// the resulting values.
+func resolveDockerBuild(spec DockerBuildSpec, params map[string]string, outcomes map[string]StepOutcome) (DockerBuildSpec, error) {
+	var err error
+	expand := func(field, s string) string {
+		if err != nil {
+			return s
+		}
+		var expanded string
+		expanded, err = expandTemplate(s, params, outcomes)
+		if err != nil {
+			err = fmt.Errorf("docker_build.%s: %w", field, err)
+		}
+		return expanded
+	}
+
+	resolved := spec
+	resolved.Image = expand("image", spec.Image)
+	resolved.Target = expand("target", spec.Target)
+	resolved.Platform = expand("platform", spec.Platform)
+	resolved.Dockerfile = expand("dockerfile", spec.Dockerfile)
+	resolved.BuildArgs = expandMap("build_args", spec.BuildArgs, expand)
+	resolved.Labels = expandMap("labels", spec.Labels, expand)
+	if err != nil {
+		return spec, err
+	}
+
+	if resolved.Image == "" || strings.ContainsAny(resolved.Image, " \t\n") {
+		return spec, fmt.Errorf("docker_build.image resolved to invalid reference %q", resolved.Image)
+	}
+	if resolved.Target != "" && !targetPattern.MatchString(resolved.Target) {
+		return spec, fmt.Errorf("docker_build.target resolved to invalid stage name %q", resolved.Target)
+	}
+	if resolved.Platform != "" {
+		for _, platform := range strings.Split(resolved.Platform, ",") {
+			if !platformPattern.MatchString(strings.TrimSpace(platform)) {
+				return spec, fmt.Errorf("docker_build.platform resolved to invalid platform %q", resolved.Platform)
+			}
+		}
+	}
+	return resolved, nil
+}
+
+func expandMap(field string, values map[string]string, expand func(field, s string) string) map[string]string {
+	if values == nil {
+		return nil
+	}
+	// Sorted, so the error reported for several bad values is the same on
+	// every replay.
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expanded := make(map[string]string, len(values))
+	for _, key := range keys {
+		expanded[key] = expand(field+"."+key, values[key])
+	}
+	return expanded
+}
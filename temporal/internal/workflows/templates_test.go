@@ -0,0 +1,67 @@
+package workflows
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	params := map[string]string{"env": "prod"}
+	outcomes := map[string]StepOutcome{
+		"version": {ID: "version", Result: PipelineStepResult{Outputs: map[string]string{"tag": "1.4.2"}}},
+	}
+	got, err := expandTemplate("org/app:${ steps.version.outputs.tag }-${params.env}", params, outcomes)
+	if err != nil || got != "org/app:1.4.2-prod" {
+		t.Errorf("expandTemplate() = %q, %v", got, err)
+	}
+	for _, s := range []string{"${params.missing}", "${steps.version.outputs.nope}", "${steps.ghost.outputs.tag}", "${env}"} {
+		if _, err := expandTemplate(s, params, outcomes); err == nil {
+			t.Errorf("expandTemplate(%q) should fail", s)
+		}
+	}
+}
+
+func TestResolveDockerBuild(t *testing.T) {
+	spec := DockerBuildSpec{
+		Image:     "org/app:${params.env}",
+		Target:    "${params.env}",
+		Platform:  "${params.platform}",
+		BuildArgs: map[string]string{"MODE": "${params.env}", "FIXED": "1"},
+	}
+	resolved, err := resolveDockerBuild(spec, map[string]string{"env": "dev", "platform": "linux/amd64,linux/arm64/v8"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Image != "org/app:dev" || resolved.Target != "dev" || resolved.BuildArgs["MODE"] != "dev" || resolved.BuildArgs["FIXED"] != "1" {
+		t.Errorf("unexpected resolution: %+v", resolved)
+	}
+	if spec.BuildArgs["MODE"] != "${params.env}" {
+		t.Error("resolveDockerBuild must not modify the plan's spec")
+	}
+
+	tests := []struct {
+		params  map[string]string
+		wantErr string
+	}{
+		{map[string]string{"env": "dev stage", "platform": "linux/amd64"}, "image"},
+		{map[string]string{"env": "dev", "platform": "amd64"}, "platform"},
+		{map[string]string{"env": "dev"}, "docker_build.platform: unresolved"},
+	}
+	for _, tt := range tests {
+		_, err := resolveDockerBuild(spec, tt.params, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("params %v: error = %v, want %q", tt.params, err, tt.wantErr)
+		}
+	}
+	// With several bad build args the first in key order is reported.
+	spec = DockerBuildSpec{Image: "org/app", BuildArgs: map[string]string{}}
+	for _, key := range []string{"E", "B", "D", "A", "C"} {
+		spec.BuildArgs[key] = "${params." + key + "}"
+	}
+	for i := 0; i < 20; i++ {
+		_, err := resolveDockerBuild(spec, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "docker_build.build_args.A:") {
+			t.Fatalf("error = %v, want build_args.A", err)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package workflows
+
+import "go.temporal.io/sdk/workflow"
+
+// Change IDs of commands added to Pipeline for every run, whatever its plan.
+// A run started before a change replays without its commands; commands
+// that only plans using a newer field can reach need no change ID.
+const (
+	skipEventsChange     = "step-skipped-events"
+	progressEventsChange = "pipeline-progress-events"
+	runIndexChange       = "run-index"
+	circuitRecordsChange = "circuit-records"
+	effectivePlanChange  = "effective-plan"
+	archiveRunChange     = "archive-run"
+)
+
+// hasChange reports whether the run records the commands of change: always
+// for runs started since, never when replaying a run started before it.
+func hasChange(ctx workflow.Context, change string) bool {
+	return workflow.GetVersion(ctx, change, workflow.DefaultVersion, 1) == 1
+}
@@ -0,0 +1,113 @@
+package workflows
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// runBeforeChanges runs a plan the first Pipeline could run, failing step a
+// so that b is skipped and downloading with fetch, as a replay of a run
+// started before changes.
+func runBeforeChanges(t *testing.T, changes ...string) string {
+	t.Helper()
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.RunCommandInput) (activities.RunCommandResult, error) {
+		return activities.RunCommandResult{ExitCode: 1}, nil
+	}, activity.RegisterOptions{Name: "RunCommand"})
+	env.RegisterActivityWithOptions(func(ctx context.Context, input activities.DownloadInput) (activities.DownloadResult, error) {
+		return activities.DownloadResult{}, nil
+	}, activity.RegisterOptions{Name: "DownloadFile"})
+	for _, change := range changes {
+		env.OnGetVersion(change, workflow.DefaultVersion, 1).Return(workflow.DefaultVersion)
+	}
+	logDir := t.TempDir()
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir: logDir,
+		Steps: []PipelineStep{
+			{ID: "a", Type: "command", Command: "false", AllowFailure: true},
+			{ID: "b", Type: "command", Command: "true", DependsOn: []string{"a"}},
+			{ID: "fetch", Type: "download", Download: &DownloadSpec{URL: "https://data.example.com/x.csv", Output: "x.csv"}},
+		},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	return logDir
+}
+
+func readEvents(logDir string) string {
+	data, _ := os.ReadFile(filepath.Join(logDir, "events.jsonl"))
+	return string(data)
+}
+
+func TestSkipEventsChange(t *testing.T) {
+	if got := readEvents(runBeforeChanges(t)); !strings.Contains(got, `"step_skipped"`) {
+		t.Errorf("new run events = %q, want step_skipped", got)
+	}
+	if got := readEvents(runBeforeChanges(t, skipEventsChange)); strings.Contains(got, `"step_skipped"`) {
+		t.Errorf("replayed run events = %q, want no step_skipped", got)
+	}
+}
+
+func TestProgressEventsChange(t *testing.T) {
+	if got := readEvents(runBeforeChanges(t)); !strings.Contains(got, `"pipeline_progress"`) {
+		t.Errorf("new run events = %q, want pipeline_progress", got)
+	}
+	if got := readEvents(runBeforeChanges(t, progressEventsChange)); strings.Contains(got, `"pipeline_progress"`) {
+		t.Errorf("replayed run events = %q, want no pipeline_progress", got)
+	}
+}
+
+func TestRunIndexChange(t *testing.T) {
+	if _, err := os.Stat(activities.RunIndexPath(runBeforeChanges(t))); err != nil {
+		t.Errorf("new run: %v, want it indexed", err)
+	}
+	if _, err := os.Stat(activities.RunIndexPath(runBeforeChanges(t, runIndexChange))); !os.IsNotExist(err) {
+		t.Errorf("replayed run: %v, want no run index", err)
+	}
+}
+
+func TestCircuitRecordsChange(t *testing.T) {
+	if _, err := os.Stat(activities.CircuitPath(runBeforeChanges(t))); err != nil {
+		t.Errorf("new run: %v, want the download's circuit recorded", err)
+	}
+	if _, err := os.Stat(activities.CircuitPath(runBeforeChanges(t, circuitRecordsChange))); !os.IsNotExist(err) {
+		t.Errorf("replayed run: %v, want no circuit records", err)
+	}
+}
+
+func TestEffectivePlanChange(t *testing.T) {
+	written := func(logDir string) []string {
+		files, _ := filepath.Glob(filepath.Join(logDir, "*"+activities.EffectivePlanFile))
+		return files
+	}
+	if got := written(runBeforeChanges(t)); len(got) != 1 {
+		t.Errorf("new run wrote %v, want the effective plan", got)
+	}
+	if got := written(runBeforeChanges(t, effectivePlanChange)); len(got) != 0 {
+		t.Errorf("replayed run wrote %v, want no effective plan", got)
+	}
+}
+
+func TestArchiveRunChange(t *testing.T) {
+	archived := func(logDir string) []string {
+		files, _ := filepath.Glob(activities.ArchivedRunPath(logDir, "*", "*"))
+		return files
+	}
+	if got := archived(runBeforeChanges(t)); len(got) != 1 {
+		t.Errorf("new run archived %v, want one copy", got)
+	}
+	if got := archived(runBeforeChanges(t, archiveRunChange)); len(got) != 0 {
+		t.Errorf("replayed run archived %v, want none", got)
+	}
+}
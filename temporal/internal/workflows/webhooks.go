@@ -0,0 +1,80 @@
+package workflows
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"temporal-orchestration/internal/activities"
+)
+
+// WebhookSpec notifies a downstream system when the pipeline finishes.
+// SecretEnv names a worker environment variable holding the HMAC-SHA256 key;
+// Fields optionally limits the payload to top-level PipelineResult fields.
+type WebhookSpec struct {
+	URL       string   `json:"url" yaml:"url"`
+	SecretEnv string   `json:"secretEnv" yaml:"secret_env"`
+	Fields    []string `json:"fields" yaml:"fields"`
+}
+
+// webhookFields are the PipelineResult fields a webhook may select.
+var webhookFields = map[string]bool{
+	"succeeded":     true,
+	"status":        true,
+	"steps":         true,
+	"params":        true,
+	"prerequisites": true,
+	"baseline":      true,
+	"blackout":      true,
+	"logs":          true,
+	"effectivePlan": true,
+}
+
+// ValidWebhookField reports whether name is a selectable result field.
+func ValidWebhookField(name string) bool {
+	return webhookFields[name]
+}
+
+// notifyWebhooks posts result to every webhook in parallel. Deliveries are
+// retried by Temporal; a webhook that still fails is logged and does not
+// change the pipeline outcome. It uses a disconnected context so cancelled
+// runs are reported too.
+func notifyWebhooks(ctx workflow.Context, info *workflow.Info, hooks []WebhookSpec, result PipelineResult) {
+	if len(hooks) == 0 {
+		return
+	}
+	logger := workflow.GetLogger(ctx)
+	payload, err := json.Marshal(result)
+	if err != nil {
+		logger.Warn("unable to encode result for webhooks", "error", err)
+		return
+	}
+	hookCtx, _ := workflow.NewDisconnectedContext(ctx)
+	hookCtx = workflow.WithActivityOptions(hookCtx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    5 * time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    2 * time.Minute,
+			MaximumAttempts:    6,
+		},
+	})
+	futures := make([]workflow.Future, len(hooks))
+	for i, hook := range hooks {
+		futures[i] = workflow.ExecuteActivity(hookCtx, activities.PostWebhook, activities.WebhookInput{
+			URL:        hook.URL,
+			SecretEnv:  hook.SecretEnv,
+			Fields:     hook.Fields,
+			WorkflowID: info.WorkflowExecution.ID,
+			RunID:      info.WorkflowExecution.RunID,
+			Result:     payload,
+		})
+	}
+	for i, future := range futures {
+		if err := future.Get(hookCtx, nil); err != nil {
+			logger.Warn("result webhook failed", "url", hooks[i].URL, "error", err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package workflows
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.temporal.io/sdk/testsuite"
+
+	"temporal-orchestration/internal/activities"
+)
+
+func TestPipelineNotifiesWebhooks(t *testing.T) {
+	deliveries := make(chan activities.WebhookPayload, 2)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload activities.WebhookPayload
+		json.Unmarshal(body, &payload)
+		deliveries <- payload
+	}))
+	defer hook.Close()
+	unhealthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unhealthy.Close()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.RegisterActivity(activities.CheckRequirement)
+	env.RegisterActivity(activities.PostWebhook)
+
+	env.ExecuteWorkflow(Pipeline, PipelineInput{
+		LogDir:   t.TempDir(),
+		Require:  []Requirement{{Name: "api", HTTP: &HTTPRequirement{URL: unhealthy.URL}}},
+		Webhooks: []WebhookSpec{{URL: hook.URL, Fields: []string{"status"}}},
+		Steps:    []PipelineStep{{ID: "never", Type: "command", Command: "false"}},
+	})
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-deliveries:
+		var result map[string]any
+		json.Unmarshal(payload.Result, &result)
+		if result["status"] != StatusPrerequisitesNotMet || len(result) != 1 {
+			t.Errorf("unexpected webhook result: %s", payload.Result)
+		}
+	default:
+		t.Fatal("webhook was not called")
+	}
+}
@@ -52,6 +52,8 @@ def show_steps(events, workflow_id, run_id):
                 "stderrPath": ev.get("stderrPath"),
                 "structuredPath": ev.get("structuredPath"),
             }
+        elif ev.get("status") == "step_skipped":
+            steps[step] = {"status": "skipped", "exitCode": None, "durationSec": None, "reason": ev.get("message")}
         elif ev.get("status") == "step_started":
             if steps[step]["status"] is None:
                 steps[step]["status"] = "started"
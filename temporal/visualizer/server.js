@@ -38,11 +38,13 @@ function summarizeRuns(events) {
     const stepId = ev.stepId || ev.stepName || 'unknown';
     if (!run.steps[stepId]) run.steps[stepId] = { status: 'unknown' };
     if (ev.status === 'step_started') run.steps[stepId].status = 'running';
+    if (ev.status === 'step_skipped') run.steps[stepId] = { status: 'skipped', reason: ev.message };
     if (ev.status === 'step_finished') {
       run.steps[stepId] = {
         status: ev.exitCode === 0 ? 'success' : 'failed',
         exitCode: ev.exitCode,
         durationSec: ev.durationSec,
+        durationMs: ev.durationMs,
         stdoutPath: ev.stdoutPath,
         stderrPath: ev.stderrPath,
       };
@@ -52,7 +54,7 @@ function summarizeRuns(events) {
   const list = Array.from(runs.values()).map((run) => {
     const steps = Object.values(run.steps);
     const total = steps.length;
-    const done = steps.filter((s) => s.status === 'success' || s.status === 'failed').length;
+    const done = steps.filter((s) => ['success', 'failed', 'skipped'].includes(s.status)).length;
     const failed = steps.some((s) => s.status === 'failed');
     return {
       workflowId: run.workflowId,
@@ -75,10 +77,17 @@ function runDetails(events, runId) {
     const stepId = ev.stepId || ev.stepName || 'unknown';
     if (!steps[stepId]) steps[stepId] = { stepId };
     if (ev.status === 'step_started') steps[stepId].status = 'running';
+    if (ev.status === 'step_skipped') {
+      steps[stepId].status = 'skipped';
+      steps[stepId].reason = ev.message;
+    }
     if (ev.status === 'step_finished') {
       steps[stepId].status = ev.exitCode === 0 ? 'success' : 'failed';
       steps[stepId].exitCode = ev.exitCode;
       steps[stepId].durationSec = ev.durationSec;
+      steps[stepId].durationMs = ev.durationMs;
+      steps[stepId].startedAt = ev.startedAt;
+      steps[stepId].finishedAt = ev.finishedAt;
       steps[stepId].stdoutPath = ev.stdoutPath;
       steps[stepId].stderrPath = ev.stderrPath;
     }